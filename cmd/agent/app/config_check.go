@@ -18,12 +18,16 @@ import (
 	"github.com/spf13/cobra"
 )
 
-var withDebug bool
+var (
+	withDebug        bool
+	explainContainer string
+)
 
 func init() {
 	AgentCmd.AddCommand(configCheckCommand)
 
 	configCheckCommand.Flags().BoolVarP(&withDebug, "verbose", "v", false, "print additional debug info")
+	configCheckCommand.Flags().StringVar(&explainContainer, "explain", "", "explain why the checks of a container, matched by ID or name, are scheduled or not")
 }
 
 var configCheckCommand = &cobra.Command{
@@ -48,7 +52,11 @@ var configCheckCommand = &cobra.Command{
 		}
 		var b bytes.Buffer
 		color.Output = &b
-		err = flare.GetConfigCheck(color.Output, withDebug)
+		if explainContainer != "" {
+			err = flare.GetConfigCheckExplain(color.Output, explainContainer)
+		} else {
+			err = flare.GetConfigCheck(color.Output, withDebug)
+		}
 		if err != nil {
 			return fmt.Errorf("unable to get config: %v", err)
 		}
@@ -39,6 +39,7 @@ import (
 	orchcfg "github.com/DataDog/datadog-agent/pkg/orchestrator/config"
 	"github.com/DataDog/datadog-agent/pkg/pidfile"
 	"github.com/DataDog/datadog-agent/pkg/serializer"
+	"github.com/DataDog/datadog-agent/pkg/serializer/export"
 	"github.com/DataDog/datadog-agent/pkg/snmp/traps"
 	"github.com/DataDog/datadog-agent/pkg/status/health"
 	"github.com/DataDog/datadog-agent/pkg/telemetry"
@@ -60,9 +61,12 @@ import (
 	_ "github.com/DataDog/datadog-agent/pkg/collector/corechecks/containers/docker"
 	_ "github.com/DataDog/datadog-agent/pkg/collector/corechecks/ebpf"
 	_ "github.com/DataDog/datadog-agent/pkg/collector/corechecks/embed"
+	_ "github.com/DataDog/datadog-agent/pkg/collector/corechecks/httpjson"
 	_ "github.com/DataDog/datadog-agent/pkg/collector/corechecks/net"
 	_ "github.com/DataDog/datadog-agent/pkg/collector/corechecks/nvidia/jetson"
+	_ "github.com/DataDog/datadog-agent/pkg/collector/corechecks/openmetrics"
 	_ "github.com/DataDog/datadog-agent/pkg/collector/corechecks/snmp"
+	_ "github.com/DataDog/datadog-agent/pkg/collector/corechecks/sqlquery"
 	_ "github.com/DataDog/datadog-agent/pkg/collector/corechecks/system/cpu"
 	_ "github.com/DataDog/datadog-agent/pkg/collector/corechecks/system/disk"
 	_ "github.com/DataDog/datadog-agent/pkg/collector/corechecks/system/filehandles"
@@ -71,6 +75,9 @@ import (
 	_ "github.com/DataDog/datadog-agent/pkg/collector/corechecks/system/winproc"
 	_ "github.com/DataDog/datadog-agent/pkg/collector/corechecks/systemd"
 
+	// register the loader of the checks compiled to WebAssembly, when built with the wasmcheck tag
+	_ "github.com/DataDog/datadog-agent/pkg/collector/loaders/wasm"
+
 	// register metadata providers
 	_ "github.com/DataDog/datadog-agent/pkg/collector/metadata"
 	_ "github.com/DataDog/datadog-agent/pkg/metadata"
@@ -82,6 +89,7 @@ var (
 
 	orchestratorForwarder  *forwarder.DefaultForwarder
 	eventPlatformForwarder epforwarder.EventPlatformForwarder
+	metricsExporter        *export.Serializer
 
 	runCmd = &cobra.Command{
 		Use:   "run",
@@ -349,7 +357,7 @@ func StartAgent() error {
 	options := forwarder.NewOptions(keysPerDomain)
 	options.EnabledFeatures = forwarder.SetFeature(options.EnabledFeatures, forwarder.CoreFeatures)
 
-	common.Forwarder = forwarder.NewDefaultForwarder(options)
+	common.Forwarder = forwarder.NewForwarder(options)
 	log.Debugf("Starting forwarder")
 	common.Forwarder.Start() //nolint:errcheck
 	log.Debugf("Forwarder started")
@@ -365,7 +373,16 @@ func StartAgent() error {
 
 	// setup the aggregator
 	s := serializer.NewSerializer(common.Forwarder, orchestratorForwarder)
-	agg := aggregator.InitAggregator(s, eventPlatformForwarder, hostname)
+	var ms serializer.MetricSerializer = s
+	if exporter, exportErr := export.FromConfig(s); exportErr != nil {
+		log.Errorf("Metrics export is disabled: %v", exportErr)
+	} else if exporter != nil {
+		if exportErr = exporter.Start(); exportErr != nil {
+			log.Errorf("Could not start metrics export: %v", exportErr)
+		}
+		metricsExporter, ms = exporter, exporter
+	}
+	agg := aggregator.InitAggregator(ms, eventPlatformForwarder, hostname)
 	agg.AddAgentStartupTelemetry(version.AgentVersion)
 
 	// start dogstatsd
@@ -469,6 +486,9 @@ func StopAgent() {
 	clcrunnerapi.StopCLCRunnerServer()
 	jmx.StopJmxfetch()
 	aggregator.StopDefaultAggregator()
+	if metricsExporter != nil {
+		metricsExporter.Stop()
+	}
 	if common.Forwarder != nil {
 		common.Forwarder.Stop()
 	}
@@ -67,6 +67,7 @@ var (
 	profileMemoryVerbose   string
 	discoveryTimeout       uint
 	discoveryRetryInterval uint
+	dryRun                 bool
 )
 
 func setupCmd(cmd *cobra.Command) {
@@ -83,6 +84,7 @@ func setupCmd(cmd *cobra.Command) {
 	cmd.Flags().BoolVarP(&saveFlare, "flare", "", false, "save check results to the log dir so it may be reported in a flare")
 	cmd.Flags().UintVarP(&discoveryTimeout, "discovery-timeout", "", 5, "max retry duration until Autodiscovery resolves the check template (in seconds)")
 	cmd.Flags().UintVarP(&discoveryRetryInterval, "discovery-retry-interval", "", 1, "duration between retries until Autodiscovery resolves the check template (in seconds)")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "report the data the check would collect without running it (supported by some checks only)")
 	config.Datadog.BindPFlag("cmd.check.fullsketches", cmd.Flags().Lookup("full-sketches")) //nolint:errcheck
 
 	// Power user flags - mark as hidden
@@ -306,6 +308,23 @@ func Check(loggerName config.LoggerName, confFilePath *string, flagNoColor *bool
 				fmt.Println("Multiple check instances found, running each of them")
 			}
 
+			if dryRun {
+				for _, c := range cs {
+					dryRunner, ok := c.(check.DryRunner)
+					if !ok {
+						return fmt.Errorf("the check %s doesn't support dry runs", c)
+					}
+					fmt.Fprintln(color.Output, fmt.Sprintf("=== %s ===", color.BlueString("Dry run of %s", c.ID())))
+					var report bytes.Buffer
+					err := dryRunner.DryRun(&report)
+					fmt.Println(report.String())
+					if err != nil {
+						return err
+					}
+				}
+				return nil
+			}
+
 			var checkFileOutput bytes.Buffer
 			var instancesData []interface{}
 			for _, c := range cs {
@@ -157,7 +157,8 @@ func start(cmd *cobra.Command, args []string) error {
 		log.Warnf("Can't initiliaze the runtime settings: %v", err)
 	}
 
-	if !config.Datadog.IsSet("api_key") {
+	// no API key is needed when the payloads are not sent
+	if !config.Datadog.IsSet("api_key") && !config.Datadog.GetBool("forwarder_tee_dry_run") {
 		log.Critical("no API key configured, exiting")
 		return nil
 	}
@@ -217,7 +218,7 @@ func start(cmd *cobra.Command, args []string) error {
 	// * The metrics reported are reported as stale so that there is no "lie" about the accuracy of the reported metrics.
 	// Serving stale data is better than serving no data at all.
 	forwarderOpts.DisableAPIKeyChecking = true
-	f := forwarder.NewDefaultForwarder(forwarderOpts)
+	f := forwarder.NewForwarder(forwarderOpts)
 	f.Start() //nolint:errcheck
 	// setup the orchestrator forwarder
 	orchestratorForwarder = orchcfg.NewOrchestratorForwarder()
@@ -26,6 +26,7 @@ import (
 	"github.com/DataDog/datadog-agent/pkg/forwarder"
 	"github.com/DataDog/datadog-agent/pkg/metadata"
 	"github.com/DataDog/datadog-agent/pkg/serializer"
+	"github.com/DataDog/datadog-agent/pkg/serializer/export"
 	"github.com/DataDog/datadog-agent/pkg/status/health"
 	"github.com/DataDog/datadog-agent/pkg/tagger"
 	"github.com/DataDog/datadog-agent/pkg/tagger/collectors"
@@ -68,8 +69,9 @@ extensions for special Datadog features.`,
 	confPath   string
 	socketPath string
 
-	metaScheduler *metadata.Scheduler
-	statsd        *dogstatsd.Server
+	metaScheduler   *metadata.Scheduler
+	statsd          *dogstatsd.Server
+	metricsExporter *export.Serializer
 )
 
 const (
@@ -158,7 +160,8 @@ func runAgent(ctx context.Context) (err error) {
 		log.Warnf("Can't setup core dumps: %v, core dumps might not be available after a crash", err)
 	}
 
-	if !config.Datadog.IsSet("api_key") {
+	// no API key is needed when the payloads are not sent
+	if !config.Datadog.IsSet("api_key") && !config.Datadog.GetBool("forwarder_tee_dry_run") {
 		err = log.Critical("no API key configured, exiting")
 		return
 	}
@@ -179,7 +182,7 @@ func runAgent(ctx context.Context) (err error) {
 	if err != nil {
 		log.Error("Misconfiguration of agent endpoints: ", err)
 	}
-	f := forwarder.NewDefaultForwarder(forwarder.NewOptions(keysPerDomain))
+	f := forwarder.NewForwarder(forwarder.NewOptions(keysPerDomain))
 	f.Start() //nolint:errcheck
 	s := serializer.NewSerializer(f, nil)
 
@@ -209,7 +212,16 @@ func runAgent(ctx context.Context) (err error) {
 		tagger.Init()
 	}
 
-	aggregatorInstance := aggregator.InitAggregator(s, nil, hname)
+	var ms serializer.MetricSerializer = s
+	if exporter, exportErr := export.FromConfig(s); exportErr != nil {
+		log.Errorf("Metrics export is disabled: %v", exportErr)
+	} else if exporter != nil {
+		if exportErr = exporter.Start(); exportErr != nil {
+			log.Errorf("Could not start metrics export: %v", exportErr)
+		}
+		metricsExporter, ms = exporter, exporter
+	}
+	aggregatorInstance := aggregator.InitAggregator(ms, nil, hname)
 
 	statsd, err = dogstatsd.NewServer(aggregatorInstance, nil)
 	if err != nil {
@@ -266,6 +278,10 @@ func stopAgent(cancel context.CancelFunc) {
 		statsd.Stop()
 	}
 
+	if metricsExporter != nil {
+		metricsExporter.Stop()
+	}
+
 	log.Info("See ya!")
 	log.Flush()
 	return
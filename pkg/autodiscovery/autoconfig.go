@@ -216,10 +216,11 @@ func (ac *AutoConfig) HasRunOnce() bool {
 // GetAllConfigs queries all the providers and returns all the integration
 // configurations found, resolving the ones it can
 func (ac *AutoConfig) GetAllConfigs() []integration.Config {
+	ctx := context.TODO()
 	var resolvedConfigs []integration.Config
 
 	for _, pd := range ac.providers {
-		cfgs, err := pd.provider.Collect(context.TODO())
+		cfgs, err := pd.provider.Collect(ctx)
 		if err != nil {
 			log.Debugf("Unexpected error returned when collecting configurations from provider %v: %v", pd.provider, err)
 		}
@@ -255,7 +256,7 @@ func (ac *AutoConfig) GetAllConfigs() []integration.Config {
 		// resolve configs if needed
 		for _, config := range cfgs {
 			config.Provider = pd.provider.String()
-			rc := ac.processNewConfig(config)
+			rc := ac.processNewConfig(ctx, config)
 			resolvedConfigs = append(resolvedConfigs, rc...)
 		}
 	}
@@ -274,7 +275,7 @@ func (ac *AutoConfig) unschedule(configs []integration.Config) {
 }
 
 // processNewConfig store (in template cache) and resolves a given config into a slice of resolved configs
-func (ac *AutoConfig) processNewConfig(config integration.Config) []integration.Config {
+func (ac *AutoConfig) processNewConfig(ctx context.Context, config integration.Config) []integration.Config {
 	var configs []integration.Config
 
 	// add default metrics to collect to JMX checks
@@ -294,7 +295,7 @@ func (ac *AutoConfig) processNewConfig(config integration.Config) []integration.
 		}
 
 		// try to resolve the template
-		resolvedConfigs := ac.resolveTemplate(config)
+		resolvedConfigs := ac.resolveTemplate(ctx, config)
 		if len(resolvedConfigs) == 0 {
 			e := fmt.Sprintf("Can't resolve the template for %s at this moment.", config.Name)
 			errorStats.setResolveWarning(config.Name, e)
@@ -478,6 +479,7 @@ func (ac *AutoConfig) removeConfigTemplates(configs []integration.Config) {
 			tplDigest := c.Digest()
 			configs := ac.store.getConfigsForTemplate(tplDigest)
 			ac.store.removeConfigsForTemplate(tplDigest)
+			ac.store.removeResolveOutcomesForTemplate(tplDigest)
 			ac.processRemovedConfigs(configs)
 
 			// Remove template from the cache
@@ -500,7 +502,7 @@ func (ac *AutoConfig) removeConfigTemplates(configs []integration.Config) {
 // The function might return an empty list in the case the configuration has a
 // list of Autodiscovery identifiers for services that are unknown to the
 // resolver at this moment.
-func (ac *AutoConfig) resolveTemplate(tpl integration.Config) []integration.Config {
+func (ac *AutoConfig) resolveTemplate(ctx context.Context, tpl integration.Config) []integration.Config {
 	// use a map to dedupe configurations
 	resolvedSet := map[string]integration.Config{}
 
@@ -521,7 +523,7 @@ func (ac *AutoConfig) resolveTemplate(tpl integration.Config) []integration.Conf
 				log.Warnf("Service %s was removed before we could resolve its config", serviceID)
 				continue
 			}
-			resolvedConfig, err := ac.resolveTemplateForService(tpl, svc)
+			resolvedConfig, err := ac.resolveTemplateForService(ctx, tpl, svc)
 			if err != nil {
 				continue
 			}
@@ -540,18 +542,35 @@ func (ac *AutoConfig) resolveTemplate(tpl integration.Config) []integration.Conf
 
 // resolveTemplateForService calls the config resolver for the template against the service,
 // decrypts secrets and stores the resolved config and service mapping if successful
-func (ac *AutoConfig) resolveTemplateForService(tpl integration.Config, svc listeners.Service) (integration.Config, error) {
-	config, tagsHash, err := configresolver.Resolve(tpl, svc)
+func (ac *AutoConfig) resolveTemplateForService(ctx context.Context, tpl integration.Config, svc listeners.Service) (integration.Config, error) {
+	outcome := ResolveOutcome{
+		Template: tpl.Name,
+		Provider: tpl.Provider,
+		Source:   tpl.Source,
+		Status:   ResolveScheduled,
+	}
+	defer func() {
+		ac.store.setResolveOutcome(svc.GetEntity(), tpl.Digest(), outcome)
+	}()
+
+	config, tagsHash, err := configresolver.Resolve(ctx, tpl, svc)
 	if err != nil {
+		outcome.Status, outcome.Error = ResolveTemplateVariableError, err.Error()
 		newErr := fmt.Errorf("error resolving template %s for service %s: %v", tpl.Name, svc.GetEntity(), err)
 		errorStats.setResolveWarning(tpl.Name, newErr.Error())
 		return tpl, log.Warn(newErr)
 	}
 	resolvedConfig, err := decryptConfig(config)
 	if err != nil {
+		outcome.Status, outcome.Error = ResolveSecretError, err.Error()
 		newErr := fmt.Errorf("error decrypting secrets in config %s for service %s: %v", config.Name, svc.GetEntity(), err)
 		return config, log.Warn(newErr)
 	}
+	if resolvedConfig.IsCheckConfig() && resolvedConfig.HasFilter(containers.MetricsFilter) {
+		outcome.Status = ResolveExcluded
+		outcome.Error = "the metrics of the container are excluded by the container_exclude or container_exclude_metrics options"
+	}
+	outcome.digest = resolvedConfig.Digest()
 	ac.store.setLoadedConfig(resolvedConfig)
 	ac.store.addConfigForService(svc.GetEntity(), resolvedConfig)
 	ac.store.addConfigForTemplate(tpl.Digest(), resolvedConfig)
@@ -600,6 +619,7 @@ func (ac *AutoConfig) processNewService(ctx context.Context, svc listeners.Servi
 	// get all the templates matching service identifiers
 	var templates []integration.Config
 	ADIdentifiers, err := svc.GetADIdentifiers(ctx)
+	ac.store.setADIdentifiersForService(svc.GetEntity(), ADIdentifiers, err)
 	if err != nil {
 		log.Errorf("Failed to get AD identifiers for service %s, it will not be monitored - %s", svc.GetEntity(), err)
 		return
@@ -616,7 +636,7 @@ func (ac *AutoConfig) processNewService(ctx context.Context, svc listeners.Servi
 
 	for _, template := range templates {
 		// resolve the template
-		resolvedConfig, err := ac.resolveTemplateForService(template, svc)
+		resolvedConfig, err := ac.resolveTemplateForService(ctx, template, svc)
 		if err != nil {
 			continue
 		}
@@ -643,6 +663,7 @@ func (ac *AutoConfig) processDelService(svc listeners.Service) {
 	ac.store.removeServiceForEntity(svc.GetEntity())
 	configs := ac.store.getConfigsForService(svc.GetEntity())
 	ac.store.removeConfigsForService(svc.GetEntity())
+	ac.store.removeResolutionForService(svc.GetEntity())
 	ac.processRemovedConfigs(configs)
 	ac.store.removeTagsHashForService(svc.GetTaggerEntity())
 	// FIXME: unschedule remove services as well
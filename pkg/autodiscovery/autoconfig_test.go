@@ -311,7 +311,7 @@ func TestResolveTemplate(t *testing.T) {
 	}
 
 	// no services
-	res := ac.resolveTemplate(tpl)
+	res := ac.resolveTemplate(ctx, tpl)
 	assert.Len(t, res, 0)
 
 	service := dummyService{
@@ -321,7 +321,7 @@ func TestResolveTemplate(t *testing.T) {
 	ac.processNewService(ctx, &service)
 
 	// there are no template vars but it's ok
-	res = ac.resolveTemplate(tpl)
+	res = ac.resolveTemplate(ctx, tpl)
 	assert.Len(t, res, 1)
 }
 
@@ -334,7 +334,7 @@ func TestRemoveTemplate(t *testing.T) {
 	c := integration.Config{
 		Name: "memory",
 	}
-	ac.processNewConfig(c)
+	ac.processNewConfig(ctx, c)
 	assert.Len(t, ac.GetLoadedConfigs(), 1)
 
 	// Add new service
@@ -349,7 +349,7 @@ func TestRemoveTemplate(t *testing.T) {
 		Name:          "cpu",
 		ADIdentifiers: []string{"redis"},
 	}
-	configs := ac.processNewConfig(tpl)
+	configs := ac.processNewConfig(ctx, tpl)
 	assert.Len(t, configs, 1)
 	assert.Len(t, ac.GetLoadedConfigs(), 2)
 
@@ -380,7 +380,7 @@ func TestCheckOverride(t *testing.T) {
 		ADIdentifiers: []string{"redis"},
 		CheckNames:    []string{"redis"},
 	})
-	assert.Len(t, ac.resolveTemplate(tpl), 0)
+	assert.Len(t, ac.resolveTemplate(ctx, tpl), 0)
 
 	// check must be overridden (empty config)
 	ac.processNewService(ctx, &dummyService{
@@ -388,7 +388,7 @@ func TestCheckOverride(t *testing.T) {
 		ADIdentifiers: []string{"redis"},
 		CheckNames:    []string{""},
 	})
-	assert.Len(t, ac.resolveTemplate(tpl), 0)
+	assert.Len(t, ac.resolveTemplate(ctx, tpl), 0)
 
 	// check must be scheduled (different checks)
 	ac.processNewService(ctx, &dummyService{
@@ -396,7 +396,7 @@ func TestCheckOverride(t *testing.T) {
 		ADIdentifiers: []string{"redis"},
 		CheckNames:    []string{"tcp_check"},
 	})
-	assert.Len(t, ac.resolveTemplate(tpl), 1)
+	assert.Len(t, ac.resolveTemplate(ctx, tpl), 1)
 }
 
 type MockSecretDecrypt struct {
@@ -499,14 +499,14 @@ func TestSecretDecrypt(t *testing.T) {
 	defer func() { secretsDecrypt = originalSecretsDecrypt }()
 
 	// no services
-	res := ac.resolveTemplate(sharedTpl)
+	res := ac.resolveTemplate(ctx, sharedTpl)
 	assert.Len(t, res, 0)
 
 	service := sharedService
 	ac.processNewService(ctx, &service)
 
 	// there are no template vars but it's ok
-	res = ac.resolveTemplate(sharedTpl)
+	res = ac.resolveTemplate(ctx, sharedTpl)
 	assert.Len(t, res, 1)
 
 	assert.True(t, mockDecrypt.haveAllScenariosBeenCalled())
@@ -528,7 +528,7 @@ func TestSkipSecretDecrypt(t *testing.T) {
 	service := sharedService
 	ac.processNewService(ctx, &service)
 
-	res := ac.resolveTemplate(sharedTpl)
+	res := ac.resolveTemplate(ctx, sharedTpl)
 	assert.Len(t, res, 1)
 
 	assert.Equal(t, sharedTpl.Instances, res[0].Instances)
@@ -0,0 +1,24 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/DataDog/datadog-agent/pkg/config"
+)
+
+func TestCheckName(t *testing.T) {
+	mockConfig := config.Mock()
+
+	assert.Equal(t, "openmetrics", checkName())
+
+	mockConfig.Set("prometheus_scrape.use_core_check", true)
+	defer mockConfig.Set("prometheus_scrape.use_core_check", false)
+	assert.Equal(t, "openmetrics_core", checkName())
+}
@@ -22,6 +22,8 @@ type dummyService struct {
 	Hostname      string
 	CreationTime  integration.CreationTime
 	CheckNames    []string
+
+	MetricsExcluded bool
 }
 
 // GetEntity returns the service entity name
@@ -79,9 +81,9 @@ func (s *dummyService) GetCheckNames(context.Context) []string {
 	return s.CheckNames
 }
 
-// HasFilter returns false
+// HasFilter returns whether the metrics are excluded
 func (s *dummyService) HasFilter(filter containers.FilterType) bool {
-	return false
+	return filter == containers.MetricsFilter && s.MetricsExcluded
 }
 
 // GetExtraConfig isn't supported
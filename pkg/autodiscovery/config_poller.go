@@ -109,7 +109,7 @@ func (pd *configPoller) poll(ac *AutoConfig) {
 
 			for _, config := range newConfigs {
 				config.Provider = pd.provider.String()
-				resolvedConfigs := ac.processNewConfig(config)
+				resolvedConfigs := ac.processNewConfig(ctx, config)
 				ac.schedule(resolvedConfigs)
 			}
 		}
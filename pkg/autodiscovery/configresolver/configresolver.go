@@ -12,6 +12,7 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 
 	"github.com/DataDog/datadog-agent/pkg/autodiscovery/integration"
 	"github.com/DataDog/datadog-agent/pkg/autodiscovery/listeners"
@@ -29,6 +30,11 @@ var templateVariables = map[string]variableGetter{
 	"hostname": getHostname,
 	"extra":    getAdditionalTplVariables,
 	"kube":     getAdditionalTplVariables,
+
+	// metadata of the entity of the service
+	"label":      getLabel,
+	"annotation": getAnnotation,
+	"tag":        getTag,
 }
 
 // SubstituteTemplateVariables replaces %%VARIABLES%% using the variableGetters passed in
@@ -38,6 +44,7 @@ func SubstituteTemplateVariables(ctx context.Context, config *integration.Config
 		for _, v := range vars {
 			if f, found := getters[string(v.Name)]; found {
 				resolvedVar, err := f(ctx, v.Key, svc)
+				resolvedVar, err = applyFunctions(ctx, resolvedVar, err, v.Functions, svc)
 				if err != nil {
 					return err
 				}
@@ -51,13 +58,14 @@ func SubstituteTemplateVariables(ctx context.Context, config *integration.Config
 }
 
 // SubstituteTemplateEnvVars replaces %%ENV_VARIABLE%% from environment variables
-func SubstituteTemplateEnvVars(config *integration.Config) error {
+func SubstituteTemplateEnvVars(ctx context.Context, config *integration.Config) error {
 	var retErr error
 	for i := 0; i < len(config.Instances); i++ {
 		vars := config.GetTemplateVariablesForInstance(i)
 		for _, v := range vars {
 			if "env" == string(v.Name) {
 				resolvedVar, err := getEnvvar(v.Key)
+				resolvedVar, err = applyFunctions(ctx, resolvedVar, err, v.Functions, nil)
 				if err != nil {
 					log.Warnf("variable not replaced: %s", err)
 					if retErr == nil {
@@ -78,8 +86,7 @@ func SubstituteTemplateEnvVars(config *integration.Config) error {
 // valid connection info and relevant tags.
 // Resolve also returns the hash of the tags to the config.
 // The tags and hashes are computed once and in this function, then propagated to the main AD to avoid having inconsistent tags and hashes in the AD store.
-func Resolve(tpl integration.Config, svc listeners.Service) (integration.Config, string, error) {
-	ctx := context.TODO()
+func Resolve(ctx context.Context, tpl integration.Config, svc listeners.Service) (integration.Config, string, error) {
 	// Copy original template
 	resolvedConfig := integration.Config{
 		Name:            tpl.Name,
@@ -128,7 +135,7 @@ func Resolve(tpl integration.Config, svc listeners.Service) (integration.Config,
 		return resolvedConfig, "", err
 	}
 
-	if err := SubstituteTemplateEnvVars(&resolvedConfig); err != nil {
+	if err := SubstituteTemplateEnvVars(ctx, &resolvedConfig); err != nil {
 		// We add the service name to the error here, since SubstituteTemplateEnvVars doesn't know about that
 		return resolvedConfig, "", fmt.Errorf("%w, skipping service %s", err, svc.GetEntity())
 	}
@@ -261,6 +268,48 @@ func getAdditionalTplVariables(_ context.Context, tplVar []byte, svc listeners.S
 	return value, nil
 }
 
+// getLabel returns the value of a label of the container or pod of the service
+func getLabel(_ context.Context, tplVar []byte, svc listeners.Service) ([]byte, error) {
+	metadataSvc, ok := svc.(listeners.MetadataService)
+	if !ok {
+		return nil, fmt.Errorf("labels aren't supported for service %s", svc.GetEntity())
+	}
+	value, found := metadataSvc.GetLabels()[string(tplVar)]
+	if !found {
+		return nil, fmt.Errorf("label %s not found for service %s", tplVar, svc.GetEntity())
+	}
+	return []byte(value), nil
+}
+
+// getAnnotation returns the value of an annotation of the pod of the service
+func getAnnotation(_ context.Context, tplVar []byte, svc listeners.Service) ([]byte, error) {
+	metadataSvc, ok := svc.(listeners.MetadataService)
+	if !ok {
+		return nil, fmt.Errorf("annotations aren't supported for service %s", svc.GetEntity())
+	}
+	value, found := metadataSvc.GetAnnotations()[string(tplVar)]
+	if !found {
+		return nil, fmt.Errorf("annotation %s not found for service %s", tplVar, svc.GetEntity())
+	}
+	return []byte(value), nil
+}
+
+// getTag returns the value of the first tag of the service with the given name,
+// the tags coming from the tagger.
+func getTag(_ context.Context, tplVar []byte, svc listeners.Service) ([]byte, error) {
+	tags, _, err := svc.GetTags()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tags for service %s, skipping config - %s", svc.GetEntity(), err)
+	}
+	prefix := string(tplVar) + ":"
+	for _, tag := range tags {
+		if strings.HasPrefix(tag, prefix) {
+			return []byte(strings.TrimPrefix(tag, prefix)), nil
+		}
+	}
+	return nil, fmt.Errorf("tag %s not found for service %s", tplVar, svc.GetEntity())
+}
+
 // getEnvvar returns a system environment variable if found
 func getEnvvar(envVar []byte) ([]byte, error) {
 	if len(envVar) == 0 {
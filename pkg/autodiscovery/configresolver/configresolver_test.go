@@ -32,6 +32,8 @@ type dummyService struct {
 	CreationTime  integration.CreationTime
 	CheckNames    []string
 	ExtraConfig   map[string]string
+	Labels        map[string]string
+	Annotations   map[string]string
 }
 
 // GetEntity returns the service entity name
@@ -99,6 +101,21 @@ func (s *dummyService) GetExtraConfig(key []byte) ([]byte, error) {
 	return []byte(s.ExtraConfig[string(key)]), nil
 }
 
+// GetLabels returns dummy labels
+func (s *dummyService) GetLabels() map[string]string {
+	return s.Labels
+}
+
+// GetAnnotations returns dummy annotations
+func (s *dummyService) GetAnnotations() map[string]string {
+	return s.Annotations
+}
+
+// noMetadataService is a service without labels nor annotations
+type noMetadataService struct {
+	listeners.Service
+}
+
 func TestGetFallbackHost(t *testing.T) {
 	ip, err := getFallbackHost(map[string]string{"bridge": "172.17.0.1"})
 	assert.Equal(t, "172.17.0.1", ip)
@@ -619,6 +636,107 @@ func TestResolve(t *testing.T) {
 				Entity:        "a5901276aed1",
 			},
 		},
+		//// metadata template variables and functions
+		{
+			testName: "label, annotation and tag variables",
+			svc: &dummyService{
+				ID:            "a5901276aed1",
+				ADIdentifiers: []string{"redis"},
+				Labels:        map[string]string{"app.kubernetes.io/name": "Redis-Cache"},
+				Annotations:   map[string]string{"example.com/db": "3"},
+			},
+			tpl: integration.Config{
+				Name:          "redis",
+				ADIdentifiers: []string{"redis"},
+				Instances:     []integration.Data{integration.Data("app: %%label_app.kubernetes.io/name%%\ndb: %%annotation_example.com/db%%\nfoo: %%tag_foo%%")},
+			},
+			out: integration.Config{
+				Name:          "redis",
+				ADIdentifiers: []string{"redis"},
+				Instances:     []integration.Data{integration.Data("app: Redis-Cache\ndb: 3\nfoo: bar\ntags:\n- foo:bar\n")},
+				Entity:        "a5901276aed1",
+			},
+		},
+		{
+			testName: "missing label",
+			svc: &dummyService{
+				ID:            "a5901276aed1",
+				ADIdentifiers: []string{"redis"},
+			},
+			tpl: integration.Config{
+				Name:          "redis",
+				ADIdentifiers: []string{"redis"},
+				Instances:     []integration.Data{integration.Data("app: %%label_app%%")},
+			},
+			errorString: "label app not found for service a5901276aed1",
+		},
+		{
+			testName: "labels not supported",
+			svc: &noMetadataService{&dummyService{
+				ID:            "a5901276aed1",
+				ADIdentifiers: []string{"redis"},
+			}},
+			tpl: integration.Config{
+				Name:          "redis",
+				ADIdentifiers: []string{"redis"},
+				Instances:     []integration.Data{integration.Data("app: %%label_app%%")},
+			},
+			errorString: "labels aren't supported for service a5901276aed1",
+		},
+		{
+			testName: "template functions",
+			svc: &dummyService{
+				ID:            "a5901276aed1",
+				ADIdentifiers: []string{"redis"},
+				Ports:         newFakeContainerPorts(),
+				Labels:        map[string]string{"app": "Redis", "metrics-port": "bar", "admin-port": "8080"},
+			},
+			tpl: integration.Config{
+				Name:          "redis",
+				ADIdentifiers: []string{"redis"},
+				Instances: []integration.Data{integration.Data(
+					"app: %%label_app | lower%%\nenv: %%label_env | default(staging env) | upper%%\nport: %%label_metrics-port|port%%\nadmin_port: %%label_admin-port | port%%\nteam: %%tag_team|default()%%"),
+				},
+			},
+			out: integration.Config{
+				Name:          "redis",
+				ADIdentifiers: []string{"redis"},
+				Instances:     []integration.Data{integration.Data("admin_port: 8080\napp: redis\nenv: STAGING ENV\nport: 2\ntags:\n- foo:bar\nteam: null\n")},
+				Entity:        "a5901276aed1",
+			},
+		},
+		{
+			testName: "unknown template function",
+			svc: &dummyService{
+				ID:            "a5901276aed1",
+				ADIdentifiers: []string{"redis"},
+				Labels:        map[string]string{"app": "redis"},
+			},
+			tpl: integration.Config{
+				Name:          "redis",
+				ADIdentifiers: []string{"redis"},
+				Instances:     []integration.Data{integration.Data("app: %%label_app | title%%")},
+			},
+			errorString: "unknown template function \"title\"",
+		},
+		{
+			testName: "envvar with a default value",
+			svc: &dummyService{
+				ID:            "a5901276aed1",
+				ADIdentifiers: []string{"redis"},
+			},
+			tpl: integration.Config{
+				Name:          "redis",
+				ADIdentifiers: []string{"redis"},
+				Instances:     []integration.Data{integration.Data("test: %%env_test_envvar_not_set | default(fallback)%%")},
+			},
+			out: integration.Config{
+				Name:          "redis",
+				ADIdentifiers: []string{"redis"},
+				Instances:     []integration.Data{integration.Data("tags:\n- foo:bar\ntest: fallback\n")},
+				Entity:        "a5901276aed1",
+			},
+		},
 	}
 	validTemplates := 0
 
@@ -627,7 +745,7 @@ func TestResolve(t *testing.T) {
 			// Make sure we don't modify the template object
 			checksum := tc.tpl.Digest()
 
-			cfg, hash, err := Resolve(tc.tpl, tc.svc)
+			cfg, hash, err := Resolve(context.Background(), tc.tpl, tc.svc)
 			if tc.errorString != "" {
 				assert.EqualError(t, err, tc.errorString)
 			} else {
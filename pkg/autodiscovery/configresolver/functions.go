@@ -0,0 +1,79 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package configresolver
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/DataDog/datadog-agent/pkg/autodiscovery/listeners"
+	"github.com/DataDog/datadog-agent/pkg/util/tmplvar"
+)
+
+// templateFunction transforms the value of a template variable, or the error
+// resolving it.
+type templateFunction func(ctx context.Context, value []byte, err error, arg []byte, svc listeners.Service) ([]byte, error)
+
+var templateFunctions = map[string]templateFunction{
+	"default": defaultValue,
+	"lower":   lower,
+	"upper":   upper,
+	"port":    portByName,
+}
+
+// applyFunctions pipes the value of a template variable, or the error
+// resolving it, to the functions of the variable.
+func applyFunctions(ctx context.Context, value []byte, err error, functions []tmplvar.Function, svc listeners.Service) ([]byte, error) {
+	for _, function := range functions {
+		f, found := templateFunctions[string(function.Name)]
+		if !found {
+			return nil, fmt.Errorf("unknown template function %q", function.Name)
+		}
+		value, err = f(ctx, value, err, function.Arg, svc)
+	}
+	return value, err
+}
+
+// defaultValue returns its argument when the variable can't be resolved or is
+// empty.
+func defaultValue(_ context.Context, value []byte, err error, arg []byte, _ listeners.Service) ([]byte, error) {
+	if err != nil || len(value) == 0 {
+		return arg, nil
+	}
+	return value, nil
+}
+
+func lower(_ context.Context, value []byte, err error, _ []byte, _ listeners.Service) ([]byte, error) {
+	if err != nil {
+		return nil, err
+	}
+	return bytes.ToLower(value), nil
+}
+
+func upper(_ context.Context, value []byte, err error, _ []byte, _ listeners.Service) ([]byte, error) {
+	if err != nil {
+		return nil, err
+	}
+	return bytes.ToUpper(value), nil
+}
+
+// portByName returns the number of the port of the service named after the
+// value, e.g. `%%label_metrics-port | port%%`. Numeric values are already
+// port numbers.
+func portByName(ctx context.Context, value []byte, err error, _ []byte, svc listeners.Service) ([]byte, error) {
+	if err != nil {
+		return nil, err
+	}
+	if _, err := strconv.Atoi(string(value)); err == nil {
+		return value, nil
+	}
+	if svc == nil {
+		return nil, fmt.Errorf("the port function isn't supported without a service")
+	}
+	return getPort(ctx, value, svc)
+}
@@ -0,0 +1,135 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package autodiscovery
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/DataDog/datadog-agent/pkg/collector"
+	"github.com/DataDog/datadog-agent/pkg/tagger"
+	"github.com/DataDog/datadog-agent/pkg/tagger/collectors"
+)
+
+// ResolveStatus is the outcome of the resolution of a template for a service
+type ResolveStatus string
+
+const (
+	// ResolveScheduled means the template was resolved and its config scheduled
+	ResolveScheduled ResolveStatus = "scheduled"
+	// ResolveNoMatchingTemplate means no template has an AD identifier of the service
+	ResolveNoMatchingTemplate ResolveStatus = "no_matching_ad_identifier"
+	// ResolveTemplateVariableError means a template variable can't be resolved for the service
+	ResolveTemplateVariableError ResolveStatus = "template_variable_error"
+	// ResolveSecretError means the secrets of the resolved config can't be decrypted
+	ResolveSecretError ResolveStatus = "secret_error"
+	// ResolveLoaderError means no loader can load the check of the resolved config
+	ResolveLoaderError ResolveStatus = "loader_error"
+	// ResolveExcluded means the metrics of the service are excluded by the container exclusion config
+	ResolveExcluded ResolveStatus = "excluded"
+)
+
+// ResolveOutcome is the outcome of the resolution of a template for a service
+type ResolveOutcome struct {
+	Template string        `json:"template,omitempty"`
+	Provider string        `json:"provider,omitempty"`
+	Source   string        `json:"source,omitempty"`
+	Status   ResolveStatus `json:"status"`
+	Error    string        `json:"error,omitempty"`
+
+	// digest is the digest of the resolved config, which the loader errors are
+	// looked up by
+	digest string
+}
+
+// ServiceExplanation explains why the checks of a service are scheduled or not
+type ServiceExplanation struct {
+	Entity        string           `json:"entity"`
+	ADIdentifiers []string         `json:"ad_identifiers"`
+	Error         string           `json:"error,omitempty"`
+	Outcomes      []ResolveOutcome `json:"outcomes"`
+}
+
+var (
+	// the check loader errors and the tags of the services, variables for testing purposes
+	getLoaderErrors = collector.GetConfigLoaderErrors
+	getServiceTags  = func(entity string) ([]string, error) { return tagger.Tag(entity, collectors.HighCardinality) }
+)
+
+// containerNameTags are the tags of the container name a service can be
+// looked up by
+var containerNameTags = []string{"container_name", "kube_container_name", "display_container_name", "ecs_container_name"}
+
+// ExplainServices explains the resolution of the templates for the services
+// matching the container, all of them if it's empty. A container is matched by
+// entity, by ID prefix or by name.
+func (ac *AutoConfig) ExplainServices(container string) []ServiceExplanation {
+	loaderErrors := getLoaderErrors()
+
+	explanations := []ServiceExplanation{}
+	for _, svc := range ac.store.getServices() {
+		if container != "" && !matchService(svc.GetEntity(), svc.GetTaggerEntity(), container) {
+			continue
+		}
+		explanation, found := ac.store.getServiceExplanation(svc.GetEntity())
+		if !found {
+			continue
+		}
+		for i, outcome := range explanation.Outcomes {
+			if outcome.Status != ResolveScheduled || len(loaderErrors[outcome.digest]) == 0 {
+				continue
+			}
+			explanation.Outcomes[i].Status = ResolveLoaderError
+			explanation.Outcomes[i].Error = formatLoaderErrors(loaderErrors[outcome.digest])
+		}
+		if len(explanation.Outcomes) == 0 && explanation.Error == "" {
+			explanation.Outcomes = []ResolveOutcome{{
+				Status: ResolveNoMatchingTemplate,
+				Error:  fmt.Sprintf("no template has one of the AD identifiers of the service: %s", strings.Join(explanation.ADIdentifiers, ", ")),
+			}}
+		}
+		explanations = append(explanations, explanation)
+	}
+
+	sort.Slice(explanations, func(i, j int) bool {
+		return explanations[i].Entity < explanations[j].Entity
+	})
+	return explanations
+}
+
+// matchService returns whether a service is the container
+func matchService(entity, taggerEntity, container string) bool {
+	if entity == container {
+		return true
+	}
+	if i := strings.Index(entity, "://"); i >= 0 && strings.HasPrefix(entity[i+3:], container) {
+		return true
+	}
+
+	tags, err := getServiceTags(taggerEntity)
+	if err != nil {
+		return false
+	}
+	for _, tag := range tags {
+		for _, name := range containerNameTags {
+			if tag == name+":"+container {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// formatLoaderErrors formats the errors of the loaders of a check
+func formatLoaderErrors(errors map[string]string) string {
+	msgs := make([]string, 0, len(errors))
+	for loader, err := range errors {
+		msgs = append(msgs, fmt.Sprintf("%s: %s", loader, err))
+	}
+	sort.Strings(msgs)
+	return strings.Join(msgs, "; ")
+}
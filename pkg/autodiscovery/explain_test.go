@@ -0,0 +1,121 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package autodiscovery
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/autodiscovery/integration"
+	"github.com/DataDog/datadog-agent/pkg/autodiscovery/scheduler"
+)
+
+func mockExplainDependencies(t *testing.T, loaderErrors map[string]map[string]string, tags map[string][]string) {
+	originalLoaderErrors, originalServiceTags := getLoaderErrors, getServiceTags
+	getLoaderErrors = func() map[string]map[string]string { return loaderErrors }
+	getServiceTags = func(entity string) ([]string, error) { return tags[entity], nil }
+	t.Cleanup(func() { getLoaderErrors, getServiceTags = originalLoaderErrors, originalServiceTags })
+}
+
+func outcomeStatuses(explanation ServiceExplanation) map[string]ResolveStatus {
+	statuses := make(map[string]ResolveStatus)
+	for _, outcome := range explanation.Outcomes {
+		statuses[outcome.Template] = outcome.Status
+	}
+	return statuses
+}
+
+func TestExplainServices(t *testing.T) {
+	// the loader errors are keyed by the digest of the resolved configs, set below
+	loaderErrors := make(map[string]map[string]string)
+	mockExplainDependencies(t, loaderErrors, map[string][]string{
+		"docker://ee8b7d0a3ae1": {"container_name:front", "short_image:nginx"},
+	})
+	ctx := context.Background()
+	ac := NewAutoConfig(scheduler.NewMetaScheduler())
+
+	redis := &dummyService{ID: "docker://a5901276aed1", ADIdentifiers: []string{"redis"}}
+	otherRedis := &dummyService{ID: "docker://b7c3e1f2a4d5", ADIdentifiers: []string{"redis"}}
+	nginx := &dummyService{ID: "docker://ee8b7d0a3ae1", ADIdentifiers: []string{"nginx"}}
+	excluded := &dummyService{ID: "docker://4f2a7b8c9d0e", ADIdentifiers: []string{"redis"}, MetricsExcluded: true}
+	ac.processNewService(ctx, redis)
+	ac.processNewService(ctx, otherRedis)
+	ac.processNewService(ctx, nginx)
+	ac.processNewService(ctx, excluded)
+
+	// no template matches the services yet
+	explanations := ac.ExplainServices("")
+	require.Len(t, explanations, 4)
+	for _, explanation := range explanations {
+		require.Len(t, explanation.Outcomes, 1)
+		assert.Equal(t, ResolveNoMatchingTemplate, explanation.Outcomes[0].Status)
+	}
+
+	tpls := []integration.Config{
+		{Name: "cpu", ADIdentifiers: []string{"redis"}, Instances: []integration.Data{integration.Data("{}")}},
+		{Name: "redisdb", ADIdentifiers: []string{"redis"}, Instances: []integration.Data{integration.Data("host: localhost")}},
+		{Name: "tcp_check", ADIdentifiers: []string{"redis"}, Instances: []integration.Data{integration.Data("host: '%%host%%'")}, Source: "file:tcp_check.yaml"},
+	}
+	for _, tpl := range tpls {
+		ac.processNewConfig(ctx, tpl)
+	}
+	// only the config resolved for the first redis service fails to load
+	for _, config := range ac.store.getConfigsForService(redis.GetEntity()) {
+		if config.Name == "redisdb" {
+			loaderErrors[config.Digest()] = map[string]string{"python": "unable to import module 'redisdb'"}
+		}
+	}
+	require.Len(t, loaderErrors, 1)
+
+	explanations = ac.ExplainServices("a5901276")
+	require.Len(t, explanations, 1)
+	assert.Equal(t, "docker://a5901276aed1", explanations[0].Entity)
+	assert.Equal(t, []string{"redis"}, explanations[0].ADIdentifiers)
+	assert.Equal(t, map[string]ResolveStatus{
+		"cpu":       ResolveScheduled,
+		"redisdb":   ResolveLoaderError,
+		"tcp_check": ResolveTemplateVariableError,
+	}, outcomeStatuses(explanations[0]))
+	for _, outcome := range explanations[0].Outcomes {
+		switch outcome.Template {
+		case "redisdb":
+			assert.Equal(t, "python: unable to import module 'redisdb'", outcome.Error)
+		case "tcp_check":
+			assert.Equal(t, "file:tcp_check.yaml", outcome.Source)
+			assert.Contains(t, outcome.Error, "no network found for container")
+		}
+	}
+
+	// another instance of the same check isn't affected
+	explanations = ac.ExplainServices("docker://b7c3e1f2a4d5")
+	require.Len(t, explanations, 1)
+	assert.Equal(t, ResolveScheduled, outcomeStatuses(explanations[0])["redisdb"])
+
+	explanations = ac.ExplainServices("docker://4f2a7b8c9d0e")
+	require.Len(t, explanations, 1)
+	assert.Equal(t, ResolveExcluded, outcomeStatuses(explanations[0])["cpu"])
+
+	// the containers are matched by name too
+	explanations = ac.ExplainServices("front")
+	require.Len(t, explanations, 1)
+	assert.Equal(t, "docker://ee8b7d0a3ae1", explanations[0].Entity)
+	assert.Equal(t, ResolveNoMatchingTemplate, explanations[0].Outcomes[0].Status)
+
+	assert.Empty(t, ac.ExplainServices("back"))
+
+	// the outcomes of the removed templates and services are removed too
+	ac.removeConfigTemplates(tpls[:1])
+	explanations = ac.ExplainServices("docker://a5901276aed1")
+	require.Len(t, explanations, 1)
+	assert.NotContains(t, outcomeStatuses(explanations[0]), "cpu")
+
+	ac.processDelService(redis)
+	assert.Empty(t, ac.ExplainServices("docker://a5901276aed1"))
+	assert.Len(t, ac.ExplainServices(""), 3)
+}
@@ -62,11 +62,17 @@ type Config struct {
 // CommonInstanceConfig holds the reserved fields for the yaml instance data
 type CommonInstanceConfig struct {
 	MinCollectionInterval int      `yaml:"min_collection_interval"`
+	RunTimeout            int      `yaml:"run_timeout"`
+	Cron                  string   `yaml:"cron"`
+	Jitter                int      `yaml:"jitter"`
 	EmptyDefaultHostname  bool     `yaml:"empty_default_hostname"`
 	Tags                  []string `yaml:"tags"`
 	Service               string   `yaml:"service"`
 	Name                  string   `yaml:"name"`
 	Namespace             string   `yaml:"namespace"`
+
+	RunAfter         []string `yaml:"run_after"`
+	ConcurrencyGroup string   `yaml:"concurrency_group"`
 }
 
 // CommonGlobalConfig holds the reserved fields for the yaml init_config data
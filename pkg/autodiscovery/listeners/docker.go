@@ -57,10 +57,12 @@ type DockerService struct {
 	checkNames      []string
 	metricsExcluded bool
 	logsExcluded    bool
+	labels          map[string]string
 }
 
-// Make sure DockerService implements the Service interface
+// Make sure DockerService implements the Service and MetadataService interfaces
 var _ Service = &DockerService{}
+var _ MetadataService = &DockerService{}
 
 func init() {
 	Register("docker", NewDockerListener)
@@ -236,6 +238,7 @@ func (l *DockerListener) createService(ctx context.Context, cID string) {
 			DockerService: DockerService{
 				cID:        cID,
 				checkNames: checkNames,
+				labels:     cInspect.Config.Labels,
 			},
 		}
 	} else {
@@ -245,6 +248,7 @@ func (l *DockerListener) createService(ctx context.Context, cID string) {
 			checkNames:      checkNames,
 			metricsExcluded: l.filters.IsExcluded(containers.MetricsFilter, containerName, containerImage, ""),
 			logsExcluded:    l.filters.IsExcluded(containers.LogsFilter, containerName, containerImage, ""),
+			labels:          cInspect.Config.Labels,
 		}
 	}
 
@@ -612,3 +616,13 @@ func (s *DockerService) HasFilter(filter containers.FilterType) bool {
 func (s *DockerService) GetExtraConfig(key []byte) ([]byte, error) {
 	return []byte{}, ErrNotSupported
 }
+
+// GetLabels returns the labels of the container
+func (s *DockerService) GetLabels() map[string]string {
+	return s.labels
+}
+
+// GetAnnotations isn't supported, containers have no annotations
+func (s *DockerService) GetAnnotations() map[string]string {
+	return nil
+}
@@ -48,12 +48,14 @@ type ECSService struct {
 	taskVersion     string
 	creationTime    integration.CreationTime
 	checkNames      []string
+	labels          map[string]string
 	metricsExcluded bool
 	logsExcluded    bool
 }
 
-// Make sure ECSService implements the Service interface
+// Make sure ECSService implements the Service and MetadataService interfaces
 var _ Service = &ECSService{}
+var _ MetadataService = &ECSService{}
 
 func init() {
 	Register("ecs", NewECSListener)
@@ -196,6 +198,7 @@ func (l *ECSListener) createService(c v2.Container, firstRun bool) (ECSService,
 	// ADIdentifiers
 	image := c.Image
 	labels := c.Labels
+	svc.labels = labels
 	svc.ADIdentifiers = ComputeContainerServiceIDs(svc.GetEntity(), image, labels)
 	var err error
 	svc.checkNames, err = getCheckNamesFromLabels(labels)
@@ -280,6 +283,16 @@ func (s *ECSService) IsReady(context.Context) bool {
 	return true
 }
 
+// GetLabels returns the labels of the container
+func (s *ECSService) GetLabels() map[string]string {
+	return s.labels
+}
+
+// GetAnnotations isn't supported, containers have no annotations
+func (s *ECSService) GetAnnotations() map[string]string {
+	return nil
+}
+
 // GetCheckNames returns slice check names defined in docker labels
 func (s *ECSService) GetCheckNames(context.Context) []string {
 	return s.checkNames
@@ -11,6 +11,7 @@ package listeners
 import (
 	"context"
 	"fmt"
+	"reflect"
 	"sort"
 	"sync"
 
@@ -47,11 +48,14 @@ type KubeServiceService struct {
 	tags         []string
 	hosts        map[string]string
 	ports        []ContainerPort
+	labels       map[string]string
+	annotations  map[string]string
 	creationTime integration.CreationTime
 }
 
-// Make sure KubeServiceService implements the Service interface
+// Make sure KubeServiceService implements the Service and MetadataService interfaces
 var _ Service = &KubeServiceService{}
+var _ MetadataService = &KubeServiceService{}
 
 func init() {
 	Register("kube_services", NewKubeServiceListener)
@@ -156,6 +160,10 @@ func servicesDiffer(first, second *v1.Service) bool {
 	if standardTagsDigest(first.GetLabels()) != standardTagsDigest(second.GetLabels()) {
 		return true
 	}
+	// Labels and annotations - %%label_*%% and %%annotation_*%% template variables
+	if !reflect.DeepEqual(first.GetLabels(), second.GetLabels()) || !reflect.DeepEqual(first.GetAnnotations(), second.GetAnnotations()) {
+		return true
+	}
 	// Cluster IP
 	if first.Spec.ClusterIP != second.Spec.ClusterIP {
 		return true
@@ -216,6 +224,9 @@ func processService(ksvc *v1.Service, firstRun bool) *KubeServiceService {
 	// Hosts, only use internal ClusterIP for now
 	svc.hosts = map[string]string{"cluster": ksvc.Spec.ClusterIP}
 
+	svc.labels = ksvc.GetLabels()
+	svc.annotations = ksvc.GetAnnotations()
+
 	// Ports
 	var ports []ContainerPort
 	for _, port := range ksvc.Spec.Ports {
@@ -315,6 +326,16 @@ func (s *KubeServiceService) HasFilter(filter containers.FilterType) bool {
 	return false
 }
 
+// GetLabels returns the labels of the kubernetes service
+func (s *KubeServiceService) GetLabels() map[string]string {
+	return s.labels
+}
+
+// GetAnnotations returns the annotations of the kubernetes service
+func (s *KubeServiceService) GetAnnotations() map[string]string {
+	return s.annotations
+}
+
 // GetExtraConfig isn't supported
 func (s *KubeServiceService) GetExtraConfig(key []byte) ([]byte, error) {
 	return []byte{}, ErrNotSupported
@@ -78,6 +78,9 @@ func TestProcessService(t *testing.T) {
 	sort.Strings(tags)
 	assert.Equal(t, expectedTags, tags)
 
+	assert.Equal(t, ksvc.GetLabels(), svc.GetLabels())
+	assert.Equal(t, ksvc.GetAnnotations(), svc.GetAnnotations())
+
 	svc = processService(ksvc, false)
 	assert.Equal(t, integration.After, svc.GetCreationTime())
 }
@@ -311,6 +314,44 @@ func TestServicesDiffer(t *testing.T) {
 			},
 			result: true,
 		},
+		"Change label": {
+			first: &v1.Service{
+				ObjectMeta: metav1.ObjectMeta{
+					ResourceVersion: "123",
+					Labels: map[string]string{
+						"app": "web",
+					},
+				},
+			},
+			second: &v1.Service{
+				ObjectMeta: metav1.ObjectMeta{
+					ResourceVersion: "124",
+					Labels: map[string]string{
+						"app": "api",
+					},
+				},
+			},
+			result: true,
+		},
+		"Change annotation": {
+			first: &v1.Service{
+				ObjectMeta: metav1.ObjectMeta{
+					ResourceVersion: "123",
+					Annotations: map[string]string{
+						"prometheus.io/port": "8080",
+					},
+				},
+			},
+			second: &v1.Service{
+				ObjectMeta: metav1.ObjectMeta{
+					ResourceVersion: "124",
+					Annotations: map[string]string{
+						"prometheus.io/port": "9090",
+					},
+				},
+			},
+			result: true,
+		},
 		"Same standard tags": {
 			first: &v1.Service{
 				ObjectMeta: metav1.ObjectMeta{
@@ -58,10 +58,13 @@ type KubeContainerService struct {
 	metricsExcluded bool
 	logsExcluded    bool
 	extraConfig     map[string]string
+	labels          map[string]string
+	annotations     map[string]string
 }
 
-// Make sure KubeContainerService implements the Service interface
+// Make sure KubeContainerService implements the Service and MetadataService interfaces
 var _ Service = &KubeContainerService{}
+var _ MetadataService = &KubeContainerService{}
 
 // KubePodService registers pod as a Service, implements and store results from the Service interface for the Kubelet listener
 // needed to run checks on pod's endpoints
@@ -71,10 +74,13 @@ type KubePodService struct {
 	hosts         map[string]string
 	ports         []ContainerPort
 	creationTime  integration.CreationTime
+	labels        map[string]string
+	annotations   map[string]string
 }
 
-// Make sure KubePodService implements the Service interface
+// Make sure KubePodService implements the Service and MetadataService interfaces
 var _ Service = &KubePodService{}
+var _ MetadataService = &KubePodService{}
 
 func init() {
 	Register("kubelet", NewKubeletListener)
@@ -197,6 +203,8 @@ func (l *KubeletListener) createPodService(pod *kubelet.Pod, firstRun bool) {
 		hosts:         map[string]string{"pod": podIP},
 		ports:         ports,
 		creationTime:  crTime,
+		labels:        pod.Metadata.Labels,
+		annotations:   pod.Metadata.Annotations,
 	}
 
 	l.m.Lock()
@@ -257,6 +265,8 @@ func (l *KubeletListener) createService(container kubelet.ContainerStatus, pod *
 			"namespace": pod.Metadata.Namespace,
 			"pod_uid":   pod.Metadata.UID,
 		},
+		labels:      pod.Metadata.Labels,
+		annotations: pod.Metadata.Annotations,
 	}
 	podName := pod.Metadata.Name
 
@@ -490,6 +500,16 @@ func (s *KubeContainerService) GetExtraConfig(key []byte) ([]byte, error) {
 	return []byte(result), nil
 }
 
+// GetLabels returns the labels of the pod of the container
+func (s *KubeContainerService) GetLabels() map[string]string {
+	return s.labels
+}
+
+// GetAnnotations returns the annotations of the pod of the container
+func (s *KubeContainerService) GetAnnotations() map[string]string {
+	return s.annotations
+}
+
 // GetCheckNames returns names of checks defined in pod annotations
 func (s *KubeContainerService) GetCheckNames(context.Context) []string {
 	return s.checkNames
@@ -577,3 +597,13 @@ func (s *KubePodService) HasFilter(filter containers.FilterType) bool {
 func (s *KubePodService) GetExtraConfig(key []byte) ([]byte, error) {
 	return []byte{}, ErrNotSupported
 }
+
+// GetLabels returns the labels of the pod
+func (s *KubePodService) GetLabels() map[string]string {
+	return s.labels
+}
+
+// GetAnnotations returns the annotations of the pod
+func (s *KubePodService) GetAnnotations() map[string]string {
+	return s.annotations
+}
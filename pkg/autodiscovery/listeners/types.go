@@ -39,6 +39,14 @@ type Service interface {
 	GetExtraConfig([]byte) ([]byte, error)               // Extra configuration values
 }
 
+// MetadataService is implemented by the services exposing the labels and
+// annotations of their entity, resolving the %%label_*%% and %%annotation_*%%
+// template variables.
+type MetadataService interface {
+	GetLabels() map[string]string      // container or pod labels
+	GetAnnotations() map[string]string // pod annotations
+}
+
 // ServiceListener monitors running services and triggers check (un)scheduling
 //
 // It holds a cache of running services, listens to new/killed services and
@@ -32,6 +32,9 @@ type configFormat struct {
 	Instances               []integration.RawMap
 	DockerImages            []string `yaml:"docker_images"`             // Only imported for deprecation warning
 	IgnoreAutodiscoveryTags bool     `yaml:"ignore_autodiscovery_tags"` // Use to ignore tags coming from autodiscovery
+
+	// Selectors are only used by the git config provider
+	Selectors interface{} `yaml:"selectors"`
 }
 
 type configPkg struct {
@@ -327,7 +330,7 @@ func GetIntegrationConfigFromFile(name, fpath string) (integration.Config, error
 	}
 
 	// Interpolate env vars. Returns an error a variable wasn't subsituted, ignore it.
-	_ = configresolver.SubstituteTemplateEnvVars(&config)
+	_ = configresolver.SubstituteTemplateEnvVars(context.Background(), &config)
 
 	config.Source = "file:" + fpath
 
@@ -0,0 +1,329 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package providers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/DataDog/datadog-agent/pkg/autodiscovery/integration"
+	"github.com/DataDog/datadog-agent/pkg/autodiscovery/providers/names"
+	"github.com/DataDog/datadog-agent/pkg/config"
+	"github.com/DataDog/datadog-agent/pkg/util"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// gitCommandTimeout is the timeout of the git commands fetching and checking
+// out the repository
+const gitCommandTimeout = time.Minute
+
+var (
+	// the hostname and tags the selectors of the configs match, variables for testing purposes
+	gitHostname   = util.GetHostname
+	gitConfigTags = func() []string { return config.GetConfiguredTags(false) }
+)
+
+// gitSelectors restricts the hosts a config applies to. A config without
+// selectors applies to all the hosts.
+type gitSelectors struct {
+	// Hostnames are glob patterns, one of them must match the hostname
+	Hostnames []string `yaml:"hostnames"`
+	// Tags must all be host tags of the Agent
+	Tags []string `yaml:"tags"`
+}
+
+// GitConfigProvider collects the check configs of a git repository, laid out
+// like the `conf.d` directory.
+type GitConfigProvider struct {
+	sync.Mutex
+	url       string
+	branch    string
+	dir       string
+	localPath string
+	// the commit of the collected configs
+	commit string
+	// the commit fetched by IsUpToDate, which Collect checks out
+	fetched      string
+	configErrors map[string]ErrorMsgSet
+}
+
+// NewGitConfigProvider returns a new GitConfigProvider fetching the repository
+// at the template URL. The configs are read from the template directory of the
+// repository, its root by default.
+func NewGitConfigProvider(cfg config.ConfigurationProviders) (ConfigProvider, error) {
+	if cfg.TemplateURL == "" {
+		return nil, errors.New("the git config provider requires the URL of the repository in template_url")
+	}
+	if _, err := exec.LookPath("git"); err != nil {
+		return nil, fmt.Errorf("the git config provider requires git: %v", err)
+	}
+
+	branch := cfg.Branch
+	if branch == "" {
+		branch = "HEAD"
+	}
+	// each repository gets its own local copy
+	hash := sha256.Sum256([]byte(cfg.TemplateURL + "\x00" + branch))
+	localPath := filepath.Join(config.Datadog.GetString("run_path"), "git-config-provider", hex.EncodeToString(hash[:8]))
+
+	return &GitConfigProvider{
+		url:          cfg.TemplateURL,
+		branch:       branch,
+		dir:          cfg.TemplateDir,
+		localPath:    localPath,
+		configErrors: make(map[string]ErrorMsgSet),
+	}, nil
+}
+
+// Collect checks out the latest commit of the repository, the one fetched by
+// IsUpToDate if it was called before, and returns the configs matching the host
+func (p *GitConfigProvider) Collect(ctx context.Context) ([]integration.Config, error) {
+	p.Lock()
+	defer p.Unlock()
+
+	commit := p.fetched
+	p.fetched = ""
+	if commit == "" {
+		var err error
+		if commit, err = p.fetch(ctx); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := p.git(ctx, "checkout", "--quiet", "--force", "--detach", commit); err != nil {
+		return nil, err
+	}
+
+	configs, configErrors := p.readConfigs(ctx)
+	log.Infof("%v: collected %d configs from commit %s of %s", p, len(configs), commit, p.url)
+	p.commit = commit
+	p.configErrors = configErrors
+	return configs, nil
+}
+
+// IsUpToDate fetches the repository, and checks whether its latest commit is
+// the one of the collected configs
+func (p *GitConfigProvider) IsUpToDate(ctx context.Context) (bool, error) {
+	p.Lock()
+	defer p.Unlock()
+
+	commit, err := p.fetch(ctx)
+	if err != nil {
+		// keep the configs of the last commit until the repository can be fetched again
+		return true, err
+	}
+	if commit == p.commit {
+		return true, nil
+	}
+	p.fetched = commit
+	return false, nil
+}
+
+// String returns a string representation of the GitConfigProvider
+func (p *GitConfigProvider) String() string {
+	return names.Git
+}
+
+// GetConfigErrors returns the errors of the config files of the repository
+func (p *GitConfigProvider) GetConfigErrors() map[string]ErrorMsgSet {
+	p.Lock()
+	defer p.Unlock()
+	return p.configErrors
+}
+
+// fetch fetches the branch of the repository in the local copy, and returns
+// its latest commit
+func (p *GitConfigProvider) fetch(ctx context.Context) (string, error) {
+	if _, err := os.Stat(filepath.Join(p.localPath, ".git")); os.IsNotExist(err) {
+		if err := os.MkdirAll(p.localPath, 0700); err != nil {
+			return "", err
+		}
+		if _, err := p.git(ctx, "init", "--quiet"); err != nil {
+			return "", err
+		}
+	}
+	// the URL and the branch are never taken for options
+	if _, err := p.git(ctx, "fetch", "--quiet", "--depth", "1", "--", p.url, p.branch); err != nil {
+		return "", err
+	}
+	commit, err := p.git(ctx, "rev-parse", "FETCH_HEAD")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(commit), nil
+}
+
+// git runs a git command in the local copy of the repository
+func (p *GitConfigProvider) git(ctx context.Context, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, gitCommandTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", p.localPath}, args...)...)
+	// never wait for credentials on a terminal
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("git %s failed: %v: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
+
+// readConfigs reads the configs of the checkout matching the host, with the
+// `conf.d` layout: `<check>.yaml` files and `<check>.d/*.yaml` directories.
+func (p *GitConfigProvider) readConfigs(ctx context.Context) ([]integration.Config, map[string]ErrorMsgSet) {
+	configs := []integration.Config{}
+	configErrors := make(map[string]ErrorMsgSet)
+
+	hostname, err := gitHostname(ctx)
+	if err != nil {
+		log.Warnf("%v: unable to get the hostname, the configs with hostname selectors are skipped: %v", p, err)
+	}
+	tags := gitConfigTags()
+
+	localPath, root, err := p.resolveDir()
+	if err != nil {
+		configErrors[p.dir] = ErrorMsgSet{err.Error(): struct{}{}}
+		return configs, configErrors
+	}
+	entries, err := ioutil.ReadDir(root)
+	if err != nil {
+		configErrors[p.dir] = ErrorMsgSet{err.Error(): struct{}{}}
+		return configs, configErrors
+	}
+
+	// the symlinks committed to the repository aren't followed, as they could point
+	// to any file of the host
+	collect := func(name, path string) {
+		relPath, _ := filepath.Rel(localPath, path)
+		conf, matched, err := readGitConfig(name, path, hostname, tags)
+		if err != nil {
+			log.Warnf("%v: %s is not a valid config file: %s", p, relPath, err)
+			configErrors[relPath] = ErrorMsgSet{err.Error(): struct{}{}}
+			return
+		}
+		if !matched {
+			log.Debugf("%v: skipping %s, its selectors don't match the host", p, relPath)
+			return
+		}
+		conf.Source = "git:" + relPath
+		configs = append(configs, conf)
+	}
+
+	for _, entry := range entries {
+		if entry.Mode().IsRegular() {
+			if name, ok := gitConfigName(entry.Name()); ok {
+				collect(name, filepath.Join(root, entry.Name()))
+			}
+			continue
+		}
+		if !entry.IsDir() || filepath.Ext(entry.Name()) != ".d" {
+			continue
+		}
+		dirPath := filepath.Join(root, entry.Name())
+		subEntries, err := ioutil.ReadDir(dirPath)
+		if err != nil {
+			log.Warnf("%v: skipping config directory %s: %s", p, entry.Name(), err)
+			continue
+		}
+		for _, subEntry := range subEntries {
+			if _, ok := gitConfigName(subEntry.Name()); ok && subEntry.Mode().IsRegular() {
+				collect(strings.TrimSuffix(entry.Name(), ".d"), filepath.Join(dirPath, subEntry.Name()))
+			}
+		}
+	}
+	return configs, configErrors
+}
+
+// resolveDir returns the path of the local clone and of the configs directory, with their
+// symlinks resolved. It returns an error if the configs directory resolves outside of
+// the clone.
+func (p *GitConfigProvider) resolveDir() (string, string, error) {
+	localPath, err := filepath.EvalSymlinks(p.localPath)
+	if err != nil {
+		return "", "", err
+	}
+	root, err := filepath.EvalSymlinks(filepath.Join(localPath, p.dir))
+	if err != nil {
+		return "", "", err
+	}
+	if rel, err := filepath.Rel(localPath, root); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", "", fmt.Errorf("the config directory %s is outside of the repository", p.dir)
+	}
+	return localPath, root, nil
+}
+
+// gitConfigName returns the check name of a config file, false if the file
+// isn't a config file. The `.default` and `metrics.yaml` files of the
+// `conf.d` directory aren't supported.
+func gitConfigName(fileName string) (string, bool) {
+	ext := filepath.Ext(fileName)
+	if ext != ".yaml" && ext != ".yml" {
+		return "", false
+	}
+	name := strings.TrimSuffix(fileName, ext)
+	return name, name != "metrics"
+}
+
+// readGitConfig reads a config file, and returns whether its selectors match
+// the host
+func readGitConfig(name, path, hostname string, tags []string) (integration.Config, bool, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return integration.Config{}, false, err
+	}
+	var selectors struct {
+		Selectors gitSelectors `yaml:"selectors"`
+	}
+	if err := yaml.Unmarshal(data, &selectors); err != nil {
+		return integration.Config{}, false, err
+	}
+	if !selectors.Selectors.match(hostname, tags) {
+		return integration.Config{}, false, nil
+	}
+
+	conf, err := GetIntegrationConfigFromFile(name, path)
+	return conf, true, err
+}
+
+func (s gitSelectors) match(hostname string, tags []string) bool {
+	if len(s.Hostnames) > 0 {
+		matched := false
+		for _, pattern := range s.Hostnames {
+			if ok, _ := filepath.Match(pattern, hostname); ok && hostname != "" {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	for _, selector := range s.Tags {
+		if !containsString(tags, selector) {
+			return false
+		}
+	}
+	return true
+}
+
+func init() {
+	RegisterProvider("git", NewGitConfigProvider)
+}
@@ -0,0 +1,274 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package providers
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/autodiscovery/integration"
+	"github.com/DataDog/datadog-agent/pkg/config"
+)
+
+// testGitRepository is a repository of check configs
+type testGitRepository struct {
+	t    *testing.T
+	path string
+}
+
+func newTestGitRepository(t *testing.T) *testGitRepository {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git isn't installed")
+	}
+	path, err := ioutil.TempDir("", "git-provider-repo")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(path) })
+
+	r := &testGitRepository{t: t, path: path}
+	r.git("init", "--quiet")
+	return r
+}
+
+func (r *testGitRepository) git(args ...string) {
+	cmd := exec.Command("git", append([]string{"-C", r.path, "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+	output, err := cmd.CombinedOutput()
+	require.NoError(r.t, err, string(output))
+}
+
+func (r *testGitRepository) commit(files map[string]string) {
+	for name, content := range files {
+		path := filepath.Join(r.path, name)
+		if content == "" {
+			require.NoError(r.t, os.Remove(path))
+			continue
+		}
+		require.NoError(r.t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(r.t, ioutil.WriteFile(path, []byte(content), 0644))
+	}
+	r.git("add", "--all")
+	r.git("commit", "--quiet", "--allow-empty", "--message", "update")
+}
+
+func (r *testGitRepository) symlink(name, target string) {
+	path := filepath.Join(r.path, name)
+	require.NoError(r.t, os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(r.t, os.Symlink(target, path))
+	r.git("add", "--all")
+	r.git("commit", "--quiet", "--message", "link "+name)
+}
+
+func newTestGitProvider(t *testing.T, cfg config.ConfigurationProviders) ConfigProvider {
+	runPath, err := ioutil.TempDir("", "git-provider-run")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(runPath) })
+
+	mockConfig := config.Mock()
+	mockConfig.Set("run_path", runPath)
+
+	hostname, tags := gitHostname, gitConfigTags
+	gitHostname = func(context.Context) (string, error) { return "web-1.example.com", nil }
+	gitConfigTags = func() []string { return []string{"env:prod", "team:front"} }
+	t.Cleanup(func() { gitHostname, gitConfigTags = hostname, tags })
+
+	provider, err := NewGitConfigProvider(cfg)
+	require.NoError(t, err)
+	return provider
+}
+
+func configSources(configs []integration.Config) []string {
+	sources := make([]string, 0, len(configs))
+	for _, c := range configs {
+		sources = append(sources, c.Name+"@"+c.Source)
+	}
+	sort.Strings(sources)
+	return sources
+}
+
+func TestGitConfigProvider(t *testing.T) {
+	repo := newTestGitRepository(t)
+	repo.commit(map[string]string{
+		"conf.d/redisdb.d/conf.yaml":   "ad_identifiers: [redis]\ninit_config:\ninstances:\n  - host: '%%host%%'\n",
+		"conf.d/http_check.yaml":       "init_config:\ninstances:\n  - url: http://localhost\n",
+		"conf.d/nginx.d/prod.yaml":     "selectors:\n  tags: [env:prod]\n  hostnames: ['web-*']\ninit_config:\ninstances:\n  - url: http://localhost/status\n",
+		"conf.d/nginx.d/staging.yaml":  "selectors:\n  tags: [env:staging]\ninit_config:\ninstances:\n  - url: http://localhost/status\n",
+		"conf.d/invalid.yaml":          "init_config:\n",
+		"conf.d/README.md":             "check configs",
+		"conf.d/notaconfig/conf.yaml":  "init_config:\ninstances:\n  - {}\n",
+		"outside/conf.d/outside.yaml":  "init_config:\ninstances:\n  - {}\n",
+		"conf.d/postgres.d/other.yaml": "init_config:\ninstances:\n  - {}\n",
+	})
+
+	provider := newTestGitProvider(t, config.ConfigurationProviders{
+		Name:        "git",
+		TemplateURL: "file://" + repo.path,
+		TemplateDir: "conf.d",
+	})
+	ctx := context.Background()
+
+	configs, err := provider.Collect(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"http_check@git:conf.d/http_check.yaml",
+		"nginx@git:conf.d/nginx.d/prod.yaml",
+		"postgres@git:conf.d/postgres.d/other.yaml",
+		"redisdb@git:conf.d/redisdb.d/conf.yaml",
+	}, configSources(configs))
+	for _, c := range configs {
+		if c.Name == "redisdb" {
+			assert.Equal(t, []string{"redis"}, c.ADIdentifiers)
+			assert.Equal(t, []integration.Data{integration.Data("host: '%%host%%'\n")}, c.Instances)
+		}
+	}
+	assert.Contains(t, provider.GetConfigErrors(), "conf.d/invalid.yaml")
+
+	upToDate, err := provider.IsUpToDate(ctx)
+	require.NoError(t, err)
+	assert.True(t, upToDate)
+
+	// the changes are detected at the next commit
+	repo.commit(map[string]string{
+		"conf.d/http_check.yaml":      "",
+		"conf.d/nginx.d/staging.yaml": "selectors:\n  tags: [env:prod]\ninit_config:\ninstances:\n  - url: http://localhost/status\n",
+	})
+	upToDate, err = provider.IsUpToDate(ctx)
+	require.NoError(t, err)
+	assert.False(t, upToDate)
+
+	// the commit fetched by IsUpToDate is the one collected
+	repo.commit(map[string]string{"conf.d/postgres.d/other.yaml": ""})
+	configs, err = provider.Collect(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"nginx@git:conf.d/nginx.d/prod.yaml",
+		"nginx@git:conf.d/nginx.d/staging.yaml",
+		"postgres@git:conf.d/postgres.d/other.yaml",
+		"redisdb@git:conf.d/redisdb.d/conf.yaml",
+	}, configSources(configs))
+
+	upToDate, err = provider.IsUpToDate(ctx)
+	require.NoError(t, err)
+	assert.False(t, upToDate)
+	configs, err = provider.Collect(ctx)
+	require.NoError(t, err)
+	assert.NotContains(t, configSources(configs), "postgres@git:conf.d/postgres.d/other.yaml")
+
+	upToDate, err = provider.IsUpToDate(ctx)
+	require.NoError(t, err)
+	assert.True(t, upToDate)
+}
+
+func TestGitConfigProviderSymlinks(t *testing.T) {
+	hostDir, err := ioutil.TempDir("", "git-provider-host")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(hostDir) })
+	hostFile := filepath.Join(hostDir, "secret.yaml")
+	require.NoError(t, ioutil.WriteFile(hostFile, []byte("init_config:\ninstances:\n  - {}\n"), 0644))
+
+	repo := newTestGitRepository(t)
+	repo.commit(map[string]string{
+		"conf.d/http_check.yaml": "init_config:\ninstances:\n  - url: http://localhost\n",
+		"other/conf.yaml":        "init_config:\ninstances:\n  - {}\n",
+	})
+	repo.symlink("conf.d/secret.yaml", hostFile)
+	repo.symlink("conf.d/host.d", hostDir)
+	repo.symlink("conf.d/other.d", "../other")
+	repo.symlink("host", hostDir)
+
+	ctx := context.Background()
+	provider := newTestGitProvider(t, config.ConfigurationProviders{
+		Name:        "git",
+		TemplateURL: "file://" + repo.path,
+		TemplateDir: "conf.d",
+	})
+	configs, err := provider.Collect(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"http_check@git:conf.d/http_check.yaml"}, configSources(configs))
+	assert.Empty(t, provider.GetConfigErrors())
+
+	// the config directory can't resolve outside of the repository
+	for _, dir := range []string{"host", "../"} {
+		provider = newTestGitProvider(t, config.ConfigurationProviders{
+			Name:        "git",
+			TemplateURL: "file://" + repo.path,
+			TemplateDir: dir,
+		})
+		configs, err = provider.Collect(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, configs, dir)
+		assert.Contains(t, provider.GetConfigErrors(), dir)
+	}
+}
+
+func TestGitConfigProviderBranch(t *testing.T) {
+	repo := newTestGitRepository(t)
+	repo.commit(map[string]string{"http_check.yaml": "init_config:\ninstances:\n  - url: http://localhost\n"})
+	repo.git("checkout", "--quiet", "-b", "canary")
+	repo.commit(map[string]string{"redisdb.yaml": "init_config:\ninstances:\n  - host: localhost\n"})
+
+	provider := newTestGitProvider(t, config.ConfigurationProviders{
+		Name:        "git",
+		TemplateURL: repo.path,
+		Branch:      "canary",
+	})
+	configs, err := provider.Collect(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"http_check@git:http_check.yaml", "redisdb@git:redisdb.yaml"}, configSources(configs))
+}
+
+func TestGitConfigProviderFetchError(t *testing.T) {
+	provider := newTestGitProvider(t, config.ConfigurationProviders{
+		Name:        "git",
+		TemplateURL: "file:///nonexistent/repository",
+	})
+	_, err := provider.Collect(context.Background())
+	assert.Error(t, err)
+
+	// the configs are kept while the repository can't be fetched
+	upToDate, err := provider.IsUpToDate(context.Background())
+	assert.Error(t, err)
+	assert.True(t, upToDate)
+}
+
+func TestGitConfigProviderOptionURL(t *testing.T) {
+	marker := filepath.Join(t.TempDir(), "marker")
+	provider := newTestGitProvider(t, config.ConfigurationProviders{
+		Name:        "git",
+		TemplateURL: "--upload-pack=touch " + marker,
+	})
+	_, err := provider.Collect(context.Background())
+	assert.Error(t, err)
+	assert.NoFileExists(t, marker)
+}
+
+func TestGitSelectors(t *testing.T) {
+	tags := []string{"env:prod", "team:front"}
+	for name, tc := range map[string]struct {
+		selectors gitSelectors
+		hostname  string
+		expected  bool
+	}{
+		"no selectors":       {gitSelectors{}, "web-1", true},
+		"hostname match":     {gitSelectors{Hostnames: []string{"db-*", "web-*"}}, "web-1", true},
+		"hostname mismatch":  {gitSelectors{Hostnames: []string{"db-*"}}, "web-1", false},
+		"unknown hostname":   {gitSelectors{Hostnames: []string{"*"}}, "", false},
+		"tags match":         {gitSelectors{Tags: []string{"env:prod", "team:front"}}, "web-1", true},
+		"tags mismatch":      {gitSelectors{Tags: []string{"env:prod", "team:back"}}, "web-1", false},
+		"hostname and tags":  {gitSelectors{Hostnames: []string{"web-*"}, Tags: []string{"env:prod"}}, "web-1", true},
+		"hostname, bad tags": {gitSelectors{Hostnames: []string{"web-*"}, Tags: []string{"env:dev"}}, "web-1", false},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.selectors.match(tc.hostname, tags))
+		})
+	}
+}
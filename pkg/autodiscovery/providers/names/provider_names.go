@@ -15,6 +15,7 @@ const (
 	EndpointsChecks    = "endpoints-checks"
 	Etcd               = "etcd"
 	File               = "file"
+	Git                = "git"
 	Kubernetes         = "kubernetes"
 	KubeServices       = "kubernetes-services"
 	KubeEndpoints      = "kubernetes-endpoints"
@@ -6,6 +6,7 @@
 package autodiscovery
 
 import (
+	"sort"
 	"sync"
 
 	"github.com/DataDog/datadog-agent/pkg/autodiscovery/integration"
@@ -23,6 +24,16 @@ type store struct {
 	entityToService   map[string]listeners.Service
 	templateCache     *TemplateCache
 	m                 sync.RWMutex
+
+	// the resolution of the templates for each service
+	serviceToResolution map[string]*serviceResolution
+}
+
+// serviceResolution records the resolution of the templates for a service
+type serviceResolution struct {
+	adIdentifiers []string
+	err           string
+	outcomes      map[string]ResolveOutcome // template digest -> outcome
 }
 
 // newStore creates a store
@@ -36,6 +47,8 @@ func newStore() *store {
 		adIDToServices:    make(map[string]map[string]bool),
 		entityToService:   make(map[string]listeners.Service),
 		templateCache:     NewTemplateCache(),
+
+		serviceToResolution: make(map[string]*serviceResolution),
 	}
 
 	return &s
@@ -187,3 +200,76 @@ func (s *store) getServiceEntitiesForADID(adID string) (map[string]bool, bool) {
 	services, found := s.adIDToServices[adID]
 	return services, found
 }
+
+// setADIdentifiersForService records the AD identifiers of a service, or the
+// error getting them
+func (s *store) setADIdentifiersForService(serviceEntity string, adIdentifiers []string, err error) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	resolution := s.getOrCreateResolution(serviceEntity)
+	resolution.adIdentifiers = adIdentifiers
+	resolution.err = ""
+	if err != nil {
+		resolution.err = err.Error()
+	}
+}
+
+// setResolveOutcome records the outcome of the resolution of a template for a service
+func (s *store) setResolveOutcome(serviceEntity string, templateDigest string, outcome ResolveOutcome) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	s.getOrCreateResolution(serviceEntity).outcomes[templateDigest] = outcome
+}
+
+func (s *store) getOrCreateResolution(serviceEntity string) *serviceResolution {
+	resolution, found := s.serviceToResolution[serviceEntity]
+	if !found {
+		resolution = &serviceResolution{outcomes: make(map[string]ResolveOutcome)}
+		s.serviceToResolution[serviceEntity] = resolution
+	}
+	return resolution
+}
+
+// removeResolutionForService removes the resolution of the templates for a service
+func (s *store) removeResolutionForService(serviceEntity string) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	delete(s.serviceToResolution, serviceEntity)
+}
+
+// removeResolveOutcomesForTemplate removes the outcomes of the resolution of a
+// template for all the services
+func (s *store) removeResolveOutcomesForTemplate(templateDigest string) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	for _, resolution := range s.serviceToResolution {
+		delete(resolution.outcomes, templateDigest)
+	}
+}
+
+// getServiceExplanation returns the resolution of the templates for a service
+func (s *store) getServiceExplanation(serviceEntity string) (ServiceExplanation, bool) {
+	s.m.RLock()
+	defer s.m.RUnlock()
+	resolution, found := s.serviceToResolution[serviceEntity]
+	if !found {
+		return ServiceExplanation{}, false
+	}
+
+	explanation := ServiceExplanation{
+		Entity:        serviceEntity,
+		ADIdentifiers: append([]string{}, resolution.adIdentifiers...),
+		Error:         resolution.err,
+		Outcomes:      make([]ResolveOutcome, 0, len(resolution.outcomes)),
+	}
+	for _, outcome := range resolution.outcomes {
+		explanation.Outcomes = append(explanation.Outcomes, outcome)
+	}
+	sort.Slice(explanation.Outcomes, func(i, j int) bool {
+		if explanation.Outcomes[i].Template != explanation.Outcomes[j].Template {
+			return explanation.Outcomes[i].Template < explanation.Outcomes[j].Template
+		}
+		return explanation.Outcomes[i].Source < explanation.Outcomes[j].Source
+	})
+	return explanation, true
+}
@@ -6,6 +6,8 @@
 package check
 
 import (
+	"context"
+	"io"
 	"time"
 
 	"github.com/DataDog/datadog-agent/pkg/autodiscovery/integration"
@@ -27,6 +29,10 @@ type Check interface {
 	Configure(config, initConfig integration.Data, source string) error
 	// Interval returns the interval time for the check
 	Interval() time.Duration
+	// RunTimeout returns the maximum duration of a run of the check, 0 to use the default one
+	RunTimeout() time.Duration
+	// Schedule returns the schedule settings complementing the interval of the check
+	Schedule() Schedule
 	// ID provides a unique identifier for every check instance
 	ID() ID
 	// GetWarnings returns the last warning registered by the check
@@ -40,3 +46,30 @@ type Check interface {
 	// IsTelemetryEnabled returns if telemetry is enabled for this check
 	IsTelemetryEnabled() bool
 }
+
+// DryRunner is implemented by the checks able to report the data a run would
+// collect without submitting it, for the `check --dry-run` command
+type DryRunner interface {
+	// DryRun runs the check without submitting any data, writing a report to w
+	DryRun(w io.Writer) error
+}
+
+// ContextRunner is implemented by the checks whose runs can be interrupted without
+// tearing the check down: the runner runs them with RunContext instead of Run, and
+// cancels the context when the run times out
+type ContextRunner interface {
+	// RunContext runs the check, returning early once ctx is done
+	RunContext(ctx context.Context) error
+}
+
+// Schedule holds the optional schedule settings of a check instance
+type Schedule struct {
+	// Cron is a cron expression of the times the check runs at, instead of once every interval
+	Cron string
+	// Jitter is the maximum random delay added to each run
+	Jitter time.Duration
+	// RunAfter are the checks, by name or ID, whose runs must complete before the check runs
+	RunAfter []string
+	// ConcurrencyGroup is the group of checks of which only one runs at a time
+	ConcurrencyGroup string
+}
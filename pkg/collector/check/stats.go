@@ -40,6 +40,8 @@ var (
 		[]string{"check_name"}, "Service checks count")
 	tlmExecutionTime = telemetry.NewGauge("checks", "execution_time",
 		[]string{"check_name"}, "Check execution time")
+	tlmTimeouts = telemetry.NewCounter("checks", "timeouts",
+		[]string{"check_name"}, "Check runs timed out")
 )
 
 // SenderStats contains statistics showing the count of various types of telemetry sent by a check sender
@@ -93,6 +95,9 @@ type Stats struct {
 	LastError                string    // error that occurred in the last run, if any
 	LastWarnings             []string  // warnings that occurred in the last run, if any
 	UpdateTimestamp          int64     // latest update to this instance, unix timestamp in seconds
+	TotalTimeouts            uint64    // runs which didn't complete before the run timeout
+	Stuck                    bool      // whether the current run didn't complete before the run timeout
+	StuckSince               int64     // start date of the stuck run, unix timestamp in seconds
 	m                        sync.Mutex
 	telemetry                bool // do we want telemetry on this Check
 }
@@ -165,6 +170,8 @@ func (cs *Stats) Add(t time.Duration, err error, warnings []error, metricStats S
 		}
 	}
 	cs.UpdateTimestamp = time.Now().Unix()
+	cs.Stuck = false
+	cs.StuckSince = 0
 
 	if metricStats.MetricSamples > 0 {
 		cs.MetricSamples = metricStats.MetricSamples
@@ -197,6 +204,20 @@ func (cs *Stats) Add(t time.Duration, err error, warnings []error, metricStats S
 	}
 }
 
+// SetStuck tracks a run started at start which didn't complete before the run timeout.
+// The check is stuck until the run completes and its execution time is added.
+func (cs *Stats) SetStuck(start time.Time) {
+	cs.m.Lock()
+	defer cs.m.Unlock()
+
+	cs.TotalTimeouts++
+	if cs.telemetry {
+		tlmTimeouts.Inc(cs.CheckName)
+	}
+	cs.Stuck = true
+	cs.StuckSince = start.Unix()
+}
+
 type aggStats struct {
 	EventPlatformEvents       map[string]interface{}
 	EventPlatformEventsErrors map[string]interface{}
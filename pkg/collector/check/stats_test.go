@@ -6,9 +6,11 @@
 package check
 
 import (
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
@@ -138,3 +140,20 @@ func TestTranslateEventPlatformEventTypes(t *testing.T) {
 	assert.True(t, assert.ObjectsAreEqual(expected, result))
 	assert.EqualValues(t, expected, result)
 }
+
+func TestStatsStuck(t *testing.T) {
+	stats := NewStats(newMockCheck())
+	start := time.Now()
+
+	stats.SetStuck(start)
+	assert.True(t, stats.Stuck)
+	assert.Equal(t, start.Unix(), stats.StuckSince)
+	assert.Equal(t, uint64(1), stats.TotalTimeouts)
+
+	// the check isn't stuck anymore once its run completes
+	stats.Add(time.Minute, errors.New("the check run timed out after 30s"), []error{}, SenderStats{})
+	assert.False(t, stats.Stuck)
+	assert.Zero(t, stats.StuckSince)
+	assert.Equal(t, uint64(1), stats.TotalTimeouts)
+	assert.Equal(t, uint64(1), stats.TotalErrors)
+}
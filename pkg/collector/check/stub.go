@@ -35,6 +35,12 @@ func (c *StubCheck) Configure(integration.Data, integration.Data, string) error
 // Interval returns a duration of one second
 func (c *StubCheck) Interval() time.Duration { return 1 * time.Second }
 
+// RunTimeout returns 0
+func (c *StubCheck) RunTimeout() time.Duration { return 0 }
+
+// Schedule returns an empty schedule
+func (c *StubCheck) Schedule() Schedule { return Schedule{} }
+
 // Run is a noop
 func (c *StubCheck) Run() error { return nil }
 
@@ -88,6 +88,18 @@ func (c *Collector) Stop() {
 	c.state = stopped
 }
 
+// DependencyWarnings returns why the `run_after` option of the scheduled checks
+// is ignored, entirely or in part
+func (c *Collector) DependencyWarnings() map[check.ID]string {
+	c.m.RLock()
+	defer c.m.RUnlock()
+
+	if c.scheduler == nil {
+		return nil
+	}
+	return c.scheduler.DependencyWarnings()
+}
+
 // RunCheck sends a Check in the execution queue
 func (c *Collector) RunCheck(ch check.Check) (check.ID, error) {
 	c.m.Lock()
@@ -105,7 +117,7 @@ func (c *Collector) RunCheck(ch check.Check) (check.ID, error) {
 
 	err := c.scheduler.Enter(ch)
 	if err != nil {
-		return emptyID, fmt.Errorf("unable to schedule the check: %s", err)
+		return emptyID, fmt.Errorf("unable to schedule the check: %w", err)
 	}
 
 	// Track the total number of checks running in order to have an appropriate number of workers
@@ -42,6 +42,8 @@ type CheckBase struct {
 	checkID        check.ID
 	latestWarnings []error
 	checkInterval  time.Duration
+	runTimeout     time.Duration
+	schedule       check.Schedule
 	source         string
 	telemetry      bool
 }
@@ -118,6 +120,20 @@ func (c *CheckBase) CommonConfigure(instance integration.Data, source string) er
 		c.checkInterval = time.Duration(commonOptions.MinCollectionInterval) * time.Second
 	}
 
+	// See if a run timeout was specified
+	if commonOptions.RunTimeout > 0 {
+		c.runTimeout = time.Duration(commonOptions.RunTimeout) * time.Second
+	}
+
+	// See if the runs are scheduled with a cron expression or a jitter, and
+	// ordered or serialized with other checks
+	c.schedule = check.Schedule{
+		Cron:             commonOptions.Cron,
+		Jitter:           time.Duration(commonOptions.Jitter) * time.Second,
+		RunAfter:         commonOptions.RunAfter,
+		ConcurrencyGroup: commonOptions.ConcurrencyGroup,
+	}
+
 	// Disable default hostname if specified
 	if commonOptions.EmptyDefaultHostname {
 		s, err := aggregator.GetSender(c.checkID)
@@ -192,6 +208,17 @@ func (c *CheckBase) Interval() time.Duration {
 	return c.checkInterval
 }
 
+// RunTimeout returns the maximum duration of a run of the check, 0 if the
+// instance doesn't set one.
+func (c *CheckBase) RunTimeout() time.Duration {
+	return c.runTimeout
+}
+
+// Schedule returns the schedule settings of the instance.
+func (c *CheckBase) Schedule() check.Schedule {
+	return c.schedule
+}
+
 // String returns the name of the check, the same for every instance
 func (c *CheckBase) String() string {
 	return c.checkName
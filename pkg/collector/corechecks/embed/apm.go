@@ -182,6 +182,16 @@ func (c *APMCheck) Interval() time.Duration {
 	return 0
 }
 
+// RunTimeout returns 0 since long running checks aren't timed out
+func (c *APMCheck) RunTimeout() time.Duration {
+	return 0
+}
+
+// Schedule returns an empty schedule since long running checks are scheduled once
+func (c *APMCheck) Schedule() check.Schedule {
+	return check.Schedule{}
+}
+
 // ID returns the name of the check since there should be only one instance running
 func (c *APMCheck) ID() check.ID {
 	return "APM_AGENT"
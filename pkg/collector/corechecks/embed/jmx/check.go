@@ -83,6 +83,14 @@ func (c *JMXCheck) Interval() time.Duration {
 	return 0
 }
 
+func (c *JMXCheck) RunTimeout() time.Duration {
+	return 0
+}
+
+func (c *JMXCheck) Schedule() check.Schedule {
+	return check.Schedule{}
+}
+
 func (c *JMXCheck) ID() check.ID {
 	return c.id
 }
@@ -178,6 +178,16 @@ func (c *ProcessAgentCheck) Interval() time.Duration {
 	return 0
 }
 
+// RunTimeout returns 0 since long running checks aren't timed out
+func (c *ProcessAgentCheck) RunTimeout() time.Duration {
+	return 0
+}
+
+// Schedule returns an empty schedule since long running checks are scheduled once
+func (c *ProcessAgentCheck) Schedule() check.Schedule {
+	return check.Schedule{}
+}
+
 // ID returns the name of the check since there should be only one instance running
 func (c *ProcessAgentCheck) ID() check.ID {
 	return "PROCESS_AGENT"
@@ -0,0 +1,455 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package httpjson
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	yaml "gopkg.in/yaml.v2"
+
+	"github.com/DataDog/datadog-agent/pkg/aggregator"
+	"github.com/DataDog/datadog-agent/pkg/autodiscovery/integration"
+	"github.com/DataDog/datadog-agent/pkg/collector/check"
+	core "github.com/DataDog/datadog-agent/pkg/collector/corechecks"
+	"github.com/DataDog/datadog-agent/pkg/metrics"
+	httputils "github.com/DataDog/datadog-agent/pkg/util/http"
+	"github.com/DataDog/datadog-agent/pkg/util/jsonquery"
+)
+
+const (
+	checkName = "http_json"
+
+	defaultTimeout = 10
+	// maxResponseSize limits the size of the JSON documents read from the APIs
+	maxResponseSize = 10 * 1024 * 1024
+
+	canConnectServiceCheck = "http_json.can_connect"
+)
+
+var metricTypes = map[string]bool{
+	"gauge":           true,
+	"count":           true,
+	"monotonic_count": true,
+	"rate":            true,
+}
+
+type metricConfig struct {
+	Name      string            `yaml:"name"`
+	Type      string            `yaml:"type"`
+	Items     string            `yaml:"items"`
+	Value     string            `yaml:"value"`
+	Tags      map[string]string `yaml:"tags"`
+	Timestamp string            `yaml:"timestamp"`
+}
+
+type instanceConfig struct {
+	URL           string            `yaml:"url"`
+	Method        string            `yaml:"method"`
+	Body          string            `yaml:"body"`
+	Headers       map[string]string `yaml:"headers"`
+	Username      string            `yaml:"username"`
+	Password      string            `yaml:"password"`
+	Timeout       int               `yaml:"timeout"`
+	TLSVerify     bool              `yaml:"tls_verify"`
+	TLSCACert     string            `yaml:"tls_ca_cert"`
+	TLSCert       string            `yaml:"tls_cert"`
+	TLSPrivateKey string            `yaml:"tls_private_key"`
+	SkipProxy     bool              `yaml:"skip_proxy"`
+	MaxAge        int               `yaml:"max_age"`
+	Metrics       []metricConfig    `yaml:"metrics"`
+}
+
+// HTTPJSONCheck polls a JSON HTTP API, and submits the metrics extracted from
+// its responses with jq queries or JSONPath expressions.
+type HTTPJSONCheck struct {
+	core.CheckBase
+	config *instanceConfig
+	client *http.Client
+	// the timestamps of the samples submitted at the previous run, by context
+	lastTimestamps map[string]time.Time
+}
+
+func (c *instanceConfig) parse(data []byte) error {
+	c.Method = http.MethodGet
+	c.Timeout = defaultTimeout
+	c.TLSVerify = true
+
+	if err := yaml.Unmarshal(data, c); err != nil {
+		return err
+	}
+	if c.URL == "" {
+		return errors.New("url is required")
+	}
+	if len(c.Metrics) == 0 {
+		return errors.New("at least one metric must be set in metrics")
+	}
+
+	for i := range c.Metrics {
+		metric := &c.Metrics[i]
+		if metric.Name == "" || metric.Value == "" {
+			return fmt.Errorf("the name and value of metric #%d are required", i+1)
+		}
+		if metric.Type == "" {
+			metric.Type = "gauge"
+		}
+		if !metricTypes[metric.Type] {
+			return fmt.Errorf("invalid type %q for metric %s", metric.Type, metric.Name)
+		}
+
+		var err error
+		for _, query := range []*string{&metric.Items, &metric.Value, &metric.Timestamp} {
+			if *query, err = compileQuery(*query); err != nil {
+				return fmt.Errorf("invalid query for metric %s: %v", metric.Name, err)
+			}
+		}
+		for tag, query := range metric.Tags {
+			if metric.Tags[tag], err = compileQuery(query); err != nil {
+				return fmt.Errorf("invalid query for tag %s of metric %s: %v", tag, metric.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// compileQuery checks that a query compiles, the JSONPath expressions being
+// converted to jq queries first.
+func compileQuery(query string) (string, error) {
+	if query == "" {
+		return "", nil
+	}
+	if strings.HasPrefix(query, "$") {
+		converted, err := jsonquery.FromJSONPath(query)
+		if err != nil {
+			return "", err
+		}
+		query = converted
+	}
+	_, err := jsonquery.Parse(query)
+	return query, err
+}
+
+// Configure parses the configuration of the check and creates its HTTP client
+func (c *HTTPJSONCheck) Configure(data integration.Data, initConfig integration.Data, source string) error {
+	c.BuildID(data, initConfig)
+	if err := c.CommonConfigure(data, source); err != nil {
+		return err
+	}
+
+	cfg := &instanceConfig{}
+	if err := cfg.parse(data); err != nil {
+		return err
+	}
+	c.config = cfg
+
+	transport, err := httputils.CreateHTTPTransportWithOptions(httputils.TransportOptions{
+		NoProxy:     cfg.SkipProxy,
+		TLSCAFile:   cfg.TLSCACert,
+		TLSCertFile: cfg.TLSCert,
+		TLSKeyFile:  cfg.TLSPrivateKey,
+	})
+	if err != nil {
+		return err
+	}
+	if !cfg.TLSVerify {
+		transport.TLSClientConfig.InsecureSkipVerify = true
+	}
+	c.client = &http.Client{
+		Transport: transport,
+		Timeout:   time.Duration(cfg.Timeout) * time.Second,
+	}
+	c.lastTimestamps = map[string]time.Time{}
+	return nil
+}
+
+// Run polls the API and submits the extracted metrics
+func (c *HTTPJSONCheck) Run() error {
+	sender, err := aggregator.GetSender(c.ID())
+	if err != nil {
+		return err
+	}
+	defer sender.Commit()
+
+	serviceCheckTags := []string{"url:" + c.config.URL}
+	document, err := c.fetch()
+	if err != nil {
+		sender.ServiceCheck(canConnectServiceCheck, metrics.ServiceCheckCritical, "", serviceCheckTags, err.Error())
+		return err
+	}
+	sender.ServiceCheck(canConnectServiceCheck, metrics.ServiceCheckOK, "", serviceCheckTags, "")
+
+	samples, errs := c.extract(document)
+	for _, err := range errs {
+		c.Warn(err) //nolint:errcheck
+	}
+
+	now := time.Now()
+	timestamps := make(map[string]time.Time, len(samples))
+	for _, s := range samples {
+		if !s.timestamp.IsZero() {
+			context := s.context()
+			timestamps[context] = s.timestamp
+			if c.isStale(s, now) || s.timestamp.Equal(c.lastTimestamps[context]) {
+				continue
+			}
+		}
+		s.submit(sender)
+	}
+	c.lastTimestamps = timestamps
+	return nil
+}
+
+// DryRun polls the API and reports the extracted metrics without submitting them
+func (c *HTTPJSONCheck) DryRun(w io.Writer) error {
+	fmt.Fprintf(w, "%s %s\n", c.config.Method, c.config.URL)
+	document, err := c.fetch()
+	if err != nil {
+		fmt.Fprintf(w, "  error: %v\n", err)
+		return err
+	}
+
+	samples, errs := c.extract(document)
+	now := time.Now()
+	for i := range c.config.Metrics {
+		metric := &c.config.Metrics[i]
+		fmt.Fprintf(w, "\n%s (%s)\n", metric.Name, metric.Type)
+		found := false
+		for _, s := range samples {
+			if s.metric != metric {
+				continue
+			}
+			found = true
+			fmt.Fprintf(w, "  %v [%s]", s.value, strings.Join(s.tags, ", "))
+			if !s.timestamp.IsZero() {
+				fmt.Fprintf(w, " at %s", s.timestamp.Format(time.RFC3339))
+				if c.isStale(s, now) {
+					fmt.Fprint(w, " (skipped, older than max_age)")
+				}
+			}
+			fmt.Fprintln(w)
+		}
+		if !found {
+			fmt.Fprintln(w, "  no value")
+		}
+	}
+
+	if len(errs) > 0 {
+		fmt.Fprintln(w, "\nErrors:")
+		for _, err := range errs {
+			fmt.Fprintf(w, "  %v\n", err)
+		}
+	}
+	return nil
+}
+
+// fetch requests the API and decodes its JSON response.
+func (c *HTTPJSONCheck) fetch() (interface{}, error) {
+	var body io.Reader
+	if c.config.Body != "" {
+		body = strings.NewReader(c.config.Body)
+	}
+	req, err := http.NewRequest(c.config.Method, c.config.URL, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	for name, value := range c.config.Headers {
+		req.Header.Set(name, value)
+	}
+	if c.config.Username != "" {
+		req.SetBasicAuth(c.config.Username, c.config.Password)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("unexpected status code %d from %s", resp.StatusCode, c.config.URL)
+	}
+
+	var document interface{}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(&document); err != nil {
+		return nil, fmt.Errorf("invalid JSON response from %s: %v", c.config.URL, err)
+	}
+	// drain the response so the connection can be reused
+	io.Copy(ioutil.Discard, resp.Body) //nolint:errcheck
+	return document, nil
+}
+
+func (c *HTTPJSONCheck) isStale(s sample, now time.Time) bool {
+	return c.config.MaxAge > 0 && now.Sub(s.timestamp) > time.Duration(c.config.MaxAge)*time.Second
+}
+
+// sample is a value extracted from a response
+type sample struct {
+	metric    *metricConfig
+	value     float64
+	tags      []string
+	timestamp time.Time // zero when the metric has no timestamp query
+}
+
+// context identifies the series of a sample
+func (s sample) context() string {
+	return s.metric.Name + "|" + strings.Join(s.tags, ",")
+}
+
+func (s sample) submit(sender aggregator.Sender) {
+	switch s.metric.Type {
+	case "gauge":
+		sender.Gauge(s.metric.Name, s.value, "", s.tags)
+	case "count":
+		sender.Count(s.metric.Name, s.value, "", s.tags)
+	case "monotonic_count":
+		sender.MonotonicCount(s.metric.Name, s.value, "", s.tags)
+	case "rate":
+		sender.Rate(s.metric.Name, s.value, "", s.tags)
+	}
+}
+
+// extract runs the queries of the metrics on a response. The errors of a
+// metric don't prevent the extraction of the other ones.
+func (c *HTTPJSONCheck) extract(document interface{}) ([]sample, []error) {
+	var samples []sample
+	var errs []error
+	for i := range c.config.Metrics {
+		metric := &c.config.Metrics[i]
+		items := []interface{}{document}
+		if metric.Items != "" {
+			var err error
+			if items, err = jsonquery.Run(metric.Items, document); err != nil {
+				errs = append(errs, fmt.Errorf("metric %s: items query failed: %v", metric.Name, err))
+				continue
+			}
+		}
+
+		for _, item := range items {
+			s, err := extractSample(metric, item)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("metric %s: %v", metric.Name, err))
+				continue
+			}
+			if s != nil {
+				samples = append(samples, *s)
+			}
+		}
+	}
+	return samples, errs
+}
+
+// extractSample extracts the sample of a metric from an item, nil if the item
+// has no value.
+func extractSample(metric *metricConfig, item interface{}) (*sample, error) {
+	value, found, err := runFirst(metric.Value, item)
+	if err != nil {
+		return nil, fmt.Errorf("value query failed: %v", err)
+	}
+	if !found {
+		return nil, nil
+	}
+	s := &sample{metric: metric}
+	if s.value, err = toFloat(value); err != nil {
+		return nil, err
+	}
+
+	for tag, query := range metric.Tags {
+		value, found, err := runFirst(query, item)
+		if err != nil {
+			return nil, fmt.Errorf("query of tag %s failed: %v", tag, err)
+		}
+		if found {
+			s.tags = append(s.tags, tag+":"+fmt.Sprint(value))
+		}
+	}
+	sort.Strings(s.tags)
+
+	if metric.Timestamp != "" {
+		value, found, err := runFirst(metric.Timestamp, item)
+		if err != nil {
+			return nil, fmt.Errorf("timestamp query failed: %v", err)
+		}
+		if found {
+			if s.timestamp, err = toTime(value); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return s, nil
+}
+
+// runFirst returns the first non-null output of a query
+func runFirst(query string, item interface{}) (interface{}, bool, error) {
+	values, err := jsonquery.Run(query, item)
+	if err != nil {
+		return nil, false, err
+	}
+	for _, value := range values {
+		if value != nil {
+			return value, true, nil
+		}
+	}
+	return nil, false, nil
+}
+
+func toFloat(value interface{}) (float64, error) {
+	switch v := value.(type) {
+	case float64:
+		return v, nil
+	case int:
+		return float64(v), nil
+	case bool:
+		if v {
+			return 1, nil
+		}
+		return 0, nil
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return 0, fmt.Errorf("the value %q isn't a number", v)
+		}
+		return f, nil
+	case *big.Int:
+		f, _ := new(big.Float).SetInt(v).Float64()
+		return f, nil
+	default:
+		return 0, fmt.Errorf("the value %v isn't a number", value)
+	}
+}
+
+// toTime converts a Unix timestamp in seconds or an RFC 3339 date
+func toTime(value interface{}) (time.Time, error) {
+	if s, ok := value.(string); ok {
+		if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+			return t, nil
+		}
+	}
+	seconds, err := toFloat(value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("the timestamp %v is neither a Unix timestamp nor an RFC 3339 date", value)
+	}
+	return time.Unix(0, int64(seconds*float64(time.Second))), nil
+}
+
+func httpJSONFactory() check.Check {
+	return &HTTPJSONCheck{
+		CheckBase: core.NewCheckBase(checkName),
+	}
+}
+
+func init() {
+	core.RegisterCheck(checkName, httpJSONFactory)
+}
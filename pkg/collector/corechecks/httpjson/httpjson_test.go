@@ -0,0 +1,181 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package httpjson
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/aggregator/mocksender"
+	"github.com/DataDog/datadog-agent/pkg/autodiscovery/integration"
+	"github.com/DataDog/datadog-agent/pkg/metrics"
+)
+
+const testResponse = `{
+  "status": {"uptime": 3600, "healthy": true, "version": "1.2"},
+  "queues": [
+    {"name": "jobs", "depth": 12, "consumers": "3", "updated": "%s"},
+    {"name": "mails", "depth": 4, "consumers": "1", "updated": "%s"},
+    {"name": "broken", "depth": "n/a"}
+  ]
+}`
+
+const testInstance = `
+url: %s
+headers:
+  X-Api-Key: secret
+username: user
+password: pass
+metrics:
+  - name: app.uptime
+    value: .status.uptime
+    tags:
+      version: $.status.version
+  - name: app.healthy
+    value: $.status.healthy
+  - name: app.queue.depth
+    items: $.queues[*]
+    value: .depth
+    tags:
+      queue: .name
+    timestamp: .updated
+  - name: app.queue.consumers
+    type: monotonic_count
+    items: .queues[]
+    value: .consumers
+    tags:
+      queue: .name
+`
+
+func newTestServer(t *testing.T, updated *time.Time) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "secret", r.Header.Get("X-Api-Key"))
+		username, password, _ := r.BasicAuth()
+		assert.Equal(t, "user", username)
+		assert.Equal(t, "pass", password)
+		date := updated.Format(time.RFC3339)
+		fmt.Fprintf(w, testResponse, date, date)
+	}))
+}
+
+func newTestCheck(t *testing.T, instance string) (*HTTPJSONCheck, *mocksender.MockSender) {
+	c := httpJSONFactory().(*HTTPJSONCheck)
+	require.NoError(t, c.Configure(integration.Data(instance), nil, "test"))
+	sender := mocksender.NewMockSender(c.ID())
+	sender.SetupAcceptAll()
+	return c, sender
+}
+
+func TestRun(t *testing.T) {
+	updated := time.Now()
+	server := newTestServer(t, &updated)
+	defer server.Close()
+
+	c, sender := newTestCheck(t, fmt.Sprintf(testInstance, server.URL))
+	require.NoError(t, c.Run())
+
+	sender.AssertServiceCheck(t, "http_json.can_connect", metrics.ServiceCheckOK, "", []string{"url:" + server.URL}, "")
+	sender.AssertMetric(t, "Gauge", "app.uptime", 3600, "", []string{"version:1.2"})
+	sender.AssertMetric(t, "Gauge", "app.healthy", 1, "", nil)
+	sender.AssertMetric(t, "Gauge", "app.queue.depth", 12, "", []string{"queue:jobs"})
+	sender.AssertMetric(t, "Gauge", "app.queue.depth", 4, "", []string{"queue:mails"})
+	sender.AssertMetric(t, "MonotonicCount", "app.queue.consumers", 3, "", []string{"queue:jobs"})
+	sender.AssertMetric(t, "MonotonicCount", "app.queue.consumers", 1, "", []string{"queue:mails"})
+	sender.AssertNumberOfCalls(t, "Gauge", 4)
+	sender.AssertNumberOfCalls(t, "Commit", 1)
+
+	// the depth of the broken queue isn't a number
+	assert.Len(t, c.GetWarnings(), 1)
+
+	// the samples whose timestamp didn't change aren't submitted again
+	sender.ResetCalls()
+	require.NoError(t, c.Run())
+	sender.AssertNumberOfCalls(t, "Gauge", 2)
+	sender.AssertNotCalled(t, "Gauge", "app.queue.depth", mock.Anything, mock.Anything, mock.Anything)
+
+	updated = updated.Add(time.Minute)
+	sender.ResetCalls()
+	require.NoError(t, c.Run())
+	sender.AssertNumberOfCalls(t, "Gauge", 4)
+}
+
+func TestRunMaxAge(t *testing.T) {
+	updated := time.Now().Add(-time.Hour)
+	server := newTestServer(t, &updated)
+	defer server.Close()
+
+	c, sender := newTestCheck(t, fmt.Sprintf(testInstance+"max_age: 60\n", server.URL))
+	require.NoError(t, c.Run())
+	sender.AssertNotCalled(t, "Gauge", "app.queue.depth", mock.Anything, mock.Anything, mock.Anything)
+	sender.AssertMetric(t, "Gauge", "app.uptime", 3600, "", []string{"version:1.2"})
+}
+
+func TestRunFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	c, sender := newTestCheck(t, fmt.Sprintf("url: %s\nmetrics:\n  - name: foo\n    value: .foo", server.URL))
+	message := fmt.Sprintf("unexpected status code 401 from %s", server.URL)
+	assert.EqualError(t, c.Run(), message)
+	sender.AssertServiceCheck(t, "http_json.can_connect", metrics.ServiceCheckCritical, "", []string{"url:" + server.URL}, message)
+	sender.AssertNumberOfCalls(t, "Commit", 1)
+}
+
+func TestDryRun(t *testing.T) {
+	updated := time.Date(2021, 5, 3, 10, 0, 0, 0, time.UTC)
+	server := newTestServer(t, &updated)
+	defer server.Close()
+
+	c, sender := newTestCheck(t, fmt.Sprintf(testInstance, server.URL))
+	var buf bytes.Buffer
+	require.NoError(t, c.DryRun(&buf))
+
+	output := buf.String()
+	assert.Contains(t, output, "GET "+server.URL)
+	assert.Contains(t, output, "app.uptime (gauge)\n  3600 [version:1.2]\n")
+	assert.Contains(t, output, "  12 [queue:jobs] at 2021-05-03T10:00:00Z\n")
+	assert.Contains(t, output, `metric app.queue.depth: the value "n/a" isn't a number`)
+	sender.AssertNotCalled(t, "Gauge", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestToTime(t *testing.T) {
+	expected := time.Unix(1620036000, 0)
+	for _, value := range []interface{}{1620036000.0, 1620036000, "1620036000", "2021-05-03T10:00:00Z"} {
+		timestamp, err := toTime(value)
+		require.NoError(t, err)
+		assert.True(t, expected.Equal(timestamp), "%v", value)
+	}
+
+	_, err := toTime("yesterday")
+	assert.Error(t, err)
+}
+
+func TestConfigureErrors(t *testing.T) {
+	for name, instance := range map[string]string{
+		"missing url":       "metrics:\n  - name: foo\n    value: .foo",
+		"missing metrics":   "url: http://localhost",
+		"missing value":     "url: http://localhost\nmetrics:\n  - name: foo",
+		"invalid type":      "url: http://localhost\nmetrics:\n  - name: foo\n    value: .foo\n    type: histogram",
+		"invalid jq":        "url: http://localhost\nmetrics:\n  - name: foo\n    value: .foo[",
+		"invalid json path": "url: http://localhost\nmetrics:\n  - name: foo\n    value: $..foo",
+		"invalid tag":       "url: http://localhost\nmetrics:\n  - name: foo\n    value: .foo\n    tags:\n      bar: '.bar |'",
+	} {
+		t.Run(name, func(t *testing.T) {
+			c := httpJSONFactory()
+			assert.Error(t, c.Configure(integration.Data(instance), nil, "test"))
+		})
+	}
+}
@@ -0,0 +1,237 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package openmetrics
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	yaml "gopkg.in/yaml.v2"
+
+	"github.com/DataDog/datadog-agent/pkg/aggregator"
+	"github.com/DataDog/datadog-agent/pkg/autodiscovery/integration"
+	"github.com/DataDog/datadog-agent/pkg/collector/check"
+	core "github.com/DataDog/datadog-agent/pkg/collector/corechecks"
+	"github.com/DataDog/datadog-agent/pkg/metrics"
+	httputils "github.com/DataDog/datadog-agent/pkg/util/http"
+)
+
+const (
+	// CheckName is the name of the check, the Python openmetrics integration
+	// being named `openmetrics`
+	CheckName = "openmetrics_core"
+
+	defaultTimeout            = 10
+	defaultMaxReturnedMetrics = 2000
+	defaultBearerTokenPath    = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+)
+
+// metricTypes are the types of the type overrides
+var metricTypes = map[string]dto.MetricType{
+	"counter":   dto.MetricType_COUNTER,
+	"gauge":     dto.MetricType_GAUGE,
+	"summary":   dto.MetricType_SUMMARY,
+	"untyped":   dto.MetricType_UNTYPED,
+	"histogram": dto.MetricType_HISTOGRAM,
+}
+
+// instanceConfig is the configuration of an instance, compatible with the one
+// of the Python openmetrics integration.
+type instanceConfig struct {
+	URL                               string                     `yaml:"prometheus_url"`
+	Namespace                         string                     `yaml:"namespace"`
+	Metrics                           []interface{}              `yaml:"metrics"`
+	Prefix                            string                     `yaml:"prometheus_metrics_prefix"`
+	IgnoreMetrics                     []string                   `yaml:"ignore_metrics"`
+	LabelsMapper                      map[string]string          `yaml:"labels_mapper"`
+	ExcludeLabels                     []string                   `yaml:"exclude_labels"`
+	LabelToHostname                   string                     `yaml:"label_to_hostname"`
+	LabelJoins                        map[string]labelJoinConfig `yaml:"label_joins"`
+	TypeOverrides                     map[string]string          `yaml:"type_overrides"`
+	HealthServiceCheck                bool                       `yaml:"health_service_check"`
+	SendHistogramsBuckets             bool                       `yaml:"send_histograms_buckets"`
+	SendDistributionBuckets           bool                       `yaml:"send_distribution_buckets"`
+	SendMonotonicCounter              bool                       `yaml:"send_monotonic_counter"`
+	SendDistributionCountsAsMonotonic bool                       `yaml:"send_distribution_counts_as_monotonic"`
+	SendDistributionSumsAsMonotonic   bool                       `yaml:"send_distribution_sums_as_monotonic"`
+	MaxReturnedMetrics                int                        `yaml:"max_returned_metrics"`
+	Headers                           map[string]string          `yaml:"headers"`
+	ExtraHeaders                      map[string]string          `yaml:"extra_headers"`
+	Timeout                           int                        `yaml:"timeout"`
+	BearerTokenAuth                   bool                       `yaml:"bearer_token_auth"`
+	BearerTokenPath                   string                     `yaml:"bearer_token_path"`
+	Username                          string                     `yaml:"username"`
+	Password                          string                     `yaml:"password"`
+	TLSVerify                         bool                       `yaml:"tls_verify"`
+	TLSCert                           string                     `yaml:"tls_cert"`
+	TLSPrivateKey                     string                     `yaml:"tls_private_key"`
+	TLSCACert                         string                     `yaml:"tls_ca_cert"`
+	SkipProxy                         bool                       `yaml:"skip_proxy"`
+}
+
+type labelJoinConfig struct {
+	LabelsToMatch []string `yaml:"labels_to_match"`
+	LabelsToGet   []string `yaml:"labels_to_get"`
+}
+
+// OpenMetricsCheck scrapes an endpoint exposing metrics in the OpenMetrics or
+// Prometheus formats.
+type OpenMetricsCheck struct {
+	core.CheckBase
+	config        *instanceConfig
+	client        *http.Client
+	renames       map[string]string // the metrics to submit, with their new name
+	wildcards     []*regexp.Regexp  // the metrics to submit with their name
+	ignored       []*regexp.Regexp
+	typeOverrides map[string]dto.MetricType
+	labelJoins    map[string]*labelJoin
+	excludeLabels map[string]bool
+}
+
+func (c *instanceConfig) parse(data []byte) error {
+	// the defaults of the Python integration
+	c.HealthServiceCheck = true
+	c.SendHistogramsBuckets = true
+	c.SendMonotonicCounter = true
+	c.TLSVerify = true
+	c.Timeout = defaultTimeout
+	c.MaxReturnedMetrics = defaultMaxReturnedMetrics
+	c.BearerTokenPath = defaultBearerTokenPath
+
+	if err := yaml.Unmarshal(data, c); err != nil {
+		return err
+	}
+	if c.URL == "" {
+		return errors.New("prometheus_url is required")
+	}
+	if len(c.Metrics) == 0 {
+		return errors.New("at least one metric must be set in metrics")
+	}
+	return nil
+}
+
+// Configure parses the configuration of the check and creates its HTTP client
+func (c *OpenMetricsCheck) Configure(data integration.Data, initConfig integration.Data, source string) error {
+	c.BuildID(data, initConfig)
+	if err := c.CommonConfigure(data, source); err != nil {
+		return err
+	}
+
+	cfg := &instanceConfig{}
+	if err := cfg.parse(data); err != nil {
+		return err
+	}
+	c.config = cfg
+
+	c.renames = map[string]string{}
+	for _, metric := range cfg.Metrics {
+		switch m := metric.(type) {
+		case string:
+			if strings.Contains(m, "*") {
+				c.wildcards = append(c.wildcards, wildcardRegexp(m))
+			} else {
+				c.renames[m] = m
+			}
+		case map[interface{}]interface{}:
+			for name, rename := range m {
+				c.renames[fmt.Sprint(name)] = fmt.Sprint(rename)
+			}
+		default:
+			return fmt.Errorf("invalid metric %v: expected a name or a mapping to a new name", metric)
+		}
+	}
+	for _, metric := range cfg.IgnoreMetrics {
+		c.ignored = append(c.ignored, wildcardRegexp(metric))
+	}
+
+	c.typeOverrides = map[string]dto.MetricType{}
+	for name, override := range cfg.TypeOverrides {
+		metricType, found := metricTypes[override]
+		if !found {
+			return fmt.Errorf("invalid type override %q for metric %s", override, name)
+		}
+		c.typeOverrides[name] = metricType
+	}
+
+	c.labelJoins = map[string]*labelJoin{}
+	for name, join := range cfg.LabelJoins {
+		if len(join.LabelsToMatch) == 0 {
+			return fmt.Errorf("labels_to_match must be set in the label join of metric %s", name)
+		}
+		c.labelJoins[name] = &labelJoin{labelsToMatch: join.LabelsToMatch, labelsToGet: join.LabelsToGet}
+	}
+
+	c.excludeLabels = map[string]bool{}
+	for _, label := range cfg.ExcludeLabels {
+		c.excludeLabels[label] = true
+	}
+
+	transport, err := httputils.CreateHTTPTransportWithOptions(httputils.TransportOptions{
+		NoProxy:     cfg.SkipProxy,
+		TLSCAFile:   cfg.TLSCACert,
+		TLSCertFile: cfg.TLSCert,
+		TLSKeyFile:  cfg.TLSPrivateKey,
+	})
+	if err != nil {
+		return err
+	}
+	if !cfg.TLSVerify {
+		transport.TLSClientConfig.InsecureSkipVerify = true
+	}
+	c.client = &http.Client{
+		Transport: transport,
+		Timeout:   time.Duration(cfg.Timeout) * time.Second,
+	}
+	return nil
+}
+
+// Run scrapes the endpoint and submits its metrics
+func (c *OpenMetricsCheck) Run() error {
+	sender, err := aggregator.GetSender(c.ID())
+	if err != nil {
+		return err
+	}
+	defer sender.Commit()
+
+	families, err := c.scrape()
+	if c.config.HealthServiceCheck {
+		status, message := metrics.ServiceCheckOK, ""
+		if err != nil {
+			status, message = metrics.ServiceCheckCritical, err.Error()
+		}
+		sender.ServiceCheck(c.metricName("prometheus.health"), status, "", []string{"endpoint:" + c.config.URL}, message)
+	}
+	if err != nil {
+		return err
+	}
+
+	c.submitFamilies(sender, families)
+	return nil
+}
+
+// wildcardRegexp returns the regexp matching a metric name with `*` wildcards
+func wildcardRegexp(pattern string) *regexp.Regexp {
+	parts := strings.Split(pattern, "*")
+	for i, part := range parts {
+		parts[i] = regexp.QuoteMeta(part)
+	}
+	return regexp.MustCompile("^" + strings.Join(parts, ".*") + "$")
+}
+
+func openMetricsFactory() check.Check {
+	return &OpenMetricsCheck{
+		CheckBase: core.NewCheckBase(CheckName),
+	}
+}
+
+func init() {
+	core.RegisterCheck(CheckName, openMetricsFactory)
+}
@@ -0,0 +1,209 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package openmetrics
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/aggregator/mocksender"
+	"github.com/DataDog/datadog-agent/pkg/autodiscovery/integration"
+	"github.com/DataDog/datadog-agent/pkg/metrics"
+)
+
+const testPayload = `# HELP go_goroutines Number of goroutines.
+# TYPE app_go_goroutines gauge
+app_go_goroutines 12
+# TYPE app_requests_total counter
+app_requests_total{code="200",method="get"} 1027
+app_requests_total{code="500",method="get"} 3
+# TYPE app_request_duration_seconds histogram
+app_request_duration_seconds_bucket{le="0.1"} 5
+app_request_duration_seconds_bucket{le="0.5"} 8
+app_request_duration_seconds_bucket{le="+Inf"} 10
+app_request_duration_seconds_sum 3.5
+app_request_duration_seconds_count 10
+# TYPE app_rpc_duration_seconds summary
+app_rpc_duration_seconds{quantile="0.5"} 0.2
+app_rpc_duration_seconds{quantile="0.9"} NaN
+app_rpc_duration_seconds_sum 12
+app_rpc_duration_seconds_count 40
+# TYPE app_pod_info gauge
+app_pod_info{pod="web-1",node="node-a",team="front"} 1
+# TYPE app_pod_memory_bytes gauge
+app_pod_memory_bytes{pod="web-1",container="nginx"} 1024
+app_pod_memory_bytes{pod="web-2",container="nginx"} 2048
+# TYPE app_ignored gauge
+app_ignored 1
+`
+
+func newTestCheck(t *testing.T, instance string) (*OpenMetricsCheck, *mocksender.MockSender) {
+	c := openMetricsFactory().(*OpenMetricsCheck)
+	require.NoError(t, c.Configure(integration.Data(instance), nil, "test"))
+	sender := mocksender.NewMockSender(c.ID())
+	sender.SetupAcceptAll()
+	return c, sender
+}
+
+func TestRun(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Contains(t, r.Header.Get("Accept"), "application/openmetrics-text")
+		assert.Equal(t, "value", r.Header.Get("X-Custom"))
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		w.Write([]byte(testPayload))
+	}))
+	defer server.Close()
+
+	c, sender := newTestCheck(t, fmt.Sprintf(`
+prometheus_url: %s
+namespace: app
+prometheus_metrics_prefix: app_
+metrics:
+  - go_goroutines: goroutines
+  - requests_total: requests
+  - request_duration_seconds
+  - rpc_*
+  - pod_memory_bytes
+  - ignored
+ignore_metrics:
+  - ign*
+labels_mapper:
+  code: status_code
+exclude_labels:
+  - method
+label_joins:
+  pod_info:
+    labels_to_match: [pod]
+    labels_to_get: [node, team]
+headers:
+  X-Custom: value
+`, server.URL))
+
+	require.NoError(t, c.Run())
+	sender.AssertServiceCheck(t, "app.prometheus.health", metrics.ServiceCheckOK, "", []string{"endpoint:" + server.URL}, "")
+	sender.AssertMetric(t, "Gauge", "app.goroutines", 12, "", []string{})
+	sender.AssertMetric(t, "MonotonicCount", "app.requests", 1027, "", []string{"status_code:200"})
+	sender.AssertMetric(t, "MonotonicCount", "app.requests", 3, "", []string{"status_code:500"})
+
+	// histograms
+	sender.AssertMetric(t, "Gauge", "app.request_duration_seconds.count", 10, "", []string{})
+	sender.AssertMetric(t, "Gauge", "app.request_duration_seconds.sum", 3.5, "", []string{})
+	sender.AssertMetric(t, "Gauge", "app.request_duration_seconds.count", 5, "", []string{"upper_bound:0.1"})
+	sender.AssertMetric(t, "Gauge", "app.request_duration_seconds.count", 10, "", []string{"upper_bound:none"})
+	sender.AssertNotCalled(t, "HistogramBucket", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+
+	// summaries, the NaN quantiles are skipped
+	sender.AssertMetric(t, "Gauge", "app.rpc_duration_seconds.count", 40, "", []string{})
+	sender.AssertMetric(t, "Gauge", "app.rpc_duration_seconds.sum", 12, "", []string{})
+	sender.AssertMetric(t, "Gauge", "app.rpc_duration_seconds.quantile", 0.2, "", []string{"quantile:0.5"})
+	sender.AssertNotCalled(t, "Gauge", "app.rpc_duration_seconds.quantile", mock.Anything, "", []string{"quantile:0.9"})
+
+	// label joins
+	sender.AssertMetric(t, "Gauge", "app.pod_memory_bytes", 1024, "", []string{"container:nginx", "pod:web-1", "node:node-a", "team:front"})
+	sender.AssertMetric(t, "Gauge", "app.pod_memory_bytes", 2048, "", []string{"container:nginx", "pod:web-2"})
+	sender.AssertNotCalled(t, "Gauge", "app.pod_info", mock.Anything, mock.Anything, mock.Anything)
+
+	// ignored metrics
+	sender.AssertNotCalled(t, "Gauge", "app.ignored", mock.Anything, mock.Anything, mock.Anything)
+	sender.AssertNumberOfCalls(t, "Commit", 1)
+}
+
+func TestRunOptions(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(testPayload))
+	}))
+	defer server.Close()
+
+	c, sender := newTestCheck(t, fmt.Sprintf(`
+prometheus_url: %s
+metrics:
+  - app_*
+type_overrides:
+  app_pod_memory_bytes: counter
+  app_go_goroutines: histogram
+label_to_hostname: node
+send_monotonic_counter: false
+send_distribution_buckets: true
+send_distribution_counts_as_monotonic: true
+send_distribution_sums_as_monotonic: true
+health_service_check: false
+`, server.URL))
+
+	require.NoError(t, c.Run())
+	sender.AssertNotCalled(t, "ServiceCheck", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	sender.AssertMetric(t, "Gauge", "app_requests_total", 1027, "", []string{"code:200", "method:get"})
+	sender.AssertMetric(t, "Gauge", "app_pod_memory_bytes", 1024, "", []string{"container:nginx", "pod:web-1"})
+	sender.AssertMetric(t, "Gauge", "app_pod_info", 1, "node-a", []string{"node:node-a", "pod:web-1", "team:front"})
+	sender.AssertNotCalled(t, "Gauge", "app_go_goroutines", mock.Anything, mock.Anything, mock.Anything)
+
+	// histograms converted to distributions
+	sender.AssertMetric(t, "MonotonicCount", "app_request_duration_seconds.count", 10, "", []string{})
+	sender.AssertMetric(t, "MonotonicCount", "app_request_duration_seconds.sum", 3.5, "", []string{})
+	sender.AssertMetric(t, "MonotonicCount", "app_rpc_duration_seconds.count", 40, "", []string{})
+	sender.AssertCalled(t, "HistogramBucket", "app_request_duration_seconds", int64(5), 0.0, 0.1, true, "", []string{}, false)
+	sender.AssertCalled(t, "HistogramBucket", "app_request_duration_seconds", int64(3), 0.1, 0.5, true, "", []string{}, false)
+	sender.AssertCalled(t, "HistogramBucket", "app_request_duration_seconds", int64(2), 0.5, 0.5, true, "", []string{}, false)
+	sender.AssertNumberOfCalls(t, "HistogramBucket", 3)
+}
+
+func TestRunMaxReturnedMetrics(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(testPayload))
+	}))
+	defer server.Close()
+
+	c, sender := newTestCheck(t, fmt.Sprintf(`
+prometheus_url: %s
+metrics: ["*"]
+max_returned_metrics: 3
+`, server.URL))
+
+	require.NoError(t, c.Run())
+	// the families are submitted by name until the limit is reached
+	sender.AssertMetric(t, "Gauge", "app_go_goroutines", 12, "", []string{})
+	sender.AssertMetric(t, "Gauge", "app_pod_info", 1, "", []string{"node:node-a", "pod:web-1", "team:front"})
+	sender.AssertNumberOfCalls(t, "Gauge", 3)
+	assert.Len(t, c.GetWarnings(), 1)
+}
+
+func TestRunScrapeFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	c, sender := newTestCheck(t, fmt.Sprintf(`
+prometheus_url: %s
+namespace: app
+metrics: ["*"]
+`, server.URL))
+
+	message := fmt.Sprintf("unexpected status code 403 from %s", server.URL)
+	assert.EqualError(t, c.Run(), message)
+	sender.AssertServiceCheck(t, "app.prometheus.health", metrics.ServiceCheckCritical, "", []string{"endpoint:" + server.URL}, message)
+	sender.AssertNumberOfCalls(t, "Commit", 1)
+}
+
+func TestConfigureErrors(t *testing.T) {
+	for name, instance := range map[string]string{
+		"missing url":           "metrics: [foo]",
+		"missing metrics":       "prometheus_url: http://localhost",
+		"invalid metric":        "prometheus_url: http://localhost\nmetrics: [[foo]]",
+		"invalid type override": "prometheus_url: http://localhost\nmetrics: [foo]\ntype_overrides:\n  foo: distribution",
+		"invalid label join":    "prometheus_url: http://localhost\nmetrics: [foo]\nlabel_joins:\n  foo:\n    labels_to_get: [bar]",
+	} {
+		t.Run(name, func(t *testing.T) {
+			c := openMetricsFactory()
+			assert.Error(t, c.Configure(integration.Data(instance), nil, "test"))
+		})
+	}
+}
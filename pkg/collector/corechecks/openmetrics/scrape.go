@@ -0,0 +1,198 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package openmetrics
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+const (
+	// acceptHeader prefers the protobuf format, then the OpenMetrics and Prometheus text formats
+	acceptHeader = `application/vnd.google.protobuf;proto=io.prometheus.client.MetricFamily;encoding=delimited;q=0.7,` +
+		`application/openmetrics-text;version=0.0.1;q=0.75,text/plain;version=0.0.4;q=0.5,*/*;q=0.1`
+
+	openMetricsMediaType = "application/openmetrics-text"
+)
+
+// scrape fetches and parses the metrics exposed by the endpoint of the check.
+func (c *OpenMetricsCheck) scrape() ([]*dto.MetricFamily, error) {
+	req, err := http.NewRequest(http.MethodGet, c.config.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", acceptHeader)
+	for name, value := range c.config.Headers {
+		req.Header.Set(name, value)
+	}
+	for name, value := range c.config.ExtraHeaders {
+		req.Header.Set(name, value)
+	}
+	if c.config.BearerTokenAuth {
+		// read at each run, as the token can be rotated
+		token, err := ioutil.ReadFile(c.config.BearerTokenPath)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read the bearer token: %v", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+	if c.config.Username != "" {
+		req.SetBasicAuth(c.config.Username, c.config.Password)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d from %s", resp.StatusCode, c.config.URL)
+	}
+	return parseMetricFamilies(resp.Body, resp.Header)
+}
+
+// parseMetricFamilies parses the metric families of a response in the protobuf
+// format, or in the Prometheus or OpenMetrics text formats.
+func parseMetricFamilies(r io.Reader, header http.Header) ([]*dto.MetricFamily, error) {
+	format := expfmt.ResponseFormat(header)
+	if mediaType, _, err := mime.ParseMediaType(header.Get("Content-Type")); err == nil && mediaType == openMetricsMediaType {
+		body, err := ioutil.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		r = bytes.NewReader(openMetricsToText(body))
+		format = expfmt.FmtText
+	}
+
+	var families []*dto.MetricFamily
+	if format == expfmt.FmtProtoDelim {
+		decoder := expfmt.NewDecoder(r, format)
+		for {
+			family := &dto.MetricFamily{}
+			if err := decoder.Decode(family); err != nil {
+				if err == io.EOF {
+					break
+				}
+				return nil, err
+			}
+			families = append(families, family)
+		}
+		return families, nil
+	}
+
+	// the responses in an unknown format are parsed as text
+	var parser expfmt.TextParser
+	familiesByName, err := parser.TextToMetricFamilies(r)
+	if err != nil {
+		return nil, err
+	}
+	for _, family := range familiesByName {
+		families = append(families, family)
+	}
+	sort.Slice(families, func(i, j int) bool {
+		return families[i].GetName() < families[j].GetName()
+	})
+	return families, nil
+}
+
+// openMetricsToText converts the OpenMetrics text format to the Prometheus one:
+// the counters are renamed after their `_total` samples, the types unknown to
+// Prometheus are converted to gauges or left untyped, the `_created` samples,
+// timestamps, exemplars, and the HELP, UNIT and EOF lines are removed.
+func openMetricsToText(body []byte) []byte {
+	var out bytes.Buffer
+	// the families with `_created` samples
+	withCreated := map[string]bool{}
+
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(make([]byte, 0, 64*1024), len(body)+1)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "#") {
+			fields := strings.Fields(line)
+			if len(fields) != 4 || fields[1] != "TYPE" {
+				continue
+			}
+			name, metricType := fields[2], fields[3]
+			switch metricType {
+			case "counter":
+				withCreated[name] = true
+				if !strings.HasSuffix(name, "_total") {
+					name += "_total"
+				}
+			case "info":
+				name, metricType = name+"_info", "gauge"
+			case "stateset":
+				metricType = "gauge"
+			case "histogram", "summary":
+				withCreated[name] = true
+			case "gauge":
+			default:
+				// the samples of the unknown and gauge histogram families are untyped
+				continue
+			}
+			fmt.Fprintf(&out, "# TYPE %s %s\n", name, metricType)
+			continue
+		}
+
+		nameAndLabels, rest := splitSample(line)
+		name := nameAndLabels
+		if i := strings.IndexByte(name, '{'); i >= 0 {
+			name = name[:i]
+		}
+		if strings.HasSuffix(name, "_created") && withCreated[strings.TrimSuffix(name, "_created")] {
+			continue
+		}
+		// the timestamps are in seconds instead of milliseconds, only keep the value
+		fields := strings.Fields(rest)
+		if len(fields) == 0 {
+			continue
+		}
+		fmt.Fprintf(&out, "%s %s\n", nameAndLabels, fields[0])
+	}
+	return out.Bytes()
+}
+
+// splitSample splits a sample line after its name and labels.
+func splitSample(line string) (string, string) {
+	i := strings.IndexAny(line, "{ ")
+	if i < 0 {
+		return line, ""
+	}
+	if line[i] == ' ' {
+		return line[:i], line[i:]
+	}
+
+	inQuotes, escaped := false, false
+	for j := i + 1; j < len(line); j++ {
+		switch {
+		case escaped:
+			escaped = false
+		case line[j] == '\\':
+			escaped = true
+		case line[j] == '"':
+			inQuotes = !inQuotes
+		case line[j] == '}' && !inQuotes:
+			return line[:j+1], line[j+1:]
+		}
+	}
+	return line, ""
+}
@@ -0,0 +1,113 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package openmetrics
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenMetricsToText(t *testing.T) {
+	body := `# HELP requests Number of requests.
+# TYPE requests counter
+# UNIT requests requests
+requests_total{path="/a b",code="200"} 1027 1520879607.789 # {trace_id="KOO5S4vxi0o"} 0.67
+requests_created{path="/a b",code="200"} 1520872607.123
+# TYPE build info
+build_info{version="1.0"} 1
+# TYPE state stateset
+state{state="ready"} 1
+# TYPE latency histogram
+latency_bucket{le="+Inf"} 4
+latency_count 4
+latency_sum 2.5
+latency_created 1520872607.123
+# TYPE queue gaugehistogram
+queue_gcount 3
+# TYPE temperature gauge
+temperature{room="a}b"} 21.5
+# EOF
+`
+	expected := `# TYPE requests_total counter
+requests_total{path="/a b",code="200"} 1027
+# TYPE build_info gauge
+build_info{version="1.0"} 1
+# TYPE state gauge
+state{state="ready"} 1
+# TYPE latency histogram
+latency_bucket{le="+Inf"} 4
+latency_count 4
+latency_sum 2.5
+queue_gcount 3
+# TYPE temperature gauge
+temperature{room="a}b"} 21.5
+`
+	assert.Equal(t, expected, string(openMetricsToText([]byte(body))))
+}
+
+func TestParseMetricFamilies(t *testing.T) {
+	header := http.Header{}
+	header.Set("Content-Type", "application/openmetrics-text; version=0.0.1; charset=utf-8")
+	families, err := parseMetricFamilies(strings.NewReader("# TYPE requests counter\nrequests_total 3\nrequests_created 1520872607\n# EOF\n"), header)
+	require.NoError(t, err)
+	require.Len(t, families, 1)
+	assert.Equal(t, "requests_total", families[0].GetName())
+	assert.Equal(t, dto.MetricType_COUNTER, families[0].GetType())
+	assert.Equal(t, 3.0, families[0].Metric[0].Counter.GetValue())
+
+	// protobuf format
+	var buf bytes.Buffer
+	encoder := expfmt.NewEncoder(&buf, expfmt.FmtProtoDelim)
+	for _, name := range []string{"foo", "bar"} {
+		require.NoError(t, encoder.Encode(&dto.MetricFamily{
+			Name:   proto.String(name),
+			Type:   dto.MetricType_GAUGE.Enum(),
+			Metric: []*dto.Metric{{Gauge: &dto.Gauge{Value: proto.Float64(1)}}},
+		}))
+	}
+	header.Set("Content-Type", string(expfmt.FmtProtoDelim))
+	families, err = parseMetricFamilies(&buf, header)
+	require.NoError(t, err)
+	require.Len(t, families, 2)
+	assert.Equal(t, "foo", families[0].GetName())
+	assert.Equal(t, "bar", families[1].GetName())
+
+	// unknown formats are parsed as text
+	families, err = parseMetricFamilies(strings.NewReader("foo 1\n"), http.Header{})
+	require.NoError(t, err)
+	require.Len(t, families, 1)
+	assert.Equal(t, dto.MetricType_UNTYPED, families[0].GetType())
+}
+
+func TestSubmitHistogramBucketsProtobuf(t *testing.T) {
+	c, sender := newTestCheck(t, "prometheus_url: http://localhost\nmetrics: [latency]\nsend_distribution_buckets: true")
+
+	// the +Inf bucket is implicit in the protobuf format
+	c.submitFamilies(sender, []*dto.MetricFamily{{
+		Name: proto.String("latency"),
+		Type: dto.MetricType_HISTOGRAM.Enum(),
+		Metric: []*dto.Metric{{
+			Histogram: &dto.Histogram{
+				SampleCount: proto.Uint64(7),
+				SampleSum:   proto.Float64(3),
+				Bucket: []*dto.Bucket{
+					{UpperBound: proto.Float64(1), CumulativeCount: proto.Uint64(4)},
+				},
+			},
+		}},
+	}})
+	sender.AssertCalled(t, "HistogramBucket", "latency", int64(4), 0.0, 1.0, true, "", []string{}, false)
+	sender.AssertCalled(t, "HistogramBucket", "latency", int64(3), 1.0, 1.0, true, "", []string{}, false)
+	sender.AssertNumberOfCalls(t, "HistogramBucket", 2)
+}
@@ -0,0 +1,270 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package openmetrics
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/DataDog/datadog-agent/pkg/aggregator"
+)
+
+// labelJoin adds the labels of the samples of a metric to the samples of the
+// other metrics with the same values for the labels to match.
+type labelJoin struct {
+	labelsToMatch []string
+	labelsToGet   []string
+	// the labels to add, by values of the labels to match, built at each run
+	labels map[string][]*dto.LabelPair
+}
+
+// key returns the values of the labels to match of a sample, false if one of
+// the labels is missing.
+func (j *labelJoin) key(labels []*dto.LabelPair) (string, bool) {
+	values := make([]string, 0, len(j.labelsToMatch))
+	for _, name := range j.labelsToMatch {
+		found := false
+		for _, label := range labels {
+			if label.GetName() == name {
+				values = append(values, label.GetValue())
+				found = true
+				break
+			}
+		}
+		if !found {
+			return "", false
+		}
+	}
+	return strings.Join(values, "\x00"), true
+}
+
+func (j *labelJoin) collect(family *dto.MetricFamily) {
+	for _, metric := range family.Metric {
+		key, ok := j.key(metric.Label)
+		if !ok {
+			continue
+		}
+		for _, label := range metric.Label {
+			for _, name := range j.labelsToGet {
+				if label.GetName() == name {
+					j.labels[key] = append(j.labels[key], label)
+				}
+			}
+		}
+	}
+}
+
+// submitFamilies submits the metrics of the scraped families, up to the maximum
+// number of returned metrics.
+func (c *OpenMetricsCheck) submitFamilies(sender aggregator.Sender, families []*dto.MetricFamily) {
+	for name, join := range c.labelJoins {
+		join.labels = map[string][]*dto.LabelPair{}
+		for _, family := range families {
+			if c.trimPrefix(family.GetName()) == name {
+				join.collect(family)
+			}
+		}
+	}
+
+	submitted := 0
+	for _, family := range families {
+		name := c.trimPrefix(family.GetName())
+		newName, ok := c.newName(name)
+		if !ok {
+			continue
+		}
+		if submitted+len(family.Metric) > c.config.MaxReturnedMetrics {
+			c.Warnf("Check %s exceeded the maximum number of metrics (%d), set max_returned_metrics to collect more", c, c.config.MaxReturnedMetrics) //nolint:errcheck
+			return
+		}
+		submitted += len(family.Metric)
+
+		metricType := family.GetType()
+		if override, found := c.typeOverrides[name]; found {
+			metricType = override
+		}
+		for _, metric := range family.Metric {
+			c.submitMetric(sender, c.metricName(newName), metricType, metric)
+		}
+	}
+}
+
+// newName returns the name of the metric submitted for a scraped metric, false
+// if the metric isn't collected.
+func (c *OpenMetricsCheck) newName(name string) (string, bool) {
+	for _, ignored := range c.ignored {
+		if ignored.MatchString(name) {
+			return "", false
+		}
+	}
+	if newName, found := c.renames[name]; found {
+		return newName, true
+	}
+	for _, wildcard := range c.wildcards {
+		if wildcard.MatchString(name) {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+func (c *OpenMetricsCheck) trimPrefix(name string) string {
+	return strings.TrimPrefix(name, c.config.Prefix)
+}
+
+func (c *OpenMetricsCheck) metricName(name string) string {
+	if c.config.Namespace == "" {
+		return name
+	}
+	return c.config.Namespace + "." + name
+}
+
+// tags returns the tags and hostname of a sample, from its labels and the
+// labels joined to them.
+func (c *OpenMetricsCheck) tags(labels []*dto.LabelPair) ([]string, string) {
+	for _, join := range c.labelJoins {
+		if key, ok := join.key(labels); ok {
+			labels = append(labels[:len(labels):len(labels)], join.labels[key]...)
+		}
+	}
+
+	tags := make([]string, 0, len(labels))
+	hostname := ""
+	for _, label := range labels {
+		name := label.GetName()
+		if c.config.LabelToHostname != "" && name == c.config.LabelToHostname {
+			hostname = label.GetValue()
+		}
+		if c.excludeLabels[name] {
+			continue
+		}
+		if mapped, found := c.config.LabelsMapper[name]; found {
+			name = mapped
+		}
+		tags = append(tags, name+":"+label.GetValue())
+	}
+	return tags, hostname
+}
+
+func (c *OpenMetricsCheck) submitMetric(sender aggregator.Sender, name string, metricType dto.MetricType, metric *dto.Metric) {
+	tags, hostname := c.tags(metric.Label)
+
+	switch metricType {
+	case dto.MetricType_COUNTER:
+		value, ok := sampleValue(metric)
+		if !ok {
+			return
+		}
+		if c.config.SendMonotonicCounter {
+			sender.MonotonicCount(name, value, hostname, tags)
+		} else {
+			sender.Gauge(name, value, hostname, tags)
+		}
+	case dto.MetricType_GAUGE, dto.MetricType_UNTYPED:
+		if value, ok := sampleValue(metric); ok {
+			sender.Gauge(name, value, hostname, tags)
+		}
+	case dto.MetricType_SUMMARY:
+		summary := metric.GetSummary()
+		if summary == nil {
+			return
+		}
+		c.submitDistributionCount(sender, name+".count", float64(summary.GetSampleCount()), c.config.SendDistributionCountsAsMonotonic, hostname, tags)
+		c.submitDistributionCount(sender, name+".sum", summary.GetSampleSum(), c.config.SendDistributionSumsAsMonotonic, hostname, tags)
+		for _, quantile := range summary.Quantile {
+			if math.IsNaN(quantile.GetValue()) {
+				continue
+			}
+			quantileTags := append(tags[:len(tags):len(tags)], "quantile:"+formatFloat(quantile.GetQuantile()))
+			sender.Gauge(name+".quantile", quantile.GetValue(), hostname, quantileTags)
+		}
+	case dto.MetricType_HISTOGRAM:
+		histogram := metric.GetHistogram()
+		if histogram == nil {
+			return
+		}
+		c.submitDistributionCount(sender, name+".count", float64(histogram.GetSampleCount()), c.config.SendDistributionCountsAsMonotonic, hostname, tags)
+		c.submitDistributionCount(sender, name+".sum", histogram.GetSampleSum(), c.config.SendDistributionSumsAsMonotonic, hostname, tags)
+		if c.config.SendDistributionBuckets {
+			submitHistogramBuckets(sender, name, histogram, hostname, tags)
+		} else if c.config.SendHistogramsBuckets {
+			for _, bucket := range histogram.Bucket {
+				upperBound := "none"
+				if !math.IsInf(bucket.GetUpperBound(), 1) {
+					upperBound = formatFloat(bucket.GetUpperBound())
+				}
+				bucketTags := append(tags[:len(tags):len(tags)], "upper_bound:"+upperBound)
+				c.submitDistributionCount(sender, name+".count", float64(bucket.GetCumulativeCount()), c.config.SendDistributionCountsAsMonotonic, hostname, bucketTags)
+			}
+		}
+	}
+}
+
+func (c *OpenMetricsCheck) submitDistributionCount(sender aggregator.Sender, name string, value float64, monotonic bool, hostname string, tags []string) {
+	if math.IsNaN(value) {
+		return
+	}
+	if monotonic {
+		sender.MonotonicCount(name, value, hostname, tags)
+	} else {
+		sender.Gauge(name, value, hostname, tags)
+	}
+}
+
+// submitHistogramBuckets submits the buckets of a histogram to be aggregated
+// in a distribution. The cumulative counts are converted to the counts of each
+// bucket, the values of the `+Inf` bucket being counted at its lower bound.
+func submitHistogramBuckets(sender aggregator.Sender, name string, histogram *dto.Histogram, hostname string, tags []string) {
+	lowerBound, previousCount := 0.0, uint64(0)
+	for _, bucket := range histogram.Bucket {
+		upperBound, count := bucket.GetUpperBound(), bucket.GetCumulativeCount()
+		if math.IsInf(upperBound, 1) {
+			upperBound = lowerBound
+		}
+		if lowerBound > upperBound {
+			lowerBound = upperBound
+		}
+		if count >= previousCount {
+			sender.HistogramBucket(name, int64(count-previousCount), lowerBound, upperBound, true, hostname, tags, false)
+			previousCount = count
+		}
+		lowerBound = upperBound
+	}
+
+	// the `+Inf` bucket is implicit in the protobuf format
+	if sampleCount := histogram.GetSampleCount(); sampleCount > previousCount && !hasInfBucket(histogram) {
+		sender.HistogramBucket(name, int64(sampleCount-previousCount), lowerBound, lowerBound, true, hostname, tags, false)
+	}
+}
+
+func hasInfBucket(histogram *dto.Histogram) bool {
+	buckets := histogram.Bucket
+	return len(buckets) > 0 && math.IsInf(buckets[len(buckets)-1].GetUpperBound(), 1)
+}
+
+// sampleValue returns the value of a counter, gauge or untyped sample, its type
+// being possibly overridden.
+func sampleValue(metric *dto.Metric) (float64, bool) {
+	var value float64
+	switch {
+	case metric.Gauge != nil:
+		value = metric.Gauge.GetValue()
+	case metric.Counter != nil:
+		value = metric.Counter.GetValue()
+	case metric.Untyped != nil:
+		value = metric.Untyped.GetValue()
+	default:
+		return 0, false
+	}
+	return value, !math.IsNaN(value)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
@@ -0,0 +1,12 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package sqlquery
+
+import (
+	// register the drivers of the databases in database/sql
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/lib/pq"
+)
@@ -0,0 +1,17 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+// +build cgo
+
+package sqlquery
+
+import (
+	// the SQLite driver embeds the C library
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func init() {
+	drivers["sqlite"] = "sqlite3"
+}
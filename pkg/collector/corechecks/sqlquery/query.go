@@ -0,0 +1,179 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package sqlquery
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/aggregator"
+)
+
+// intervalTolerance keeps a query from skipping a run when the check runs a
+// bit earlier than its interval after the previous run of the query.
+const intervalTolerance = time.Second
+
+type query struct {
+	config  *queryConfig
+	lastRun time.Time
+}
+
+func (q *query) isDue(now time.Time) bool {
+	interval := time.Duration(q.config.Interval) * time.Second
+	return q.lastRun.IsZero() || now.Sub(q.lastRun)+intervalTolerance >= interval
+}
+
+// run runs the query and submits the values of the columns of its rows.
+func (q *query) run(ctx context.Context, db *sql.DB, sender aggregator.Sender, namespace string) error {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(q.config.Timeout)*time.Second)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, q.config.Query)
+	if err != nil {
+		return scrubDriverError(err)
+	}
+	defer rows.Close()
+
+	names, err := rows.Columns()
+	if err != nil {
+		return scrubDriverError(err)
+	}
+	// the index of the configured columns in the rows
+	indexes := make([]int, len(q.config.Columns))
+	for i, column := range q.config.Columns {
+		indexes[i] = -1
+		for j, name := range names {
+			if strings.EqualFold(name, column.Name) {
+				indexes[i] = j
+				break
+			}
+		}
+		if indexes[i] < 0 {
+			return fmt.Errorf("column %s not found in the result", column.Name)
+		}
+	}
+
+	values := make([]interface{}, len(names))
+	pointers := make([]interface{}, len(names))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(pointers...); err != nil {
+			return scrubDriverError(err)
+		}
+		if err := q.submitRow(sender, namespace, indexes, values); err != nil {
+			return err
+		}
+	}
+	return scrubDriverError(rows.Err())
+}
+
+// quotedFragment matches the strings and identifiers quoted in the errors of the
+// drivers, e.g. `pq: syntax error at or near "'s3cret'"` or `near 's3cret' at line 1`
+var quotedFragment = regexp.MustCompile(`'(?:[^']|'')*'?|"[^"]*"?`)
+
+// scrubDriverError removes the quoted fragments of the errors of the drivers,
+// which can echo the literals of the queries or of the DSN.
+func scrubDriverError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return errors.New(quotedFragment.ReplaceAllString(err.Error(), "?"))
+}
+
+func (q *query) submitRow(sender aggregator.Sender, namespace string, indexes []int, values []interface{}) error {
+	tags := append([]string{}, q.config.Tags...)
+	for i, column := range q.config.Columns {
+		value := values[indexes[i]]
+		if column.Type != "tag" || value == nil {
+			continue
+		}
+		tags = append(tags, columnAlias(column)+":"+toString(value))
+	}
+
+	for i, column := range q.config.Columns {
+		value := values[indexes[i]]
+		if column.Type == "tag" || value == nil {
+			continue
+		}
+		f, err := toFloat(value)
+		if err != nil {
+			return fmt.Errorf("column %s: %v", column.Name, err)
+		}
+
+		name := columnAlias(column)
+		if namespace != "" {
+			name = namespace + "." + name
+		}
+		switch column.Type {
+		case "gauge":
+			sender.Gauge(name, f, "", tags)
+		case "count":
+			sender.Count(name, f, "", tags)
+		case "monotonic_count":
+			sender.MonotonicCount(name, f, "", tags)
+		case "rate":
+			sender.Rate(name, f, "", tags)
+		}
+	}
+	return nil
+}
+
+// columnAlias returns the name of the metric or tag of a column
+func columnAlias(column columnConfig) string {
+	if column.Alias != "" {
+		return column.Alias
+	}
+	return column.Name
+}
+
+// toFloat converts the values returned by the drivers to numbers. Some drivers
+// return the numeric types they don't map to Go types as text.
+func toFloat(value interface{}) (float64, error) {
+	switch v := value.(type) {
+	case int64:
+		return float64(v), nil
+	case float64:
+		return v, nil
+	case bool:
+		if v {
+			return 1, nil
+		}
+		return 0, nil
+	case []byte:
+		return parseFloat(string(v))
+	case string:
+		return parseFloat(v)
+	default:
+		return 0, fmt.Errorf("the value %v isn't a number", value)
+	}
+}
+
+func parseFloat(s string) (float64, error) {
+	f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil {
+		return 0, fmt.Errorf("the value %q isn't a number", s)
+	}
+	return f, nil
+}
+
+func toString(value interface{}) string {
+	switch v := value.(type) {
+	case []byte:
+		return string(v)
+	case time.Time:
+		return v.Format(time.RFC3339)
+	default:
+		return fmt.Sprint(v)
+	}
+}
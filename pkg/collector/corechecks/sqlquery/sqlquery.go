@@ -0,0 +1,228 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package sqlquery
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	yaml "gopkg.in/yaml.v2"
+
+	"github.com/DataDog/datadog-agent/pkg/aggregator"
+	"github.com/DataDog/datadog-agent/pkg/autodiscovery/integration"
+	"github.com/DataDog/datadog-agent/pkg/collector/check"
+	core "github.com/DataDog/datadog-agent/pkg/collector/corechecks"
+	"github.com/DataDog/datadog-agent/pkg/metrics"
+	"github.com/DataDog/datadog-agent/pkg/trace/obfuscate"
+)
+
+const (
+	checkName = "sql_query"
+
+	defaultTimeout = 10
+
+	canConnectServiceCheck = "sql_query.can_connect"
+)
+
+// drivers maps the drivers of the configuration to the names they are
+// registered with in database/sql
+var drivers = map[string]string{
+	"mysql":    "mysql",
+	"postgres": "postgres",
+}
+
+var columnTypes = map[string]bool{
+	"gauge":           true,
+	"count":           true,
+	"monotonic_count": true,
+	"rate":            true,
+	"tag":             true,
+}
+
+type columnConfig struct {
+	Name  string `yaml:"name"`
+	Type  string `yaml:"type"`
+	Alias string `yaml:"alias"`
+}
+
+type queryConfig struct {
+	Name     string         `yaml:"name"`
+	Query    string         `yaml:"query"`
+	Interval int            `yaml:"interval"`
+	Timeout  int            `yaml:"timeout"`
+	Columns  []columnConfig `yaml:"columns"`
+	Tags     []string       `yaml:"tags"`
+}
+
+type instanceConfig struct {
+	Driver    string        `yaml:"driver"`
+	DSN       string        `yaml:"dsn"`
+	Namespace string        `yaml:"namespace"`
+	Timeout   int           `yaml:"timeout"`
+	Queries   []queryConfig `yaml:"queries"`
+}
+
+// SQLQueryCheck runs SQL queries on a database, and submits the values of the
+// columns of their results as metrics.
+type SQLQueryCheck struct {
+	core.CheckBase
+	config     *instanceConfig
+	db         *sql.DB
+	queries    []*query
+	obfuscator *obfuscate.Obfuscator
+}
+
+func (c *instanceConfig) parse(data []byte) error {
+	c.Timeout = defaultTimeout
+
+	if err := yaml.Unmarshal(data, c); err != nil {
+		return err
+	}
+	if _, found := drivers[c.Driver]; !found {
+		supported := make([]string, 0, len(drivers))
+		for driver := range drivers {
+			supported = append(supported, driver)
+		}
+		sort.Strings(supported)
+		return fmt.Errorf("unsupported driver %q, the supported drivers are: %s", c.Driver, strings.Join(supported, ", "))
+	}
+	if c.DSN == "" {
+		return errors.New("dsn is required")
+	}
+	if len(c.Queries) == 0 {
+		return errors.New("at least one query must be set in queries")
+	}
+
+	for i := range c.Queries {
+		q := &c.Queries[i]
+		if q.Name == "" {
+			q.Name = fmt.Sprintf("#%d", i+1)
+		}
+		if q.Query == "" {
+			return fmt.Errorf("the text of query %s is required", q.Name)
+		}
+		if q.Timeout <= 0 {
+			q.Timeout = c.Timeout
+		}
+		if len(q.Columns) == 0 {
+			return fmt.Errorf("at least one column must be set for query %s", q.Name)
+		}
+		for _, column := range q.Columns {
+			if column.Name == "" {
+				return fmt.Errorf("the name of the columns of query %s is required", q.Name)
+			}
+			if !columnTypes[column.Type] {
+				return fmt.Errorf("invalid type %q for column %s of query %s", column.Type, column.Name, q.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// Configure parses the configuration of the check and opens the database
+func (c *SQLQueryCheck) Configure(data integration.Data, initConfig integration.Data, source string) error {
+	c.BuildID(data, initConfig)
+	if err := c.CommonConfigure(data, source); err != nil {
+		return err
+	}
+
+	cfg := &instanceConfig{}
+	if err := cfg.parse(data); err != nil {
+		return err
+	}
+	c.config = cfg
+
+	db, err := sql.Open(drivers[cfg.Driver], cfg.DSN)
+	if err != nil {
+		return err
+	}
+	// the queries run one at a time
+	db.SetMaxOpenConns(1)
+	c.db = db
+
+	c.queries = make([]*query, 0, len(cfg.Queries))
+	for i := range cfg.Queries {
+		c.queries = append(c.queries, &query{config: &cfg.Queries[i]})
+	}
+	c.obfuscator = obfuscate.NewObfuscator(nil)
+	return nil
+}
+
+// Run runs the queries that are due and submits their results
+func (c *SQLQueryCheck) Run() error {
+	return c.RunContext(context.Background())
+}
+
+// RunContext runs the queries that are due and submits their results, until
+// ctx is done. It implements check.ContextRunner, for the run timeout.
+func (c *SQLQueryCheck) RunContext(ctx context.Context) error {
+	sender, err := aggregator.GetSender(c.ID())
+	if err != nil {
+		return err
+	}
+	defer sender.Commit()
+
+	serviceCheckTags := []string{"driver:" + c.config.Driver}
+	pingCtx, cancel := context.WithTimeout(ctx, time.Duration(c.config.Timeout)*time.Second)
+	err = scrubDriverError(c.db.PingContext(pingCtx))
+	cancel()
+	if err != nil {
+		sender.ServiceCheck(canConnectServiceCheck, metrics.ServiceCheckCritical, "", serviceCheckTags, err.Error())
+		return err
+	}
+	sender.ServiceCheck(canConnectServiceCheck, metrics.ServiceCheckOK, "", serviceCheckTags, "")
+
+	now := time.Now()
+	for _, q := range c.queries {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if !q.isDue(now) {
+			continue
+		}
+		q.lastRun = now
+		if err := q.run(ctx, c.db, sender, c.config.Namespace); err != nil {
+			c.Warnf("Query %s failed: %v, query: %s", q.config.Name, err, c.obfuscate(q.config.Query)) //nolint:errcheck
+		}
+	}
+	return nil
+}
+
+// obfuscate returns the text of a query without its literals, to keep the
+// values it contains out of the logs.
+func (c *SQLQueryCheck) obfuscate(text string) string {
+	obfuscated, err := c.obfuscator.ObfuscateSQLString(text)
+	if err != nil {
+		return "<the query could not be obfuscated>"
+	}
+	return obfuscated.Query
+}
+
+// Cancel closes the database
+func (c *SQLQueryCheck) Cancel() {
+	if c.db != nil {
+		c.db.Close()
+	}
+	if c.obfuscator != nil {
+		c.obfuscator.Stop()
+	}
+	c.CommonCancel()
+}
+
+func sqlQueryFactory() check.Check {
+	return &SQLQueryCheck{
+		CheckBase: core.NewCheckBase(checkName),
+	}
+}
+
+func init() {
+	core.RegisterCheck(checkName, sqlQueryFactory)
+}
@@ -0,0 +1,201 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+// +build cgo
+
+package sqlquery
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/aggregator/mocksender"
+	"github.com/DataDog/datadog-agent/pkg/autodiscovery/integration"
+	"github.com/DataDog/datadog-agent/pkg/metrics"
+)
+
+func newTestDatabase(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "sqlquery")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	path := filepath.Join(dir, "test.db")
+	db, err := sql.Open("sqlite3", path)
+	require.NoError(t, err)
+	defer db.Close()
+	_, err = db.Exec(`
+CREATE TABLE jobs (queue TEXT, state TEXT, duration REAL);
+INSERT INTO jobs VALUES ('mails', 'done', 1.5), ('mails', 'done', 2.5), ('mails', 'failed', NULL), ('reports', 'done', 10);
+`)
+	require.NoError(t, err)
+	return path
+}
+
+func newTestCheck(t *testing.T, instance string) (*SQLQueryCheck, *mocksender.MockSender) {
+	c := sqlQueryFactory().(*SQLQueryCheck)
+	require.NoError(t, c.Configure(integration.Data(instance), nil, "test"))
+	t.Cleanup(c.Cancel)
+	sender := mocksender.NewMockSender(c.ID())
+	sender.SetupAcceptAll()
+	return c, sender
+}
+
+func TestRun(t *testing.T) {
+	c, sender := newTestCheck(t, fmt.Sprintf(`
+driver: sqlite
+dsn: %s
+namespace: app
+queries:
+  - name: jobs
+    query: SELECT queue, state, COUNT(*) AS total, SUM(duration) AS duration FROM jobs GROUP BY queue, state
+    tags: [source:jobs]
+    columns:
+      - name: queue
+        type: tag
+      - name: state
+        type: tag
+        alias: job_state
+      - name: total
+        type: gauge
+        alias: jobs.count
+      - name: duration
+        type: monotonic_count
+        alias: jobs.duration
+  - name: hourly
+    query: SELECT 3 AS backups
+    interval: 3600
+    columns:
+      - name: BACKUPS
+        type: gauge
+`, newTestDatabase(t)))
+
+	require.NoError(t, c.Run())
+	sender.AssertServiceCheck(t, "sql_query.can_connect", metrics.ServiceCheckOK, "", []string{"driver:sqlite"}, "")
+	sender.AssertMetric(t, "Gauge", "app.jobs.count", 2, "", []string{"source:jobs", "queue:mails", "job_state:done"})
+	sender.AssertMetric(t, "Gauge", "app.jobs.count", 1, "", []string{"source:jobs", "queue:mails", "job_state:failed"})
+	sender.AssertMetric(t, "Gauge", "app.jobs.count", 1, "", []string{"source:jobs", "queue:reports", "job_state:done"})
+	sender.AssertMetric(t, "MonotonicCount", "app.jobs.duration", 4, "", []string{"source:jobs", "queue:mails", "job_state:done"})
+	sender.AssertMetric(t, "MonotonicCount", "app.jobs.duration", 10, "", []string{"source:jobs", "queue:reports", "job_state:done"})
+	// the NULL values are skipped
+	sender.AssertNumberOfCalls(t, "MonotonicCount", 2)
+	sender.AssertMetric(t, "Gauge", "app.BACKUPS", 3, "", []string{})
+	sender.AssertNumberOfCalls(t, "Commit", 1)
+	assert.Empty(t, c.GetWarnings())
+
+	// the hourly query isn't due
+	sender.ResetCalls()
+	require.NoError(t, c.Run())
+	sender.AssertNumberOfCalls(t, "Gauge", 3)
+	sender.AssertNotCalled(t, "Gauge", "app.BACKUPS", mock.Anything, mock.Anything, mock.Anything)
+
+	c.queries[1].lastRun = time.Now().Add(-time.Hour)
+	sender.ResetCalls()
+	require.NoError(t, c.Run())
+	sender.AssertNumberOfCalls(t, "Gauge", 4)
+}
+
+func TestRunQueryErrors(t *testing.T) {
+	c, sender := newTestCheck(t, fmt.Sprintf(`
+driver: sqlite
+dsn: %s
+queries:
+  - query: SELECT COUNT(*) AS total FROM users WHERE password = 'hunter2'
+    columns:
+      - name: total
+        type: gauge
+  - query: SELECT queue AS total FROM jobs
+    columns:
+      - name: total
+        type: gauge
+  - name: missing column
+    query: SELECT COUNT(*) FROM jobs
+    columns:
+      - name: total
+        type: gauge
+  - name: syntax error
+    query: SELECT COUNT(*) AS total FROM jobs WHERE queue = "s3cret" AND state = 'hunter2
+    columns:
+      - name: total
+        type: gauge
+  - name: working
+    query: SELECT COUNT(*) AS total FROM jobs
+    columns:
+      - name: total
+        type: gauge
+`, newTestDatabase(t)))
+
+	// a failing query doesn't prevent the other ones from running
+	require.NoError(t, c.Run())
+	sender.AssertMetric(t, "Gauge", "total", 4, "", []string{})
+	sender.AssertNumberOfCalls(t, "Gauge", 1)
+
+	warnings := c.GetWarnings()
+	require.Len(t, warnings, 4)
+	assert.Contains(t, warnings[0].Error(), "Query #1 failed: no such table: users")
+	assert.Contains(t, warnings[0].Error(), "password = ?")
+	assert.NotContains(t, warnings[0].Error(), "hunter2")
+	assert.Contains(t, warnings[1].Error(), `Query #2 failed: column total: the value "mails" isn't a number`)
+	assert.Contains(t, warnings[2].Error(), "Query missing column failed: column total not found in the result")
+	// the drivers echo the literals of the queries in their errors
+	assert.Contains(t, warnings[3].Error(), "Query syntax error failed: unrecognized token: ?")
+	assert.NotContains(t, warnings[3].Error(), "hunter2")
+	assert.NotContains(t, warnings[3].Error(), "s3cret")
+}
+
+func TestScrubDriverError(t *testing.T) {
+	for err, expected := range map[string]string{
+		`pq: syntax error at or near "'s3cret'"`:                                                    `pq: syntax error at or near ?`,
+		`Error 1064: You have an error in your SQL syntax; near 's3cret' AND b = 'it''s' at line 1`: `Error 1064: You have an error in your SQL syntax; near ? AND b = ? at line 1`,
+		`pq: password authentication failed for user "datadog"`:                                     `pq: password authentication failed for user ?`,
+		`unrecognized token: "'s3cret"`:                                                             `unrecognized token: ?`,
+		`no such table: users`:                                                                      `no such table: users`,
+	} {
+		assert.Equal(t, expected, scrubDriverError(errors.New(err)).Error())
+	}
+	assert.NoError(t, scrubDriverError(nil))
+}
+
+func TestRunConnectionFailure(t *testing.T) {
+	c, sender := newTestCheck(t, `
+driver: sqlite
+dsn: file:/nonexistent/test.db?mode=ro
+queries:
+  - query: SELECT 1 AS one
+    columns:
+      - name: one
+        type: gauge
+`)
+
+	assert.Error(t, c.Run())
+	sender.AssertServiceCheck(t, "sql_query.can_connect", metrics.ServiceCheckCritical, "", []string{"driver:sqlite"}, mock.Anything)
+	sender.AssertNumberOfCalls(t, "Gauge", 0)
+}
+
+func TestConfigureErrors(t *testing.T) {
+	query := "queries:\n  - query: SELECT 1 AS one\n    columns:\n      - name: one\n        type: gauge\n"
+	for name, instance := range map[string]string{
+		"unsupported driver": "driver: oracle\ndsn: foo\n" + query,
+		"missing dsn":        "driver: postgres\n" + query,
+		"missing queries":    "driver: postgres\ndsn: foo",
+		"missing columns":    "driver: postgres\ndsn: foo\nqueries:\n  - query: SELECT 1",
+		"missing text":       "driver: postgres\ndsn: foo\nqueries:\n  - columns:\n      - name: one\n        type: gauge",
+		"invalid type":       "driver: postgres\ndsn: foo\nqueries:\n  - query: SELECT 1 AS one\n    columns:\n      - name: one\n        type: histogram",
+	} {
+		t.Run(name, func(t *testing.T) {
+			c := sqlQueryFactory()
+			assert.Error(t, c.Configure(integration.Data(instance), nil, "test"))
+		})
+	}
+}
@@ -0,0 +1,188 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package wasm
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	yaml "gopkg.in/yaml.v2"
+
+	"github.com/DataDog/datadog-agent/pkg/aggregator"
+	"github.com/DataDog/datadog-agent/pkg/autodiscovery/integration"
+	"github.com/DataDog/datadog-agent/pkg/collector/corechecks"
+	"github.com/DataDog/datadog-agent/pkg/util"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// WASMCheck runs a check compiled to WebAssembly. The module of the check
+// exports a `dd_check_run` function, and optionally a `dd_check_configure`
+// function called when the module is instantiated, both taking no parameter
+// and returning 0 on success. It submits its data and reads its configuration
+// with the functions of the `datadog` host module.
+type WASMCheck struct {
+	corechecks.CheckBase
+	code           []byte
+	newInstance    instanceFactory
+	instance       instance
+	instanceConfig map[string]interface{}
+	initConfig     map[string]interface{}
+
+	// set during a call to the module
+	sender    aggregator.Sender
+	callError error // set by the module with set_error
+	hostErr   error // set when a host function fails
+
+	cancelM sync.Mutex
+	cancel  context.CancelFunc // cancels the current call
+}
+
+func newWASMCheck(name string, code []byte, factory instanceFactory) *WASMCheck {
+	return &WASMCheck{
+		CheckBase:   corechecks.NewCheckBase(name),
+		code:        code,
+		newInstance: factory,
+	}
+}
+
+// Configure parses the configuration of the check and instantiates its module
+func (c *WASMCheck) Configure(data integration.Data, initConfig integration.Data, source string) error {
+	c.BuildID(data, initConfig)
+	if err := c.CheckBase.Configure(data, initConfig, source); err != nil {
+		return err
+	}
+
+	var err error
+	if c.instanceConfig, err = parseSection(data); err != nil {
+		return fmt.Errorf("invalid instance section: %v", err)
+	}
+	if c.initConfig, err = parseSection(initConfig); err != nil {
+		return fmt.Errorf("invalid init_config section: %v", err)
+	}
+
+	return c.instantiate()
+}
+
+// Run runs the check
+func (c *WASMCheck) Run() error {
+	// the module is instantiated again after a failure
+	if c.instance == nil {
+		if err := c.instantiate(); err != nil {
+			return err
+		}
+	}
+
+	sender, err := aggregator.GetSender(c.ID())
+	if err != nil {
+		return err
+	}
+	if err := c.call(runFunction, true); err != nil {
+		return err
+	}
+	sender.Commit()
+	return nil
+}
+
+// Stop interrupts the current run of the check
+func (c *WASMCheck) Stop() {
+	c.cancelM.Lock()
+	defer c.cancelM.Unlock()
+
+	if c.cancel != nil {
+		c.cancel()
+	}
+}
+
+// Cancel releases the module instance of the check
+func (c *WASMCheck) Cancel() {
+	c.closeInstance()
+	c.CommonCancel()
+}
+
+// instantiate instantiates the module of the check, and calls its configure
+// function.
+func (c *WASMCheck) instantiate() error {
+	inst, err := c.newInstance(context.Background(), c, c.code)
+	if err != nil {
+		return fmt.Errorf("could not instantiate the module: %v", err)
+	}
+	c.instance = inst
+
+	if err := c.call(configureFunction, false); err != nil {
+		c.closeInstance()
+		return err
+	}
+	return nil
+}
+
+func (c *WASMCheck) closeInstance() {
+	if c.instance == nil {
+		return
+	}
+	if err := c.instance.close(); err != nil {
+		log.Debugf("Check %s: error closing the module instance: %v", c, err)
+	}
+	c.instance = nil
+}
+
+// call calls a function exported by the module of the check. The instance is
+// closed when the call fails in the runtime, e.g. when it traps or is
+// interrupted.
+func (c *WASMCheck) call(name string, required bool) error {
+	sender, err := aggregator.GetSender(c.ID())
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c.cancelM.Lock()
+	c.cancel = cancel
+	c.cancelM.Unlock()
+	defer func() {
+		c.cancelM.Lock()
+		c.cancel = nil
+		c.cancelM.Unlock()
+		cancel()
+	}()
+
+	c.sender, c.callError, c.hostErr = sender, nil, nil
+	result, found, err := c.instance.call(ctx, name)
+	if err != nil {
+		c.closeInstance()
+		return fmt.Errorf("%s failed: %v", name, err)
+	}
+	if !found {
+		if required {
+			return fmt.Errorf("the module doesn't export a %s function", name)
+		}
+		return nil
+	}
+	if c.hostErr != nil {
+		return c.hostErr
+	}
+	if result != 0 {
+		if c.callError != nil {
+			return c.callError
+		}
+		return fmt.Errorf("%s returned %d", name, result)
+	}
+	return nil
+}
+
+// parseSection parses a section of the configuration so it can be encoded to JSON
+func parseSection(data integration.Data) (map[string]interface{}, error) {
+	raw := integration.RawMap{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	section := map[string]interface{}{}
+	for key, value := range raw {
+		section[fmt.Sprint(key)] = util.GetJSONSerializableMap(value)
+	}
+	return section, nil
+}
@@ -0,0 +1,255 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package wasm
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/aggregator/mocksender"
+	"github.com/DataDog/datadog-agent/pkg/autodiscovery/integration"
+	"github.com/DataDog/datadog-agent/pkg/collector/check"
+	"github.com/DataDog/datadog-agent/pkg/metrics"
+)
+
+// fakeMemory is the memory of a fake module, the strings it passes to the
+// host functions being appended to it.
+type fakeMemory struct {
+	data []byte
+}
+
+func (m *fakeMemory) Read(offset, byteCount uint32) ([]byte, bool) {
+	if uint64(offset)+uint64(byteCount) > uint64(len(m.data)) {
+		return nil, false
+	}
+	return m.data[offset : offset+byteCount], true
+}
+
+func (m *fakeMemory) Write(offset uint32, v []byte) bool {
+	if uint64(offset)+uint64(len(v)) > uint64(len(m.data)) {
+		return false
+	}
+	copy(m.data[offset:], v)
+	return true
+}
+
+func (m *fakeMemory) put(s string) (uint32, uint32) {
+	ptr := uint32(len(m.data))
+	m.data = append(m.data, s...)
+	return ptr, uint32(len(s))
+}
+
+func (m *fakeMemory) alloc(size uint32) uint32 {
+	ptr := uint32(len(m.data))
+	m.data = append(m.data, make([]byte, size)...)
+	return ptr
+}
+
+// fakeInstance runs the exported functions of a fake module as Go functions
+type fakeInstance struct {
+	functions map[string]func(mem *fakeMemory) int32
+	closed    bool
+}
+
+func (i *fakeInstance) call(ctx context.Context, name string) (int32, bool, error) {
+	f, found := i.functions[name]
+	if !found {
+		return 0, false, nil
+	}
+	return f(&fakeMemory{}), true, nil
+}
+
+func (i *fakeInstance) close() error {
+	i.closed = true
+	return nil
+}
+
+func fakeFactory(instances *[]*fakeInstance, functions func(c *WASMCheck) map[string]func(mem *fakeMemory) int32) instanceFactory {
+	return func(ctx context.Context, c *WASMCheck, code []byte) (instance, error) {
+		if string(code) != "module" {
+			return nil, errors.New("invalid module")
+		}
+		inst := &fakeInstance{functions: functions(c)}
+		*instances = append(*instances, inst)
+		return inst, nil
+	}
+}
+
+func getConfig(c *WASMCheck, mem *fakeMemory, scope uint32, key string) (string, int32) {
+	keyPtr, keyLen := mem.put(key)
+	// the first call returns the size of the buffer to allocate
+	size := c.getConfig(mem, scope, keyPtr, keyLen, 0, 0)
+	if size < 0 {
+		return "", size
+	}
+	buffer := mem.alloc(uint32(size))
+	size = c.getConfig(mem, scope, keyPtr, keyLen, buffer, uint32(size))
+	value, _ := mem.Read(buffer, uint32(size))
+	return string(value), size
+}
+
+func TestLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wasm-checks")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "foo.wasm"), []byte("module"), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "bar.wasm"), []byte("invalid"), 0644))
+
+	var instances []*fakeInstance
+	var configured []string
+	loader := &WASMCheckLoader{
+		checksPath: dir,
+		newInstance: fakeFactory(&instances, func(c *WASMCheck) map[string]func(mem *fakeMemory) int32 {
+			return map[string]func(mem *fakeMemory) int32{
+				configureFunction: func(mem *fakeMemory) int32 {
+					value, _ := getConfig(c, mem, configScopeInstance, "url")
+					configured = append(configured, value)
+					if value == `"invalid"` {
+						msgPtr, msgLen := mem.put("invalid url")
+						c.setError(mem, msgPtr, msgLen)
+						return 1
+					}
+					return 0
+				},
+			}
+		}),
+	}
+
+	instance := integration.Data("url: http://localhost")
+	initConfig := integration.Data("timeout: 5")
+	mocksender.NewMockSender(check.BuildID("foo", instance, initConfig)).SetupAcceptAll()
+
+	c, err := loader.Load(integration.Config{Name: "foo", InitConfig: initConfig}, instance)
+	require.NoError(t, err)
+	assert.Equal(t, check.BuildID("foo", instance, initConfig), c.ID())
+	assert.Equal(t, []string{`"http://localhost"`}, configured)
+
+	// the module of the check can't be found
+	_, err = loader.Load(integration.Config{Name: "baz"}, instance)
+	assert.Error(t, err)
+
+	// the module of the check is invalid
+	_, err = loader.Load(integration.Config{Name: "bar"}, instance)
+	assert.Error(t, err)
+
+	// the configure function of the module fails
+	instance = integration.Data("url: invalid")
+	mocksender.NewMockSender(check.BuildID("foo", instance, nil)).SetupAcceptAll()
+	_, err = loader.Load(integration.Config{Name: "foo"}, instance)
+	assert.EqualError(t, err, "Could not configure check foo: invalid url")
+	require.Len(t, instances, 2)
+	assert.True(t, instances[1].closed)
+}
+
+func TestGetConfig(t *testing.T) {
+	c := newWASMCheck("foo", nil, nil)
+	var err error
+	c.instanceConfig, err = parseSection(integration.Data("url: http://localhost\nports: [80, 443]\nauth:\n  user: datadog\n"))
+	require.NoError(t, err)
+	c.initConfig, err = parseSection(integration.Data(""))
+	require.NoError(t, err)
+	mem := &fakeMemory{}
+
+	value, _ := getConfig(c, mem, configScopeInstance, "ports")
+	assert.Equal(t, "[80,443]", value)
+	value, _ = getConfig(c, mem, configScopeInstance, "auth")
+	assert.Equal(t, `{"user":"datadog"}`, value)
+	value, _ = getConfig(c, mem, configScopeInstance, "")
+	assert.JSONEq(t, `{"url":"http://localhost","ports":[80,443],"auth":{"user":"datadog"}}`, value)
+	value, _ = getConfig(c, mem, configScopeInitConfig, "")
+	assert.Equal(t, "{}", value)
+
+	_, code := getConfig(c, mem, configScopeInstance, "unknown")
+	assert.Equal(t, errCodeNotFound, code)
+	_, code = getConfig(c, mem, 2, "url")
+	assert.Equal(t, errCodeInvalidValue, code)
+
+	// the buffer is out of the memory of the module
+	keyPtr, keyLen := mem.put("url")
+	assert.Equal(t, errCodeInvalidMemory, c.getConfig(mem, configScopeInstance, keyPtr, keyLen, 1<<20, 100))
+	assert.Error(t, c.hostErr)
+}
+
+func TestRun(t *testing.T) {
+	var instances []*fakeInstance
+	var fail bool
+	c := newWASMCheck("foo", []byte("module"), fakeFactory(&instances, func(c *WASMCheck) map[string]func(mem *fakeMemory) int32 {
+		return map[string]func(mem *fakeMemory) int32{
+			runFunction: func(mem *fakeMemory) int32 {
+				namePtr, nameLen := mem.put("foo.metric")
+				tagsPtr, tagsLen := mem.put("env:prod\nservice:foo")
+				hostnamePtr, hostnameLen := mem.put("host")
+				c.submitMetric(mem, metricTypeGauge, namePtr, nameLen, 42, tagsPtr, tagsLen, hostnamePtr, hostnameLen)
+				c.submitMetric(mem, metricTypeMonotonicCount, namePtr, nameLen, 10, 0, 0, 0, 0)
+
+				namePtr, nameLen = mem.put("foo.can_connect")
+				messagePtr, messageLen := mem.put("connection refused")
+				c.submitServiceCheck(mem, namePtr, nameLen, 2, tagsPtr, tagsLen, 0, 0, messagePtr, messageLen)
+
+				eventPtr, eventLen := mem.put(`{"msg_title":"title","msg_text":"text","timestamp":1600000000,"alert_type":"error","tags":["env:prod"]}`)
+				if code := c.submitEvent(mem, eventPtr, eventLen); code != 0 {
+					return code
+				}
+				eventPtr, eventLen = mem.put(`{"msg_title":"title","priority":"urgent"}`)
+				if code := c.submitEvent(mem, eventPtr, eventLen); code != errCodeInvalidValue {
+					return 1
+				}
+
+				if fail {
+					// the name of the metric is out of the memory of the module
+					c.submitMetric(mem, metricTypeGauge, 1<<20, 10, 1, 0, 0, 0, 0)
+				}
+				return 0
+			},
+		}
+	}))
+
+	mocksender.NewMockSender(check.BuildID("foo", nil, nil)).SetupAcceptAll()
+	require.NoError(t, c.Configure(nil, nil, "test"))
+	sender := mocksender.NewMockSender(c.ID())
+	sender.SetupAcceptAll()
+
+	require.NoError(t, c.Run())
+	sender.AssertMetric(t, "Gauge", "foo.metric", 42, "host", []string{"env:prod", "service:foo"})
+	sender.AssertMetric(t, "MonotonicCount", "foo.metric", 10, "", []string(nil))
+	sender.AssertServiceCheck(t, "foo.can_connect", metrics.ServiceCheckCritical, "", []string{"env:prod", "service:foo"}, "connection refused")
+	sender.AssertEvent(t, metrics.Event{
+		Title:     "title",
+		Text:      "text",
+		Ts:        1600000000,
+		AlertType: metrics.EventAlertTypeError,
+		Tags:      []string{"env:prod"},
+	}, 0)
+	sender.AssertNumberOfCalls(t, "Event", 1)
+	sender.AssertNumberOfCalls(t, "Commit", 1)
+
+	// a failing host function fails the run
+	fail = true
+	assert.EqualError(t, c.Run(), "submit_metric failed: invalid memory access")
+	sender.AssertNumberOfCalls(t, "Commit", 1)
+
+	c.Cancel()
+	require.Len(t, instances, 1)
+	assert.True(t, instances[0].closed)
+}
+
+func TestRunMissingFunction(t *testing.T) {
+	var instances []*fakeInstance
+	c := newWASMCheck("foo", []byte("module"), fakeFactory(&instances, func(c *WASMCheck) map[string]func(mem *fakeMemory) int32 {
+		return nil
+	}))
+
+	mocksender.NewMockSender(check.BuildID("foo", nil, nil)).SetupAcceptAll()
+	require.NoError(t, c.Configure(nil, nil, "test"))
+	assert.EqualError(t, c.Run(), "the module doesn't export a dd_check_run function")
+}
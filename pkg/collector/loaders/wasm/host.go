@@ -0,0 +1,267 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package wasm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/DataDog/datadog-agent/pkg/metrics"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// The metric types of submit_metric, same as the ones of the Python checks
+const (
+	metricTypeGauge uint32 = iota
+	metricTypeRate
+	metricTypeCount
+	metricTypeMonotonicCount
+	metricTypeCounter
+	metricTypeHistogram
+	metricTypeHistorate
+)
+
+// The log levels of log
+const (
+	logLevelTrace uint32 = iota
+	logLevelDebug
+	logLevelInfo
+	logLevelWarn
+	logLevelError
+)
+
+// The scopes of get_config
+const (
+	configScopeInstance uint32 = iota
+	configScopeInitConfig
+)
+
+// The error codes returned by the host functions
+const (
+	errCodeNotFound      int32 = -1
+	errCodeInvalidMemory int32 = -2
+	errCodeInvalidValue  int32 = -3
+)
+
+var errInvalidMemory = errors.New("invalid memory access")
+
+// The functions below implement the host API provided to the checks in the
+// `datadog` module. The strings are passed as a pointer and a length in the
+// memory of the module, and the lists of tags as newline-separated strings.
+
+func readString(mem memory, ptr, length uint32) (string, error) {
+	if length == 0 {
+		return "", nil
+	}
+	b, ok := mem.Read(ptr, length)
+	if !ok {
+		return "", errInvalidMemory
+	}
+	return string(b), nil
+}
+
+func readTags(mem memory, ptr, length uint32) ([]string, error) {
+	s, err := readString(mem, ptr, length)
+	if err != nil || s == "" {
+		return nil, err
+	}
+	return strings.Split(s, "\n"), nil
+}
+
+// submitMetric implements `submit_metric(type, name, value, tags, hostname)`
+func (c *WASMCheck) submitMetric(mem memory, metricType, namePtr, nameLen uint32, value float64, tagsPtr, tagsLen, hostnamePtr, hostnameLen uint32) {
+	name, err := readString(mem, namePtr, nameLen)
+	if err != nil {
+		c.hostError("submit_metric", err)
+		return
+	}
+	tags, err := readTags(mem, tagsPtr, tagsLen)
+	if err != nil {
+		c.hostError("submit_metric", err)
+		return
+	}
+	hostname, err := readString(mem, hostnamePtr, hostnameLen)
+	if err != nil {
+		c.hostError("submit_metric", err)
+		return
+	}
+
+	switch metricType {
+	case metricTypeGauge:
+		c.sender.Gauge(name, value, hostname, tags)
+	case metricTypeRate:
+		c.sender.Rate(name, value, hostname, tags)
+	case metricTypeCount:
+		c.sender.Count(name, value, hostname, tags)
+	case metricTypeMonotonicCount:
+		c.sender.MonotonicCount(name, value, hostname, tags)
+	case metricTypeCounter:
+		c.sender.Counter(name, value, hostname, tags)
+	case metricTypeHistogram:
+		c.sender.Histogram(name, value, hostname, tags)
+	case metricTypeHistorate:
+		c.sender.Historate(name, value, hostname, tags)
+	default:
+		c.hostError("submit_metric", fmt.Errorf("unknown metric type %d for metric %s", metricType, name))
+	}
+}
+
+// submitServiceCheck implements `submit_service_check(name, status, tags, hostname, message)`
+func (c *WASMCheck) submitServiceCheck(mem memory, namePtr, nameLen, status, tagsPtr, tagsLen, hostnamePtr, hostnameLen, messagePtr, messageLen uint32) {
+	name, err := readString(mem, namePtr, nameLen)
+	if err != nil {
+		c.hostError("submit_service_check", err)
+		return
+	}
+	tags, err := readTags(mem, tagsPtr, tagsLen)
+	if err != nil {
+		c.hostError("submit_service_check", err)
+		return
+	}
+	hostname, err := readString(mem, hostnamePtr, hostnameLen)
+	if err != nil {
+		c.hostError("submit_service_check", err)
+		return
+	}
+	message, err := readString(mem, messagePtr, messageLen)
+	if err != nil {
+		c.hostError("submit_service_check", err)
+		return
+	}
+	serviceCheckStatus, err := metrics.GetServiceCheckStatus(int(status))
+	if err != nil {
+		c.hostError("submit_service_check", fmt.Errorf("%v for service check %s: %d", err, name, status))
+		return
+	}
+
+	c.sender.ServiceCheck(name, serviceCheckStatus, hostname, tags, message)
+}
+
+// submitEvent implements `submit_event(event) -> i32`, the event being a JSON
+// object with the same fields as the events of the Python checks.
+func (c *WASMCheck) submitEvent(mem memory, eventPtr, eventLen uint32) int32 {
+	raw, err := readString(mem, eventPtr, eventLen)
+	if err != nil {
+		c.hostError("submit_event", err)
+		return errCodeInvalidMemory
+	}
+
+	event := metrics.Event{}
+	if err := json.Unmarshal([]byte(raw), &event); err != nil {
+		log.Warnf("Check %s submitted an invalid event: %v", c, err)
+		return errCodeInvalidValue
+	}
+	if event.Priority != "" {
+		if _, err := metrics.GetEventPriorityFromString(string(event.Priority)); err != nil {
+			log.Warnf("Check %s submitted an invalid event: %v", c, err)
+			return errCodeInvalidValue
+		}
+	}
+	if event.AlertType != "" {
+		if _, err := metrics.GetAlertTypeFromString(string(event.AlertType)); err != nil {
+			log.Warnf("Check %s submitted an invalid event: %v", c, err)
+			return errCodeInvalidValue
+		}
+	}
+
+	c.sender.Event(event)
+	return 0
+}
+
+// getConfig implements `get_config(scope, key, buffer, capacity) -> i32`. It
+// writes the JSON value of a key of the instance or init_config, or of the whole
+// section when the key is empty, and returns its length. The value isn't
+// written when it doesn't fit in the buffer, the module calling the function
+// again with a large enough buffer.
+func (c *WASMCheck) getConfig(mem memory, scope, keyPtr, keyLen, bufferPtr, bufferCap uint32) int32 {
+	key, err := readString(mem, keyPtr, keyLen)
+	if err != nil {
+		c.hostError("get_config", err)
+		return errCodeInvalidMemory
+	}
+
+	var section map[string]interface{}
+	switch scope {
+	case configScopeInstance:
+		section = c.instanceConfig
+	case configScopeInitConfig:
+		section = c.initConfig
+	default:
+		return errCodeInvalidValue
+	}
+
+	var value interface{} = section
+	if key != "" {
+		var found bool
+		if value, found = section[key]; !found {
+			return errCodeNotFound
+		}
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		log.Warnf("Check %s: can't encode the value of config key %q: %v", c, key, err)
+		return errCodeInvalidValue
+	}
+
+	if uint32(len(encoded)) <= bufferCap && !mem.Write(bufferPtr, encoded) {
+		c.hostError("get_config", errInvalidMemory)
+		return errCodeInvalidMemory
+	}
+	return int32(len(encoded))
+}
+
+// log implements `log(level, message)`
+func (c *WASMCheck) log(mem memory, level, messagePtr, messageLen uint32) {
+	message, err := readString(mem, messagePtr, messageLen)
+	if err != nil {
+		c.hostError("log", err)
+		return
+	}
+
+	switch level {
+	case logLevelTrace:
+		log.Tracef("Check %s: %s", c, message)
+	case logLevelDebug:
+		log.Debugf("Check %s: %s", c, message)
+	case logLevelInfo:
+		log.Infof("Check %s: %s", c, message)
+	case logLevelWarn:
+		log.Warnf("Check %s: %s", c, message)
+	default:
+		log.Errorf("Check %s: %s", c, message)
+	}
+}
+
+// warning implements `warning(message)`, the warning being shown in the status
+func (c *WASMCheck) warning(mem memory, messagePtr, messageLen uint32) {
+	message, err := readString(mem, messagePtr, messageLen)
+	if err != nil {
+		c.hostError("warning", err)
+		return
+	}
+	c.Warnf("Check %s: %s", c, message) //nolint:errcheck
+}
+
+// setError implements `set_error(message)`, the message being the error of the
+// current call when the function returns a non-zero value.
+func (c *WASMCheck) setError(mem memory, messagePtr, messageLen uint32) {
+	message, err := readString(mem, messagePtr, messageLen)
+	if err != nil {
+		c.hostError("set_error", err)
+		return
+	}
+	c.callError = errors.New(message)
+}
+
+// hostError records the error of a host function, failing the current call.
+func (c *WASMCheck) hostError(function string, err error) {
+	log.Debugf("Check %s: %s failed: %v", c, function, err)
+	if c.hostErr == nil {
+		c.hostErr = fmt.Errorf("%s failed: %v", function, err)
+	}
+}
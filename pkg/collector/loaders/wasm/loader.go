@@ -0,0 +1,81 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package wasm
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/DataDog/datadog-agent/pkg/autodiscovery/integration"
+	"github.com/DataDog/datadog-agent/pkg/collector/check"
+	"github.com/DataDog/datadog-agent/pkg/collector/loaders"
+	"github.com/DataDog/datadog-agent/pkg/config"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// the modules take precedence over the Python and Go checks with the same name
+const loaderOrder = 15
+
+// registerLoader registers the loader, once a runtime is set by the build tags
+func registerLoader() {
+	factory := func() (check.Loader, error) {
+		return NewWASMCheckLoader()
+	}
+
+	loaders.RegisterLoader(loaderOrder, factory)
+}
+
+// WASMCheckLoader is a specific loader for the checks compiled to WebAssembly,
+// their modules being named `<check name>.wasm` in the custom checks directory.
+type WASMCheckLoader struct {
+	checksPath  string
+	newInstance instanceFactory
+}
+
+// NewWASMCheckLoader creates a loader for WebAssembly checks
+func NewWASMCheckLoader() (*WASMCheckLoader, error) {
+	if newInstance == nil {
+		return nil, errors.New("the agent was built without WebAssembly support")
+	}
+	return &WASMCheckLoader{
+		checksPath:  config.Datadog.GetString("additional_checksd"),
+		newInstance: newInstance,
+	}, nil
+}
+
+// Name returns WASM loader name
+func (wl *WASMCheckLoader) Name() string {
+	return "wasm"
+}
+
+// Load returns a WebAssembly check
+func (wl *WASMCheckLoader) Load(config integration.Config, instance integration.Data) (check.Check, error) {
+	var c check.Check
+
+	path := filepath.Join(wl.checksPath, config.Name+".wasm")
+	code, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return c, fmt.Errorf("unable to find a WebAssembly module for check %s in %s", config.Name, wl.checksPath)
+		}
+		return c, fmt.Errorf("unable to read the WebAssembly module of check %s: %v", config.Name, err)
+	}
+
+	wc := newWASMCheck(config.Name, code, wl.newInstance)
+	if err := wc.Configure(instance, config.InitConfig, config.Source); err != nil {
+		log.Errorf("wasm.loader: could not configure check %s: %s", wc, err)
+		return c, fmt.Errorf("Could not configure check %s: %s", wc, err)
+	}
+
+	return wc, nil
+}
+
+func (wl *WASMCheckLoader) String() string {
+	return "WASM Check Loader"
+}
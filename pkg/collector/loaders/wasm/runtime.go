@@ -0,0 +1,40 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package wasm
+
+import (
+	"context"
+)
+
+const (
+	// hostModuleName is the name of the module providing the host API to the checks
+	hostModuleName = "datadog"
+
+	// the functions exported by the modules of the checks
+	configureFunction = "dd_check_configure"
+	runFunction       = "dd_check_run"
+)
+
+// memory is the linear memory of a module instance
+type memory interface {
+	Read(offset, byteCount uint32) ([]byte, bool)
+	Write(offset uint32, v []byte) bool
+}
+
+// instance is a module instantiated in its own sandbox
+type instance interface {
+	// call calls an exported function taking no parameter and returning an
+	// i32, found is false when the module doesn't export the function
+	call(ctx context.Context, name string) (result int32, found bool, err error)
+	close() error
+}
+
+// instanceFactory compiles and instantiates the module of a check, its imports
+// from the host module being bound to the check.
+type instanceFactory func(ctx context.Context, c *WASMCheck, code []byte) (instance, error)
+
+// newInstance is set when the agent is built with a WebAssembly runtime
+var newInstance instanceFactory
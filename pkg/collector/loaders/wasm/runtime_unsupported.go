@@ -0,0 +1,12 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+// +build wasmcheck,!go1.18
+
+package wasm
+
+// The wazero runtime requires Go 1.18: fail the build instead of leaving the
+// loader out of a binary built with the wasmcheck tag.
+var _ = wasmcheckBuildTagRequiresGo118
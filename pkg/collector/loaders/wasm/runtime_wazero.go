@@ -0,0 +1,143 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+// +build wasmcheck,go1.18
+
+package wasm
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+
+	"github.com/DataDog/datadog-agent/pkg/config"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// the memory of the modules is allocated in pages of 64 KiB
+const pagesPerMiB = 16
+
+func init() {
+	newInstance = newWazeroInstance
+	registerLoader()
+}
+
+// wazeroInstance is a module instantiated in its own wazero runtime. The module
+// can use WASI, without access to the filesystem, the environment variables or
+// the network, its standard outputs being logged.
+type wazeroInstance struct {
+	runtime wazero.Runtime
+	module  api.Module
+}
+
+func newWazeroInstance(ctx context.Context, c *WASMCheck, code []byte) (instance, error) {
+	// the calls are interrupted when their context is done, e.g. when the check is stopped
+	runtimeConfig := wazero.NewRuntimeConfig().WithCloseOnContextDone(true)
+	if limit := config.Datadog.GetInt("wasm_check_memory_limit"); limit > 0 {
+		runtimeConfig = runtimeConfig.WithMemoryLimitPages(uint32(limit * pagesPerMiB))
+	}
+	r := wazero.NewRuntimeWithConfig(ctx, runtimeConfig)
+
+	if _, err := wasi_snapshot_preview1.Instantiate(ctx, r); err != nil {
+		r.Close(ctx) //nolint:errcheck
+		return nil, err
+	}
+	if err := instantiateHostModule(ctx, r, c); err != nil {
+		r.Close(ctx) //nolint:errcheck
+		return nil, err
+	}
+
+	output := &logWriter{check: c}
+	moduleConfig := wazero.NewModuleConfig().
+		WithName(string(c.ID())).
+		WithStdout(output).
+		WithStderr(output).
+		// the modules are reactors, their `_start` function isn't called
+		WithStartFunctions("_initialize")
+	module, err := r.InstantiateWithConfig(ctx, code, moduleConfig)
+	if err != nil {
+		r.Close(ctx) //nolint:errcheck
+		return nil, err
+	}
+
+	return &wazeroInstance{runtime: r, module: module}, nil
+}
+
+func instantiateHostModule(ctx context.Context, r wazero.Runtime, c *WASMCheck) error {
+	_, err := r.NewHostModuleBuilder(hostModuleName).
+		NewFunctionBuilder().
+		WithFunc(func(_ context.Context, m api.Module, metricType, namePtr, nameLen uint32, value float64, tagsPtr, tagsLen, hostnamePtr, hostnameLen uint32) {
+			c.submitMetric(m.Memory(), metricType, namePtr, nameLen, value, tagsPtr, tagsLen, hostnamePtr, hostnameLen)
+		}).
+		Export("submit_metric").
+		NewFunctionBuilder().
+		WithFunc(func(_ context.Context, m api.Module, namePtr, nameLen, status, tagsPtr, tagsLen, hostnamePtr, hostnameLen, messagePtr, messageLen uint32) {
+			c.submitServiceCheck(m.Memory(), namePtr, nameLen, status, tagsPtr, tagsLen, hostnamePtr, hostnameLen, messagePtr, messageLen)
+		}).
+		Export("submit_service_check").
+		NewFunctionBuilder().
+		WithFunc(func(_ context.Context, m api.Module, eventPtr, eventLen uint32) int32 {
+			return c.submitEvent(m.Memory(), eventPtr, eventLen)
+		}).
+		Export("submit_event").
+		NewFunctionBuilder().
+		WithFunc(func(_ context.Context, m api.Module, scope, keyPtr, keyLen, bufferPtr, bufferCap uint32) int32 {
+			return c.getConfig(m.Memory(), scope, keyPtr, keyLen, bufferPtr, bufferCap)
+		}).
+		Export("get_config").
+		NewFunctionBuilder().
+		WithFunc(func(_ context.Context, m api.Module, level, messagePtr, messageLen uint32) {
+			c.log(m.Memory(), level, messagePtr, messageLen)
+		}).
+		Export("log").
+		NewFunctionBuilder().
+		WithFunc(func(_ context.Context, m api.Module, messagePtr, messageLen uint32) {
+			c.warning(m.Memory(), messagePtr, messageLen)
+		}).
+		Export("warning").
+		NewFunctionBuilder().
+		WithFunc(func(_ context.Context, m api.Module, messagePtr, messageLen uint32) {
+			c.setError(m.Memory(), messagePtr, messageLen)
+		}).
+		Export("set_error").
+		Instantiate(ctx)
+	return err
+}
+
+func (i *wazeroInstance) call(ctx context.Context, name string) (int32, bool, error) {
+	function := i.module.ExportedFunction(name)
+	if function == nil {
+		return 0, false, nil
+	}
+
+	results, err := function.Call(ctx)
+	if err != nil {
+		return 0, true, err
+	}
+	if len(results) != 1 {
+		return 0, true, fmt.Errorf("expected 1 result, got %d", len(results))
+	}
+	return api.DecodeI32(results[0]), true, nil
+}
+
+func (i *wazeroInstance) close() error {
+	return i.runtime.Close(context.Background())
+}
+
+// logWriter logs the lines written by a module to its standard outputs
+type logWriter struct {
+	check *WASMCheck
+}
+
+func (w *logWriter) Write(p []byte) (int, error) {
+	for _, line := range bytes.Split(bytes.TrimRight(p, "\n"), []byte("\n")) {
+		log.Debugf("Check %s: %s", w.check, line)
+	}
+	return len(p), nil
+}
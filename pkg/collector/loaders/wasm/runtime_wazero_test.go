@@ -0,0 +1,72 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+// +build wasmcheck,go1.18
+
+package wasm
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/aggregator/mocksender"
+	"github.com/DataDog/datadog-agent/pkg/autodiscovery/integration"
+	"github.com/DataDog/datadog-agent/pkg/collector/check"
+)
+
+// The modules of testdata are compiled from their .wat sources with wat2wasm.
+
+func TestWazeroRun(t *testing.T) {
+	loader, err := NewWASMCheckLoader()
+	require.NoError(t, err)
+	loader.checksPath = "testdata"
+
+	instance := integration.Data("value: 12345")
+	mocksender.NewMockSender(check.BuildID("metric", instance, nil)).SetupAcceptAll()
+	c, err := loader.Load(integration.Config{Name: "metric"}, instance)
+	require.NoError(t, err)
+	defer c.Cancel()
+
+	sender := mocksender.NewMockSender(c.ID())
+	sender.SetupAcceptAll()
+	require.NoError(t, c.Run())
+	sender.AssertMetric(t, "Gauge", "wasm.test.length", 5, "", []string{"env:test"})
+	sender.AssertNumberOfCalls(t, "Commit", 1)
+}
+
+func TestWazeroStop(t *testing.T) {
+	code, err := ioutil.ReadFile(filepath.Join("testdata", "loop.wasm"))
+	require.NoError(t, err)
+	c := newWASMCheck("loop", code, newInstance)
+	mocksender.NewMockSender(check.BuildID("loop", nil, nil)).SetupAcceptAll()
+	require.NoError(t, c.Configure(nil, nil, "test"))
+	mocksender.NewMockSender(c.ID()).SetupAcceptAll()
+	defer c.Cancel()
+
+	done := make(chan error)
+	go func() { done <- c.Run() }()
+	time.Sleep(100 * time.Millisecond)
+
+	// the run never returns until the check is stopped
+	c.Stop()
+	select {
+	case err := <-done:
+		assert.Error(t, err)
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "the run wasn't interrupted")
+	}
+	assert.Nil(t, c.instance)
+}
+
+func TestWazeroInvalidModule(t *testing.T) {
+	c := newWASMCheck("invalid", []byte("not a module"), newInstance)
+	mocksender.NewMockSender(check.BuildID("invalid", nil, nil)).SetupAcceptAll()
+	assert.Error(t, c.Configure(nil, nil, "test"))
+}
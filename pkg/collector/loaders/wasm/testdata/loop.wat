@@ -0,0 +1,6 @@
+;; Never returns from its run.
+(module
+  (memory (export "memory") 1)
+  (func (export "dd_check_run") (result i32)
+    (loop $forever (br $forever))
+    (i32.const 0)))
@@ -0,0 +1,17 @@
+;; Submits the gauge wasm.test.length, the length of the JSON value of the
+;; `value` key of the instance, tagged env:test.
+(module
+  (import "datadog" "submit_metric" (func $submit_metric (param i32 i32 i32 f64 i32 i32 i32 i32)))
+  (import "datadog" "get_config" (func $get_config (param i32 i32 i32 i32 i32) (result i32)))
+  (memory (export "memory") 1)
+  (data (i32.const 0) "wasm.test.length")
+  (data (i32.const 16) "env:test")
+  (data (i32.const 32) "value")
+  (func (export "dd_check_run") (result i32)
+    (call $submit_metric
+      (i32.const 0) ;; gauge
+      (i32.const 0) (i32.const 16)
+      (f64.convert_i32_s (call $get_config (i32.const 0) (i32.const 32) (i32.const 5) (i32.const 64) (i32.const 64)))
+      (i32.const 16) (i32.const 8)
+      (i32.const 0) (i32.const 0))
+    (i32.const 0)))
@@ -43,6 +43,8 @@ type PythonCheck struct {
 	class        *C.rtloader_pyobject_t
 	ModuleName   string
 	interval     time.Duration
+	runTimeout   time.Duration
+	schedule     check.Schedule
 	lastWarnings []error
 	source       string
 	telemetry    bool // whether or not the telemetry is enabled for this check
@@ -226,6 +228,20 @@ func (c *PythonCheck) Configure(data integration.Data, initConfig integration.Da
 		c.interval = time.Duration(commonOptions.MinCollectionInterval) * time.Second
 	}
 
+	// See if a run timeout was specified
+	if commonOptions.RunTimeout > 0 {
+		c.runTimeout = time.Duration(commonOptions.RunTimeout) * time.Second
+	}
+
+	// See if the runs are scheduled with a cron expression or a jitter, and
+	// ordered or serialized with other checks
+	c.schedule = check.Schedule{
+		Cron:             commonOptions.Cron,
+		Jitter:           time.Duration(commonOptions.Jitter) * time.Second,
+		RunAfter:         commonOptions.RunAfter,
+		ConcurrencyGroup: commonOptions.ConcurrencyGroup,
+	}
+
 	// Disable default hostname if specified
 	if commonOptions.EmptyDefaultHostname {
 		s, err := aggregator.GetSender(c.id)
@@ -310,6 +326,16 @@ func (c *PythonCheck) Interval() time.Duration {
 	return c.interval
 }
 
+// RunTimeout returns the maximum duration of a run of the check
+func (c *PythonCheck) RunTimeout() time.Duration {
+	return c.runTimeout
+}
+
+// Schedule returns the schedule settings of the check
+func (c *PythonCheck) Schedule() check.Schedule {
+	return c.schedule
+}
+
 // ID returns the ID of the check
 func (c *PythonCheck) ID() check.ID {
 	return c.id
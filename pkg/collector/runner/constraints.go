@@ -0,0 +1,109 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package runner
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/collector/check"
+)
+
+// waitingFor returns what a check waits for before running, an empty string if
+// it can run now:
+// - a check of its concurrency group running
+// - a check it runs after running or which hasn't completed a run yet
+// Long-running checks ignore these constraints, and aren't among the checks others
+// run after. It must be called with r.m held.
+//
+// The waiting checks are re-evaluated each time a worker completes a check run,
+// there is no other wake-up: a check only waits for checks which are running or
+// scheduled to run, and is sent again by the scheduler on its next run.
+func (r *Runner) waitingFor(c check.Check) string {
+	if c.Interval() == 0 {
+		return ""
+	}
+	schedule := c.Schedule()
+
+	if schedule.ConcurrencyGroup != "" {
+		for id, running := range r.runningChecks {
+			if running.Interval() != 0 && running.Schedule().ConcurrencyGroup == schedule.ConcurrencyGroup {
+				return fmt.Sprintf("check %s of concurrency group %s", id, schedule.ConcurrencyGroup)
+			}
+		}
+	}
+
+	if len(schedule.RunAfter) > 0 && r.scheduler != nil {
+		for _, id := range r.scheduler.RunAfter(c.ID()) {
+			if _, isRunning := r.runningChecks[id]; isRunning {
+				return fmt.Sprintf("check %s to complete its run", id)
+			}
+			if !hasCompletedRun(id) {
+				return fmt.Sprintf("the first run of check %s", id)
+			}
+		}
+	}
+	return ""
+}
+
+// addWaitingCheck adds a check to the ones waiting for other checks, unless it
+// already waits. It must be called with r.m held.
+func (r *Runner) addWaitingCheck(c check.Check) {
+	for _, waiting := range r.waitingChecks {
+		if waiting.ID() == c.ID() {
+			return
+		}
+	}
+	r.waitingChecks = append(r.waitingChecks, c)
+	runnerStats.Add("WaitingChecks", 1)
+}
+
+// nextWaitingCheck returns the first waiting check which can run now, marked
+// as running, nil if there is none. The checks unscheduled, running or backing
+// off since they started waiting are dropped.
+func (r *Runner) nextWaitingCheck() check.Check {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	now := time.Now()
+	for i := 0; i < len(r.waitingChecks); i++ {
+		c := r.waitingChecks[i]
+		_, isRunning := r.runningChecks[c.ID()]
+		if isRunning || r.isBackingOff(c.ID(), now) || (r.scheduler != nil && !r.scheduler.IsCheckScheduled(c.ID())) {
+			r.removeWaitingCheck(i)
+			i--
+			continue
+		}
+		if r.waitingFor(c) != "" {
+			continue
+		}
+
+		r.removeWaitingCheck(i)
+		r.runningChecks[c.ID()] = c
+		runnerStats.Add("RunningChecks", 1)
+		return c
+	}
+	return nil
+}
+
+// removeWaitingCheck removes the i-th waiting check. It must be called with r.m held.
+func (r *Runner) removeWaitingCheck(i int) {
+	copy(r.waitingChecks[i:], r.waitingChecks[i+1:])
+	r.waitingChecks[len(r.waitingChecks)-1] = nil
+	r.waitingChecks = r.waitingChecks[:len(r.waitingChecks)-1]
+	runnerStats.Add("WaitingChecks", -1)
+}
+
+// hasCompletedRun returns whether a check completed a run since it was scheduled
+func hasCompletedRun(id check.ID) bool {
+	checkStats.M.RLock()
+	defer checkStats.M.RUnlock()
+
+	name := strings.Split(string(id), ":")[0]
+	s, found := checkStats.Stats[name][id]
+	return found && s.TotalRuns > 0
+}
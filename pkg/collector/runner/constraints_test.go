@@ -0,0 +1,127 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package runner
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/collector/check"
+	"github.com/DataDog/datadog-agent/pkg/collector/scheduler"
+)
+
+type ConstrainedCheck struct {
+	*TestCheck
+	name     string
+	schedule check.Schedule
+	release  chan struct{}
+}
+
+func newConstrainedCheck(t *testing.T, name string, schedule check.Schedule) *ConstrainedCheck {
+	c := &ConstrainedCheck{TestCheck: newTestCheck(false, "1"), name: name, schedule: schedule}
+	t.Cleanup(func() { RemoveCheckStats(c.ID()) })
+	return c
+}
+
+func (c *ConstrainedCheck) Run() error {
+	if c.release != nil {
+		<-c.release
+	}
+	return c.TestCheck.Run()
+}
+func (c *ConstrainedCheck) String() string           { return c.name }
+func (c *ConstrainedCheck) ID() check.ID             { return check.ID(c.name + ":1") }
+func (c *ConstrainedCheck) Schedule() check.Schedule { return c.schedule }
+
+func (r *Runner) isWaiting(id check.ID) bool {
+	r.m.Lock()
+	defer r.m.Unlock()
+	for _, c := range r.waitingChecks {
+		if c.ID() == id {
+			return true
+		}
+	}
+	return false
+}
+
+func waitForRun(t *testing.T, c *ConstrainedCheck) {
+	select {
+	case <-c.done:
+	case <-time.After(1 * time.Second):
+		require.Fail(t, "Check hasn't run 1 second after being scheduled", "check %s", c)
+	}
+}
+
+func TestConcurrencyGroup(t *testing.T) {
+	r := NewRunner()
+	defer r.Stop()
+
+	first := newConstrainedCheck(t, "first", check.Schedule{ConcurrencyGroup: "device"})
+	first.release = make(chan struct{})
+	second := newConstrainedCheck(t, "second", check.Schedule{ConcurrencyGroup: "device"})
+	other := newConstrainedCheck(t, "other", check.Schedule{ConcurrencyGroup: "other-device"})
+
+	r.pending <- first
+	assert.Eventually(t, func() bool {
+		r.m.Lock()
+		defer r.m.Unlock()
+		_, isRunning := r.runningChecks[first.ID()]
+		return isRunning
+	}, 1*time.Second, 10*time.Millisecond)
+
+	// the second check waits for the first one, the checks of other groups run
+	r.pending <- second
+	r.pending <- other
+	waitForRun(t, other)
+	assert.Eventually(t, func() bool { return r.isWaiting(second.ID()) }, 1*time.Second, 10*time.Millisecond)
+	assert.False(t, second.HasRun())
+
+	close(first.release)
+	waitForRun(t, first)
+	waitForRun(t, second)
+	assert.False(t, r.isWaiting(second.ID()))
+}
+
+func TestRunAfter(t *testing.T) {
+	pipe := make(chan check.Check)
+	defer close(pipe)
+	s := scheduler.NewScheduler(pipe)
+	s.Run()
+	defer s.Stop()
+	go func() {
+		for range pipe {
+		}
+	}()
+
+	r := NewRunner()
+	defer r.Stop()
+	r.SetScheduler(s)
+
+	discovery := newConstrainedCheck(t, "discovery", check.Schedule{})
+	app := newConstrainedCheck(t, "app", check.Schedule{RunAfter: []string{"discovery"}})
+	unknown := newConstrainedCheck(t, "unknown", check.Schedule{RunAfter: []string{"missing"}})
+	for _, c := range []*ConstrainedCheck{discovery, app, unknown} {
+		require.NoError(t, s.Enter(c))
+		defer s.Cancel(c.ID()) //nolint:errcheck
+	}
+
+	// the dependencies which aren't scheduled are ignored
+	r.pending <- unknown
+	waitForRun(t, unknown)
+
+	// the check waits for the first run of the discovery check
+	r.pending <- app
+	assert.Eventually(t, func() bool { return r.isWaiting(app.ID()) }, 1*time.Second, 10*time.Millisecond)
+	assert.False(t, app.HasRun())
+
+	r.pending <- discovery
+	waitForRun(t, discovery)
+	waitForRun(t, app)
+	assert.False(t, r.isWaiting(app.ID()))
+}
@@ -74,6 +74,8 @@ type Runner struct {
 	scheduler        *scheduler.Scheduler     // Scheduler runner operates on
 	m                sync.Mutex               // To control races on runningChecks
 
+	timeoutBackoffs map[check.ID]*timeoutBackoff // The checks skipped after timeouts, guarded by m
+	waitingChecks   []check.Check                // The checks waiting for other checks to run, guarded by m
 }
 
 // NewRunner takes the number of desired goroutines processing incoming checks.
@@ -84,6 +86,7 @@ func NewRunner() *Runner {
 		// initialize the channel
 		pending:          make(chan check.Check),
 		runningChecks:    make(map[check.ID]check.Check),
+		timeoutBackoffs:  make(map[check.ID]*timeoutBackoff),
 		running:          1,
 		staticNumWorkers: numWorkers != 0,
 	}
@@ -248,104 +251,150 @@ func (r *Runner) StopCheck(id check.ID) error {
 func (r *Runner) work() {
 	log.Debug("Ready to process checks...")
 	defer TestWg.Done()
-	defer runnerStats.Add("Workers", -1)
+
+	// a worker replaced while running a stuck check hands its slot over to its replacement
+	replaced := false
+	defer func() {
+		if !replaced {
+			runnerStats.Add("Workers", -1)
+		}
+	}()
 
 	for check := range r.pending {
-		// see if the check is already running
-		r.m.Lock()
-		if _, isRunning := r.runningChecks[check.ID()]; isRunning {
-			log.Debugf("Check %s is already running, skip execution...", check)
-			r.m.Unlock()
+		if !r.startCheck(check) {
 			continue
-		} else {
-			r.runningChecks[check.ID()] = check
-			runnerStats.Add("RunningChecks", 1)
 		}
-		r.m.Unlock()
-
-		doLog, lastLog := shouldLog(check.ID())
 
-		if doLog {
-			log.Infoc("Running check", "check", check)
-		} else {
-			log.Debugc("Running check", "check", check)
+		exit := false
+		for check != nil {
+			checkReplaced, checkExit := r.processCheck(check)
+			replaced = replaced || checkReplaced
+			exit = exit || checkExit
+			// run the checks which were waiting for this one to complete
+			check = r.nextWaitingCheck()
 		}
+		if exit {
+			return
+		}
+	}
+
+	log.Debug("Finished processing checks.")
+}
+
+// startCheck marks a check received from the pending channel as running, and
+// returns whether it must run now. The checks already running or backing off
+// are skipped, the ones waiting for other checks are run after them.
+func (r *Runner) startCheck(check check.Check) bool {
+	r.m.Lock()
+	defer r.m.Unlock()
 
-		// run the check
-		var err error
-		t0 := time.Now()
+	// see if the check is already running
+	if _, isRunning := r.runningChecks[check.ID()]; isRunning {
+		log.Debugf("Check %s is already running, skip execution...", check)
+		return false
+	} else if r.isBackingOff(check.ID(), time.Now()) {
+		log.Debugf("Check %s timed out on its last runs, skip execution...", check)
+		return false
+	} else if waitingFor := r.waitingFor(check); waitingFor != "" {
+		log.Debugf("Check %s waits for %s, delaying execution...", check, waitingFor)
+		r.addWaitingCheck(check)
+		return false
+	}
+	r.runningChecks[check.ID()] = check
+	runnerStats.Add("RunningChecks", 1)
+	return true
+}
 
-		runningChecksStats.Set(string(check.ID()), timeVar(t0))
-		err = check.Run()
-		runningChecksStats.Delete(string(check.ID()))
-		longRunning := check.Interval() == 0
+// processCheck runs a check and publishes its status and stats. It returns
+// whether the worker was replaced while the check was stuck, and whether the
+// worker must exit.
+func (r *Runner) processCheck(check check.Check) (bool, bool) {
+	doLog, lastLog := shouldLog(check.ID())
 
-		warnings := check.GetWarnings()
+	if doLog {
+		log.Infoc("Running check", "check", check)
+	} else {
+		log.Debugc("Running check", "check", check)
+	}
 
-		// use the default sender for the service checks
-		sender, e := aggregator.GetDefaultSender()
-		if e != nil {
-			log.Errorf("Error getting default sender: %v. Not sending status check for %s", e, check)
-		}
-		serviceCheckTags := []string{fmt.Sprintf("check:%s", check.String())}
-		serviceCheckStatus := metrics.ServiceCheckOK
+	// run the check
+	t0 := time.Now()
 
-		hostname := getHostname()
+	runningChecksStats.Set(string(check.ID()), timeVar(t0))
+	replaced, err := r.runCheck(check, t0)
+	runningChecksStats.Delete(string(check.ID()))
+	longRunning := check.Interval() == 0
 
-		if len(warnings) != 0 {
-			// len returns int, and this expect int64, so it has to be converted
-			runnerStats.Add("Warnings", int64(len(warnings)))
-			serviceCheckStatus = metrics.ServiceCheckWarning
-		}
+	warnings := check.GetWarnings()
 
-		if err != nil {
-			log.Errorf("Error running check %s: %s", check, err)
-			runnerStats.Add("Errors", 1)
-			serviceCheckStatus = metrics.ServiceCheckCritical
-		}
+	// use the default sender for the service checks
+	sender, e := aggregator.GetDefaultSender()
+	if e != nil {
+		log.Errorf("Error getting default sender: %v. Not sending status check for %s", e, check)
+	}
+	serviceCheckTags := []string{fmt.Sprintf("check:%s", check.String())}
+	serviceCheckStatus := metrics.ServiceCheckOK
 
-		if sender != nil && !longRunning {
-			sender.ServiceCheck("datadog.agent.check_status", serviceCheckStatus, hostname, serviceCheckTags, "")
-			sender.Commit()
-		}
+	hostname := getHostname()
 
-		// remove the check from the running list
-		r.m.Lock()
-		delete(r.runningChecks, check.ID())
-		r.m.Unlock()
-
-		// publish statistics about this run
-		runnerStats.Add("RunningChecks", -1)
-		runnerStats.Add("Runs", 1)
-
-		r.m.Lock()
-		if !longRunning || len(warnings) != 0 || err != nil {
-			// If the scheduler isn't assigned (it should), just add stats
-			// otherwise only do so if the check is in the scheduler
-			if r.scheduler == nil || r.scheduler.IsCheckScheduled(check.ID()) {
-				sStats, _ := check.GetSenderStats()
-				addWorkStats(check, time.Since(t0), err, warnings, sStats)
-			}
-		}
-		r.m.Unlock()
+	if len(warnings) != 0 {
+		// len returns int, and this expect int64, so it has to be converted
+		runnerStats.Add("Warnings", int64(len(warnings)))
+		serviceCheckStatus = metrics.ServiceCheckWarning
+	}
 
-		l := "Done running check"
-		if doLog {
-			if lastLog {
-				l = l + fmt.Sprintf(", next runs will be logged every %v runs", config.Datadog.GetInt64("logging_frequency"))
-			}
-			log.Infoc(l, "check", check.String())
-		} else {
-			log.Debugc(l, "check", check.String())
+	if err != nil {
+		log.Errorf("Error running check %s: %s", check, err)
+		runnerStats.Add("Errors", 1)
+		serviceCheckStatus = metrics.ServiceCheckCritical
+	}
+
+	if sender != nil && !longRunning {
+		sender.ServiceCheck("datadog.agent.check_status", serviceCheckStatus, hostname, serviceCheckTags, "")
+		sender.Commit()
+	}
+
+	// remove the check from the running list
+	r.m.Lock()
+	delete(r.runningChecks, check.ID())
+	r.m.Unlock()
+
+	// publish statistics about this run
+	runnerStats.Add("RunningChecks", -1)
+	runnerStats.Add("Runs", 1)
+
+	r.m.Lock()
+	if !longRunning || len(warnings) != 0 || err != nil {
+		// If the scheduler isn't assigned (it should), just add stats
+		// otherwise only do so if the check is in the scheduler
+		if r.scheduler == nil || r.scheduler.IsCheckScheduled(check.ID()) {
+			sStats, _ := check.GetSenderStats()
+			addWorkStats(check, time.Since(t0), err, warnings, sStats)
 		}
+	}
+	r.m.Unlock()
 
-		if check.Interval() == 0 {
-			log.Infof("Check %v one-time's execution has finished", check)
-			return
+	l := "Done running check"
+	if doLog {
+		if lastLog {
+			l = l + fmt.Sprintf(", next runs will be logged every %v runs", config.Datadog.GetInt64("logging_frequency"))
 		}
+		log.Infoc(l, "check", check.String())
+	} else {
+		log.Debugc(l, "check", check.String())
 	}
 
-	log.Debug("Finished processing checks.")
+	if check.Interval() == 0 {
+		log.Infof("Check %v one-time's execution has finished", check)
+		return replaced, true
+	}
+
+	if replaced {
+		log.Debugf("Stuck check %v completed, its worker was replaced and exits", check)
+		return replaced, true
+	}
+
+	return replaced, false
 }
 
 func shouldLog(id check.ID) (doLog bool, lastLog bool) {
@@ -377,24 +426,26 @@ func shouldLog(id check.ID) (doLog bool, lastLog bool) {
 }
 
 func addWorkStats(c check.Check, execTime time.Duration, err error, warnings []error, mStats check.SenderStats) {
-	var s *check.Stats
-	var found bool
+	log.Tracef("Add stats for %s", string(c.ID()))
+	getCheckStats(c).Add(execTime, err, warnings, mStats)
+}
 
+// getCheckStats returns the stats of a check, creating them if needed
+func getCheckStats(c check.Check) *check.Stats {
 	checkStats.M.Lock()
-	log.Tracef("Add stats for %s", string(c.ID()))
+	defer checkStats.M.Unlock()
+
 	stats, found := checkStats.Stats[c.String()]
 	if !found {
 		stats = make(map[check.ID]*check.Stats)
 		checkStats.Stats[c.String()] = stats
 	}
-	s, found = stats[c.ID()]
+	s, found := stats[c.ID()]
 	if !found {
 		s = check.NewStats(c)
 		stats[c.ID()] = s
 	}
-	checkStats.M.Unlock()
-
-	s.Add(execTime, err, warnings, mStats)
+	return s
 }
 
 func expCheckStats() interface{} {
@@ -6,6 +6,7 @@
 package runner
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"runtime"
@@ -202,3 +203,121 @@ func TestGetCheckStats(t *testing.T) {
 	require.True(t, m["StatsCheck"] != nil, "should be a StatsCheck map")
 	require.True(t, m["StatsCheck"]["StatsCheck:99"] != nil, "should be a StatsCheck:99 check")
 }
+
+type StuckCheck struct {
+	TestCheck
+	release chan struct{}
+}
+
+func (c *StuckCheck) Run() error {
+	<-c.release
+	return c.TestCheck.Run()
+}
+func (c *StuckCheck) RunTimeout() time.Duration { return 50 * time.Millisecond }
+
+func TestRunTimeout(t *testing.T) {
+	numWorkers := config.Datadog.GetInt("check_runners")
+	config.Datadog.Set("check_runners", 1)
+	defer config.Datadog.Set("check_runners", numWorkers)
+
+	r := NewRunner()
+	defer r.Stop()
+	stuck := &StuckCheck{TestCheck: *newTestCheck(false, "stuck"), release: make(chan struct{})}
+	r.pending <- stuck
+
+	// the only worker is replaced, and the other checks keep running
+	c := newTestCheck(false, "other")
+	r.pending <- c
+	select {
+	case <-c.done:
+	case <-time.After(1 * time.Second):
+		require.Fail(t, "Check hasn't run 1 second after being scheduled")
+	}
+
+	s := GetCheckStats()["TestCheck"][stuck.ID()]
+	require.NotNil(t, s)
+	assert.True(t, s.Stuck)
+	assert.NotZero(t, s.StuckSince)
+	assert.Equal(t, uint64(1), s.TotalTimeouts)
+
+	close(stuck.release)
+	select {
+	case <-stuck.done:
+	case <-time.After(1 * time.Second):
+		require.Fail(t, "Stuck check hasn't completed 1 second after being released")
+	}
+	assert.Eventually(t, func() bool {
+		return s.LastError != ""
+	}, 1*time.Second, 10*time.Millisecond)
+	assert.False(t, s.Stuck)
+	assert.Contains(t, s.LastError, "the check run timed out after 50ms")
+}
+
+type InterruptibleCheck struct {
+	*TestCheck
+	stopped chan struct{}
+}
+
+func (c *InterruptibleCheck) RunContext(ctx context.Context) error {
+	<-ctx.Done()
+	c.TestCheck.Run() //nolint:errcheck
+	return ctx.Err()
+}
+func (c *InterruptibleCheck) Stop()                     { close(c.stopped) }
+func (c *InterruptibleCheck) RunTimeout() time.Duration { return 50 * time.Millisecond }
+
+func TestRunTimeoutInterrupt(t *testing.T) {
+	r := NewRunner()
+	defer r.Stop()
+	c := &InterruptibleCheck{TestCheck: newTestCheck(false, "interruptible"), stopped: make(chan struct{})}
+	defer RemoveCheckStats(c.ID())
+	r.pending <- c
+
+	// the run is interrupted once timed out, without stopping the check
+	select {
+	case <-c.done:
+	case <-time.After(1 * time.Second):
+		require.Fail(t, "Check hasn't been interrupted 1 second after being scheduled")
+	}
+	var s *check.Stats
+	require.Eventually(t, func() bool {
+		s = GetCheckStats()["TestCheck"][c.ID()]
+		return s != nil && s.LastError != ""
+	}, 1*time.Second, 10*time.Millisecond)
+	assert.Contains(t, s.LastError, "the check run timed out after 50ms: context canceled")
+	select {
+	case <-c.stopped:
+		assert.Fail(t, "The check was stopped")
+	default:
+	}
+}
+
+func TestTimeoutBackoff(t *testing.T) {
+	config.Datadog.Set("check_run_timeout_backoff_max_intervals", 4)
+	defer config.Datadog.Set("check_run_timeout_backoff_max_intervals", 0)
+
+	r := NewRunner()
+	defer r.Stop()
+	c := newTestCheck(false, "backoff")
+	now := time.Now()
+
+	r.m.Lock()
+	assert.False(t, r.isBackingOff(c.ID(), now))
+	r.m.Unlock()
+
+	// the runs are skipped for 1, 2, 4 and then at most 4 intervals of 1 second
+	expected := []int{1, 2, 4, 4}
+	for i, intervals := range expected {
+		r.addTimeoutBackoff(c)
+		r.m.Lock()
+		assert.Equal(t, i+1, r.timeoutBackoffs[c.ID()].consecutiveTimeouts)
+		assert.True(t, r.isBackingOff(c.ID(), now.Add(time.Duration(intervals)*time.Second-100*time.Millisecond)))
+		assert.False(t, r.isBackingOff(c.ID(), now.Add(time.Duration(intervals)*time.Second+500*time.Millisecond)))
+		r.m.Unlock()
+	}
+
+	r.resetTimeoutBackoff(c.ID())
+	r.m.Lock()
+	assert.False(t, r.isBackingOff(c.ID(), now))
+	r.m.Unlock()
+}
@@ -0,0 +1,138 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package runner
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/collector/check"
+	"github.com/DataDog/datadog-agent/pkg/config"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// timeoutBackoff holds the runs of a check skipped after consecutive timeouts.
+type timeoutBackoff struct {
+	consecutiveTimeouts int
+	skipUntil           time.Time
+}
+
+// runTimeout returns the run timeout of a check, 0 if its runs aren't timed out.
+func runTimeout(c check.Check) time.Duration {
+	// long-running checks aren't supposed to return
+	if c.Interval() == 0 {
+		return 0
+	}
+	if timeout := c.RunTimeout(); timeout > 0 {
+		return timeout
+	}
+	return time.Duration(config.Datadog.GetInt("check_run_timeout")) * time.Second
+}
+
+// runContext runs a check, with the given context if its runs can be interrupted.
+func runContext(ctx context.Context, c check.Check) error {
+	if cr, ok := c.(check.ContextRunner); ok {
+		return cr.RunContext(ctx)
+	}
+	return c.Run()
+}
+
+// runCheck runs a check started at start. When the run doesn't complete before
+// the run timeout, the check is marked as stuck and a new worker is started to
+// run the other checks, the current one waiting for the run to complete. The
+// run is interrupted if the check supports it; the check isn't stopped, since
+// it stays scheduled. It returns whether the current worker was replaced.
+func (r *Runner) runCheck(c check.Check, start time.Time) (bool, error) {
+	timeout := runTimeout(c)
+	if timeout <= 0 {
+		return false, c.Run()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		done <- runContext(ctx, c)
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case err := <-done:
+		r.resetTimeoutBackoff(c.ID())
+		return false, err
+	case <-timer.C:
+	}
+
+	log.Errorf("Check %s didn't complete after %v, it's stuck: starting a new worker to run the other checks", c, timeout)
+	runnerStats.Add("Timeouts", 1)
+	runnerStats.Add("StuckChecks", 1)
+	r.m.Lock()
+	if r.scheduler == nil || r.scheduler.IsCheckScheduled(c.ID()) {
+		getCheckStats(c).SetStuck(start)
+	}
+	r.m.Unlock()
+
+	// the current worker hands its slot over to its replacement
+	TestWg.Add(1)
+	go r.work()
+	if _, ok := c.(check.ContextRunner); ok {
+		cancel()
+	} else {
+		log.Warnf("Check %s can't be interrupted, waiting for its stuck run to complete", c)
+	}
+
+	err := <-done
+	runnerStats.Add("StuckChecks", -1)
+	log.Warnf("Stuck check %s completed after %v", c, time.Since(start))
+	r.addTimeoutBackoff(c)
+
+	if err != nil {
+		return true, fmt.Errorf("the check run timed out after %v: %v", timeout, err)
+	}
+	return true, fmt.Errorf("the check run timed out after %v", timeout)
+}
+
+// addTimeoutBackoff skips the next runs of a check which timed out, the number
+// of skipped runs doubling with each consecutive timeout.
+func (r *Runner) addTimeoutBackoff(c check.Check) {
+	maxIntervals := config.Datadog.GetInt("check_run_timeout_backoff_max_intervals")
+	if maxIntervals <= 0 {
+		return
+	}
+
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	backoff, found := r.timeoutBackoffs[c.ID()]
+	if !found {
+		backoff = &timeoutBackoff{}
+		r.timeoutBackoffs[c.ID()] = backoff
+	}
+	backoff.consecutiveTimeouts++
+
+	intervals := maxIntervals
+	if backoff.consecutiveTimeouts <= 30 && 1<<(backoff.consecutiveTimeouts-1) < maxIntervals {
+		intervals = 1 << (backoff.consecutiveTimeouts - 1)
+	}
+	backoff.skipUntil = time.Now().Add(time.Duration(intervals) * c.Interval())
+	log.Warnf("Check %s timed out %d times in a row, skipping its runs for %d intervals", c, backoff.consecutiveTimeouts, intervals)
+}
+
+func (r *Runner) resetTimeoutBackoff(id check.ID) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	delete(r.timeoutBackoffs, id)
+}
+
+// isBackingOff returns whether the runs of a check are skipped after timeouts.
+// It must be called with r.m held.
+func (r *Runner) isBackingOff(id check.ID, now time.Time) bool {
+	backoff, found := r.timeoutBackoffs[id]
+	return found && now.Before(backoff.skipUntil)
+}
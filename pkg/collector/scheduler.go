@@ -6,6 +6,7 @@
 package collector
 
 import (
+	"errors"
 	"expvar"
 	"fmt"
 	"strings"
@@ -14,6 +15,7 @@ import (
 	"github.com/DataDog/datadog-agent/pkg/autodiscovery/integration"
 	"github.com/DataDog/datadog-agent/pkg/collector/check"
 	"github.com/DataDog/datadog-agent/pkg/collector/loaders"
+	"github.com/DataDog/datadog-agent/pkg/collector/scheduler"
 	"github.com/DataDog/datadog-agent/pkg/util/containers"
 	"github.com/DataDog/datadog-agent/pkg/util/log"
 
@@ -42,6 +44,15 @@ func init() {
 	schedulerErrs.Set("RunErrors", expvar.Func(func() interface{} {
 		return errorStats.getRunErrors()
 	}))
+	schedulerErrs.Set("DependencyErrors", expvar.Func(func() interface{} {
+		errs := errorStats.getDependencyErrors()
+		if checkScheduler != nil && checkScheduler.collector != nil {
+			for id, warning := range checkScheduler.collector.DependencyWarnings() {
+				errs[id] = warning
+			}
+		}
+		return errs
+	}))
 }
 
 // CheckScheduler is the check scheduler
@@ -75,8 +86,13 @@ func (s *CheckScheduler) Schedule(configs []integration.Config) {
 		if err != nil {
 			log.Errorf("Unable to run Check %s: %v", c, err)
 			errorStats.setRunError(c.ID(), err.Error())
+			var cycleErr *scheduler.DependencyCycleError
+			if errors.As(err, &cycleErr) {
+				errorStats.setDependencyError(c.ID(), cycleErr.Error())
+			}
 			continue
 		}
+		errorStats.removeDependencyError(c.ID())
 	}
 }
 
@@ -91,7 +107,9 @@ func (s *CheckScheduler) Unschedule(configs []integration.Config) {
 		digest := config.Digest()
 		ids := s.configToChecks[digest]
 		stopped := map[check.ID]struct{}{}
+		errorStats.removeConfigLoaderErrors(digest)
 		for _, id := range ids {
+			errorStats.removeDependencyError(id)
 			// `StopCheck` might time out so we don't risk to block
 			// the polling loop forever
 			err := s.collector.StopCheck(id)
@@ -151,8 +169,12 @@ func (s *CheckScheduler) getChecks(config integration.Config) ([]check.Check, er
 	}
 	selectedLoader := initConfig.LoaderName
 
+	// the loader errors of the instances no loader could load
+	configErrors := make(map[string]string)
 	for _, instance := range config.Instances {
 		errors := []string{}
+		instanceErrors := make(map[string]string)
+		loaded := false
 		selectedInstanceLoader := selectedLoader
 		instanceConfig := commonInstanceConfig{}
 
@@ -182,6 +204,7 @@ func (s *CheckScheduler) getChecks(config integration.Config) ([]check.Check, er
 				log.Debugf("%v: successfully loaded check '%s'", loader, config.Name)
 				errorStats.removeLoaderErrors(config.Name)
 				checks = append(checks, c)
+				loaded = true
 				break
 			} else if c != nil && check.IsJMXInstance(config.Name, instance, config.InitConfig) {
 				// JMXfetch is more permissive than the agent regarding instance configuration. It
@@ -190,10 +213,17 @@ func (s *CheckScheduler) getChecks(config integration.Config) ([]check.Check, er
 				log.Debugf("%v: loading issue for JMX check '%s', the agent will still attempt to schedule it", loader, config.Name)
 				errorStats.setLoaderError(config.Name, fmt.Sprintf("%v", loader), err.Error())
 				checks = append(checks, c)
+				loaded = true
 				break
 			} else {
 				errorStats.setLoaderError(config.Name, fmt.Sprintf("%v", loader), err.Error())
 				errors = append(errors, fmt.Sprintf("%v: %s", loader, err))
+				instanceErrors[fmt.Sprintf("%v", loader)] = err.Error()
+			}
+		}
+		if !loaded {
+			for loader, err := range instanceErrors {
+				configErrors[loader] = err
 			}
 		}
 
@@ -202,6 +232,12 @@ func (s *CheckScheduler) getChecks(config integration.Config) ([]check.Check, er
 		}
 	}
 
+	if len(configErrors) > 0 {
+		errorStats.setConfigLoaderErrors(config.Digest(), configErrors)
+	} else {
+		errorStats.removeConfigLoaderErrors(config.Digest())
+	}
+
 	if len(checks) == 0 {
 		return checks, fmt.Errorf("unable to load any check from config '%s'", config.Name)
 	}
@@ -264,3 +300,9 @@ func (s *CheckScheduler) GetChecksFromConfigs(configs []integration.Config, popu
 func GetLoaderErrors() map[string]map[string]string {
 	return errorStats.getLoaderErrors()
 }
+
+// GetConfigLoaderErrors returns the check loader errors by config digest, for
+// the configs having instances no loader could load
+func GetConfigLoaderErrors() map[string]map[string]string {
+	return errorStats.getConfigLoaderErrors()
+}
@@ -0,0 +1,66 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCronErrors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+		"@every 5m",
+		"0 0 30 feb *",
+	} {
+		_, err := parseCron(expr)
+		assert.Error(t, err, expr)
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	// a Wednesday
+	from := time.Date(2021, time.March, 3, 10, 17, 30, 0, time.UTC)
+
+	for _, tc := range []struct {
+		expr     string
+		expected time.Time
+	}{
+		{"* * * * *", time.Date(2021, time.March, 3, 10, 18, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2021, time.March, 3, 10, 30, 0, 0, time.UTC)},
+		{"5/20 * * * *", time.Date(2021, time.March, 3, 10, 25, 0, 0, time.UTC)},
+		{"0 3 * * *", time.Date(2021, time.March, 4, 3, 0, 0, 0, time.UTC)},
+		{"30 2,22 * * *", time.Date(2021, time.March, 3, 22, 30, 0, 0, time.UTC)},
+		{"0 9-17/4 * * *", time.Date(2021, time.March, 3, 13, 0, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2021, time.April, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * sun", time.Date(2021, time.March, 7, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2021, time.March, 7, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * jan-feb mon", time.Date(2022, time.January, 3, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2024, time.February, 29, 0, 0, 0, 0, time.UTC)},
+		// a day matching either the day of month or the day of week matches
+		{"0 0 15 * fri", time.Date(2021, time.March, 5, 0, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2021, time.March, 3, 11, 0, 0, 0, time.UTC)},
+		{"@weekly", time.Date(2021, time.March, 7, 0, 0, 0, 0, time.UTC)},
+		{"@Yearly", time.Date(2022, time.January, 1, 0, 0, 0, 0, time.UTC)},
+	} {
+		c, err := parseCron(tc.expr)
+		require.NoError(t, err, tc.expr)
+		assert.Equal(t, tc.expected, c.next(from), tc.expr)
+	}
+}
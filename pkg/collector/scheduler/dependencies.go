@@ -0,0 +1,186 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package scheduler
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/DataDog/datadog-agent/pkg/collector/check"
+)
+
+// DependencyCycleError is returned when the `run_after` option of a check
+// would make it depend on itself through other checks.
+type DependencyCycleError struct {
+	// Cycle are the checks of the cycle, starting and ending with the scheduled check
+	Cycle []check.ID
+}
+
+func (e *DependencyCycleError) Error() string {
+	ids := make([]string, 0, len(e.Cycle))
+	for _, id := range e.Cycle {
+		ids = append(ids, string(id))
+	}
+	return fmt.Sprintf("the run_after options of the checks form a cycle: %s", strings.Join(ids, " -> "))
+}
+
+// dependencyGraph holds the scheduled checks, to resolve the checks their
+// `run_after` option refers to.
+type dependencyGraph struct {
+	names       map[check.ID]string   // check ID -> check name
+	runAfter    map[check.ID][]string // check ID -> checks it runs after, by name or ID
+	longRunning map[check.ID]bool     // the long-running and one-shot checks, scheduled once
+}
+
+func newDependencyGraph() *dependencyGraph {
+	return &dependencyGraph{
+		names:       make(map[check.ID]string),
+		runAfter:    make(map[check.ID][]string),
+		longRunning: make(map[check.ID]bool),
+	}
+}
+
+// add adds a check to the graph, unless it creates a dependency cycle.
+func (g *dependencyGraph) add(c check.Check) error {
+	g.names[c.ID()] = c.String()
+	if runAfter := c.Schedule().RunAfter; len(runAfter) > 0 {
+		g.runAfter[c.ID()] = runAfter
+	}
+	if c.Interval() == 0 {
+		// these checks neither wait for others nor are waited for, they
+		// can't be part of a cycle
+		g.longRunning[c.ID()] = true
+		return nil
+	}
+
+	// the graph had no cycle, a new one would go through the check
+	if cycle := g.findCycle(c.ID()); cycle != nil {
+		g.remove(c.ID())
+		return &DependencyCycleError{Cycle: cycle}
+	}
+	return nil
+}
+
+func (g *dependencyGraph) remove(id check.ID) {
+	delete(g.names, id)
+	delete(g.runAfter, id)
+	delete(g.longRunning, id)
+}
+
+// resolve returns the scheduled checks matching a reference of `run_after`,
+// sorted by ID.
+func (g *dependencyGraph) resolve(id check.ID, ref string) []check.ID {
+	var ids []check.ID
+	for depID, name := range g.names {
+		if depID != id && (ref == name || ref == string(depID)) {
+			ids = append(ids, depID)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// dependencies returns the checks a check runs after, sorted by ID. The
+// long-running and one-shot checks don't wait for other checks, and aren't
+// waited for: their runs aren't tracked.
+func (g *dependencyGraph) dependencies(id check.ID) []check.ID {
+	if g.longRunning[id] {
+		return nil
+	}
+
+	seen := make(map[check.ID]bool)
+	var deps []check.ID
+	for _, ref := range g.runAfter[id] {
+		for _, depID := range g.resolve(id, ref) {
+			if !g.longRunning[depID] && !seen[depID] {
+				seen[depID] = true
+				deps = append(deps, depID)
+			}
+		}
+	}
+	sort.Slice(deps, func(i, j int) bool { return deps[i] < deps[j] })
+	return deps
+}
+
+// warning returns why the `run_after` option of a check is ignored, entirely
+// or in part, an empty string if it's not.
+func (g *dependencyGraph) warning(id check.ID) string {
+	refs := g.runAfter[id]
+	if len(refs) == 0 {
+		return ""
+	}
+	if g.longRunning[id] {
+		return "run_after is ignored: long-running and one-shot checks don't wait for other checks"
+	}
+
+	var unscheduled, longRunning []string
+	for _, ref := range refs {
+		ids := g.resolve(id, ref)
+		if len(ids) == 0 {
+			unscheduled = append(unscheduled, ref)
+			continue
+		}
+		for _, depID := range ids {
+			if g.longRunning[depID] {
+				longRunning = append(longRunning, string(depID))
+			}
+		}
+	}
+
+	var warnings []string
+	if len(unscheduled) > 0 {
+		warnings = append(warnings, fmt.Sprintf("run_after refers to checks which aren't scheduled, not waiting for them: %s", strings.Join(unscheduled, ", ")))
+	}
+	if len(longRunning) > 0 {
+		warnings = append(warnings, fmt.Sprintf("run_after refers to long-running or one-shot checks, which never complete a run, not waiting for them: %s", strings.Join(longRunning, ", ")))
+	}
+	return strings.Join(warnings, "; ")
+}
+
+// warnings returns the warnings of all the checks whose `run_after` option is
+// ignored, entirely or in part.
+func (g *dependencyGraph) warnings() map[check.ID]string {
+	warnings := make(map[check.ID]string)
+	for id := range g.runAfter {
+		if warning := g.warning(id); warning != "" {
+			warnings[id] = warning
+		}
+	}
+	return warnings
+}
+
+// findCycle returns a dependency cycle going through the check, nil if there
+// is none.
+func (g *dependencyGraph) findCycle(start check.ID) []check.ID {
+	visited := make(map[check.ID]bool)
+	path := []check.ID{start}
+
+	var visit func(id check.ID) bool
+	visit = func(id check.ID) bool {
+		for _, dep := range g.dependencies(id) {
+			if dep == start {
+				path = append(path, dep)
+				return true
+			}
+			if visited[dep] {
+				continue
+			}
+			visited[dep] = true
+			path = append(path, dep)
+			if visit(dep) {
+				return true
+			}
+			path = path[:len(path)-1]
+		}
+		return false
+	}
+
+	if visit(start) {
+		return path
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/collector/check"
+)
+
+type TestDependencyCheck struct {
+	TestCheck
+	name     string
+	id       string
+	runAfter []string
+}
+
+func newTestDependencyCheck(name, id string, runAfter ...string) *TestDependencyCheck {
+	return &TestDependencyCheck{TestCheck: TestCheck{intl: time.Minute}, name: name, id: id, runAfter: runAfter}
+}
+
+func (c *TestDependencyCheck) String() string { return c.name }
+func (c *TestDependencyCheck) ID() check.ID   { return check.ID(c.name + ":" + c.id) }
+func (c *TestDependencyCheck) Schedule() check.Schedule {
+	return check.Schedule{RunAfter: c.runAfter}
+}
+
+func TestDependencyGraph(t *testing.T) {
+	g := newDependencyGraph()
+	require.NoError(t, g.add(newTestDependencyCheck("app", "1", "discovery")))
+	require.NoError(t, g.add(newTestDependencyCheck("app", "2", "discovery", "cache:1")))
+	require.NoError(t, g.add(newTestDependencyCheck("discovery", "1")))
+	require.NoError(t, g.add(newTestDependencyCheck("discovery", "2")))
+
+	// the checks are referred to by name or ID, unknown checks are ignored
+	assert.Equal(t, []check.ID{"discovery:1", "discovery:2"}, g.dependencies("app:1"))
+	assert.Equal(t, []check.ID{"discovery:1", "discovery:2"}, g.dependencies("app:2"))
+	assert.Empty(t, g.dependencies("discovery:1"))
+	require.NoError(t, g.add(newTestDependencyCheck("cache", "1")))
+	assert.Equal(t, []check.ID{"cache:1", "discovery:1", "discovery:2"}, g.dependencies("app:2"))
+
+	// the checks creating a cycle aren't added
+	err := g.add(newTestDependencyCheck("discovery", "3", "app:2"))
+	require.IsType(t, &DependencyCycleError{}, err)
+	assert.Equal(t, []check.ID{"discovery:3", "app:2", "discovery:3"}, err.(*DependencyCycleError).Cycle)
+	assert.EqualError(t, err, "the run_after options of the checks form a cycle: discovery:3 -> app:2 -> discovery:3")
+	assert.Equal(t, []check.ID{"discovery:1", "discovery:2"}, g.dependencies("app:1"))
+
+	// a check doesn't run after itself, but after the other instances of its check
+	require.NoError(t, g.add(newTestDependencyCheck("worker", "1", "worker")))
+	assert.Error(t, g.add(newTestDependencyCheck("worker", "2", "worker")))
+
+	g.remove("discovery:1")
+	assert.Equal(t, []check.ID{"discovery:2"}, g.dependencies("app:1"))
+}
+
+func TestEnterDependencyCycle(t *testing.T) {
+	s := getScheduler()
+	defer s.Stop()
+
+	app := newTestDependencyCheck("app", "1", "discovery")
+	discovery := newTestDependencyCheck("discovery", "1", "app")
+	require.NoError(t, s.Enter(app))
+	assert.Empty(t, s.RunAfter(app.ID()))
+
+	err := s.Enter(discovery)
+	assert.IsType(t, &DependencyCycleError{}, err)
+	assert.False(t, s.IsCheckScheduled(discovery.ID()))
+
+	// once the cycle is broken, the check is scheduled
+	discovery.runAfter = nil
+	require.NoError(t, s.Enter(discovery))
+	assert.Equal(t, []check.ID{"discovery:1"}, s.RunAfter(app.ID()))
+
+	require.NoError(t, s.Cancel(discovery.ID()))
+	assert.Empty(t, s.RunAfter(app.ID()))
+}
+
+func TestDependencyWarnings(t *testing.T) {
+	g := newDependencyGraph()
+	app := newTestDependencyCheck("app", "1", "discovery", "cache", "listener")
+	require.NoError(t, g.add(app))
+	require.NoError(t, g.add(newTestDependencyCheck("discovery", "1")))
+	listener := newTestDependencyCheck("listener", "1")
+	listener.intl = 0
+	require.NoError(t, g.add(listener))
+	oneShot := newTestDependencyCheck("setup", "1", "discovery")
+	oneShot.intl = 0
+	require.NoError(t, g.add(oneShot))
+
+	// long-running and one-shot checks are neither waited for nor wait
+	assert.Equal(t, []check.ID{"discovery:1"}, g.dependencies(app.ID()))
+	assert.Empty(t, g.dependencies(oneShot.ID()))
+
+	assert.Equal(t, map[check.ID]string{
+		app.ID(): "run_after refers to checks which aren't scheduled, not waiting for them: cache; " +
+			"run_after refers to long-running or one-shot checks, which never complete a run, not waiting for them: listener:1",
+		oneShot.ID(): "run_after is ignored: long-running and one-shot checks don't wait for other checks",
+	}, g.warnings())
+
+	require.NoError(t, g.add(newTestDependencyCheck("cache", "1")))
+	g.remove(listener.ID())
+	g.remove(oneShot.ID())
+	assert.Equal(t, map[check.ID]string{
+		app.ID(): "run_after refers to checks which aren't scheduled, not waiting for them: listener",
+	}, g.warnings())
+}
+
+func TestEnterDependencyWarnings(t *testing.T) {
+	s := getScheduler()
+	defer s.Stop()
+
+	oneShot := newTestDependencyCheck("setup", "1", "app")
+	oneShot.intl = 0
+	require.NoError(t, s.Enter(oneShot))
+	assert.Contains(t, s.DependencyWarnings(), oneShot.ID())
+
+	// one-time checks are forgotten once cancelled
+	require.NoError(t, s.Cancel(oneShot.ID()))
+	assert.Empty(t, s.DependencyWarnings())
+}
@@ -46,6 +46,8 @@ type Scheduler struct {
 	started          chan bool                   // Used to internally communicate the queues are up
 	jobQueues        map[time.Duration]*jobQueue // We have one scheduling queue for every interval
 	checkToQueue     map[check.ID]*jobQueue      // Keep track of what is the queue for any Check
+	timedJobs        map[check.ID]*timedJob      // The checks scheduled with a cron expression or a jitter
+	dependencies     *dependencyGraph            // The run_after dependencies between the scheduled checks
 	tlmTrackedChecks map[check.ID]string         // Keep track of the checks that are tracked with telemetry
 	mu               sync.Mutex                  // To protect critical sections in struct's fields
 
@@ -62,6 +64,8 @@ func NewScheduler(checksPipe chan<- check.Check) *Scheduler {
 		started:          make(chan bool),
 		jobQueues:        make(map[time.Duration]*jobQueue),
 		checkToQueue:     make(map[check.ID]*jobQueue),
+		timedJobs:        make(map[check.ID]*timedJob),
+		dependencies:     newDependencyGraph(),
 		tlmTrackedChecks: make(map[check.ID]string),
 		running:          0,
 		cancelOneTime:    make(chan bool),
@@ -70,10 +74,17 @@ func NewScheduler(checksPipe chan<- check.Check) *Scheduler {
 }
 
 // Enter schedules a `Check`s for execution accordingly to the `Check.Interval()` value.
-// If the interval is 0, the check is supposed to run only once.
+// If the interval is 0, the check is supposed to run only once. The checks with a cron
+// expression or a jitter in their `Check.Schedule()` are scheduled by a timed job.
+// A `DependencyCycleError` is returned when the `run_after` option of the check makes
+// it depend on itself, a warning is logged when the option is ignored in part.
 func (s *Scheduler) Enter(check check.Check) error {
 	// enqueue immediately if this is a one-time schedule
 	if check.Interval() == 0 {
+		s.mu.Lock()
+		s.dependencies.add(check) //nolint:errcheck
+		s.logDependencyWarning(check)
+		s.mu.Unlock()
 		s.enqueueOnce(check)
 		return nil
 	}
@@ -82,23 +93,39 @@ func (s *Scheduler) Enter(check check.Check) error {
 		return fmt.Errorf("Schedule interval must be greater than %v or 0", minAllowedInterval)
 	}
 
-	log.Infof("Scheduling check %v with an interval of %v", check, check.Interval())
+	job, err := newTimedJob(check)
+	if err != nil {
+		return err
+	}
 
 	// sync when accessing `jobQueues` and `check2queue`
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if _, ok := s.jobQueues[check.Interval()]; !ok {
-		s.jobQueues[check.Interval()] = newJobQueue(check.Interval())
-		s.startQueue(s.jobQueues[check.Interval()])
-		if check.IsTelemetryEnabled() {
-			tlmQueuesCount.Inc()
+	if err := s.dependencies.add(check); err != nil {
+		return err
+	}
+	s.logDependencyWarning(check)
+
+	if job != nil {
+		log.Infof("Scheduling check %v with %s", check, job)
+		s.timedJobs[check.ID()] = job
+		job.run(s.checksPipe)
+	} else {
+		log.Infof("Scheduling check %v with an interval of %v", check, check.Interval())
+
+		if _, ok := s.jobQueues[check.Interval()]; !ok {
+			s.jobQueues[check.Interval()] = newJobQueue(check.Interval())
+			s.startQueue(s.jobQueues[check.Interval()])
+			if check.IsTelemetryEnabled() {
+				tlmQueuesCount.Inc()
+			}
+			schedulerQueuesCount.Add(1)
 		}
-		schedulerQueuesCount.Add(1)
+		s.jobQueues[check.Interval()].addJob(check)
+		// map each check to the Job Queue it was assigned to
+		s.checkToQueue[check.ID()] = s.jobQueues[check.Interval()]
 	}
-	s.jobQueues[check.Interval()].addJob(check)
-	// map each check to the Job Queue it was assigned to
-	s.checkToQueue[check.ID()] = s.jobQueues[check.Interval()]
 
 	schedulerChecksEntered.Add(1)
 	if check.IsTelemetryEnabled() {
@@ -118,16 +145,24 @@ func (s *Scheduler) Cancel(id check.ID) error {
 
 	log.Infof("Unscheduling check %s", string(id))
 
-	if _, ok := s.checkToQueue[id]; !ok {
-		return nil
-	}
+	if job, ok := s.timedJobs[id]; ok {
+		job.cancel()
+		delete(s.timedJobs, id)
+	} else {
+		if _, ok := s.checkToQueue[id]; !ok {
+			// one-time checks are only tracked by the dependencies
+			s.dependencies.remove(id)
+			return nil
+		}
 
-	// remove it from the queue
-	err := s.checkToQueue[id].removeJob(id)
-	if err != nil {
-		return fmt.Errorf("unable to remove the Job from the queue: %s", err)
+		// remove it from the queue
+		err := s.checkToQueue[id].removeJob(id)
+		if err != nil {
+			return fmt.Errorf("unable to remove the Job from the queue: %s", err)
+		}
+		delete(s.checkToQueue, id)
 	}
-	delete(s.checkToQueue, id)
+	s.dependencies.remove(id)
 
 	schedulerChecksEntered.Add(-1)
 	if checkName, ok := s.tlmTrackedChecks[id]; ok {
@@ -203,11 +238,42 @@ func (s *Scheduler) IsCheckScheduled(id check.ID) bool {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	if _, found := s.timedJobs[id]; found {
+		return true
+	}
 	_, found := s.checkToQueue[id]
 	return found
 }
 
-// stopQueues shuts down the timers for each active queue
+// RunAfter returns the scheduled checks whose runs must complete before the
+// check runs, as set by its `run_after` option
+func (s *Scheduler) RunAfter(id check.ID) []check.ID {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.dependencies.dependencies(id)
+}
+
+// DependencyWarnings returns why the `run_after` option of the scheduled checks is
+// ignored, entirely or in part: the option isn't supported by long-running and
+// one-shot checks, and the checks it refers to may not be scheduled or be
+// long-running or one-shot ones.
+func (s *Scheduler) DependencyWarnings() map[check.ID]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.dependencies.warnings()
+}
+
+// logDependencyWarning logs why the `run_after` option of a check being scheduled
+// is ignored, if it is. It must be called with s.mu held.
+func (s *Scheduler) logDependencyWarning(c check.Check) {
+	if warning := s.dependencies.warning(c.ID()); warning != "" {
+		log.Warnf("Check %s: %s", c.ID(), warning)
+	}
+}
+
+// stopQueues shuts down the timers for each active queue and timed job
 // Blocks until all the queues have fully stopped
 func (s *Scheduler) stopQueues() {
 	s.mu.Lock()
@@ -224,6 +290,9 @@ func (s *Scheduler) stopQueues() {
 			q.running = false
 		}
 	}
+	for _, job := range s.timedJobs {
+		job.stopAndWait()
+	}
 }
 
 // startQueues loads the timer for each queue
@@ -235,6 +304,11 @@ func (s *Scheduler) startQueues() {
 	for _, q := range s.jobQueues {
 		s.startQueue(q)
 	}
+	for _, job := range s.timedJobs {
+		if !job.running {
+			job.run(s.checksPipe)
+		}
+	}
 }
 
 // startQueue starts a queue (non-blocking operation) if it's not running yet
@@ -11,6 +11,7 @@ import (
 
 	"github.com/DataDog/datadog-agent/pkg/collector/check"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // FIXTURE
@@ -196,3 +197,66 @@ func TestStopOneTimeSchedule(t *testing.T) {
 	// sleep to make the runtime schedule the hanging goroutines, if there are any
 	time.Sleep(time.Millisecond)
 }
+
+type TestScheduleCheck struct {
+	TestCheck
+	schedule check.Schedule
+}
+
+func (c *TestScheduleCheck) Schedule() check.Schedule { return c.schedule }
+
+func TestEnterTimedJob(t *testing.T) {
+	ch := make(chan check.Check)
+	s := NewScheduler(ch)
+
+	// the schedule settings are checked
+	c := &TestScheduleCheck{TestCheck: TestCheck{intl: time.Second}}
+	c.schedule.Cron = "not a cron"
+	assert.NotNil(t, s.Enter(c))
+	c.schedule = check.Schedule{Jitter: time.Second}
+	assert.NotNil(t, s.Enter(c))
+	assert.False(t, s.IsCheckScheduled(c.ID()))
+
+	// the jittered check runs once per interval
+	c.schedule = check.Schedule{Jitter: 100 * time.Millisecond}
+	start := time.Now()
+	assert.Nil(t, s.Enter(c))
+	assert.True(t, s.IsCheckScheduled(c.ID()))
+	assert.Len(t, s.jobQueues, 0)
+	for i := 0; i < 2; i++ {
+		select {
+		case <-ch:
+		case <-time.After(2 * time.Second):
+			assert.FailNow(t, "the check wasn't scheduled")
+		}
+	}
+	elapsed := time.Since(start)
+	assert.True(t, elapsed >= time.Second && elapsed < 1200*time.Millisecond, "unexpected elapsed time %v", elapsed)
+
+	assert.Nil(t, s.Cancel(c.ID()))
+	assert.False(t, s.IsCheckScheduled(c.ID()))
+	select {
+	case <-ch:
+		assert.Fail(t, "the check was scheduled after being canceled")
+	case <-time.After(1200 * time.Millisecond):
+	}
+}
+
+func TestTimedJobNextRun(t *testing.T) {
+	now := time.Date(2021, time.March, 3, 10, 17, 30, 0, time.UTC)
+
+	job, err := newTimedJob(&TestScheduleCheck{TestCheck: TestCheck{intl: time.Minute}, schedule: check.Schedule{Jitter: time.Second}})
+	require.NoError(t, err)
+	assert.Equal(t, now.Add(time.Minute-10*time.Second), job.nextRun(now.Add(-10*time.Second), now))
+	// the runs missed while the pipeline was blocked are skipped
+	assert.Equal(t, now, job.nextRun(now.Add(-2*time.Minute), now))
+
+	job, err = newTimedJob(&TestScheduleCheck{TestCheck: TestCheck{intl: time.Minute}, schedule: check.Schedule{Cron: "0 * * * *"}})
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2021, time.March, 3, 11, 0, 0, 0, time.UTC), job.nextRun(now.Add(-17*time.Minute), now))
+	assert.Equal(t, time.Date(2021, time.March, 3, 11, 0, 0, 0, time.UTC), job.nextRun(now.Add(-3*time.Hour), now))
+
+	job, err = newTimedJob(&TestScheduleCheck{TestCheck: TestCheck{intl: time.Minute}})
+	require.NoError(t, err)
+	assert.Nil(t, job)
+}
@@ -0,0 +1,132 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package scheduler
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/collector/check"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// timedJob schedules a check with a cron expression, or at its interval with a
+// random delay, in its own goroutine instead of in a jobQueue.
+type timedJob struct {
+	check   check.Check
+	cron    *cronSchedule // nil when the check runs at its interval
+	jitter  time.Duration
+	stop    chan struct{} // to stop this job
+	stopped chan struct{} // signals that this job has stopped
+	running bool
+}
+
+// newTimedJob returns the timed job of a check, or nil if the check is scheduled
+// at its interval without jitter.
+func newTimedJob(c check.Check) (*timedJob, error) {
+	schedule := c.Schedule()
+	if schedule.Cron == "" && schedule.Jitter <= 0 {
+		return nil, nil
+	}
+
+	job := &timedJob{check: c}
+	if schedule.Jitter > 0 {
+		job.jitter = schedule.Jitter
+	}
+	if schedule.Cron != "" {
+		cron, err := parseCron(schedule.Cron)
+		if err != nil {
+			return nil, err
+		}
+		job.cron = cron
+	} else if job.jitter >= c.Interval() {
+		return nil, fmt.Errorf("the jitter (%v) must be lower than the interval (%v)", job.jitter, c.Interval())
+	}
+	return job, nil
+}
+
+func (j *timedJob) String() string {
+	if j.cron != nil {
+		return fmt.Sprintf("cron expression %q and a jitter of %v", j.check.Schedule().Cron, j.jitter)
+	}
+	return fmt.Sprintf("an interval of %v and a jitter of %v", j.check.Interval(), j.jitter)
+}
+
+// nextRun returns the scheduled time of the run following the one scheduled at
+// last, skipping the runs missed while the execution pipeline was blocked.
+func (j *timedJob) nextRun(last, now time.Time) time.Time {
+	if j.cron != nil {
+		if next := j.cron.next(last); !next.Before(now) {
+			return next
+		}
+		return j.cron.next(now)
+	}
+	if next := last.Add(j.check.Interval()); !next.Before(now) {
+		return next
+	}
+	return now
+}
+
+func (j *timedJob) delay() time.Duration {
+	if j.jitter <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(j.jitter)))
+}
+
+// run posts the check to the execution pipeline at its scheduled times.
+// Not blocking, runs in a new goroutine.
+func (j *timedJob) run(checksPipe chan<- check.Check) {
+	j.stop = make(chan struct{})
+	j.stopped = make(chan struct{})
+	j.running = true
+
+	go func(stop <-chan struct{}, stopped chan<- struct{}) {
+		defer close(stopped)
+
+		// without a cron expression, the first run only waits for the random delay
+		scheduled := time.Now()
+		if j.cron != nil {
+			scheduled = j.cron.next(scheduled)
+		}
+		for !scheduled.IsZero() {
+			timer := time.NewTimer(time.Until(scheduled.Add(j.delay())))
+			select {
+			case <-timer.C:
+			case <-stop:
+				timer.Stop()
+				return
+			}
+
+			select {
+			// blocking, we'll be here as long as it takes
+			case checksPipe <- j.check:
+			case <-stop:
+				return
+			}
+			scheduled = j.nextRun(scheduled, time.Now())
+		}
+		log.Errorf("The cron expression of check %s doesn't match any time in the next years, it won't run anymore", j.check)
+	}(j.stop, j.stopped)
+}
+
+// cancel stops the job without waiting for its goroutine to exit.
+func (j *timedJob) cancel() {
+	if j.running {
+		close(j.stop)
+		j.running = false
+	}
+}
+
+// stopAndWait stops the job and waits for its goroutine to exit.
+func (j *timedJob) stopAndWait() {
+	if j.running {
+		close(j.stop)
+		<-j.stopped
+		j.running = false
+	}
+}
@@ -52,6 +52,42 @@ func (l *MockPythonLoader) Load(config integration.Config, instance integration.
 	return &mockCheck, nil
 }
 
+type MockFailingLoader struct{}
+
+func (l *MockFailingLoader) Name() string {
+	return "failing"
+}
+
+func (l *MockFailingLoader) String() string {
+	return "Failing Loader"
+}
+
+func (l *MockFailingLoader) Load(config integration.Config, instance integration.Data) (check.Check, error) {
+	return nil, fmt.Errorf("unable to load %s", config.Name)
+}
+
+func TestGetChecksConfigLoaderErrors(t *testing.T) {
+	s := CheckScheduler{}
+	s.AddLoader(&MockFailingLoader{})
+	s.AddLoader(&MockCoreLoader{})
+
+	// two configs of the same check, only the instance of one can't be loaded
+	failing := integration.Config{
+		Name:      "check_a",
+		Instances: []integration.Data{integration.Data("{\"loader\": \"failing\"}")},
+	}
+	loaded := integration.Config{
+		Name:      "check_a",
+		Instances: []integration.Data{integration.Data("{\"loader\": \"core\"}")},
+	}
+	s.GetChecksFromConfigs([]integration.Config{failing, loaded}, false)
+	defer errorStats.removeConfigLoaderErrors(failing.Digest())
+
+	errs := GetConfigLoaderErrors()
+	assert.Equal(t, map[string]string{"Failing Loader": "unable to load check_a"}, errs[failing.Digest()])
+	assert.NotContains(t, errs, loaded.Digest())
+}
+
 func TestAddLoader(t *testing.T) {
 	s := CheckScheduler{}
 	assert.Len(t, s.loaders, 0)
@@ -13,16 +13,22 @@ import (
 
 // collectorErrors holds the error objects
 type collectorErrors struct {
-	loader map[string]map[string]string // check Name -> loader -> error
-	run    map[check.ID]string          // check ID -> error
-	m      sync.RWMutex
+	loader       map[string]map[string]string // check Name -> loader -> error
+	configLoader map[string]map[string]string // config digest -> loader -> error
+	run          map[check.ID]string          // check ID -> error
+	m            sync.RWMutex
+
+	dependency map[check.ID]string // check ID -> dependency cycle error
 }
 
 // newCollectorErrors returns an instance holding autoconfig errors stats
 func newCollectorErrors() *collectorErrors {
 	return &collectorErrors{
-		loader: make(map[string]map[string]string),
-		run:    make(map[check.ID]string),
+		loader:       make(map[string]map[string]string),
+		configLoader: make(map[string]map[string]string),
+		run:          make(map[check.ID]string),
+
+		dependency: make(map[check.ID]string),
 	}
 }
 
@@ -58,6 +64,38 @@ func (ce *collectorErrors) getLoaderErrors() map[string]map[string]string {
 	return errorsCopy
 }
 
+// setConfigLoaderErrors sets the errors of the loaders for the instances of a
+// config which no loader could load
+func (ce *collectorErrors) setConfigLoaderErrors(digest string, errors map[string]string) {
+	ce.m.Lock()
+	defer ce.m.Unlock()
+
+	ce.configLoader[digest] = errors
+}
+
+// removeConfigLoaderErrors removes the loader errors of a config, when all its
+// instances are loaded or when it's unscheduled
+func (ce *collectorErrors) removeConfigLoaderErrors(digest string) {
+	ce.m.Lock()
+	defer ce.m.Unlock()
+
+	delete(ce.configLoader, digest)
+}
+
+func (ce *collectorErrors) getConfigLoaderErrors() map[string]map[string]string {
+	ce.m.RLock()
+	defer ce.m.RUnlock()
+
+	errorsCopy := make(map[string]map[string]string)
+	for digest, loaderErrors := range ce.configLoader {
+		errorsCopy[digest] = make(map[string]string)
+		for loader, loaderError := range loaderErrors {
+			errorsCopy[digest][loader] = loaderError
+		}
+	}
+	return errorsCopy
+}
+
 func (ce *collectorErrors) setRunError(checkID check.ID, err string) {
 	ce.m.Lock()
 	defer ce.m.Unlock()
@@ -76,3 +114,33 @@ func (ce *collectorErrors) getRunErrors() map[check.ID]string {
 
 	return runCopy
 }
+
+// setDependencyError sets the error of a check not scheduled because of a
+// dependency cycle
+func (ce *collectorErrors) setDependencyError(checkID check.ID, err string) {
+	ce.m.Lock()
+	defer ce.m.Unlock()
+
+	ce.dependency[checkID] = err
+}
+
+// removeDependencyError removes the dependency error of a check, when it's
+// scheduled or unscheduled
+func (ce *collectorErrors) removeDependencyError(checkID check.ID) {
+	ce.m.Lock()
+	defer ce.m.Unlock()
+
+	delete(ce.dependency, checkID)
+}
+
+func (ce *collectorErrors) getDependencyErrors() map[check.ID]string {
+	ce.m.RLock()
+	defer ce.m.RUnlock()
+
+	dependencyCopy := make(map[check.ID]string)
+	for k, v := range ce.dependency {
+		dependencyCopy[k] = v
+	}
+
+	return dependencyCopy
+}
@@ -9,6 +9,8 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+
+	"github.com/DataDog/datadog-agent/pkg/collector/check"
 )
 
 func TestNewCollectorErrors(t *testing.T) {
@@ -40,3 +42,23 @@ func TestGetLoaderErrors(t *testing.T) {
 	errs := ce.getLoaderErrors()
 	assert.Len(t, errs, 1)
 }
+
+func TestDependencyErrors(t *testing.T) {
+	ce := newCollectorErrors()
+	ce.setDependencyError("aCheck:1", "aCycle")
+	ce.setDependencyError("anotherCheck:2", "aCycle")
+	ce.removeDependencyError("anotherCheck:2")
+
+	errs := ce.getDependencyErrors()
+	assert.Equal(t, map[check.ID]string{"aCheck:1": "aCycle"}, errs)
+}
+
+func TestConfigLoaderErrors(t *testing.T) {
+	ce := newCollectorErrors()
+	ce.setConfigLoaderErrors("digest1", map[string]string{"aLoader": "anError"})
+	ce.setConfigLoaderErrors("digest2", map[string]string{"aLoader": "anError"})
+	ce.removeConfigLoaderErrors("digest2")
+
+	errs := ce.getConfigLoaderErrors()
+	assert.Equal(t, map[string]map[string]string{"digest1": {"aLoader": "anError"}}, errs)
+}
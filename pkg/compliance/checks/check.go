@@ -57,6 +57,14 @@ func (c *complianceCheck) Interval() time.Duration {
 	return c.interval
 }
 
+func (c *complianceCheck) RunTimeout() time.Duration {
+	return 0
+}
+
+func (c *complianceCheck) Schedule() check.Schedule {
+	return check.Schedule{}
+}
+
 func (c *complianceCheck) ID() check.ID {
 	return check.ID(c.ruleID)
 }
@@ -31,6 +31,9 @@ func setupAPM(config Config) {
 	config.SetKnown("apm_config.obfuscation.memcached.enabled")
 	config.SetKnown("apm_config.filter_tags.require")
 	config.SetKnown("apm_config.filter_tags.reject")
+	config.SetKnown("apm_config.span_metrics.namespace")
+	config.SetKnown("apm_config.span_metrics.services")
+	config.SetKnown("apm_config.span_metrics.include_resource")
 	config.SetKnown("apm_config.extra_sample_rate")
 	config.SetKnown("apm_config.dd_agent_bin")
 	config.SetKnown("apm_config.trace_writer.connection_limit")
@@ -83,6 +86,9 @@ func setupAPM(config Config) {
 	config.BindEnv("apm_config.filter_tags.require", "DD_APM_FILTER_TAGS_REQUIRE")
 	config.BindEnv("apm_config.filter_tags.reject", "DD_APM_FILTER_TAGS_REJECT")
 	config.BindEnv("apm_config.internal_profiling.enabled", "DD_APM_INTERNAL_PROFILING_ENABLED")
+	config.BindEnv("apm_config.debug_traces.enabled", "DD_APM_DEBUG_TRACES_ENABLED")
+	config.BindEnv("apm_config.debug_traces.buffer_size", "DD_APM_DEBUG_TRACES_BUFFER_SIZE")
+	config.BindEnv("apm_config.span_metrics.enabled", "DD_APM_SPAN_METRICS_ENABLED")
 	config.BindEnv("experimental.otlp.http_port", "DD_OTLP_HTTP_PORT")
 	config.BindEnv("experimental.otlp.grpc_port", "DD_OTLP_GRPC_PORT")
 
@@ -22,6 +22,7 @@ import (
 
 	"github.com/DataDog/datadog-agent/pkg/autodiscovery/common/types"
 	"github.com/DataDog/datadog-agent/pkg/collector/check/defaults"
+	"github.com/DataDog/datadog-agent/pkg/util/compression"
 	"github.com/DataDog/datadog-agent/pkg/util/hostname/validate"
 	"github.com/DataDog/datadog-agent/pkg/util/log"
 
@@ -127,6 +128,7 @@ type ConfigurationProviders struct {
 	KeyFile          string `mapstructure:"key_file"`
 	Token            string `mapstructure:"token"`
 	GraceTimeSeconds int    `mapstructure:"grace_time_seconds"`
+	Branch           string `mapstructure:"branch"`
 }
 
 // Listeners helps unmarshalling `listeners` config param
@@ -212,6 +214,9 @@ func InitConfig(config Config) {
 	config.BindEnvAndSetDefault("enable_metadata_collection", true)
 	config.BindEnvAndSetDefault("enable_gohai", true)
 	config.BindEnvAndSetDefault("check_runners", int64(4))
+	config.BindEnvAndSetDefault("check_run_timeout", 0)
+	config.BindEnvAndSetDefault("check_run_timeout_backoff_max_intervals", 0)
+	config.BindEnvAndSetDefault("wasm_check_memory_limit", 64)
 	config.BindEnvAndSetDefault("auth_token_file_path", "")
 	config.BindEnv("bind_host")
 	config.BindEnvAndSetDefault("ipc_address", "localhost")
@@ -344,6 +349,7 @@ func InitConfig(config Config) {
 	config.BindEnvAndSetDefault("enable_service_checks_stream_payload_serialization", true)
 	config.BindEnvAndSetDefault("enable_events_stream_payload_serialization", true)
 	config.BindEnvAndSetDefault("enable_sketch_stream_payload_serialization", true)
+	config.BindEnvAndSetDefault("enable_series_protobuf_stream_payload_serialization", true)
 	config.BindEnvAndSetDefault("enable_json_stream_shared_compressor_buffers", true)
 
 	// Warning: do not change the two following values. Your payloads will get dropped by Datadog's intake.
@@ -358,6 +364,15 @@ func InitConfig(config Config) {
 	config.BindEnvAndSetDefault("enable_payloads.service_checks", true)
 	config.BindEnvAndSetDefault("enable_payloads.sketches", true)
 	config.BindEnvAndSetDefault("enable_payloads.json_to_v1_intake", true)
+	// Serializer: export series and sketches to a Prometheus endpoint or an OTLP receiver
+	config.BindEnvAndSetDefault("metrics_export.forward_to_datadog", true)
+	config.BindEnvAndSetDefault("metrics_export.prometheus.enabled", false)
+	config.BindEnvAndSetDefault("metrics_export.prometheus.listen_address", "localhost:9464")
+	config.BindEnvAndSetDefault("metrics_export.prometheus.expiry_seconds", 300)
+	config.BindEnvAndSetDefault("metrics_export.otlp.enabled", false)
+	config.BindEnvAndSetDefault("metrics_export.otlp.endpoint", "http://localhost:4318")
+	config.BindEnvAndSetDefault("metrics_export.otlp.headers", map[string]string{})
+	config.BindEnvAndSetDefault("metrics_export.otlp.timeout", 10)
 
 	// Forwarder
 	config.BindEnvAndSetDefault("additional_endpoints", map[string][]string{})
@@ -381,12 +396,42 @@ func InitConfig(config Config) {
 	config.BindEnvAndSetDefault("forwarder_flush_to_disk_mem_ratio", 0.5)
 	config.BindEnvAndSetDefault("forwarder_storage_max_size_in_bytes", 0) // 0 means disabled. This is a BETA feature.
 	config.BindEnvAndSetDefault("forwarder_storage_max_disk_ratio", 0.95) // Do not store transactions on disk when the disk usage exceeds 95% of the disk capacity.
+	config.BindEnvAndSetDefault("forwarder_storage_encryption_key", "")
+	config.BindEnvAndSetDefault("forwarder_storage_encryption_key_file", "")
 
 	// Forwarder channels buffer size
 	config.BindEnvAndSetDefault("forwarder_high_prio_buffer_size", 100)
 	config.BindEnvAndSetDefault("forwarder_low_prio_buffer_size", 100)
 	config.BindEnvAndSetDefault("forwarder_requeue_buffer_size", 100)
 
+	// Forwarder compression, an empty kind means the compression selected at build time
+	config.BindEnvAndSetDefault("forwarder_compression_kind", "")
+	config.BindEnvAndSetDefault("forwarder_compression_level", 0) // 0 means the default level of the compression kind
+	config.BindEnvAndSetDefault("forwarder_compression_per_endpoint", map[string]string{})
+
+	// Forwarder egress bandwidth shaping
+	config.BindEnvAndSetDefault("forwarder_egress_limit_bytes_per_second", 0) // 0 means unlimited
+	config.BindEnvAndSetDefault("forwarder_egress_limit_per_domain", map[string]int{})
+	config.BindEnvAndSetDefault("forwarder_egress_queue_max_size", 15*1024*1024)
+
+	// Forwarder HTTP transport
+	config.BindEnvAndSetDefault("forwarder_http2", false)
+	config.BindEnvAndSetDefault("forwarder_max_idle_conns_per_host", 5)
+	config.BindEnvAndSetDefault("forwarder_max_conns_per_host", 0) // 0 means unlimited
+	config.BindEnvAndSetDefault("forwarder_idle_conn_timeout", 90) // in seconds
+	config.BindEnvAndSetDefault("forwarder_http_transport_per_domain", map[string]interface{}{})
+
+	// Forwarder payload inspection
+	config.BindEnvAndSetDefault("forwarder_tee_output", "") // a file path or "stdout", empty means disabled
+	config.BindEnvAndSetDefault("forwarder_tee_dry_run", false)
+	config.BindEnvAndSetDefault("forwarder_tee_max_file_size", 10*1024*1024)
+	config.BindEnvAndSetDefault("forwarder_tee_max_files", 5)
+
+	// Forwarder active/passive failover
+	config.BindEnvAndSetDefault("forwarder_failover_secondary_domains", []string{}) // empty means every domain receives the payloads
+	config.BindEnvAndSetDefault("forwarder_failover_check_interval", 10)            // in seconds
+	config.BindEnvAndSetDefault("forwarder_failover_failback_delay", 300)           // in seconds
+
 	// Dogstatsd
 	config.BindEnvAndSetDefault("use_dogstatsd", true)
 	config.BindEnvAndSetDefault("dogstatsd_port", 8125)    // Notice: 0 means UDP port closed
@@ -517,6 +562,7 @@ func InitConfig(config Config) {
 
 	config.BindEnvAndSetDefault("prometheus_scrape.enabled", false)           // Enables the prometheus config provider
 	config.BindEnvAndSetDefault("prometheus_scrape.service_endpoints", false) // Enables Service Endpoints checks in the prometheus config provider
+	config.BindEnvAndSetDefault("prometheus_scrape.use_core_check", false)    // Schedules the Go openmetrics_core check instead of the Python openmetrics one
 	config.BindEnv("prometheus_scrape.checks")                                // Defines any extra prometheus/openmetrics check configurations to be handled by the prometheus config provider
 	config.SetEnvKeyTransformer("prometheus_scrape.checks", func(in string) interface{} {
 		var promChecks []*types.PrometheusCheck
@@ -1072,6 +1118,10 @@ func load(config Config, origin string, loadSecret bool) (*Warnings, error) {
 		AddOverride("python_version", DefaultPython)
 	}
 
+	if err := checkForwarderCompression(config); err != nil {
+		return &warnings, err
+	}
+
 	loadProxyFromEnv(config)
 	SanitizeAPIKeyConfig(config, "api_key")
 	// Environment feature detection needs to run before applying override funcs
@@ -1120,6 +1170,24 @@ func ResolveSecrets(config Config, origin string) error {
 	return nil
 }
 
+// checkForwarderCompression returns an error when the forwarder compression selects zstd
+// while the Agent was built without it. The other invalid compressions are only ignored
+// by the forwarder, as they don't depend on the build.
+func checkForwarderCompression(config Config) error {
+	if kind := config.GetString("forwarder_compression_kind"); kind != "" {
+		_, err := compression.NewCompressor(compression.Kind(kind), config.GetInt("forwarder_compression_level"))
+		if errors.Is(err, compression.ErrZstdUnavailable) {
+			return fmt.Errorf("invalid 'forwarder_compression_kind': %v", err)
+		}
+	}
+	for endpoint, spec := range config.GetStringMapString("forwarder_compression_per_endpoint") {
+		if _, err := compression.Parse(spec); errors.Is(err, compression.ErrZstdUnavailable) {
+			return fmt.Errorf("invalid compression of the endpoint '%s' in 'forwarder_compression_per_endpoint': %v", endpoint, err)
+		}
+	}
+	return nil
+}
+
 // SanitizeAPIKeyConfig strips newlines and other control characters from a given key.
 func SanitizeAPIKeyConfig(config Config, key string) {
 	config.Set(key, SanitizeAPIKey(config.GetString(key)))
@@ -13,6 +13,7 @@ import (
 	"testing"
 
 	"github.com/DataDog/datadog-agent/pkg/autodiscovery/common/types"
+	"github.com/DataDog/datadog-agent/pkg/util/compression"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -799,6 +800,32 @@ func TestSanitizeAPIKeyConfig(t *testing.T) {
 	assert.Equal(t, "foo", config.GetString("api_key"))
 }
 
+func TestCheckForwarderCompression(t *testing.T) {
+	config := setupConf()
+	assert.NoError(t, checkForwarderCompression(config))
+
+	config.Set("forwarder_compression_kind", "brotli")
+	config.Set("forwarder_compression_per_endpoint", map[string]string{"series_v2": "gzip:20"})
+	assert.NoError(t, checkForwarderCompression(config), "invalid compressions are ignored by the forwarder")
+
+	_, zstdErr := compression.NewCompressor(compression.ZstdKind, 0)
+	config.Set("forwarder_compression_kind", "")
+	config.Set("forwarder_compression_per_endpoint", map[string]string{"series_v2": "zstd:3"})
+	if zstdErr == nil {
+		assert.NoError(t, checkForwarderCompression(config))
+	} else {
+		assert.EqualError(t, checkForwarderCompression(config), "invalid compression of the endpoint 'series_v2' in 'forwarder_compression_per_endpoint': "+zstdErr.Error())
+	}
+
+	config.Set("forwarder_compression_kind", "zstd")
+	config.Set("forwarder_compression_per_endpoint", map[string]string{})
+	if zstdErr == nil {
+		assert.NoError(t, checkForwarderCompression(config))
+	} else {
+		assert.EqualError(t, checkForwarderCompression(config), "invalid 'forwarder_compression_kind': "+zstdErr.Error())
+	}
+}
+
 // TestSecretBackendWithMultipleEndpoints tests an edge case of `viper.AllSettings()` when a config
 // key includes the key delimiter. Affects the config package when both secrets and multiple
 // endpoints are configured.
@@ -9,11 +9,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"net/url"
 
 	"github.com/fatih/color"
 
 	"github.com/DataDog/datadog-agent/cmd/agent/api/response"
 	"github.com/DataDog/datadog-agent/pkg/api/util"
+	"github.com/DataDog/datadog-agent/pkg/autodiscovery"
 	"github.com/DataDog/datadog-agent/pkg/autodiscovery/integration"
 	"github.com/DataDog/datadog-agent/pkg/collector/check"
 	"github.com/DataDog/datadog-agent/pkg/config"
@@ -92,6 +94,85 @@ func GetConfigCheck(w io.Writer, withDebug bool) error {
 	return nil
 }
 
+// GetConfigCheckExplain explains to the writer why the checks of a container
+// are scheduled or not
+func GetConfigCheckExplain(w io.Writer, container string) error {
+	if w != color.Output {
+		color.NoColor = true
+	}
+
+	c := util.GetClient(false) // FIX: get certificates right then make this true
+
+	// Set session token
+	err := util.SetAuthToken()
+	if err != nil {
+		return err
+	}
+	ipcAddress, err := config.GetIPCAddress()
+	if err != nil {
+		return err
+	}
+	explainURL := fmt.Sprintf("https://%v:%v/agent/config-check/explain?container=%s", ipcAddress, config.Datadog.GetInt("cmd_port"), url.QueryEscape(container))
+	r, err := util.DoGet(c, explainURL)
+	if err != nil {
+		if r != nil && string(r) != "" {
+			return fmt.Errorf("the agent ran into an error while explaining the checks: %s", string(r))
+		}
+		return fmt.Errorf("failed to query the agent (running?): %s", err)
+	}
+
+	explanations := []autodiscovery.ServiceExplanation{}
+	err = json.Unmarshal(r, &explanations)
+	if err != nil {
+		return err
+	}
+
+	if len(explanations) == 0 {
+		fmt.Fprintln(w, fmt.Sprintf("No service found for container %s: it isn't running, or it's excluded by the container_exclude option.", color.YellowString(container)))
+		return nil
+	}
+
+	for _, explanation := range explanations {
+		PrintServiceExplanation(w, explanation)
+	}
+	return nil
+}
+
+// PrintServiceExplanation prints a human-readable representation of the
+// resolution of the templates for a service
+func PrintServiceExplanation(w io.Writer, e autodiscovery.ServiceExplanation) {
+	fmt.Fprintln(w, fmt.Sprintf("\n=== %s ===", color.GreenString(e.Entity)))
+	if e.Error != "" {
+		fmt.Fprintln(w, fmt.Sprintf("%s: %s", color.BlueString("Error"), color.RedString(e.Error)))
+	}
+	if len(e.ADIdentifiers) > 0 {
+		fmt.Fprintln(w, fmt.Sprintf("%s:", color.BlueString("Auto-discovery IDs")))
+		for _, id := range e.ADIdentifiers {
+			fmt.Fprintln(w, fmt.Sprintf("* %s", color.CyanString(id)))
+		}
+	}
+	fmt.Fprintln(w, fmt.Sprintf("%s:", color.BlueString("Templates")))
+	for _, outcome := range e.Outcomes {
+		status := color.RedString(string(outcome.Status))
+		if outcome.Status == autodiscovery.ResolveScheduled {
+			status = color.GreenString(string(outcome.Status))
+		}
+		if outcome.Template == "" {
+			fmt.Fprintln(w, fmt.Sprintf("* %s", status))
+		} else {
+			source := outcome.Source
+			if source == "" {
+				source = outcome.Provider
+			}
+			fmt.Fprintln(w, fmt.Sprintf("* %s (%s): %s", color.CyanString(outcome.Template), source, status))
+		}
+		if outcome.Error != "" {
+			fmt.Fprintln(w, fmt.Sprintf("  %s", outcome.Error))
+		}
+	}
+	fmt.Fprintln(w, "===")
+}
+
 // GetClusterAgentConfigCheck proxies GetConfigCheck overidding the URL
 func GetClusterAgentConfigCheck(w io.Writer, withDebug bool) error {
 	configCheckURL = fmt.Sprintf("https://localhost:%v/config-check", config.Datadog.GetInt("cluster_agent.cmd_port"))
@@ -105,3 +105,18 @@ func (e *blockedEndpoints) isBlock(endpoint string) bool {
 func (e *blockedEndpoints) getBackoffDuration(numErrors int) time.Duration {
 	return e.backoffPolicy.GetBackoffDuration(numErrors)
 }
+
+// hasBlockedEndpoint returns whether the circuit breaker currently blocks some of
+// the endpoints.
+func (e *blockedEndpoints) hasBlockedEndpoint() bool {
+	e.m.RLock()
+	defer e.m.RUnlock()
+
+	now := time.Now()
+	for _, b := range e.errorPerEndpoint {
+		if now.Before(b.until) {
+			return true
+		}
+	}
+	return false
+}
@@ -7,6 +7,7 @@ package forwarder
 
 import (
 	"fmt"
+	"net/http"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -40,6 +41,10 @@ type domainForwarder struct {
 	m                         sync.Mutex // To control Start/Stop races
 	transactionPrioritySorter retry.TransactionPrioritySorter
 	blockedList               *blockedEndpoints
+	egressLimit               int // in bytes per second, 0 means unlimited
+	egressQueueMaxSize        int
+	egress                    *egressScheduler
+	transport                 *http.Transport // cloned by the workers, the agent-wide transport is used when nil
 }
 
 func newDomainForwarder(
@@ -84,12 +89,11 @@ func (f *domainForwarder) retryTransactions(retryBefore time.Time) {
 	for _, t := range transactions {
 		transactionEndpointName := t.GetEndpointName()
 		if !f.blockedList.isBlock(t.GetTarget()) {
-			select {
-			case f.lowPrio <- t:
+			if f.scheduleRetry(t) {
 				transactionsRetriedByEndpoint.Add(transactionEndpointName, 1)
 				transactionsRetried.Add(1)
 				tlmTxRetried.Inc(f.domain, transactionEndpointName)
-			default:
+			} else {
 				dropCount := f.addToTransactionRetryQueue(t)
 				tlmTxRequeued.Inc(f.domain, transactionEndpointName)
 				droppedWorkerBusy += dropCount
@@ -112,6 +116,21 @@ func (f *domainForwarder) retryTransactions(retryBefore time.Time) {
 	}
 }
 
+// scheduleRetry sends a transaction from the retry queue to the workers, through the
+// egress scheduler when the bandwidth is limited. It returns false if the workers
+// or the egress scheduler are too busy.
+func (f *domainForwarder) scheduleRetry(t transaction.Transaction) bool {
+	if f.egress != nil {
+		return f.egress.add(t)
+	}
+	select {
+	case f.lowPrio <- t:
+		return true
+	default:
+		return false
+	}
+}
+
 func (f *domainForwarder) addToTransactionRetryQueue(t transaction.Transaction) int {
 	dropCount, err := f.retryQueue.Add(t)
 	if err != nil {
@@ -196,10 +215,14 @@ func (f *domainForwarder) Start() error {
 	f.init()
 
 	for i := 0; i < f.numberOfWorkers; i++ {
-		w := NewWorker(f.highPrio, f.lowPrio, f.requeuedTransaction, f.blockedList)
+		w := newWorker(f.highPrio, f.lowPrio, f.requeuedTransaction, f.blockedList, f.transport)
 		w.Start()
 		f.workers = append(f.workers, w)
 	}
+	if f.egressLimit > 0 {
+		f.egress = newEgressScheduler(f.domain, f.egressLimit, f.egressQueueMaxSize, f.highPrio)
+		f.egress.start()
+	}
 	go f.handleFailedTransactions()
 	if f.connectionResetInterval != 0 {
 		go f.scheduleConnectionResets()
@@ -224,6 +247,13 @@ func (f *domainForwarder) Stop(purgeHighPrio bool) {
 		f.stopConnectionReset <- true
 	}
 	f.stopRetry <- true
+	if f.egress != nil {
+		// transactions waiting for the bandwidth are retried on the next start
+		for _, t := range f.egress.stop() {
+			f.addToTransactionRetryQueue(t)
+		}
+		f.egress = nil
+	}
 	for _, w := range f.workers {
 		w.Stop(purgeHighPrio)
 	}
@@ -244,6 +274,16 @@ func (f *domainForwarder) State() uint32 {
 }
 
 func (f *domainForwarder) sendHTTPTransactions(t transaction.Transaction) error {
+	if f.egress != nil {
+		if !f.egress.add(t) {
+			f.addToTransactionRetryQueue(t)
+			transactionsDroppedOnInput.Add(1)
+			tlmTxDroppedOnInput.Inc(f.domain, t.GetEndpointName())
+			return fmt.Errorf("the forwarder egress queue for %s is full: dropping transaction", f.domain)
+		}
+		return nil
+	}
+
 	// We don't want to block the collector if the highPrio queue is full
 	select {
 	case f.highPrio <- t:
@@ -273,6 +273,30 @@ func TestDomainForwarderRetryQueueAllPayloadsMaxSize(t *testing.T) {
 	require.Equal(t, 2, trs[1].GetPayloadSize())
 }
 
+func TestDomainForwarderEgressLimit(t *testing.T) {
+	forwarder := newDomainForwarderForTest(0)
+	forwarder.numberOfWorkers = 0
+	forwarder.egressLimit = 1
+	forwarder.egressQueueMaxSize = 10
+	forwarder.retryQueue = retry.NewTransactionRetryQueue(transaction.SortByCreatedTimeAndPriority{HighPriorityFirst: true}, nil, 100, 0, retry.TransactionRetryQueueTelemetry{})
+	forwarder.Start()
+
+	// the first transaction is sent with the burst, the next ones wait for the bandwidth
+	// it used, the third one fills the queue
+	assert.NoError(t, forwarder.sendHTTPTransactions(newEgressTestTransaction(seriesEndpoint, 5)))
+	assert.Eventually(t, func() bool { return len(forwarder.highPrio) == 1 }, time.Second, 10*time.Millisecond)
+	assert.NoError(t, forwarder.sendHTTPTransactions(newEgressTestTransaction(sketchSeriesEndpoint, 5)))
+	assert.NoError(t, forwarder.sendHTTPTransactions(newEgressTestTransaction(seriesEndpoint, 5)))
+	assert.Error(t, forwarder.sendHTTPTransactions(newEgressTestTransaction(seriesEndpoint, 5)))
+	assert.Len(t, forwarder.highPrio, 1)
+	requireLenForwarderRetryQueue(t, forwarder, 1)
+
+	// the transactions still waiting for the bandwidth are retried later
+	forwarder.Stop(false)
+	assert.Nil(t, forwarder.egress)
+	requireLenForwarderRetryQueue(t, forwarder, 3)
+}
+
 func TestDomainForwarderInitConfigs(t *testing.T) {
 	// Test default values
 	forwarder := newDomainForwarderForTest(0)
@@ -0,0 +1,307 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package forwarder
+
+import (
+	"context"
+	"expvar"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/DataDog/datadog-agent/pkg/forwarder/transaction"
+	"github.com/DataDog/datadog-agent/pkg/telemetry"
+)
+
+// Payload types reported by the egress scheduler
+const (
+	egressServiceChecks = "service_checks"
+	egressHostMetadata  = "host_metadata"
+	egressSeries        = "series"
+	egressSketches      = "sketches"
+	egressOther         = "other"
+	egressProcesses     = "processes"
+	egressOrchestrator  = "orchestrator"
+)
+
+// egressPriorities maps the payload types to their scheduling priority, 0 being
+// the first drained.
+var egressPriorities = map[string]int{
+	egressServiceChecks: 0,
+	egressHostMetadata:  0,
+	egressSeries:        1,
+	egressSketches:      2,
+	egressOther:         3,
+	egressProcesses:     4,
+	egressOrchestrator:  4,
+}
+
+const egressPriorityCount = 5
+
+var (
+	egressShapingExpvars           = expvar.Map{}
+	egressRateLimitByDomain        = expvar.Map{}
+	egressQueuedBytesByPayloadType = expvar.Map{}
+
+	tlmTxEgressQueuedBytes = telemetry.NewGauge("transactions", "egress_queued_bytes",
+		[]string{"domain", "payload_type"}, "Bytes waiting for the egress rate limit")
+	tlmTxEgressThrottled = telemetry.NewCounter("transactions", "egress_throttled",
+		[]string{"domain", "endpoint"}, "Count of transactions delayed by the egress rate limit")
+)
+
+func initEgressSchedulerExpvars() {
+	egressRateLimitByDomain.Init()
+	egressQueuedBytesByPayloadType.Init()
+	egressShapingExpvars.Set("RateLimitByDomain", &egressRateLimitByDomain)
+	egressShapingExpvars.Set("QueuedBytesByPayloadType", &egressQueuedBytesByPayloadType)
+	transaction.ForwarderExpvars.Set("EgressShaping", &egressShapingExpvars)
+}
+
+// egressPayloadType returns the payload type of a transaction, based on its endpoint.
+func egressPayloadType(t transaction.Transaction) string {
+	switch t.GetEndpointName() {
+	case v1CheckRunsEndpoint.Name, serviceChecksEndpoint.Name:
+		return egressServiceChecks
+	case hostMetadataEndpoint.Name:
+		return egressHostMetadata
+	case v1IntakeEndpoint.Name:
+		// host metadata is sent to the v1 intake, with a high priority
+		if t.GetPriority() == transaction.TransactionPriorityHigh {
+			return egressHostMetadata
+		}
+		return egressOther
+	case v1SeriesEndpoint.Name, seriesEndpoint.Name:
+		return egressSeries
+	case v1SketchSeriesEndpoint.Name, sketchSeriesEndpoint.Name:
+		return egressSketches
+	case processesEndpoint.Name, rtProcessesEndpoint.Name, containerEndpoint.Name, rtContainerEndpoint.Name, connectionsEndpoint.Name:
+		return egressProcesses
+	case orchestratorEndpoint.Name:
+		return egressOrchestrator
+	default:
+		return egressOther
+	}
+}
+
+// egressScheduler limits the bandwidth used to send the transactions of a domain.
+// Queued transactions are sent by payload type priority, then in the order they
+// were queued.
+type egressScheduler struct {
+	domain         string
+	limiter        *rate.Limiter
+	maxQueuedBytes int
+	output         chan<- transaction.Transaction
+
+	m           sync.Mutex
+	queues      [egressPriorityCount][]transaction.Transaction
+	queuedBytes int
+	bytesByType map[string]int
+
+	notify  chan struct{}
+	cancel  context.CancelFunc
+	stopped chan struct{}
+}
+
+func newEgressScheduler(domain string, bytesPerSecond int, maxQueuedBytes int, output chan<- transaction.Transaction) *egressScheduler {
+	return &egressScheduler{
+		domain: domain,
+		// the burst is the largest amount of bytes which can be sent at once,
+		// larger payloads are sent once enough tokens were accumulated.
+		limiter:        rate.NewLimiter(rate.Limit(bytesPerSecond), bytesPerSecond),
+		maxQueuedBytes: maxQueuedBytes,
+		output:         output,
+		bytesByType:    make(map[string]int),
+		notify:         make(chan struct{}, 1),
+	}
+}
+
+// add queues a transaction, it returns false when the queue is full.
+func (s *egressScheduler) add(t transaction.Transaction) bool {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	size := t.GetPayloadSize()
+	// always accept a transaction when the queue is empty, even if it is too big
+	if s.queuedBytes > 0 && s.queuedBytes+size > s.maxQueuedBytes {
+		return false
+	}
+
+	payloadType := egressPayloadType(t)
+	priority := egressPriorities[payloadType]
+	s.queues[priority] = append(s.queues[priority], t)
+	s.updateQueuedBytes(payloadType, size)
+
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+// next removes the transaction to send first from the queues, it returns nil if
+// there is none. Its bytes stay queued until it is released.
+func (s *egressScheduler) next() transaction.Transaction {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	return s.pop()
+}
+
+// nextAllowed removes the transaction to send first from the queues if the rate limit
+// allows sending it at now, and reserves its bytes. Otherwise it returns nil and the delay
+// after which the rate limit allows it, 0 when the queues are empty.
+//
+// The transaction is only chosen once the rate limit allows sending it, so that the
+// transactions queued while the previous ones were throttled are still sent by priority.
+// The bytes of a transaction larger than the burst are sent once the burst is allowed,
+// the remaining ones delay the next transactions.
+func (s *egressScheduler) nextAllowed(now time.Time) (transaction.Transaction, time.Duration) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	t := s.peek()
+	if t == nil {
+		return nil, 0
+	}
+
+	size, burst := t.GetPayloadSize(), s.limiter.Burst()
+	n := size
+	if n > burst {
+		n = burst
+	}
+	r := s.limiter.ReserveN(now, n)
+	if delay := r.DelayFrom(now); delay > 0 {
+		r.CancelAt(now)
+		return nil, delay
+	}
+	for size -= n; size > 0; size -= n {
+		if n > size {
+			n = size
+		}
+		s.limiter.ReserveN(now, n)
+	}
+	return s.pop(), 0
+}
+
+// peek returns the transaction to send first, it must be called with s.m locked
+func (s *egressScheduler) peek() transaction.Transaction {
+	for _, queue := range s.queues {
+		if len(queue) > 0 {
+			return queue[0]
+		}
+	}
+	return nil
+}
+
+// pop removes the transaction to send first from the queues, it must be called with
+// s.m locked
+func (s *egressScheduler) pop() transaction.Transaction {
+	for priority, queue := range s.queues {
+		if len(queue) == 0 {
+			continue
+		}
+		t := queue[0]
+		queue[0] = nil
+		s.queues[priority] = queue[1:]
+		return t
+	}
+	return nil
+}
+
+// release removes the bytes of a transaction returned by next from the queued bytes
+func (s *egressScheduler) release(t transaction.Transaction) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	s.updateQueuedBytes(egressPayloadType(t), -t.GetPayloadSize())
+}
+
+// updateQueuedBytes must be called with s.m locked
+func (s *egressScheduler) updateQueuedBytes(payloadType string, delta int) {
+	s.queuedBytes += delta
+	s.bytesByType[payloadType] += delta
+	egressQueuedBytesByPayloadType.Add(payloadType, int64(delta))
+	tlmTxEgressQueuedBytes.Set(float64(s.bytesByType[payloadType]), s.domain, payloadType)
+}
+
+func (s *egressScheduler) start() {
+	var ctx context.Context
+	ctx, s.cancel = context.WithCancel(context.Background())
+	s.stopped = make(chan struct{})
+	egressRateLimitByDomain.Set(s.domain, expvarInt(int64(s.limiter.Limit())))
+
+	go func() {
+		defer close(s.stopped)
+		throttled := false
+		for {
+			t, delay := s.nextAllowed(time.Now())
+			if t == nil {
+				var timer *time.Timer
+				var expired <-chan time.Time
+				if delay > 0 {
+					throttled = true
+					timer = time.NewTimer(delay)
+					expired = timer.C
+				}
+				// a transaction queued meanwhile may have a higher priority
+				select {
+				case <-s.notify:
+				case <-expired:
+				case <-ctx.Done():
+				}
+				if timer != nil {
+					timer.Stop()
+				}
+				if ctx.Err() != nil {
+					return
+				}
+				continue
+			}
+
+			if throttled {
+				tlmTxEgressThrottled.Inc(s.domain, t.GetEndpointName())
+				throttled = false
+			}
+			select {
+			case s.output <- t:
+				s.release(t)
+			case <-ctx.Done():
+				s.requeueFront(t)
+				return
+			}
+		}
+	}()
+}
+
+// requeueFront puts back a transaction returned by next at the head of its queue
+func (s *egressScheduler) requeueFront(t transaction.Transaction) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	priority := egressPriorities[egressPayloadType(t)]
+	s.queues[priority] = append([]transaction.Transaction{t}, s.queues[priority]...)
+}
+
+// stop stops sending the transactions and returns the ones which were still queued.
+func (s *egressScheduler) stop() []transaction.Transaction {
+	s.cancel()
+	<-s.stopped
+	egressRateLimitByDomain.Delete(s.domain)
+
+	var pending []transaction.Transaction
+	for t := s.next(); t != nil; t = s.next() {
+		s.release(t)
+		pending = append(pending, t)
+	}
+	return pending
+}
+
+func expvarInt(v int64) *expvar.Int {
+	i := &expvar.Int{}
+	i.Set(v)
+	return i
+}
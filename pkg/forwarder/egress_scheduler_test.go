@@ -0,0 +1,180 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package forwarder
+
+import (
+	"expvar"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/forwarder/transaction"
+)
+
+func newEgressTestTransaction(endpoint transaction.Endpoint, size int) *transaction.HTTPTransaction {
+	t := transaction.NewHTTPTransaction()
+	t.Endpoint = endpoint
+	payload := make([]byte, size)
+	t.Payload = &payload
+	return t
+}
+
+func queuedBytes(payloadType string) int64 {
+	if v, ok := egressQueuedBytesByPayloadType.Get(payloadType).(*expvar.Int); ok {
+		return v.Value()
+	}
+	return 0
+}
+
+func TestEgressPayloadType(t *testing.T) {
+	hostMetadata := newEgressTestTransaction(v1IntakeEndpoint, 1)
+	hostMetadata.Priority = transaction.TransactionPriorityHigh
+
+	for _, tt := range []struct {
+		transaction *transaction.HTTPTransaction
+		want        string
+	}{
+		{newEgressTestTransaction(v1CheckRunsEndpoint, 1), egressServiceChecks},
+		{newEgressTestTransaction(serviceChecksEndpoint, 1), egressServiceChecks},
+		{newEgressTestTransaction(hostMetadataEndpoint, 1), egressHostMetadata},
+		{hostMetadata, egressHostMetadata},
+		{newEgressTestTransaction(v1IntakeEndpoint, 1), egressOther},
+		{newEgressTestTransaction(v1SeriesEndpoint, 1), egressSeries},
+		{newEgressTestTransaction(seriesEndpoint, 1), egressSeries},
+		{newEgressTestTransaction(sketchSeriesEndpoint, 1), egressSketches},
+		{newEgressTestTransaction(eventsEndpoint, 1), egressOther},
+		{newEgressTestTransaction(rtContainerEndpoint, 1), egressProcesses},
+		{newEgressTestTransaction(connectionsEndpoint, 1), egressProcesses},
+		{newEgressTestTransaction(orchestratorEndpoint, 1), egressOrchestrator},
+	} {
+		assert.Equal(t, tt.want, egressPayloadType(tt.transaction), tt.transaction.GetEndpointName())
+	}
+}
+
+func TestEgressSchedulerPriority(t *testing.T) {
+	s := newEgressScheduler("test", 1000, 100, nil)
+
+	orchestrator := newEgressTestTransaction(orchestratorEndpoint, 10)
+	sketches := newEgressTestTransaction(sketchSeriesEndpoint, 10)
+	series1 := newEgressTestTransaction(seriesEndpoint, 10)
+	series2 := newEgressTestTransaction(seriesEndpoint, 10)
+	events := newEgressTestTransaction(eventsEndpoint, 10)
+	serviceChecks := newEgressTestTransaction(serviceChecksEndpoint, 10)
+	hostMetadata := newEgressTestTransaction(hostMetadataEndpoint, 10)
+
+	for _, tr := range []transaction.Transaction{orchestrator, sketches, series1, events, serviceChecks, series2, hostMetadata} {
+		require.True(t, s.add(tr))
+	}
+	assert.Equal(t, int64(20), queuedBytes(egressSeries))
+	assert.Equal(t, int64(10), queuedBytes(egressOrchestrator))
+
+	// the queue is full
+	assert.False(t, s.add(newEgressTestTransaction(serviceChecksEndpoint, 31)))
+
+	for _, want := range []transaction.Transaction{serviceChecks, hostMetadata, series1, series2, sketches, events, orchestrator} {
+		tr := s.next()
+		require.Equal(t, want, tr)
+		s.release(tr)
+	}
+	assert.Nil(t, s.next())
+	assert.Equal(t, int64(0), queuedBytes(egressSeries))
+	assert.Equal(t, int64(0), queuedBytes(egressOrchestrator))
+
+	// a transaction larger than the queue is accepted when the queue is empty
+	assert.True(t, s.add(newEgressTestTransaction(seriesEndpoint, 1000)))
+	s.release(s.next())
+}
+
+func TestEgressSchedulerRateLimit(t *testing.T) {
+	output := make(chan transaction.Transaction, 10)
+	s := newEgressScheduler("test", 10000, 100000, output)
+	s.start()
+	defer s.stop()
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		require.True(t, s.add(newEgressTestTransaction(seriesEndpoint, 5000)))
+	}
+
+	// the first two transactions fit in the burst, the third one waits for 0.5s
+	for i := 0; i < 2; i++ {
+		select {
+		case <-output:
+		case <-time.After(200 * time.Millisecond):
+			require.Fail(t, "transaction not sent")
+		}
+	}
+	select {
+	case <-output:
+		require.Fail(t, "transaction sent before the rate limit allows it")
+	case <-time.After(200 * time.Millisecond):
+	}
+	select {
+	case <-output:
+		assert.True(t, time.Since(start) >= 400*time.Millisecond)
+	case <-time.After(time.Second):
+		require.Fail(t, "transaction not sent")
+	}
+
+	// a transaction larger than the burst is sent once the burst is allowed
+	require.True(t, s.add(newEgressTestTransaction(seriesEndpoint, 15000)))
+	select {
+	case <-output:
+	case <-time.After(3 * time.Second):
+		require.Fail(t, "transaction not sent")
+	}
+}
+
+func TestEgressSchedulerPriorityWhileThrottled(t *testing.T) {
+	output := make(chan transaction.Transaction, 10)
+	s := newEgressScheduler("test", 10000, 100000, output)
+	s.start()
+	defer s.stop()
+
+	receive := func() transaction.Transaction {
+		select {
+		case tr := <-output:
+			return tr
+		case <-time.After(3 * time.Second):
+			require.Fail(t, "transaction not sent")
+			return nil
+		}
+	}
+
+	// the series use the whole burst, the orchestrator payload waits for 1s
+	series := newEgressTestTransaction(seriesEndpoint, 10000)
+	orchestrator := newEgressTestTransaction(orchestratorEndpoint, 10000)
+	require.True(t, s.add(series))
+	require.True(t, s.add(orchestrator))
+	assert.Equal(t, series, receive())
+
+	// the service checks queued meanwhile are sent first
+	time.Sleep(200 * time.Millisecond)
+	serviceChecks := newEgressTestTransaction(serviceChecksEndpoint, 1000)
+	require.True(t, s.add(serviceChecks))
+	assert.Equal(t, serviceChecks, receive())
+	assert.Equal(t, orchestrator, receive())
+}
+
+func TestEgressSchedulerStop(t *testing.T) {
+	// nobody reads the output: the first transaction is blocked
+	s := newEgressScheduler("test", 1000, 100000, make(chan transaction.Transaction))
+	s.start()
+	assert.NotNil(t, egressRateLimitByDomain.Get("test"))
+
+	series := newEgressTestTransaction(seriesEndpoint, 10)
+	sketches := newEgressTestTransaction(sketchSeriesEndpoint, 10)
+	require.True(t, s.add(sketches))
+	require.True(t, s.add(series))
+
+	pending := s.stop()
+	assert.ElementsMatch(t, []transaction.Transaction{series, sketches}, pending)
+	assert.Equal(t, int64(0), queuedBytes(egressSeries))
+	assert.Equal(t, int64(0), queuedBytes(egressSketches))
+	assert.Nil(t, egressRateLimitByDomain.Get("test"))
+}
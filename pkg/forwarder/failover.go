@@ -0,0 +1,309 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package forwarder
+
+import (
+	"expvar"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/config"
+	"github.com/DataDog/datadog-agent/pkg/forwarder/transaction"
+	"github.com/DataDog/datadog-agent/pkg/telemetry"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+var (
+	failoverExpvars          = expvar.Map{}
+	failoverActiveDomain     = expvar.String{}
+	failoverSwitches         = expvar.Int{}
+	failoverMigratedByDomain = expvar.Map{}
+
+	tlmFailoverSwitches = telemetry.NewCounter("forwarder", "failover_switches",
+		[]string{"from", "to"}, "Count of the switches of the active domain of the failover group")
+	tlmFailoverMigrated = telemetry.NewCounter("transactions", "failover_migrated",
+		[]string{"from", "to"}, "Count of transactions migrated from the retry queue of a passive domain to the active one")
+)
+
+func initFailoverExpvars() {
+	failoverMigratedByDomain.Init()
+	failoverExpvars.Set("ActiveDomain", &failoverActiveDomain)
+	failoverExpvars.Set("Switches", &failoverSwitches)
+	failoverExpvars.Set("MigratedByDomain", &failoverMigratedByDomain)
+	transaction.ForwarderExpvars.Set("Failover", &failoverExpvars)
+}
+
+// failover selects the active domain of a failover group: a primary domain and
+// its secondary domains, only the active one receiving the payloads. The domains
+// outside of the group always receive them.
+//
+// The primary domain is active as long as it's healthy. When the active domain
+// becomes unhealthy, the next reachable domain of the group becomes active, and
+// the traffic fails back to a preferred domain once it has been healthy for the
+// failback delay.
+type failover struct {
+	// domains are the domains of the group, the primary one first and then the
+	// secondary ones by order of preference
+	domains       []string
+	failbackDelay time.Duration
+
+	// healthySince is the time since which each preferred passive domain is
+	// known to be reachable, only accessed by update
+	healthySince map[string]time.Time
+
+	m      sync.RWMutex
+	active int
+}
+
+func newFailover(domains []string, failbackDelay time.Duration) *failover {
+	failoverActiveDomain.Set(domains[0])
+	return &failover{
+		domains:       domains,
+		failbackDelay: failbackDelay,
+		healthySince:  make(map[string]time.Time),
+	}
+}
+
+// receivesTraffic returns whether new payloads are sent to domain.
+func (fo *failover) receivesTraffic(domain string) bool {
+	fo.m.RLock()
+	defer fo.m.RUnlock()
+
+	for i, d := range fo.domains {
+		if d == domain {
+			return i == fo.active
+		}
+	}
+	return true
+}
+
+// isPassive returns whether domain belongs to the group without being active.
+func (fo *failover) isPassive(domain string) bool {
+	return !fo.receivesTraffic(domain)
+}
+
+func (fo *failover) activeDomain() string {
+	fo.m.RLock()
+	defer fo.m.RUnlock()
+
+	return fo.domains[fo.active]
+}
+
+// update selects the active domain from the health of the current active domain,
+// and the reachability of the passive domains checked with probe. It returns
+// whether the active domain changed.
+func (fo *failover) update(now time.Time, activeHealthy bool, probe func(domain string) bool) bool {
+	// update is the only writer of active, no lock is needed to read it here,
+	// and none is held while probing the domains.
+	active := fo.active
+	next := active
+
+	// fail back to the first preferred domain healthy for long enough, or to the
+	// first reachable one if the active domain is unhealthy
+	for i := 0; i < active; i++ {
+		domain := fo.domains[i]
+		if !probe(domain) {
+			delete(fo.healthySince, domain)
+			continue
+		}
+		since, ok := fo.healthySince[domain]
+		if !ok {
+			since = now
+			fo.healthySince[domain] = now
+		}
+		if !activeHealthy || now.Sub(since) >= fo.failbackDelay {
+			next = i
+			break
+		}
+	}
+
+	// fail over to the next reachable domain
+	if next == active && !activeHealthy {
+		for i := active + 1; i < len(fo.domains); i++ {
+			if probe(fo.domains[i]) {
+				next = i
+				break
+			}
+		}
+	}
+
+	if next == active {
+		return false
+	}
+
+	from, to := fo.domains[active], fo.domains[next]
+	if next < active {
+		log.Infof("Failing back from domain '%s' to domain '%s'", from, to)
+	} else {
+		log.Warnf("Domain '%s' is unhealthy, failing over to domain '%s'", from, to)
+	}
+	failoverSwitches.Add(1)
+	failoverActiveDomain.Set(to)
+	tlmFailoverSwitches.Inc(from, to)
+
+	for i := next; i < len(fo.domains); i++ {
+		delete(fo.healthySince, fo.domains[i])
+	}
+
+	fo.m.Lock()
+	fo.active = next
+	fo.m.Unlock()
+	return true
+}
+
+// retarget returns the copies of t to send to toDomain, one per API key of toKeys.
+// A payload is copied in a transaction per API key of its domain: only the copy
+// made for fromKey is retargeted, nil being returned for the other ones. Each copy
+// is a new submission and gets its own idempotency key.
+func retarget(t *transaction.HTTPTransaction, fromKey, toDomain string, toKeys []string) []*transaction.HTTPTransaction {
+	apiKey := t.Headers.Get(apiHTTPHeaderKey)
+	if apiKey != fromKey {
+		return nil
+	}
+
+	transactions := make([]*transaction.HTTPTransaction, 0, len(toKeys))
+	for _, key := range toKeys {
+		c := *t
+		c.Domain = toDomain
+		c.Headers = t.Headers.Clone()
+		c.Headers.Set(apiHTTPHeaderKey, key)
+		c.Headers.Set(transaction.IdempotencyKeyHTTPHeaderKey, transaction.NewIdempotencyKey())
+		c.AmbiguousFailure = false
+		if strings.HasSuffix(t.Endpoint.Route, "api_key="+apiKey) {
+			c.Endpoint.Route = strings.TrimSuffix(t.Endpoint.Route, apiKey) + key
+		}
+		transactions = append(transactions, &c)
+	}
+	return transactions
+}
+
+// setFailover sets the failover group of the forwarder from options, ignoring the
+// domains without API keys.
+func (f *DefaultForwarder) setFailover(options *Options) {
+	if len(options.FailoverSecondaryDomains) == 0 {
+		return
+	}
+
+	var domains []string
+	configDomains := make(map[string]string)
+	for _, failoverDomain := range append([]string{options.FailoverPrimaryDomain}, options.FailoverSecondaryDomains...) {
+		configDomain, found := findConfigDomain(options.KeysPerDomain, failoverDomain)
+		domain, _ := config.AddAgentVersionToDomain(configDomain, "app")
+		if _, ok := f.domainForwarders[domain]; !found || !ok {
+			log.Errorf("Ignoring the failover domain '%s': it isn't an endpoint of the forwarder", failoverDomain)
+			continue
+		}
+		if _, ok := configDomains[domain]; ok {
+			continue
+		}
+		domains = append(domains, domain)
+		configDomains[domain] = configDomain
+	}
+
+	if len(domains) < 2 {
+		log.Errorf("The failover needs a primary and a secondary domain, every domain receives the payloads")
+		return
+	}
+	log.Infof("Sending the payloads to the domains %s by order of preference", strings.Join(domains, ", "))
+	f.failover = newFailover(domains, options.FailoverFailbackDelay)
+	f.failoverCheckInterval = options.FailoverCheckInterval
+	f.failoverConfigDomains = configDomains
+}
+
+// findConfigDomain returns the domain of keysPerDomain matching domain, the domains
+// of `additional_endpoints` being lowercased by the configuration.
+func findConfigDomain(keysPerDomain map[string][]string, domain string) (string, bool) {
+	domain = strings.TrimSuffix(domain, "/")
+	for configDomain := range keysPerDomain {
+		if strings.EqualFold(strings.TrimSuffix(configDomain, "/"), domain) {
+			return configDomain, true
+		}
+	}
+	return "", false
+}
+
+func (f *DefaultForwarder) runFailover() {
+	defer close(f.failoverStopped)
+
+	ticker := time.NewTicker(f.failoverCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			f.checkFailover(now)
+		case <-f.stopFailover:
+			return
+		}
+	}
+}
+
+// checkFailover updates the active domain, and sends the transactions waiting for
+// an unhealthy passive domain to the active one.
+func (f *DefaultForwarder) checkFailover(now time.Time) {
+	active := f.failover.activeDomain()
+	activeHealthy := !f.domainForwarders[active].blockedList.hasBlockedEndpoint()
+	if f.failover.update(now, activeHealthy, f.isDomainReachable) {
+		active = f.failover.activeDomain()
+		activeHealthy = true
+	}
+	if !activeHealthy {
+		return
+	}
+
+	for _, domain := range f.failover.domains {
+		if domain != active && f.domainForwarders[domain].blockedList.hasBlockedEndpoint() {
+			f.migrateRetryQueue(domain, active)
+		}
+	}
+}
+
+// isDomainReachable probes a domain not receiving the payloads.
+func (f *DefaultForwarder) isDomainReachable(domain string) bool {
+	reachable := f.healthChecker.isDomainReachable(f.failoverConfigDomains[domain], f.keysPerDomains[domain][0])
+	log.Debugf("Failover domain '%s' reachable: %v", domain, reachable)
+	return reachable
+}
+
+// migrateRetryQueue moves the transactions of the retry queue of the domain from to
+// the retry queue of the domain to, so that they are sent to the active domain.
+func (f *DefaultForwarder) migrateRetryQueue(from, to string) {
+	source, target := f.domainForwarders[from], f.domainForwarders[to]
+	fromKey, toKeys := f.keysPerDomains[from][0], f.keysPerDomains[to]
+
+	migrated := 0
+	var kept []transaction.Transaction
+	for {
+		transactions, err := source.retryQueue.ExtractTransactions()
+		if err != nil {
+			log.Errorf("Cannot migrate the transactions of domain '%s' to domain '%s': %v", from, to, err)
+			break
+		}
+		if len(transactions) == 0 {
+			break
+		}
+		for _, t := range transactions {
+			httpTransaction, ok := t.(*transaction.HTTPTransaction)
+			if !ok {
+				kept = append(kept, t)
+				continue
+			}
+			for _, retargeted := range retarget(httpTransaction, fromKey, to, toKeys) {
+				target.addToTransactionRetryQueue(retargeted)
+				migrated++
+			}
+		}
+	}
+	for _, t := range kept {
+		source.addToTransactionRetryQueue(t)
+	}
+
+	if migrated > 0 {
+		log.Infof("Migrated %d transactions from the retry queue of domain '%s' to domain '%s'", migrated, from, to)
+		failoverMigratedByDomain.Add(from, int64(migrated))
+		tlmFailoverMigrated.Add(float64(migrated), from, to)
+	}
+}
@@ -0,0 +1,214 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package forwarder
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/forwarder/transaction"
+)
+
+func TestFailoverUpdate(t *testing.T) {
+	fo := newFailover([]string{"primary", "secondary", "tertiary"}, time.Minute)
+	reachable := map[string]bool{"primary": true, "secondary": true, "tertiary": true}
+	probe := func(domain string) bool { return reachable[domain] }
+	now := time.Now()
+
+	assert.True(t, fo.receivesTraffic("primary"))
+	assert.False(t, fo.receivesTraffic("secondary"))
+	assert.True(t, fo.receivesTraffic("other"))
+
+	// the active domain stays active while it's healthy
+	assert.False(t, fo.update(now, true, probe))
+	assert.Equal(t, "primary", fo.activeDomain())
+
+	// fail over to the next reachable domain
+	reachable["secondary"] = false
+	assert.True(t, fo.update(now, false, probe))
+	assert.Equal(t, "tertiary", fo.activeDomain())
+	assert.True(t, fo.isPassive("primary"))
+	assert.True(t, fo.isPassive("secondary"))
+
+	// the failback only happens once a preferred domain has been reachable for the delay
+	reachable["secondary"] = true
+	assert.False(t, fo.update(now.Add(time.Second), true, probe))
+	assert.False(t, fo.update(now.Add(30*time.Second), true, probe))
+	assert.True(t, fo.update(now.Add(61*time.Second), true, probe))
+	assert.Equal(t, "primary", fo.activeDomain())
+
+	// an unreachable domain resets the failback delay
+	assert.True(t, fo.update(now.Add(62*time.Second), false, probe))
+	assert.Equal(t, "secondary", fo.activeDomain())
+	reachable["primary"] = false
+	assert.False(t, fo.update(now.Add(63*time.Second), true, probe))
+	reachable["primary"] = true
+	assert.False(t, fo.update(now.Add(64*time.Second), true, probe))
+	assert.False(t, fo.update(now.Add(123*time.Second), true, probe))
+	assert.True(t, fo.update(now.Add(124*time.Second), true, probe))
+	assert.Equal(t, "primary", fo.activeDomain())
+
+	// a reachable preferred domain is used right away when the active one is unhealthy
+	reachable["primary"] = false
+	assert.True(t, fo.update(now.Add(125*time.Second), false, probe))
+	assert.Equal(t, "secondary", fo.activeDomain())
+	reachable["primary"] = true
+	assert.True(t, fo.update(now.Add(126*time.Second), false, probe))
+	assert.Equal(t, "primary", fo.activeDomain())
+
+	// the active domain is kept when no other one is reachable
+	reachable = map[string]bool{}
+	assert.False(t, fo.update(now.Add(127*time.Second), false, probe))
+	assert.Equal(t, "primary", fo.activeDomain())
+}
+
+func TestRetarget(t *testing.T) {
+	tr := transaction.NewHTTPTransaction()
+	tr.Domain = "http://primary"
+	tr.Endpoint = transaction.Endpoint{Route: "/api/beta/sketches?api_key=key1", Name: "sketches"}
+	tr.Headers.Set(apiHTTPHeaderKey, "key1")
+	tr.Headers.Set("Content-Type", "application/x-protobuf")
+	tr.Headers.Set(transaction.IdempotencyKeyHTTPHeaderKey, "idempotency-key")
+	tr.AmbiguousFailure = true
+
+	// the copy made for the other API key of the primary domain is skipped
+	other := transaction.NewHTTPTransaction()
+	other.Headers.Set(apiHTTPHeaderKey, "key2")
+	assert.Empty(t, retarget(other, "key1", "http://secondary", []string{"key3"}))
+
+	retargeted := retarget(tr, "key1", "http://secondary", []string{"key3", "key4"})
+	require.Len(t, retargeted, 2)
+	for i, key := range []string{"key3", "key4"} {
+		assert.Equal(t, "http://secondary", retargeted[i].Domain)
+		assert.Equal(t, "/api/beta/sketches?api_key="+key, retargeted[i].Endpoint.Route)
+		assert.Equal(t, key, retargeted[i].Headers.Get(apiHTTPHeaderKey))
+		assert.Equal(t, "application/x-protobuf", retargeted[i].Headers.Get("Content-Type"))
+		assert.NotEqual(t, "idempotency-key", retargeted[i].Headers.Get(transaction.IdempotencyKeyHTTPHeaderKey))
+		assert.False(t, retargeted[i].AmbiguousFailure)
+	}
+	assert.NotEqual(t, retargeted[0].Headers.Get(transaction.IdempotencyKeyHTTPHeaderKey), retargeted[1].Headers.Get(transaction.IdempotencyKeyHTTPHeaderKey))
+
+	// the original transaction isn't modified
+	assert.Equal(t, "http://primary", tr.Domain)
+	assert.Equal(t, "key1", tr.Headers.Get(apiHTTPHeaderKey))
+	assert.Equal(t, "idempotency-key", tr.Headers.Get(transaction.IdempotencyKeyHTTPHeaderKey))
+}
+
+type failoverTestServer struct {
+	*httptest.Server
+	down int32
+	m    sync.Mutex
+	keys []string
+}
+
+func newFailoverTestServer() *failoverTestServer {
+	s := &failoverTestServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&s.down) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if r.URL.Path != v1ValidateEndpoint.Route {
+			s.m.Lock()
+			s.keys = append(s.keys, r.Header.Get(apiHTTPHeaderKey))
+			s.m.Unlock()
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	return s
+}
+
+func (s *failoverTestServer) receivedKeys() []string {
+	s.m.Lock()
+	defer s.m.Unlock()
+	return append([]string{}, s.keys...)
+}
+
+func TestForwarderFailover(t *testing.T) {
+	primary := newFailoverTestServer()
+	defer primary.Close()
+	secondary := newFailoverTestServer()
+	defer secondary.Close()
+	other := newFailoverTestServer()
+	defer other.Close()
+
+	options := NewOptions(map[string][]string{
+		primary.URL:   {"primary1", "primary2"},
+		secondary.URL: {"secondary1"},
+		other.URL:     {"other1"},
+	})
+	options.DisableAPIKeyChecking = true
+	options.FailoverPrimaryDomain = primary.URL
+	options.FailoverSecondaryDomains = []string{secondary.URL, "http://unknown.example.com"}
+	options.FailoverCheckInterval = time.Hour // the checks are triggered by the test
+	options.FailoverFailbackDelay = 0
+	f := NewDefaultForwarder(options)
+	require.NotNil(t, f.failover)
+	require.NoError(t, f.Start())
+	defer f.Stop()
+
+	data := []byte("payload")
+	submit := func() { require.NoError(t, f.SubmitV1Series(Payloads{&data}, http.Header{})) }
+
+	// only the primary domain receives the payloads, the other domains aren't affected
+	submit()
+	assert.Eventually(t, func() bool {
+		return len(primary.receivedKeys()) == 2 && len(other.receivedKeys()) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Empty(t, secondary.receivedKeys())
+
+	// the transactions failing on the primary domain are migrated to the secondary one
+	atomic.StoreInt32(&primary.down, 1)
+	submit()
+	primaryForwarder := f.domainForwarders[primary.URL]
+	assert.Eventually(t, func() bool {
+		return primaryForwarder.retryQueue.GetTransactionCount() == 2
+	}, 5*time.Second, 10*time.Millisecond)
+	require.True(t, primaryForwarder.blockedList.hasBlockedEndpoint())
+
+	f.checkFailover(time.Now())
+	assert.Equal(t, secondary.URL, f.failover.activeDomain())
+	assert.Zero(t, primaryForwarder.retryQueue.GetTransactionCount())
+	f.domainForwarders[secondary.URL].retryTransactions(time.Now())
+	assert.Eventually(t, func() bool {
+		return len(secondary.receivedKeys()) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, []string{"secondary1"}, secondary.receivedKeys())
+
+	// new payloads are sent to the secondary domain
+	submit()
+	assert.Eventually(t, func() bool {
+		return len(secondary.receivedKeys()) == 2 && len(other.receivedKeys()) == 3
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Len(t, primary.receivedKeys(), 2)
+
+	// fail back once the primary domain is reachable again
+	atomic.StoreInt32(&primary.down, 0)
+	f.checkFailover(time.Now())
+	assert.Equal(t, primary.URL, f.failover.activeDomain())
+	submit()
+	assert.Eventually(t, func() bool {
+		return len(primary.receivedKeys()) == 4
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Len(t, secondary.receivedKeys(), 2)
+}
+
+func TestForwarderFailoverDisabled(t *testing.T) {
+	options := NewOptions(map[string][]string{
+		"http://primary.example.com": {"key"},
+	})
+	options.FailoverPrimaryDomain = "http://primary.example.com"
+	options.FailoverSecondaryDomains = []string{"http://secondary.example.com"}
+	f := NewDefaultForwarder(options)
+	assert.Nil(t, f.failover)
+}
@@ -18,8 +18,11 @@ import (
 	"github.com/DataDog/datadog-agent/pkg/config"
 	"github.com/DataDog/datadog-agent/pkg/forwarder/internal/retry"
 	"github.com/DataDog/datadog-agent/pkg/forwarder/transaction"
+	"github.com/DataDog/datadog-agent/pkg/util/compression"
+	httputils "github.com/DataDog/datadog-agent/pkg/util/http"
 	"github.com/DataDog/datadog-agent/pkg/util/log"
 	"github.com/DataDog/datadog-agent/pkg/version"
+	"github.com/mitchellh/mapstructure"
 )
 
 const (
@@ -97,8 +100,26 @@ type Forwarder interface {
 	SubmitOrchestratorChecks(payload Payloads, extra http.Header, payloadType string) (chan Response, error)
 }
 
-// Compile-time check to ensure that DefaultForwarder implements the Forwarder interface
+// CompressorProvider is implemented by the forwarders selecting the compression of the
+// payloads of each endpoint at runtime.
+type CompressorProvider interface {
+	Compressor(endpointName string) compression.Compressor
+}
+
+// Compile-time check to ensure that DefaultForwarder implements the Forwarder and
+// CompressorProvider interfaces
 var _ Forwarder = &DefaultForwarder{}
+var _ CompressorProvider = &DefaultForwarder{}
+
+// CompressorFor returns the compression to use for the payloads submitted to f for the
+// endpoint named endpointName: the one selected by f when it implements CompressorProvider,
+// the compression selected at build time otherwise.
+func CompressorFor(f Forwarder, endpointName string) compression.Compressor {
+	if p, ok := f.(CompressorProvider); ok {
+		return p.Compressor(endpointName)
+	}
+	return compression.Default()
+}
 
 // Features is a bitmask to enable specific forwarder features
 type Features uint8
@@ -124,6 +145,45 @@ type Options struct {
 	KeysPerDomain                  map[string][]string
 	ConnectionResetInterval        time.Duration
 	CompletionHandler              transaction.HTTPCompletionHandler
+	// DefaultCompression compresses the payloads of the endpoints missing from
+	// CompressionPerEndpoint. When nil, the compression selected at build time is used.
+	DefaultCompression compression.Compressor
+	// CompressionPerEndpoint maps endpoint names to the compression of their payloads.
+	// A payload is compressed once for all the domains, so it can't depend on the domain.
+	CompressionPerEndpoint map[string]compression.Compressor
+	// EgressLimit is the bandwidth, in bytes per second, each domain can use. 0 means unlimited.
+	EgressLimit int
+	// EgressLimitPerDomain overrides EgressLimit for some of the domains of KeysPerDomain
+	EgressLimitPerDomain map[string]int
+	// EgressQueueMaxSize is the maximum size, in bytes, of the transactions waiting
+	// for the bandwidth of a domain.
+	EgressQueueMaxSize int
+	// HTTPTransport configures the connections to the domains missing from
+	// HTTPTransportPerDomain
+	HTTPTransport httputils.TransportOptions
+	// HTTPTransportPerDomain overrides HTTPTransport for some of the domains of
+	// KeysPerDomain, to use another proxy or a client certificate for instance
+	HTTPTransportPerDomain map[string]httputils.TransportOptions
+	// TeeOutput is the file, or `stdout`, the payloads are written to by the
+	// Forwarder returned by NewForwarder. Empty when the payloads aren't written.
+	TeeOutput string
+	// TeeDryRun only writes the payloads to TeeOutput, without sending them
+	TeeDryRun bool
+	// TeeMaxFileSize is the size, in bytes, at which the TeeOutput file is rotated
+	TeeMaxFileSize int64
+	// TeeMaxFiles is the number of rotated TeeOutput files to keep
+	TeeMaxFiles int
+	// FailoverSecondaryDomains are domains of KeysPerDomain only receiving the payloads
+	// while FailoverPrimaryDomain is unhealthy, by order of preference. Every domain
+	// receives the payloads when empty.
+	FailoverSecondaryDomains []string
+	FailoverPrimaryDomain    string
+	// FailoverCheckInterval is the interval at which the health of the failover
+	// domains is checked
+	FailoverCheckInterval time.Duration
+	// FailoverFailbackDelay is how long a preferred domain has to be healthy before
+	// the payloads are sent to it again
+	FailoverFailbackDelay time.Duration
 }
 
 // SetFeature sets forwarder features in a feature set
@@ -165,6 +225,10 @@ func NewOptions(keysPerDomain map[string][]string) *Options {
 		APIKeyValidationInterval:       time.Duration(validationInterval) * time.Minute,
 		KeysPerDomain:                  keysPerDomain,
 		ConnectionResetInterval:        time.Duration(config.Datadog.GetInt("forwarder_connection_reset_interval")) * time.Second,
+		TeeOutput:                      config.Datadog.GetString("forwarder_tee_output"),
+		TeeDryRun:                      config.Datadog.GetBool("forwarder_tee_dry_run"),
+		TeeMaxFileSize:                 config.Datadog.GetInt64("forwarder_tee_max_file_size"),
+		TeeMaxFiles:                    config.Datadog.GetInt("forwarder_tee_max_files"),
 	}
 
 	if config.Datadog.IsSet(forwarderRetryQueueMaxSizeKey) {
@@ -182,9 +246,165 @@ func NewOptions(keysPerDomain map[string][]string) *Options {
 		}
 	}
 
+	option.setCompressionFromConfig()
+	option.setEgressLimitsFromConfig()
+	option.setHTTPTransportFromConfig()
+	option.setFailoverFromConfig()
+
 	return option
 }
 
+// setFailoverFromConfig sets the failover options from the configuration, the main
+// domain being the primary one.
+func (o *Options) setFailoverFromConfig() {
+	o.FailoverSecondaryDomains = config.Datadog.GetStringSlice("forwarder_failover_secondary_domains")
+	if len(o.FailoverSecondaryDomains) == 0 {
+		return
+	}
+	o.FailoverPrimaryDomain = config.GetMainInfraEndpoint()
+
+	checkInterval := config.Datadog.GetInt("forwarder_failover_check_interval")
+	if checkInterval <= 0 {
+		log.Warnf("'forwarder_failover_check_interval' set to invalid value (%d), defaulting to 10 seconds", checkInterval)
+		checkInterval = 10
+	}
+	o.FailoverCheckInterval = time.Duration(checkInterval) * time.Second
+
+	failbackDelay := config.Datadog.GetInt("forwarder_failover_failback_delay")
+	if failbackDelay < 0 {
+		log.Warnf("'forwarder_failover_failback_delay' set to invalid value (%d), defaulting to 300 seconds", failbackDelay)
+		failbackDelay = 300
+	}
+	o.FailoverFailbackDelay = time.Duration(failbackDelay) * time.Second
+}
+
+// setEgressLimitsFromConfig sets the egress limits from the configuration, ignoring
+// invalid values.
+func (o *Options) setEgressLimitsFromConfig() {
+	o.EgressLimit = config.Datadog.GetInt("forwarder_egress_limit_bytes_per_second")
+	if o.EgressLimit < 0 {
+		log.Warnf("'forwarder_egress_limit_bytes_per_second' set to invalid value (%d), the bandwidth is not limited", o.EgressLimit)
+		o.EgressLimit = 0
+	}
+	o.EgressQueueMaxSize = config.Datadog.GetInt("forwarder_egress_queue_max_size")
+
+	o.EgressLimitPerDomain = make(map[string]int)
+	for domain, v := range config.Datadog.GetStringMapString("forwarder_egress_limit_per_domain") {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 0 {
+			log.Warnf("Ignoring the egress limit of the domain '%s' in 'forwarder_egress_limit_per_domain': invalid value %v", domain, v)
+			continue
+		}
+		o.EgressLimitPerDomain[domain] = limit
+	}
+}
+
+// egressLimit returns the egress limit of a domain of KeysPerDomain
+func (o *Options) egressLimit(domain string) int {
+	if limit, ok := o.EgressLimitPerDomain[domain]; ok {
+		return limit
+	}
+	return o.EgressLimit
+}
+
+// domainTransportConfig is the configuration of the connections to a domain in
+// `forwarder_http_transport_per_domain`. Unset fields keep the forwarder-wide settings.
+type domainTransportConfig struct {
+	Proxy               *config.Proxy `mapstructure:"proxy"`
+	SkipProxy           bool          `mapstructure:"skip_proxy"`
+	TLSCAFile           string        `mapstructure:"tls_ca_file"`
+	TLSCertFile         string        `mapstructure:"tls_cert_file"`
+	TLSKeyFile          string        `mapstructure:"tls_key_file"`
+	HTTP2               *bool         `mapstructure:"http2"`
+	MaxIdleConnsPerHost *int          `mapstructure:"max_idle_conns_per_host"`
+	MaxConnsPerHost     *int          `mapstructure:"max_conns_per_host"`
+	IdleConnTimeout     *int          `mapstructure:"idle_conn_timeout"` // in seconds
+}
+
+// setHTTPTransportFromConfig sets the HTTP transport options from the configuration,
+// ignoring the domains whose configuration cannot be parsed.
+func (o *Options) setHTTPTransportFromConfig() {
+	o.HTTPTransport = httputils.TransportOptions{
+		HTTP2:               config.Datadog.GetBool("forwarder_http2"),
+		MaxIdleConnsPerHost: config.Datadog.GetInt("forwarder_max_idle_conns_per_host"),
+		MaxConnsPerHost:     config.Datadog.GetInt("forwarder_max_conns_per_host"),
+		IdleConnTimeout:     config.Datadog.GetDuration("forwarder_idle_conn_timeout") * time.Second,
+	}
+
+	o.HTTPTransportPerDomain = make(map[string]httputils.TransportOptions)
+	// the domains are URLs, which cannot be used in the keys given to UnmarshalKey
+	for domain, raw := range config.Datadog.GetStringMap("forwarder_http_transport_per_domain") {
+		var c domainTransportConfig
+		if err := decodeDomainTransportConfig(raw, &c); err != nil {
+			log.Warnf("Ignoring the transport of the domain '%s' in 'forwarder_http_transport_per_domain': %v", domain, err)
+			continue
+		}
+
+		options := o.HTTPTransport
+		options.Proxies = c.Proxy
+		options.NoProxy = c.SkipProxy
+		options.TLSCAFile = c.TLSCAFile
+		options.TLSCertFile = c.TLSCertFile
+		options.TLSKeyFile = c.TLSKeyFile
+		if c.HTTP2 != nil {
+			options.HTTP2 = *c.HTTP2
+		}
+		if c.MaxIdleConnsPerHost != nil {
+			options.MaxIdleConnsPerHost = *c.MaxIdleConnsPerHost
+		}
+		if c.MaxConnsPerHost != nil {
+			options.MaxConnsPerHost = *c.MaxConnsPerHost
+		}
+		if c.IdleConnTimeout != nil {
+			options.IdleConnTimeout = time.Duration(*c.IdleConnTimeout) * time.Second
+		}
+		o.HTTPTransportPerDomain[domain] = options
+	}
+}
+
+func decodeDomainTransportConfig(raw interface{}, c *domainTransportConfig) error {
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		Result:           c,
+		WeaklyTypedInput: true,
+		ErrorUnused:      true,
+	})
+	if err != nil {
+		return err
+	}
+	return decoder.Decode(raw)
+}
+
+// httpTransport returns the HTTP transport options of a domain of KeysPerDomain
+func (o *Options) httpTransport(domain string) httputils.TransportOptions {
+	if options, ok := o.HTTPTransportPerDomain[domain]; ok {
+		return options
+	}
+	return o.HTTPTransport
+}
+
+// setCompressionFromConfig sets the compression options from the configuration,
+// ignoring invalid values.
+func (o *Options) setCompressionFromConfig() {
+	if kind := config.Datadog.GetString("forwarder_compression_kind"); kind != "" {
+		c, err := compression.NewCompressor(compression.Kind(kind), config.Datadog.GetInt("forwarder_compression_level"))
+		if err != nil {
+			log.Warnf("'forwarder_compression_kind' is invalid, using the default compression: %v", err)
+		} else {
+			o.DefaultCompression = c
+		}
+	}
+
+	o.CompressionPerEndpoint = make(map[string]compression.Compressor)
+	for endpoint, spec := range config.Datadog.GetStringMapString("forwarder_compression_per_endpoint") {
+		c, err := compression.Parse(spec)
+		if err != nil {
+			log.Warnf("Ignoring the compression of the endpoint '%s' in 'forwarder_compression_per_endpoint': %v", endpoint, err)
+			continue
+		}
+		o.CompressionPerEndpoint[endpoint] = c
+	}
+}
+
 // setRetryQueuePayloadsTotalMaxSizeFromQueueMax set `RetryQueuePayloadsTotalMaxSize` from the value
 // of the deprecated settings `forwarder_retry_queue_max_size`
 func (o *Options) setRetryQueuePayloadsTotalMaxSizeFromQueueMax(v int) {
@@ -204,6 +424,16 @@ type DefaultForwarder struct {
 	m                sync.Mutex // To control Start/Stop races
 
 	completionHandler transaction.HTTPCompletionHandler
+
+	defaultCompression     compression.Compressor
+	compressionPerEndpoint map[string]compression.Compressor
+
+	// failover is nil when every domain receives the payloads
+	failover              *failover
+	failoverCheckInterval time.Duration
+	failoverConfigDomains map[string]string // the configured domain of each failover domain
+	stopFailover          chan struct{}
+	failoverStopped       chan struct{}
 }
 
 // NewDefaultForwarder returns a new DefaultForwarder.
@@ -217,8 +447,14 @@ func NewDefaultForwarder(options *Options) *DefaultForwarder {
 			keysPerDomains:        options.KeysPerDomain,
 			disableAPIKeyChecking: options.DisableAPIKeyChecking,
 			validationInterval:    options.APIKeyValidationInterval,
+			transportPerDomain:    map[string]*http.Transport{},
 		},
-		completionHandler: options.CompletionHandler,
+		completionHandler:      options.CompletionHandler,
+		defaultCompression:     options.DefaultCompression,
+		compressionPerEndpoint: options.CompressionPerEndpoint,
+	}
+	if f.defaultCompression == nil {
+		f.defaultCompression = compression.Default()
 	}
 	var optionalRemovalPolicy *retry.FileRemovalPolicy
 	storageMaxSize := config.Datadog.GetInt64("forwarder_storage_max_size_in_bytes")
@@ -253,13 +489,24 @@ func NewDefaultForwarder(options *Options) *DefaultForwarder {
 	domainForwarderSort := transaction.SortByCreatedTimeAndPriority{HighPriorityFirst: true}
 	transactionContainerSort := transaction.SortByCreatedTimeAndPriority{HighPriorityFirst: false}
 
-	for domain, keys := range options.KeysPerDomain {
-		domain, _ := config.AddAgentVersionToDomain(domain, "app")
+	for configDomain, keys := range options.KeysPerDomain {
+		domain, _ := config.AddAgentVersionToDomain(configDomain, "app")
 		if keys == nil || len(keys) == 0 {
 			log.Errorf("No API keys for domain '%s', dropping domain ", domain)
 		} else {
+			// Never fall back to the agent-wide transport, which could send the data
+			// through another network path.
+			transport, err := httputils.CreateHTTPTransportWithOptions(options.httpTransport(configDomain))
+			if err != nil {
+				log.Errorf("Cannot create the HTTP transport of domain '%s', dropping domain: %v", domain, err)
+				continue
+			}
+			if _, ok := options.HTTPTransportPerDomain[configDomain]; ok {
+				log.Infof("Using a dedicated HTTP transport for domain '%s'", domain)
+			}
+			f.healthChecker.transportPerDomain[configDomain] = transport
+
 			var domainFolderPath string
-			var err error
 			if optionalRemovalPolicy != nil {
 				domainFolderPath, err = optionalRemovalPolicy.RegisterDomain(domain)
 				if err != nil {
@@ -283,9 +530,17 @@ func NewDefaultForwarder(options *Options) *DefaultForwarder {
 				options.NumberOfWorkers,
 				options.ConnectionResetInterval,
 				domainForwarderSort)
+			if limit := options.egressLimit(configDomain); limit > 0 {
+				log.Infof("Limiting the bandwidth used to send data to '%s' to %d bytes per second", domain, limit)
+				f.domainForwarders[domain].egressLimit = limit
+				f.domainForwarders[domain].egressQueueMaxSize = options.EgressQueueMaxSize
+			}
+			f.domainForwarders[domain].transport = transport
 		}
 	}
 
+	f.setFailover(options)
+
 	if optionalRemovalPolicy != nil {
 		filesRemoved, err := optionalRemovalPolicy.RemoveUnknownDomains()
 		if err != nil {
@@ -317,6 +572,11 @@ func (f *DefaultForwarder) Start() error {
 	for _, df := range f.domainForwarders {
 		_ = df.Start()
 	}
+	if f.failover != nil {
+		f.stopFailover = make(chan struct{})
+		f.failoverStopped = make(chan struct{})
+		go f.runFailover()
+	}
 
 	// log endpoints configuration
 	endpointLogs := make([]string, 0, len(f.keysPerDomains))
@@ -346,6 +606,11 @@ func (f *DefaultForwarder) Stop() {
 
 	f.internalState = Stopped
 
+	if f.failover != nil {
+		close(f.stopFailover)
+		<-f.failoverStopped
+	}
+
 	purgeTimeout := config.Datadog.GetDuration("forwarder_stop_timeout") * time.Second
 	if purgeTimeout > 0 {
 		var wg sync.WaitGroup
@@ -400,6 +665,9 @@ func (f *DefaultForwarder) createAdvancedHTTPTransactions(endpoint transaction.E
 
 	for _, payload := range payloads {
 		for domain, apiKeys := range f.keysPerDomains {
+			if f.failover != nil && !f.failover.receivesTraffic(domain) {
+				continue
+			}
 			for _, apiKey := range apiKeys {
 				t := transaction.NewHTTPTransaction()
 				t.Domain = domain
@@ -411,6 +679,7 @@ func (f *DefaultForwarder) createAdvancedHTTPTransactions(endpoint transaction.E
 				t.Priority = priority
 				t.StorableOnDisk = storableOnDisk
 				t.Headers.Set(apiHTTPHeaderKey, apiKey)
+				t.Headers.Set(transaction.IdempotencyKeyHTTPHeaderKey, transaction.NewIdempotencyKey())
 				t.Headers.Set(versionHTTPHeaderKey, version.AgentVersion)
 				t.Headers.Set(useragentHTTPHeaderKey, fmt.Sprintf("datadog-agent/%s", version.AgentVersion))
 				if allowArbitraryTags {
@@ -498,6 +767,15 @@ func (f *DefaultForwarder) SubmitMetadata(payload Payloads, extra http.Header) e
 	return f.submitV1IntakeWithTransactionsFactory(payload, extra, f.createHTTPTransactions)
 }
 
+// Compressor returns the compression to use for the payloads submitted to the
+// endpoint named endpointName.
+func (f *DefaultForwarder) Compressor(endpointName string) compression.Compressor {
+	if c, ok := f.compressionPerEndpoint[endpointName]; ok {
+		return c
+	}
+	return f.defaultCompression
+}
+
 // SubmitV1Series will send timeserie to v1 endpoint (this will be remove once
 // the backend handles v2 endpoints).
 func (f *DefaultForwarder) SubmitV1Series(payload Payloads, extra http.Header) error {
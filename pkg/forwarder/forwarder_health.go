@@ -57,6 +57,10 @@ type forwarderHealth struct {
 	keysPerAPIEndpoint    map[string][]string
 	disableAPIKeyChecking bool
 	validationInterval    time.Duration
+	// transportPerDomain are the transports of the domains of keysPerDomains,
+	// transportPerAPIEndpoint the ones of the domains of keysPerAPIEndpoint
+	transportPerDomain      map[string]*http.Transport
+	transportPerAPIEndpoint map[string]*http.Transport
 }
 
 func (fh *forwarderHealth) init() {
@@ -64,6 +68,7 @@ func (fh *forwarderHealth) init() {
 	fh.stopped = make(chan struct{})
 
 	fh.keysPerAPIEndpoint = make(map[string][]string)
+	fh.transportPerAPIEndpoint = make(map[string]*http.Transport)
 	fh.computeDomainsURL()
 
 	// Since timeout is the maximum duration we can wait, we need to divide it
@@ -131,15 +136,22 @@ func (fh *forwarderHealth) healthCheckLoop() {
 // computeDomainsURL populates a map containing API Endpoints per API keys that belongs to the forwarderHealth struct
 func (fh *forwarderHealth) computeDomainsURL() {
 	for domain, apiKeys := range fh.keysPerDomains {
-		apiDomain := ""
-		re := regexp.MustCompile(`((us|eu)\d\.)?datadoghq.[a-z]+$`)
-		if re.MatchString(domain) {
-			apiDomain = "https://api." + re.FindString(domain)
-		} else {
-			apiDomain = domain
-		}
+		apiDomain := apiEndpointForDomain(domain)
 		fh.keysPerAPIEndpoint[apiDomain] = append(fh.keysPerAPIEndpoint[apiDomain], apiKeys...)
+		if transport, ok := fh.transportPerDomain[domain]; ok {
+			fh.transportPerAPIEndpoint[apiDomain] = transport
+		}
+	}
+}
+
+// apiEndpointForDomain returns the API endpoint of a Datadog domain, other domains
+// being their own API endpoint.
+func apiEndpointForDomain(domain string) string {
+	re := regexp.MustCompile(`((us|eu)\d\.)?datadoghq.[a-z]+$`)
+	if re.MatchString(domain) {
+		return "https://api." + re.FindString(domain)
 	}
+	return domain
 }
 
 func (fh *forwarderHealth) setAPIKeyStatus(apiKey string, domain string, status expvar.Var) {
@@ -156,41 +168,67 @@ func (fh *forwarderHealth) validateAPIKey(apiKey, domain string) (bool, error) {
 		return true, nil
 	}
 
-	url := fmt.Sprintf("%s%s?api_key=%s", domain, v1ValidateEndpoint, apiKey)
+	statusCode, err := fh.requestAPIKeyValidation(apiKey, domain, fh.transportPerAPIEndpoint[domain], fh.timeout)
+	if err != nil {
+		fh.setAPIKeyStatus(apiKey, domain, &apiKeyStatusUnknown)
+		return false, err
+	}
+
+	// Server will respond 200 if the key is valid or 403 if invalid
+	if statusCode == 200 {
+		fh.setAPIKeyStatus(apiKey, domain, &apiKeyValid)
+		return true, nil
+	} else if statusCode == 403 {
+		fh.setAPIKeyStatus(apiKey, domain, &apiKeyInvalid)
+		return false, nil
+	}
+
+	fh.setAPIKeyStatus(apiKey, domain, &apiKeyStatusUnknown)
+	return false, fmt.Errorf("Unexpected response code from the apikey validation endpoint: %v", statusCode)
+}
 
-	transport := httputils.CreateHTTPTransport()
+// requestAPIKeyValidation sends an API key validation request to an API endpoint,
+// cloning template when it isn't nil, and returns the status code of the response.
+func (fh *forwarderHealth) requestAPIKeyValidation(apiKey, apiEndpoint string, template *http.Transport, timeout time.Duration) (int, error) {
+	url := fmt.Sprintf("%s%s?api_key=%s", apiEndpoint, v1ValidateEndpoint, apiKey)
+
+	var transport *http.Transport
+	if template != nil {
+		transport = template.Clone()
+	} else {
+		transport = httputils.CreateHTTPTransport()
+	}
 
 	client := &http.Client{
 		Transport: transport,
-		Timeout:   fh.timeout,
+		Timeout:   timeout,
 	}
 
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
-		fh.setAPIKeyStatus(apiKey, domain, &apiKeyStatusUnknown)
-		return false, err
+		return 0, err
 	}
 
 	req.Header.Set(useragentHTTPHeaderKey, fmt.Sprintf("datadog-agent/%s", version.AgentVersion))
 
 	resp, err := client.Do(req)
 	if err != nil {
-		fh.setAPIKeyStatus(apiKey, domain, &apiKeyStatusUnknown)
-		return false, err
+		return 0, err
 	}
 	defer resp.Body.Close()
+	return resp.StatusCode, nil
+}
 
-	// Server will respond 200 if the key is valid or 403 if invalid
-	if resp.StatusCode == 200 {
-		fh.setAPIKeyStatus(apiKey, domain, &apiKeyValid)
-		return true, nil
-	} else if resp.StatusCode == 403 {
-		fh.setAPIKeyStatus(apiKey, domain, &apiKeyInvalid)
-		return false, nil
+// isDomainReachable returns whether the API of a domain of keysPerDomains answers,
+// whether apiKey is valid or not. It probes the failover domains which don't
+// receive the payloads.
+func (fh *forwarderHealth) isDomainReachable(domain, apiKey string) bool {
+	statusCode, err := fh.requestAPIKeyValidation(apiKey, apiEndpointForDomain(domain), fh.transportPerDomain[domain], validateAPIKeyTimeout)
+	if err != nil {
+		log.Debugf("Domain '%s' is unreachable: %v", domain, err)
+		return false
 	}
-
-	fh.setAPIKeyStatus(apiKey, domain, &apiKeyStatusUnknown)
-	return false, fmt.Errorf("Unexpected response code from the apikey validation endpoint: %v", resp.StatusCode)
+	return statusCode < 500
 }
 
 func (fh *forwarderHealth) hasValidAPIKey() bool {
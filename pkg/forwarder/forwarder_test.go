@@ -20,6 +20,8 @@ import (
 
 	"github.com/DataDog/datadog-agent/pkg/config"
 	"github.com/DataDog/datadog-agent/pkg/forwarder/transaction"
+	"github.com/DataDog/datadog-agent/pkg/util/compression"
+	httputils "github.com/DataDog/datadog-agent/pkg/util/http"
 	"github.com/DataDog/datadog-agent/pkg/version"
 )
 
@@ -55,6 +57,138 @@ func TestNewDefaultForwarder(t *testing.T) {
 	assert.Equal(t, forwarder.State(), forwarder.internalState)
 }
 
+func TestCompressionOptions(t *testing.T) {
+	mockConfig := config.Mock()
+
+	options := NewOptions(keysPerDomains)
+	assert.Nil(t, options.DefaultCompression)
+	assert.Empty(t, options.CompressionPerEndpoint)
+	assert.Equal(t, compression.Default(), NewDefaultForwarder(options).Compressor(SeriesEndpointName))
+
+	mockConfig.Set("forwarder_compression_kind", "gzip")
+	mockConfig.Set("forwarder_compression_level", 9)
+	mockConfig.Set("forwarder_compression_per_endpoint", map[string]string{
+		SeriesEndpointName:       "zlib:3",
+		SketchSeriesEndpointName: "none",
+		V1IntakeEndpointName:     "brotli",
+	})
+	defer func() {
+		mockConfig.Set("forwarder_compression_kind", "")
+		mockConfig.Set("forwarder_compression_level", 0)
+		mockConfig.Set("forwarder_compression_per_endpoint", map[string]string{})
+	}()
+
+	options = NewOptions(keysPerDomains)
+	f := NewDefaultForwarder(options)
+	assert.Equal(t, compression.GzipKind, f.Compressor(EventsEndpointName).Kind())
+	assert.Equal(t, compression.ZlibKind, f.Compressor(SeriesEndpointName).Kind())
+	assert.Equal(t, compression.NoneKind, f.Compressor(SketchSeriesEndpointName).Kind())
+	// invalid compressions are ignored
+	assert.Equal(t, compression.GzipKind, f.Compressor(V1IntakeEndpointName).Kind())
+	assert.Len(t, options.CompressionPerEndpoint, 2)
+
+	// the forwarders not selecting the compression at runtime use the one selected at build time
+	assert.Equal(t, compression.ZlibKind, CompressorFor(f, SeriesEndpointName).Kind())
+	assert.Equal(t, compression.ZlibKind, CompressorFor(NewTeeForwarder(f, &bufferCloser{}, true, nil), SeriesEndpointName).Kind())
+	assert.Equal(t, compression.Default(), CompressorFor(&MockedForwarder{}, SeriesEndpointName))
+
+	mockConfig.Set("forwarder_compression_level", 42)
+	assert.Nil(t, NewOptions(keysPerDomains).DefaultCompression)
+}
+
+func TestEgressLimitOptions(t *testing.T) {
+	mockConfig := config.Mock()
+
+	options := NewOptions(keysWithMultipleDomains)
+	assert.Equal(t, 0, options.egressLimit(testDomain))
+	f := NewDefaultForwarder(options)
+	for _, df := range f.domainForwarders {
+		assert.Equal(t, 0, df.egressLimit)
+	}
+
+	mockConfig.Set("forwarder_egress_limit_bytes_per_second", 1000)
+	mockConfig.Set("forwarder_egress_limit_per_domain", map[string]string{"datadog.bar": "500", "datadog.baz": "-1"})
+	defer func() {
+		mockConfig.Set("forwarder_egress_limit_bytes_per_second", 0)
+		mockConfig.Set("forwarder_egress_limit_per_domain", map[string]int{})
+	}()
+
+	options = NewOptions(keysWithMultipleDomains)
+	assert.Equal(t, 1000, options.egressLimit(testDomain))
+	assert.Equal(t, 500, options.egressLimit("datadog.bar"))
+	assert.Equal(t, 1000, options.egressLimit("datadog.baz"))
+
+	f = NewDefaultForwarder(options)
+	assert.Equal(t, 1000, f.domainForwarders[testVersionDomain].egressLimit)
+	assert.Equal(t, 500, f.domainForwarders["datadog.bar"].egressLimit)
+	assert.Equal(t, 15*1024*1024, f.domainForwarders["datadog.bar"].egressQueueMaxSize)
+}
+
+func TestHTTPTransportOptions(t *testing.T) {
+	mockConfig := config.Mock()
+
+	options := NewOptions(keysWithMultipleDomains)
+	assert.Equal(t, httputils.TransportOptions{MaxIdleConnsPerHost: 5, IdleConnTimeout: 90 * time.Second}, options.httpTransport(testDomain))
+	f := NewDefaultForwarder(options)
+	for _, df := range f.domainForwarders {
+		require.NotNil(t, df.transport)
+		assert.False(t, df.transport.ForceAttemptHTTP2)
+		assert.Equal(t, 5, df.transport.MaxIdleConnsPerHost)
+	}
+
+	mockConfig.Set("forwarder_http2", true)
+	mockConfig.Set("forwarder_max_conns_per_host", 10)
+	mockConfig.Set("forwarder_http_transport_per_domain", map[string]interface{}{
+		"datadog.bar": map[string]interface{}{
+			"proxy":                   map[string]interface{}{"https": "http://relay.com:3128"},
+			"http2":                   false,
+			"max_idle_conns_per_host": "2",
+			"idle_conn_timeout":       30,
+		},
+		testDomain: map[string]interface{}{
+			"skip_proxy": true,
+		},
+		"datadog.baz": map[string]interface{}{
+			"unknown_setting": true,
+		},
+	})
+	defer func() {
+		mockConfig.Set("forwarder_http2", false)
+		mockConfig.Set("forwarder_max_conns_per_host", 0)
+		mockConfig.Set("forwarder_http_transport_per_domain", map[string]interface{}{})
+	}()
+
+	options = NewOptions(keysWithMultipleDomains)
+	assert.Equal(t, httputils.TransportOptions{
+		NoProxy:             true,
+		HTTP2:               true,
+		MaxIdleConnsPerHost: 5,
+		MaxConnsPerHost:     10,
+		IdleConnTimeout:     90 * time.Second,
+	}, options.httpTransport(testDomain))
+	assert.Equal(t, httputils.TransportOptions{
+		Proxies:             &config.Proxy{HTTPS: "http://relay.com:3128"},
+		MaxIdleConnsPerHost: 2,
+		MaxConnsPerHost:     10,
+		IdleConnTimeout:     30 * time.Second,
+	}, options.httpTransport("datadog.bar"))
+	assert.NotContains(t, options.HTTPTransportPerDomain, "datadog.baz")
+
+	f = NewDefaultForwarder(options)
+	assert.True(t, f.domainForwarders[testVersionDomain].transport.ForceAttemptHTTP2)
+	assert.Nil(t, f.domainForwarders[testVersionDomain].transport.Proxy)
+	assert.False(t, f.domainForwarders["datadog.bar"].transport.ForceAttemptHTTP2)
+	assert.NotNil(t, f.domainForwarders["datadog.bar"].transport.Proxy)
+	assert.Equal(t, 2, f.domainForwarders["datadog.bar"].transport.MaxIdleConnsPerHost)
+	assert.Same(t, f.domainForwarders["datadog.bar"].transport, f.healthChecker.transportPerDomain["datadog.bar"])
+
+	// a domain whose TLS files cannot be loaded is dropped
+	options.HTTPTransportPerDomain["datadog.bar"] = httputils.TransportOptions{TLSCAFile: "/does/not/exist.pem"}
+	f = NewDefaultForwarder(options)
+	assert.Contains(t, f.domainForwarders, testVersionDomain)
+	assert.NotContains(t, f.domainForwarders, "datadog.bar")
+}
+
 func TestFeature(t *testing.T) {
 	var featureSet Features
 
@@ -150,8 +284,10 @@ func TestCreateHTTPTransactions(t *testing.T) {
 	assert.Equal(t, endpoint.Route, transactions[1].Endpoint.Route)
 	assert.Equal(t, endpoint.Route, transactions[2].Endpoint.Route)
 	assert.Equal(t, endpoint.Route, transactions[3].Endpoint.Route)
-	assert.Len(t, transactions[0].Headers, 4)
+	assert.Len(t, transactions[0].Headers, 5)
 	assert.NotEmpty(t, transactions[0].Headers.Get("DD-Api-Key"))
+	assert.Len(t, transactions[0].Headers.Get("DD-Idempotency-Key"), 32)
+	assert.NotEqual(t, transactions[0].Headers.Get("DD-Idempotency-Key"), transactions[1].Headers.Get("DD-Idempotency-Key"))
 	assert.NotEmpty(t, transactions[0].Headers.Get("HTTP-MAGIC"))
 	assert.Equal(t, version.AgentVersion, transactions[0].Headers.Get("DD-Agent-Version"))
 	assert.Equal(t, "datadog-agent/"+version.AgentVersion, transactions[0].Headers.Get("User-Agent"))
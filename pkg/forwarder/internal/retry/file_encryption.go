@@ -0,0 +1,175 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package retry
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"strings"
+
+	"golang.org/x/crypto/hkdf"
+
+	"github.com/DataDog/datadog-agent/pkg/config"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+const encryptedFileVersion = 1
+
+// minEncryptionKeyLength is the minimum length of the key material, from which
+// the AES-256 key is derived.
+const minEncryptionKeyLength = 32
+
+// keyIDSize is the size of the identifier of the key stored in the header of the
+// encrypted files, to tell a file encrypted with another key from a modified one.
+const keyIDSize = 8
+
+// hkdfInfo binds the keys derived from the key material to the retry files
+var hkdfInfo = []byte("datadog-agent forwarder retry files")
+
+var (
+	// errPlaintextRetryFile is returned when reading a retry file which is not encrypted
+	errPlaintextRetryFile = errors.New("the retry file is not encrypted")
+	// errRetryFileIntegrity is returned when a retry file was modified or truncated
+	errRetryFileIntegrity = errors.New("the retry file failed the integrity check: it was modified or truncated")
+	// errEncryptionKeyMismatch is returned when a retry file was encrypted with another key
+	errEncryptionKeyMismatch = errors.New("the retry file was encrypted with another key")
+)
+
+// fileEncryption encrypts and authenticates the retry files of a domain with AES-256-GCM.
+type fileEncryption struct {
+	aead  cipher.AEAD
+	keyID []byte
+	// additionalData binds the files to their format and domain: a file moved to
+	// the folder of another domain fails the integrity check.
+	additionalData []byte
+}
+
+func newFileEncryption(keyMaterial []byte, domain string) (*fileEncryption, error) {
+	keyMaterial = bytes.TrimSpace(keyMaterial)
+	if len(keyMaterial) < minEncryptionKeyLength {
+		return nil, fmt.Errorf("the encryption key must be at least %d characters long", minEncryptionKeyLength)
+	}
+
+	// the key material can be a passphrase rather than random bytes
+	kdf := hkdf.New(sha256.New, keyMaterial, nil, hkdfInfo)
+	key := make([]byte, 32)
+	keyID := make([]byte, keyIDSize)
+	if _, err := io.ReadFull(kdf, key); err != nil {
+		return nil, err
+	}
+	if _, err := io.ReadFull(kdf, keyID); err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	additionalData := append([]byte{}, EncryptedFileMagic...)
+	additionalData = append(additionalData, encryptedFileVersion)
+	additionalData = append(additionalData, domain...)
+	return &fileEncryption{aead: aead, keyID: keyID, additionalData: additionalData}, nil
+}
+
+// encrypt returns the content of an encrypted retry file containing plaintext: the
+// magic bytes, the format version, the key ID and the nonce, then the ciphertext.
+func (e *fileEncryption) encrypt(plaintext []byte) ([]byte, error) {
+	headerSize := len(EncryptedFileMagic) + 1 + keyIDSize
+	nonceSize := e.aead.NonceSize()
+
+	out := make([]byte, headerSize+nonceSize, headerSize+nonceSize+len(plaintext)+e.aead.Overhead())
+	copy(out, EncryptedFileMagic)
+	out[len(EncryptedFileMagic)] = encryptedFileVersion
+	copy(out[len(EncryptedFileMagic)+1:], e.keyID)
+	nonce := out[headerSize:]
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return e.aead.Seal(out, nonce, plaintext, e.additionalData), nil
+}
+
+// decrypt returns the plaintext of an encrypted retry file. It returns
+// errPlaintextRetryFile if the file was not encrypted, errEncryptionKeyMismatch if
+// it was encrypted with another key, and errRetryFileIntegrity if it was modified.
+func (e *fileEncryption) decrypt(content []byte) ([]byte, error) {
+	if !IsEncryptedRetryFile(content) {
+		return nil, errPlaintextRetryFile
+	}
+	content = content[len(EncryptedFileMagic):]
+	if len(content) == 0 || content[0] != encryptedFileVersion {
+		return nil, fmt.Errorf("unsupported retry file format version %v", content[:1])
+	}
+	content = content[1:]
+
+	nonceSize := e.aead.NonceSize()
+	if len(content) < keyIDSize+nonceSize+e.aead.Overhead() {
+		return nil, errRetryFileIntegrity
+	}
+	if !bytes.Equal(content[:keyIDSize], e.keyID) {
+		return nil, errEncryptionKeyMismatch
+	}
+	content = content[keyIDSize:]
+	plaintext, err := e.aead.Open(nil, content[:nonceSize], content[nonceSize:], e.additionalData)
+	if err != nil {
+		return nil, errRetryFileIntegrity
+	}
+	return plaintext, nil
+}
+
+// loadFileEncryptionFromConfig returns the encryption of the retry files of domain,
+// nil if it is not configured.
+func loadFileEncryptionFromConfig(domain string) (*fileEncryption, error) {
+	// `forwarder_storage_encryption_key` can be set with the secrets backend
+	key := config.Datadog.GetString("forwarder_storage_encryption_key")
+	keyFile := config.Datadog.GetString("forwarder_storage_encryption_key_file")
+
+	switch {
+	case key != "" && keyFile != "":
+		return nil, errors.New("'forwarder_storage_encryption_key' and 'forwarder_storage_encryption_key_file' cannot both be set")
+	case key != "":
+		return newFileEncryption([]byte(key), domain)
+	case keyFile != "":
+		content, err := readEncryptionKeyFile(keyFile)
+		if err != nil {
+			return nil, err
+		}
+		return newFileEncryption(content, domain)
+	default:
+		return nil, nil
+	}
+}
+
+func readEncryptionKeyFile(path string) ([]byte, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read the encryption key file: %v", err)
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm()&0077 != 0 {
+		log.Warnf("The encryption key file %s can be accessed by other users, its permissions should be 0600", path)
+	}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read the encryption key file: %v", err)
+	}
+	if strings.TrimSpace(string(content)) == "" {
+		return nil, fmt.Errorf("the encryption key file %s is empty", path)
+	}
+	return content, nil
+}
@@ -0,0 +1,122 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package retry
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/config"
+)
+
+const testEncryptionKey = "0123456789abcdef0123456789abcdef"
+
+func newTestFileEncryption(a *assert.Assertions, key string) *fileEncryption {
+	e, err := newFileEncryption([]byte(key), domainName)
+	a.NoError(err)
+	return e
+}
+
+func TestFileEncryption(t *testing.T) {
+	a := assert.New(t)
+	e := newTestFileEncryption(a, testEncryptionKey)
+
+	plaintext := []byte("serialized transactions")
+	content, err := e.encrypt(plaintext)
+	require.NoError(t, err)
+	a.True(IsEncryptedRetryFile(content))
+	a.NotContains(string(content), string(plaintext))
+
+	decrypted, err := e.decrypt(content)
+	a.NoError(err)
+	a.Equal(plaintext, decrypted)
+
+	// the nonce is random
+	other, err := e.encrypt(plaintext)
+	require.NoError(t, err)
+	a.NotEqual(content, other)
+
+	_, err = e.decrypt(plaintext)
+	a.Equal(errPlaintextRetryFile, err)
+}
+
+func TestFileEncryptionIntegrity(t *testing.T) {
+	a := assert.New(t)
+	e := newTestFileEncryption(a, testEncryptionKey)
+	content, err := e.encrypt([]byte("serialized transactions"))
+	require.NoError(t, err)
+
+	for i := len(EncryptedFileMagic); i < len(content); i++ {
+		modified := append([]byte{}, content...)
+		modified[i] ^= 1
+		_, err := e.decrypt(modified)
+		a.Error(err, "byte %d", i)
+	}
+
+	modified := append([]byte{}, content...)
+	modified[len(modified)-1] ^= 1
+	_, err = e.decrypt(modified)
+	a.Equal(errRetryFileIntegrity, err)
+	_, err = e.decrypt(content[:len(content)-1])
+	a.Equal(errRetryFileIntegrity, err)
+	_, err = e.decrypt(content[:len(EncryptedFileMagic)+1])
+	a.Equal(errRetryFileIntegrity, err)
+
+	// the files cannot be read with another key nor from another domain
+	_, err = newTestFileEncryption(a, testEncryptionKey+"0").decrypt(content)
+	a.Equal(errEncryptionKeyMismatch, err)
+	otherDomain, err := newFileEncryption([]byte(testEncryptionKey), "other_domain")
+	require.NoError(t, err)
+	_, err = otherDomain.decrypt(content)
+	a.Equal(errRetryFileIntegrity, err)
+}
+
+func TestLoadFileEncryptionFromConfig(t *testing.T) {
+	mockConfig := config.Mock()
+	dir, err := ioutil.TempDir("", "tests")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	keyFile := filepath.Join(dir, "key")
+	require.NoError(t, ioutil.WriteFile(keyFile, []byte(testEncryptionKey+"\n"), 0600))
+
+	e, err := loadFileEncryptionFromConfig(domainName)
+	assert.NoError(t, err)
+	assert.Nil(t, e)
+
+	// the key file and the key give the same encryption, the trailing new line is ignored
+	mockConfig.Set("forwarder_storage_encryption_key_file", keyFile)
+	fromFile, err := loadFileEncryptionFromConfig(domainName)
+	require.NoError(t, err)
+	content, err := fromFile.encrypt([]byte("serialized transactions"))
+	require.NoError(t, err)
+
+	mockConfig.Set("forwarder_storage_encryption_key", testEncryptionKey)
+	_, err = loadFileEncryptionFromConfig(domainName)
+	assert.Error(t, err)
+
+	mockConfig.Set("forwarder_storage_encryption_key_file", "")
+	fromKey, err := loadFileEncryptionFromConfig(domainName)
+	require.NoError(t, err)
+	plaintext, err := fromKey.decrypt(content)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("serialized transactions"), plaintext)
+
+	mockConfig.Set("forwarder_storage_encryption_key", "too short")
+	_, err = loadFileEncryptionFromConfig(domainName)
+	assert.Error(t, err)
+
+	mockConfig.Set("forwarder_storage_encryption_key", "")
+	mockConfig.Set("forwarder_storage_encryption_key_file", filepath.Join(dir, "missing"))
+	_, err = loadFileEncryptionFromConfig(domainName)
+	assert.Error(t, err)
+	mockConfig.Set("forwarder_storage_encryption_key_file", "")
+}
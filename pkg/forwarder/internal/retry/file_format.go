@@ -0,0 +1,22 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package retry
+
+import "bytes"
+
+// Retry files written by older versions of the Agent only contain a serialized
+// `HttpTransactionProtoCollection`. Encrypted retry files start with
+// EncryptedFileMagic, which cannot be the start of a valid protobuf message as
+// 0xff isn't a valid field key.
+//
+// This file has no dependency so that tools reading the retry files, like
+// tools/retry_file_dump, can copy it.
+var EncryptedFileMagic = []byte{0xff, 'D', 'D', 'R', 'Q'}
+
+// IsEncryptedRetryFile returns whether the content of a retry file is encrypted
+func IsEncryptedRetryFile(content []byte) bool {
+	return bytes.HasPrefix(content, EncryptedFileMagic)
+}
@@ -70,6 +70,8 @@ func (s *HTTPTransactionsSerializer) Add(transaction *transaction.HTTPTransactio
 		payload = *transaction.Payload
 	}
 
+	// The headers include the idempotency key, which must not change when the transaction
+	// is retried after it was reloaded from the disk.
 	endpoint := transaction.Endpoint
 	transactionProto := HttpTransactionProto{
 		// The domain is not stored on the disk for security reasons.
@@ -87,10 +87,30 @@ func TestHTTPTransactionSerializerMissingAPIKey(t *testing.T) {
 	r.Equal(1, errorCount)
 }
 
+func TestHTTPTransactionIdempotencyKey(t *testing.T) {
+	r := require.New(t)
+	serializer := NewHTTPTransactionsSerializer(domain, []string{apiKey1})
+
+	tr := createHTTPTransactionWithHeaderTests(http.Header{})
+	tr.Headers.Set(transaction.IdempotencyKeyHTTPHeaderKey, "0123456789abcdef")
+	tr.AmbiguousFailure = true
+	r.NoError(serializer.Add(tr))
+	bytes, err := serializer.GetBytesAndReset()
+	r.NoError(err)
+
+	transactions, errorCount, err := serializer.Deserialize(bytes)
+	r.NoError(err)
+	r.Equal(0, errorCount)
+	r.Len(transactions, 1)
+	deserialized := transactions[0].(*transaction.HTTPTransaction)
+	r.Equal("0123456789abcdef", deserialized.Headers.Get(transaction.IdempotencyKeyHTTPHeaderKey))
+	r.False(deserialized.AmbiguousFailure)
+}
+
 func TestHTTPTransactionFieldsCount(t *testing.T) {
 	tr := transaction.HTTPTransaction{}
 	transactionType := reflect.TypeOf(tr)
-	assert.Equalf(t, 11, transactionType.NumField(),
+	assert.Equalf(t, 12, transactionType.NumField(),
 		"A field was added or remove from HTTPTransaction. "+
 			"You probably need to update the implementation of "+
 			"HTTPTransactionsSerializer and then adjust this unit test.")
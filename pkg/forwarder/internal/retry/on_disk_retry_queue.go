@@ -6,6 +6,7 @@
 package retry
 
 import (
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -29,12 +30,18 @@ type onDiskRetryQueue struct {
 	filenames          []string
 	currentSizeInBytes int64
 	telemetry          onDiskRetryQueueTelemetry
+	// encryption is nil when the retry files are stored unencrypted
+	encryption *fileEncryption
+	// skippedFilenames are the files which cannot be decrypted. They are kept on
+	// disk and counted in currentSizeInBytes, and removed first when room is needed.
+	skippedFilenames []string
 }
 
 func newOnDiskRetryQueue(
 	serializer *HTTPTransactionsSerializer,
 	storagePath string,
 	diskUsageLimit *diskUsageLimit,
+	encryption *fileEncryption,
 	telemetry onDiskRetryQueueTelemetry) (*onDiskRetryQueue, error) {
 
 	if err := os.MkdirAll(storagePath, 0700); err != nil {
@@ -46,6 +53,7 @@ func newOnDiskRetryQueue(
 		storagePath:    storagePath,
 		diskUsageLimit: diskUsageLimit,
 		telemetry:      telemetry,
+		encryption:     encryption,
 	}
 
 	if err := storage.reloadExistingRetryFiles(); err != nil {
@@ -77,6 +85,11 @@ func (s *onDiskRetryQueue) Serialize(transactions []transaction.Transaction) err
 	if err != nil {
 		return err
 	}
+	if s.encryption != nil {
+		if bytes, err = s.encryption.encrypt(bytes); err != nil {
+			return err
+		}
+	}
 	bufferSize := int64(len(bytes))
 
 	if err := s.makeRoomFor(bufferSize); err != nil {
@@ -112,16 +125,34 @@ func (s *onDiskRetryQueue) Deserialize() ([]transaction.Transaction, error) {
 	index := len(s.filenames) - 1
 	path := s.filenames[index]
 	bytes, err := ioutil.ReadFile(path)
-
-	// Remove the file even in case of a read failure.
-	if errRemoveFile := s.removeFileAt(index); errRemoveFile != nil {
-		return nil, errRemoveFile
+	if err != nil {
+		// Remove the file even in case of a read failure.
+		if errRemoveFile := s.removeFileAt(index); errRemoveFile != nil {
+			return nil, errRemoveFile
+		}
+		return nil, err
 	}
 
-	if err != nil {
+	if bytes, err = s.decrypt(bytes, path); err != nil {
+		s.telemetry.addDecryptionErrorsCount()
+		if errors.Is(err, errRetryFileIntegrity) {
+			if errRemoveFile := s.removeFileAt(index); errRemoveFile != nil {
+				return nil, errRemoveFile
+			}
+		} else {
+			// the file can be read once the encryption key is fixed and the Agent restarted
+			log.Warnf("Keeping the retry file %s on disk until it can be decrypted", path)
+			s.skipFileAt(index)
+		}
+		s.telemetry.setCurrentSizeInBytes(s.getCurrentSizeInBytes())
+		s.telemetry.setFilesCount(s.getFilesCount())
 		return nil, err
 	}
 
+	if errRemoveFile := s.removeFileAt(index); errRemoveFile != nil {
+		return nil, errRemoveFile
+	}
+
 	transactions, errorsCount, err := s.serializer.Deserialize(bytes)
 	if err != nil {
 		return nil, err
@@ -133,6 +164,28 @@ func (s *onDiskRetryQueue) Deserialize() ([]transaction.Transaction, error) {
 	return transactions, err
 }
 
+// decrypt returns the serialized transactions stored in the content of a retry file.
+// Files written by a version of the Agent which didn't encrypt them are still read.
+func (s *onDiskRetryQueue) decrypt(content []byte, path string) ([]byte, error) {
+	if s.encryption == nil {
+		if IsEncryptedRetryFile(content) {
+			return nil, fmt.Errorf("cannot read the encrypted retry file %s as no encryption key is configured", path)
+		}
+		return content, nil
+	}
+
+	plaintext, err := s.encryption.decrypt(content)
+	if errors.Is(err, errPlaintextRetryFile) {
+		log.Warnf("The retry file %s is not encrypted, it was likely written by a previous version of the Agent", path)
+		s.telemetry.addPlaintextFilesCount()
+		return content, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot read the retry file %s: %w", path, err)
+	}
+	return plaintext, nil
+}
+
 // GetFileCount returns the current files count.
 func (s *onDiskRetryQueue) getFilesCount() int {
 	return len(s.filenames)
@@ -153,6 +206,15 @@ func (s *onDiskRetryQueue) makeRoomFor(bufferSize int64) error {
 	if err != nil {
 		return err
 	}
+	for len(s.skippedFilenames) > 0 && s.currentSizeInBytes+bufferSize > maxStorageInBytes {
+		filename := s.skippedFilenames[0]
+		s.skippedFilenames = s.skippedFilenames[1:]
+		log.Infof("Maximum disk space for retry transactions is reached. Removing %s which cannot be decrypted", filename)
+		if err := s.removeFile(filename); err != nil {
+			return err
+		}
+		s.telemetry.addFilesRemovedCount()
+	}
 	for len(s.filenames) > 0 && s.currentSizeInBytes+bufferSize > maxStorageInBytes {
 		index := 0
 		filename := s.filenames[index]
@@ -173,6 +235,10 @@ func (s *onDiskRetryQueue) removeFileAt(index int) error {
 	// fail on the next call.
 	s.filenames = append(s.filenames[:index], s.filenames[index+1:]...)
 
+	return s.removeFile(filename)
+}
+
+func (s *onDiskRetryQueue) removeFile(filename string) error {
 	size, err := util.GetFileSize(filename)
 	if err != nil {
 		return err
@@ -186,6 +252,14 @@ func (s *onDiskRetryQueue) removeFileAt(index int) error {
 	return nil
 }
 
+// skipFileAt stops reading a file without removing it, it is reloaded at the
+// next startup. The file still counts against the disk usage limit.
+func (s *onDiskRetryQueue) skipFileAt(index int) {
+	filename := s.filenames[index]
+	s.filenames = append(s.filenames[:index], s.filenames[index+1:]...)
+	s.skippedFilenames = append(s.skippedFilenames, filename)
+}
+
 func (s *onDiskRetryQueue) reloadExistingRetryFiles() error {
 	files, sizeInBytes, err := s.getExistingRetryFiles()
 	if err != nil {
@@ -6,8 +6,10 @@
 package retry
 
 import (
+	"errors"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strconv"
 	"testing"
 
@@ -90,6 +92,144 @@ func TestOnDiskRetryQueueReloadExistingRetryFiles(t *testing.T) {
 	a.Equal([]string{"endpoint1", "endpoint2"}, getEndpointsFromTransactions(transactions))
 }
 
+func TestOnDiskRetryQueueEncryption(t *testing.T) {
+	a := assert.New(t)
+	path, clean := createTmpFolder(a)
+	defer clean()
+
+	q := newTestEncryptedOnDiskRetryQueue(a, path, 1000, newTestFileEncryption(a, testEncryptionKey))
+	err := q.Serialize(createHTTPTransactionCollectionTests("endpoint1", "endpoint2"))
+	a.NoError(err)
+
+	content := readRetryFiles(a, path)
+	a.Len(content, 1)
+	a.True(IsEncryptedRetryFile(content[0]))
+	a.NotContains(string(content[0]), "endpoint1")
+
+	// the encrypted files are reloaded at startup
+	q = newTestEncryptedOnDiskRetryQueue(a, path, 1000, newTestFileEncryption(a, testEncryptionKey))
+	transactions, err := q.Deserialize()
+	a.NoError(err)
+	a.Equal([]string{"endpoint1", "endpoint2"}, getEndpointsFromTransactions(transactions))
+}
+
+func TestOnDiskRetryQueueEncryptionPlaintextFiles(t *testing.T) {
+	a := assert.New(t)
+	path, clean := createTmpFolder(a)
+	defer clean()
+
+	// files written by a previous version of the Agent are read then removed
+	q := newTestOnDiskRetryQueue(a, path, 1000)
+	a.NoError(q.Serialize(createHTTPTransactionCollectionTests("endpoint1")))
+
+	q = newTestEncryptedOnDiskRetryQueue(a, path, 1000, newTestFileEncryption(a, testEncryptionKey))
+	transactions, err := q.Deserialize()
+	a.NoError(err)
+	a.Equal([]string{"endpoint1"}, getEndpointsFromTransactions(transactions))
+	a.Equal(0, q.getFilesCount())
+}
+
+func TestOnDiskRetryQueueEncryptionErrors(t *testing.T) {
+	a := assert.New(t)
+	path, clean := createTmpFolder(a)
+	defer clean()
+
+	q := newTestEncryptedOnDiskRetryQueue(a, path, 1000, newTestFileEncryption(a, testEncryptionKey))
+	for _, endpoint := range []string{"endpoint1", "endpoint2", "endpoint3"} {
+		a.NoError(q.Serialize(createHTTPTransactionCollectionTests(endpoint)))
+	}
+
+	// a modified file is dropped
+	content, err := ioutil.ReadFile(q.filenames[2])
+	a.NoError(err)
+	content[len(content)-1] ^= 1
+	a.NoError(ioutil.WriteFile(q.filenames[2], content, 0600))
+	_, err = q.Deserialize()
+	a.Error(err)
+	a.Equal(2, q.getFilesCount())
+
+	// a file encrypted with another key is kept on disk
+	q.encryption = newTestFileEncryption(a, "another key which is long enough to be used")
+	_, err = q.Deserialize()
+	a.True(errors.Is(err, errEncryptionKeyMismatch))
+	a.Equal(1, q.getFilesCount())
+
+	// an encrypted file cannot be read when the encryption is disabled, it is kept on disk too
+	q.encryption = nil
+	_, err = q.Deserialize()
+	a.Error(err)
+	a.Equal(0, q.getFilesCount())
+	a.Equal(getRetryFilesSize(a, path), q.getCurrentSizeInBytes())
+	a.Len(readRetryFiles(a, path), 2)
+
+	// the files kept are read at the next startup with the right key
+	q = newTestEncryptedOnDiskRetryQueue(a, path, 1000, newTestFileEncryption(a, testEncryptionKey))
+	var endpoints []string
+	for q.getFilesCount() > 0 {
+		transactions, err := q.Deserialize()
+		a.NoError(err)
+		endpoints = append(endpoints, getEndpointsFromTransactions(transactions)...)
+	}
+	a.ElementsMatch([]string{"endpoint1", "endpoint2"}, endpoints)
+	a.Empty(readRetryFiles(a, path))
+}
+
+func TestOnDiskRetryQueueEncryptionErrorsMaxSize(t *testing.T) {
+	a := assert.New(t)
+	path, clean := createTmpFolder(a)
+	defer clean()
+
+	q := newTestEncryptedOnDiskRetryQueue(a, path, 1000, newTestFileEncryption(a, testEncryptionKey))
+	a.NoError(q.Serialize(createHTTPTransactionCollectionTests("endpoint1")))
+	fileSize := q.getCurrentSizeInBytes()
+
+	// the files which cannot be decrypted count against the disk usage limit
+	q = newTestEncryptedOnDiskRetryQueue(a, path, 3*fileSize, newTestFileEncryption(a, "another key which is long enough to be used"))
+	_, err := q.Deserialize()
+	a.True(errors.Is(err, errEncryptionKeyMismatch))
+	a.Equal(0, q.getFilesCount())
+	a.Equal(fileSize, q.getCurrentSizeInBytes())
+
+	// and are removed first when room is needed
+	for _, endpoint := range []string{"endpoint2", "endpoint3", "endpoint4"} {
+		a.NoError(q.Serialize(createHTTPTransactionCollectionTests(endpoint)))
+	}
+	a.Equal(3, q.getFilesCount())
+	a.Equal(getRetryFilesSize(a, path), q.getCurrentSizeInBytes())
+	a.LessOrEqual(q.getCurrentSizeInBytes(), 3*fileSize)
+
+	var endpoints []string
+	for q.getFilesCount() > 0 {
+		transactions, err := q.Deserialize()
+		a.NoError(err)
+		endpoints = append(endpoints, getEndpointsFromTransactions(transactions)...)
+	}
+	a.Equal([]string{"endpoint4", "endpoint3", "endpoint2"}, endpoints)
+	a.Empty(readRetryFiles(a, path))
+}
+
+func getRetryFilesSize(a *assert.Assertions, path string) int64 {
+	entries, err := ioutil.ReadDir(path)
+	a.NoError(err)
+	var size int64
+	for _, entry := range entries {
+		size += entry.Size()
+	}
+	return size
+}
+
+func readRetryFiles(a *assert.Assertions, path string) [][]byte {
+	entries, err := ioutil.ReadDir(path)
+	a.NoError(err)
+	var content [][]byte
+	for _, entry := range entries {
+		c, err := ioutil.ReadFile(filepath.Join(path, entry.Name()))
+		a.NoError(err)
+		content = append(content, c)
+	}
+	return content
+}
+
 func createHTTPTransactionCollectionTests(endpoints ...string) []transaction.Transaction {
 	var transactions []transaction.Transaction
 
@@ -118,6 +258,10 @@ func getEndpointsFromTransactions(transactions []transaction.Transaction) []stri
 }
 
 func newTestOnDiskRetryQueue(a *assert.Assertions, path string, maxSizeInBytes int64) *onDiskRetryQueue {
+	return newTestEncryptedOnDiskRetryQueue(a, path, maxSizeInBytes, nil)
+}
+
+func newTestEncryptedOnDiskRetryQueue(a *assert.Assertions, path string, maxSizeInBytes int64, encryption *fileEncryption) *onDiskRetryQueue {
 	telemetry := onDiskRetryQueueTelemetry{}
 	disk := diskUsageRetrieverMock{
 		diskUsage: &filesystem.DiskUsage{
@@ -125,7 +269,7 @@ func newTestOnDiskRetryQueue(a *assert.Assertions, path string, maxSizeInBytes i
 			Total:     10000,
 		}}
 	diskUsageLimit := newDiskUsageLimit("", disk, maxSizeInBytes, 1)
-	storage, err := newOnDiskRetryQueue(NewHTTPTransactionsSerializer(domainName, nil), path, diskUsageLimit, telemetry)
+	storage, err := newOnDiskRetryQueue(NewHTTPTransactionsSerializer(domainName, nil), path, diskUsageLimit, encryption, telemetry)
 	a.NoError(err)
 	return storage
 }
@@ -74,6 +74,8 @@ var (
 	filesRemovedCountTelemetry            *counterExpvar
 	deserializeErrorsCountTelemetry       *counterExpvar
 	deserializeTransactionsCountTelemetry *counterExpvar
+	decryptionErrorsCountTelemetry        *counterExpvar
+	plaintextFilesCountTelemetry          *counterExpvar
 )
 
 func init() {
@@ -167,6 +169,16 @@ func init() {
 		"deserialize_transactions_count",
 		"The number of transactions read from the disk",
 		&fileStorageExpvar)
+	decryptionErrorsCountTelemetry = newCounterExpvar(
+		"file_storage",
+		"decryption_errors_count",
+		"The number of files dropped because they could not be decrypted or failed the integrity check",
+		&fileStorageExpvar)
+	plaintextFilesCountTelemetry = newCounterExpvar(
+		"file_storage",
+		"plaintext_files_count",
+		"The number of unencrypted files read while the encryption is enabled",
+		&fileStorageExpvar)
 }
 
 // FileRemovalPolicyTelemetry handles the telemetry for FileRemovalPolicy.
@@ -244,6 +256,14 @@ func (onDiskRetryQueueTelemetry) addDeserializeTransactionsCount(count int) {
 	deserializeTransactionsCountTelemetry.add(float64(count))
 }
 
+func (onDiskRetryQueueTelemetry) addDecryptionErrorsCount() {
+	decryptionErrorsCountTelemetry.add(1)
+}
+
+func (onDiskRetryQueueTelemetry) addPlaintextFilesCount() {
+	plaintextFilesCountTelemetry.add(1)
+}
+
 func toCamelCase(s string) string {
 	parts := strings.Split(s, "_")
 	var camelCase string
@@ -57,7 +57,12 @@ func BuildTransactionRetryQueue(
 		diskRatio := config.Datadog.GetFloat64("forwarder_storage_max_disk_ratio")
 
 		diskUsageLimit := newDiskUsageLimit(optionalDomainFolderPath, filesystem.NewDisk(), storageMaxSize, diskRatio)
-		storage, err = newOnDiskRetryQueue(serializer, optionalDomainFolderPath, diskUsageLimit, onDiskRetryQueueTelemetry{})
+
+		// Never fall back to unencrypted files when the encryption key is invalid.
+		var encryption *fileEncryption
+		if encryption, err = loadFileEncryptionFromConfig(domain); err == nil {
+			storage, err = newOnDiskRetryQueue(serializer, optionalDomainFolderPath, diskUsageLimit, encryption, onDiskRetryQueueTelemetry{})
+		}
 
 		// If the storage on disk cannot be used, log the error and continue.
 		// Returning `nil, err` would mean not using `TransactionRetryQueue` and so not using `forwarder_retry_queue_payloads_max_size` config.
@@ -0,0 +1,353 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package forwarder
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	agentpayload "github.com/DataDog/agent-payload/gogen"
+	"github.com/DataDog/agent-payload/process"
+	"github.com/gogo/protobuf/proto"
+
+	"github.com/DataDog/datadog-agent/pkg/forwarder/transaction"
+	"github.com/DataDog/datadog-agent/pkg/util/compression"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// teeSecretHeaders are the headers never written by the TeeForwarder
+var teeSecretHeaders = map[string]struct{}{
+	"Dd-Api-Key":          {},
+	"Dd-Application-Key":  {},
+	"Authorization":       {},
+	"Proxy-Authorization": {},
+	"Cookie":              {},
+}
+
+// teeRecord is the description of a payload written by the TeeForwarder
+type teeRecord struct {
+	Timestamp string            `json:"timestamp"`
+	Endpoint  string            `json:"endpoint"`
+	Route     string            `json:"route"`
+	Headers   map[string]string `json:"headers,omitempty"`
+	// Payload is set for the JSON payloads and the protobuf payloads decoded to
+	// JSON, RawPayload for the other ones, which are encoded in base64.
+	Payload    json.RawMessage `json:"payload,omitempty"`
+	RawPayload []byte          `json:"raw_payload,omitempty"`
+	Error      string          `json:"error,omitempty"`
+}
+
+// TeeForwarder writes every payload submitted to it, with its endpoint and headers,
+// before submitting it to another Forwarder. In dry-run mode, the payloads are
+// only written.
+type TeeForwarder struct {
+	next    Forwarder
+	dryRun  bool
+	apiKeys []string
+
+	m      sync.Mutex
+	output io.WriteCloser
+}
+
+// Compile-time check to ensure that TeeForwarder implements the Forwarder and
+// CompressorProvider interfaces
+var _ Forwarder = &TeeForwarder{}
+var _ CompressorProvider = &TeeForwarder{}
+
+// NewTeeForwarder returns a TeeForwarder writing the payloads to output before
+// submitting them to next, unless dryRun is set. The occurrences of apiKeys in
+// the payloads are obfuscated.
+func NewTeeForwarder(next Forwarder, output io.WriteCloser, dryRun bool, apiKeys []string) *TeeForwarder {
+	return &TeeForwarder{
+		next:    next,
+		dryRun:  dryRun,
+		apiKeys: apiKeys,
+		output:  output,
+	}
+}
+
+// NewForwarder returns the Forwarder described by options: a DefaultForwarder,
+// wrapped in a TeeForwarder when `forwarder_tee_output` is set.
+func NewForwarder(options *Options) Forwarder {
+	f := NewDefaultForwarder(options)
+	if options.TeeOutput == "" {
+		return f
+	}
+
+	var apiKeys []string
+	for _, keys := range options.KeysPerDomain {
+		apiKeys = append(apiKeys, keys...)
+	}
+
+	output, err := newTeeOutput(options.TeeOutput, options.TeeMaxFileSize, options.TeeMaxFiles)
+	if err != nil {
+		if !options.TeeDryRun {
+			log.Errorf("Cannot write the payloads to '%s', they are only sent: %v", options.TeeOutput, err)
+			return f
+		}
+		// a dry-run must never send the payloads
+		log.Errorf("Cannot write the payloads to '%s', they are dropped: %v", options.TeeOutput, err)
+		output = nopCloser{ioutil.Discard}
+	}
+
+	if options.TeeDryRun {
+		log.Warnf("The forwarder is in dry-run mode: the payloads are written to '%s' and not sent", options.TeeOutput)
+	} else {
+		log.Infof("The payloads are written to '%s' before being sent", options.TeeOutput)
+	}
+	return NewTeeForwarder(f, output, options.TeeDryRun, apiKeys)
+}
+
+// Start starts the forwarder the payloads are submitted to, if any.
+func (f *TeeForwarder) Start() error {
+	if f.dryRun {
+		return nil
+	}
+	return f.next.Start()
+}
+
+// Stop stops the forwarder the payloads are submitted to, if any, and closes the output.
+func (f *TeeForwarder) Stop() {
+	if !f.dryRun {
+		f.next.Stop()
+	}
+
+	f.m.Lock()
+	defer f.m.Unlock()
+	if err := f.output.Close(); err != nil {
+		log.Warnf("Error when closing the output of the forwarder: %v", err)
+	}
+}
+
+// write writes the records of payloads submitted to endpoint
+func (f *TeeForwarder) write(endpoint transaction.Endpoint, payloads Payloads, extra http.Header) {
+	headers := make(map[string]string, len(extra))
+	for name := range extra {
+		if _, ok := teeSecretHeaders[http.CanonicalHeaderKey(name)]; !ok {
+			headers[name] = f.scrub(extra.Get(name))
+		}
+	}
+
+	for _, payload := range payloads {
+		if payload == nil {
+			continue
+		}
+		record := teeRecord{
+			Timestamp: time.Now().UTC().Format(time.RFC3339Nano),
+			Endpoint:  endpoint.Name,
+			Route:     endpoint.Route,
+			Headers:   headers,
+		}
+		f.setPayload(&record, *payload, endpoint.Name, extra.Get("Content-Encoding"))
+
+		b, err := json.MarshalIndent(record, "", "  ")
+		if err != nil {
+			log.Warnf("Cannot write a payload of the endpoint '%s': %v", endpoint.Name, err)
+			continue
+		}
+
+		f.m.Lock()
+		_, err = f.output.Write(append(b, '\n'))
+		f.m.Unlock()
+		if err != nil {
+			log.Warnf("Cannot write a payload of the endpoint '%s': %v", endpoint.Name, err)
+		}
+	}
+}
+
+func (f *TeeForwarder) setPayload(record *teeRecord, payload []byte, endpointName string, contentEncoding string) {
+	c, err := compression.ForContentEncoding(contentEncoding)
+	if err == nil {
+		var decompressed []byte
+		if decompressed, err = c.Decompress(payload); err == nil {
+			payload = decompressed
+		}
+	}
+	if err != nil {
+		record.RawPayload = payload
+		record.Error = fmt.Sprintf("cannot decompress the payload: %v", err)
+		return
+	}
+
+	if !json.Valid(payload) {
+		decoded, err := decodeProtobufPayload(payload, endpointName)
+		if err != nil {
+			record.RawPayload = []byte(f.scrub(string(payload)))
+			record.Error = fmt.Sprintf("cannot decode the protobuf payload: %v", err)
+			return
+		}
+		if decoded == nil {
+			record.RawPayload = []byte(f.scrub(string(payload)))
+			return
+		}
+		payload = decoded
+	}
+	record.Payload = []byte(f.scrub(string(payload)))
+}
+
+// scrub obfuscates the API keys found in s, which are part of the host metadata
+// payloads for instance.
+func (f *TeeForwarder) scrub(s string) string {
+	for _, key := range f.apiKeys {
+		if len(key) > 5 && strings.Contains(s, key) {
+			s = strings.ReplaceAll(s, key, strings.Repeat("*", len(key)-5)+key[len(key)-5:])
+		}
+	}
+	return s
+}
+
+// teeProtobufPayloads returns the message of the protobuf payloads of the endpoints
+// submitting agent-payload messages
+var teeProtobufPayloads = map[string]func() proto.Message{
+	SeriesEndpointName:        func() proto.Message { return &agentpayload.MetricsPayload{} },
+	EventsEndpointName:        func() proto.Message { return &agentpayload.EventsPayload{} },
+	ServiceChecksEndpointName: func() proto.Message { return &agentpayload.ServiceChecksPayload{} },
+	SketchSeriesEndpointName:  func() proto.Message { return &agentpayload.SketchPayload{} },
+}
+
+// teeProcessPayloads are the endpoints submitting process messages, a header
+// followed by a protobuf body
+var teeProcessPayloads = map[string]struct{}{
+	processesEndpoint.Name:    {},
+	rtProcessesEndpoint.Name:  {},
+	containerEndpoint.Name:    {},
+	rtContainerEndpoint.Name:  {},
+	connectionsEndpoint.Name:  {},
+	orchestratorEndpoint.Name: {},
+}
+
+// decodeProtobufPayload returns the JSON encoding of a protobuf payload, nil if
+// the payloads of the endpoint aren't known to be protobuf.
+func decodeProtobufPayload(payload []byte, endpointName string) ([]byte, error) {
+	if newMessage, ok := teeProtobufPayloads[endpointName]; ok {
+		message := newMessage()
+		if err := proto.Unmarshal(payload, message); err != nil {
+			return nil, err
+		}
+		return json.Marshal(message)
+	}
+	if _, ok := teeProcessPayloads[endpointName]; ok {
+		message, err := process.DecodeMessage(payload)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(message)
+	}
+	return nil, nil
+}
+
+func (f *TeeForwarder) submit(endpoint transaction.Endpoint, payload Payloads, extra http.Header, next func() error) error {
+	f.write(endpoint, payload, extra)
+	if f.dryRun {
+		return nil
+	}
+	return next()
+}
+
+func (f *TeeForwarder) submitProcessLike(endpoint transaction.Endpoint, payload Payloads, extra http.Header, next func() (chan Response, error)) (chan Response, error) {
+	f.write(endpoint, payload, extra)
+	if f.dryRun {
+		// no response will ever be received
+		responses := make(chan Response)
+		close(responses)
+		return responses, nil
+	}
+	return next()
+}
+
+// SubmitV1Series writes then submits timeseries to the v1 endpoint
+func (f *TeeForwarder) SubmitV1Series(payload Payloads, extra http.Header) error {
+	return f.submit(v1SeriesEndpoint, payload, extra, func() error { return f.next.SubmitV1Series(payload, extra) })
+}
+
+// SubmitV1Intake writes then submits payloads to the universal `/intake/` endpoint
+func (f *TeeForwarder) SubmitV1Intake(payload Payloads, extra http.Header) error {
+	return f.submit(v1IntakeEndpoint, payload, extra, func() error { return f.next.SubmitV1Intake(payload, extra) })
+}
+
+// SubmitV1CheckRuns writes then submits service checks to the v1 endpoint
+func (f *TeeForwarder) SubmitV1CheckRuns(payload Payloads, extra http.Header) error {
+	return f.submit(v1CheckRunsEndpoint, payload, extra, func() error { return f.next.SubmitV1CheckRuns(payload, extra) })
+}
+
+// SubmitSeries writes then submits a series type payload
+func (f *TeeForwarder) SubmitSeries(payload Payloads, extra http.Header) error {
+	return f.submit(seriesEndpoint, payload, extra, func() error { return f.next.SubmitSeries(payload, extra) })
+}
+
+// SubmitEvents writes then submits an event type payload
+func (f *TeeForwarder) SubmitEvents(payload Payloads, extra http.Header) error {
+	return f.submit(eventsEndpoint, payload, extra, func() error { return f.next.SubmitEvents(payload, extra) })
+}
+
+// SubmitServiceChecks writes then submits a service check type payload
+func (f *TeeForwarder) SubmitServiceChecks(payload Payloads, extra http.Header) error {
+	return f.submit(serviceChecksEndpoint, payload, extra, func() error { return f.next.SubmitServiceChecks(payload, extra) })
+}
+
+// SubmitSketchSeries writes then submits sketches
+func (f *TeeForwarder) SubmitSketchSeries(payload Payloads, extra http.Header) error {
+	return f.submit(sketchSeriesEndpoint, payload, extra, func() error { return f.next.SubmitSketchSeries(payload, extra) })
+}
+
+// SubmitHostMetadata writes then submits a host_metadata payload
+func (f *TeeForwarder) SubmitHostMetadata(payload Payloads, extra http.Header) error {
+	return f.submit(v1IntakeEndpoint, payload, extra, func() error { return f.next.SubmitHostMetadata(payload, extra) })
+}
+
+// SubmitAgentChecksMetadata writes then submits an agentchecks_metadata payload
+func (f *TeeForwarder) SubmitAgentChecksMetadata(payload Payloads, extra http.Header) error {
+	return f.submit(v1IntakeEndpoint, payload, extra, func() error { return f.next.SubmitAgentChecksMetadata(payload, extra) })
+}
+
+// SubmitMetadata writes then submits a metadata payload
+func (f *TeeForwarder) SubmitMetadata(payload Payloads, extra http.Header) error {
+	return f.submit(v1IntakeEndpoint, payload, extra, func() error { return f.next.SubmitMetadata(payload, extra) })
+}
+
+// SubmitProcessChecks writes then submits process checks
+func (f *TeeForwarder) SubmitProcessChecks(payload Payloads, extra http.Header) (chan Response, error) {
+	return f.submitProcessLike(processesEndpoint, payload, extra, func() (chan Response, error) { return f.next.SubmitProcessChecks(payload, extra) })
+}
+
+// SubmitRTProcessChecks writes then submits real time process checks
+func (f *TeeForwarder) SubmitRTProcessChecks(payload Payloads, extra http.Header) (chan Response, error) {
+	return f.submitProcessLike(rtProcessesEndpoint, payload, extra, func() (chan Response, error) { return f.next.SubmitRTProcessChecks(payload, extra) })
+}
+
+// SubmitContainerChecks writes then submits container checks
+func (f *TeeForwarder) SubmitContainerChecks(payload Payloads, extra http.Header) (chan Response, error) {
+	return f.submitProcessLike(containerEndpoint, payload, extra, func() (chan Response, error) { return f.next.SubmitContainerChecks(payload, extra) })
+}
+
+// SubmitRTContainerChecks writes then submits real time container checks
+func (f *TeeForwarder) SubmitRTContainerChecks(payload Payloads, extra http.Header) (chan Response, error) {
+	return f.submitProcessLike(rtContainerEndpoint, payload, extra, func() (chan Response, error) { return f.next.SubmitRTContainerChecks(payload, extra) })
+}
+
+// SubmitConnectionChecks writes then submits connection checks
+func (f *TeeForwarder) SubmitConnectionChecks(payload Payloads, extra http.Header) (chan Response, error) {
+	return f.submitProcessLike(connectionsEndpoint, payload, extra, func() (chan Response, error) { return f.next.SubmitConnectionChecks(payload, extra) })
+}
+
+// SubmitOrchestratorChecks writes then submits orchestrator checks
+func (f *TeeForwarder) SubmitOrchestratorChecks(payload Payloads, extra http.Header, payloadType string) (chan Response, error) {
+	return f.submitProcessLike(orchestratorEndpoint, payload, extra, func() (chan Response, error) {
+		return f.next.SubmitOrchestratorChecks(payload, extra, payloadType)
+	})
+}
+
+// Compressor returns the compression of the forwarder the payloads are submitted to,
+// so that the payloads are written as they would be sent.
+func (f *TeeForwarder) Compressor(endpointName string) compression.Compressor {
+	return CompressorFor(f.next, endpointName)
+}
@@ -0,0 +1,227 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+// +build test
+
+package forwarder
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	agentpayload "github.com/DataDog/agent-payload/gogen"
+	"github.com/DataDog/agent-payload/process"
+	"github.com/gogo/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/config"
+	"github.com/DataDog/datadog-agent/pkg/util/compression"
+)
+
+const teeTestAPIKey = "0123456789abcdef0123456789abcdef"
+
+type bufferCloser struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (b *bufferCloser) Close() error {
+	b.closed = true
+	return nil
+}
+
+func decodeTeeRecords(t *testing.T, r io.Reader) []teeRecord {
+	var records []teeRecord
+	decoder := json.NewDecoder(r)
+	for decoder.More() {
+		var record teeRecord
+		require.NoError(t, decoder.Decode(&record))
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestTeeForwarder(t *testing.T) {
+	next := &MockedForwarder{}
+	output := &bufferCloser{}
+	f := NewTeeForwarder(next, output, false, []string{teeTestAPIKey})
+
+	c, err := compression.NewCompressor(compression.GzipKind, 0)
+	require.NoError(t, err)
+	compressed, err := c.Compress([]byte(`{"series":[{"metric":"foo","tags":["env:prod"]}]}`))
+	require.NoError(t, err)
+	payload := Payloads{&compressed}
+	headers := http.Header{}
+	headers.Set("Content-Type", "application/json")
+	headers.Set("Content-Encoding", "gzip")
+	headers.Set("DD-Api-Key", teeTestAPIKey)
+
+	next.On("SubmitSeries", payload, headers).Return(nil).Times(1)
+	require.NoError(t, f.SubmitSeries(payload, headers))
+	next.AssertExpectations(t)
+
+	hostMetadata := []byte(`{"apiKey":"` + teeTestAPIKey + `"}`)
+	sketches := &agentpayload.SketchPayload{Sketches: []agentpayload.SketchPayload_Sketch{{Metric: "foo", Tags: []string{"env:prod"}}}}
+	protobuf, err := proto.Marshal(sketches)
+	require.NoError(t, err)
+	next.On("SubmitHostMetadata", Payloads{&hostMetadata}, http.Header(nil)).Return(nil).Times(1)
+	next.On("SubmitSketchSeries", Payloads{&protobuf}, http.Header(nil)).Return(nil).Times(1)
+	require.NoError(t, f.SubmitHostMetadata(Payloads{&hostMetadata}, nil))
+	require.NoError(t, f.SubmitSketchSeries(Payloads{&protobuf}, nil))
+	next.AssertExpectations(t)
+
+	records := decodeTeeRecords(t, output)
+	require.Len(t, records, 3)
+
+	assert.Equal(t, SeriesEndpointName, records[0].Endpoint)
+	assert.Equal(t, "/api/v2/series", records[0].Route)
+	assert.Equal(t, map[string]string{"Content-Type": "application/json", "Content-Encoding": "gzip"}, records[0].Headers)
+	assert.JSONEq(t, `{"series":[{"metric":"foo","tags":["env:prod"]}]}`, string(records[0].Payload))
+	assert.Empty(t, records[0].Error)
+
+	assert.Equal(t, V1IntakeEndpointName, records[1].Endpoint)
+	assert.JSONEq(t, `{"apiKey":"***************************bcdef"}`, string(records[1].Payload))
+
+	assert.Equal(t, SketchSeriesEndpointName, records[2].Endpoint)
+	assert.Nil(t, records[2].RawPayload)
+	assert.Empty(t, records[2].Error)
+	var decoded agentpayload.SketchPayload
+	require.NoError(t, json.Unmarshal(records[2].Payload, &decoded))
+	assert.Equal(t, "foo", decoded.Sketches[0].Metric)
+	assert.Equal(t, []string{"env:prod"}, decoded.Sketches[0].Tags)
+
+	next.On("Stop").Return().Times(1)
+	f.Stop()
+	next.AssertExpectations(t)
+	assert.True(t, output.closed)
+}
+
+func TestTeeForwarderDecompressionError(t *testing.T) {
+	output := &bufferCloser{}
+	f := NewTeeForwarder(&MockedForwarder{}, output, true, nil)
+
+	payload := []byte("not compressed")
+	require.NoError(t, f.SubmitEvents(Payloads{&payload}, http.Header{"Content-Encoding": []string{"deflate"}}))
+	require.NoError(t, f.SubmitEvents(Payloads{&payload}, http.Header{"Content-Encoding": []string{"br"}}))
+
+	records := decodeTeeRecords(t, output)
+	require.Len(t, records, 2)
+	assert.Equal(t, payload, records[0].RawPayload)
+	assert.NotEmpty(t, records[0].Error)
+	assert.Equal(t, payload, records[1].RawPayload)
+	assert.Equal(t, `cannot decompress the payload: unknown content encoding "br"`, records[1].Error)
+}
+
+func TestTeeForwarderProcessPayload(t *testing.T) {
+	output := &bufferCloser{}
+	f := NewTeeForwarder(&MockedForwarder{}, output, true, nil)
+
+	payload, err := process.EncodeMessage(process.Message{
+		Header: process.MessageHeader{
+			Version:  process.MessageV3,
+			Encoding: process.MessageEncodingZstdPB,
+			Type:     process.TypeCollectorProc,
+		},
+		Body: &process.CollectorProc{HostName: "my-host"},
+	})
+	require.NoError(t, err)
+	_, err = f.SubmitProcessChecks(Payloads{&payload}, nil)
+	require.NoError(t, err)
+
+	records := decodeTeeRecords(t, output)
+	require.Len(t, records, 1)
+	assert.Nil(t, records[0].RawPayload)
+	assert.Empty(t, records[0].Error)
+	var decoded struct {
+		Body process.CollectorProc
+	}
+	require.NoError(t, json.Unmarshal(records[0].Payload, &decoded))
+	assert.Equal(t, "my-host", decoded.Body.HostName)
+}
+
+func TestTeeForwarderProtobufDecodingError(t *testing.T) {
+	output := &bufferCloser{}
+	f := NewTeeForwarder(&MockedForwarder{}, output, true, nil)
+
+	payload := []byte{0x0a, 0xff}
+	require.NoError(t, f.SubmitSketchSeries(Payloads{&payload}, nil))
+
+	records := decodeTeeRecords(t, output)
+	require.Len(t, records, 1)
+	assert.Nil(t, records[0].Payload)
+	assert.Equal(t, payload, records[0].RawPayload)
+	assert.NotEmpty(t, records[0].Error)
+}
+
+func TestTeeForwarderDryRun(t *testing.T) {
+	// nothing is submitted to the mocked forwarder, which would fail otherwise
+	next := &MockedForwarder{}
+	output := &bufferCloser{}
+	f := NewTeeForwarder(next, output, true, nil)
+
+	require.NoError(t, f.Start())
+	payload := []byte(`{"check":"foo"}`)
+	require.NoError(t, f.SubmitServiceChecks(Payloads{&payload}, nil))
+	responses, err := f.SubmitProcessChecks(Payloads{&payload}, nil)
+	require.NoError(t, err)
+	_, ok := <-responses
+	assert.False(t, ok)
+	f.Stop()
+
+	records := decodeTeeRecords(t, output)
+	require.Len(t, records, 2)
+	assert.Equal(t, ServiceChecksEndpointName, records[0].Endpoint)
+	assert.Equal(t, "process", records[1].Endpoint)
+	next.AssertExpectations(t)
+}
+
+func TestNewForwarder(t *testing.T) {
+	mockConfig := config.Mock()
+	dir, err := ioutil.TempDir("", "tee")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	options := NewOptions(keysPerDomains)
+	assert.Equal(t, "", options.TeeOutput)
+	assert.IsType(t, &DefaultForwarder{}, NewForwarder(options))
+
+	output := filepath.Join(dir, "payloads.json")
+	mockConfig.Set("forwarder_tee_output", output)
+	mockConfig.Set("forwarder_tee_dry_run", true)
+	defer func() {
+		mockConfig.Set("forwarder_tee_output", "")
+		mockConfig.Set("forwarder_tee_dry_run", false)
+	}()
+
+	options = NewOptions(keysPerDomains)
+	assert.Equal(t, output, options.TeeOutput)
+	assert.True(t, options.TeeDryRun)
+	assert.Equal(t, int64(10*1024*1024), options.TeeMaxFileSize)
+	assert.Equal(t, 5, options.TeeMaxFiles)
+
+	f := NewForwarder(options)
+	require.IsType(t, &TeeForwarder{}, f)
+	payload := []byte(`{}`)
+	require.NoError(t, f.SubmitV1Intake(Payloads{&payload}, nil))
+	f.Stop()
+
+	content, err := os.Open(output)
+	require.NoError(t, err)
+	defer content.Close()
+	assert.Len(t, decodeTeeRecords(t, content), 1)
+
+	// the payloads are never sent in dry-run mode, even when they cannot be written
+	options.TeeOutput = filepath.Join(output, "not_a_directory", "payloads.json")
+	f = NewForwarder(options)
+	require.IsType(t, &TeeForwarder{}, f)
+	assert.True(t, f.(*TeeForwarder).dryRun)
+}
@@ -0,0 +1,52 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package forwarder
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRotatingFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tee")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "payloads.json")
+	r, err := newRotatingFile(path, 10, 2)
+	require.NoError(t, err)
+
+	for _, record := range []string{"aaaaaa", "bbbb", "cccccc", "dddddd", "eeeeeeeeeeeeeeee", "f"} {
+		_, err := r.Write([]byte(record))
+		require.NoError(t, err)
+	}
+	require.NoError(t, r.Close())
+
+	readFile := func(path string) string {
+		content, err := ioutil.ReadFile(path)
+		require.NoError(t, err)
+		return string(content)
+	}
+	// records are never split, even when larger than the maximum size
+	assert.Equal(t, "f", readFile(path))
+	assert.Equal(t, "eeeeeeeeeeeeeeee", readFile(path+".1"))
+	assert.Equal(t, "dddddd", readFile(path+".2"))
+	_, err = os.Stat(path + ".3")
+	assert.True(t, os.IsNotExist(err))
+
+	// the current file is appended to after a restart
+	r, err = newRotatingFile(path, 10, 2)
+	require.NoError(t, err)
+	_, err = r.Write([]byte("g"))
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+	assert.Equal(t, "fg", readFile(path))
+}
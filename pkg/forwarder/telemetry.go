@@ -12,6 +12,18 @@ import (
 	"github.com/DataDog/datadog-agent/pkg/telemetry"
 )
 
+// Names of the endpoints which payloads are built by the serializer, used to select
+// the compression of their payloads.
+const (
+	V1SeriesEndpointName      = "series_v1"
+	V1CheckRunsEndpointName   = "check_run_v1"
+	V1IntakeEndpointName      = "intake"
+	SeriesEndpointName        = "series_v2"
+	EventsEndpointName        = "events_v2"
+	ServiceChecksEndpointName = "services_checks_v2"
+	SketchSeriesEndpointName  = "sketches_v2"
+)
+
 var (
 	transactionsIntakePod         = expvar.Int{}
 	transactionsIntakeDeployment  = expvar.Int{}
@@ -24,16 +36,16 @@ var (
 	transactionsIntakeDaemonSet   = expvar.Int{}
 	transactionsIntakeStatefulSet = expvar.Int{}
 
-	v1SeriesEndpoint       = transaction.Endpoint{Route: "/api/v1/series", Name: "series_v1"}
-	v1CheckRunsEndpoint    = transaction.Endpoint{Route: "/api/v1/check_run", Name: "check_run_v1"}
-	v1IntakeEndpoint       = transaction.Endpoint{Route: "/intake/", Name: "intake"}
+	v1SeriesEndpoint       = transaction.Endpoint{Route: "/api/v1/series", Name: V1SeriesEndpointName}
+	v1CheckRunsEndpoint    = transaction.Endpoint{Route: "/api/v1/check_run", Name: V1CheckRunsEndpointName}
+	v1IntakeEndpoint       = transaction.Endpoint{Route: "/intake/", Name: V1IntakeEndpointName}
 	v1SketchSeriesEndpoint = transaction.Endpoint{Route: "/api/v1/sketches", Name: "sketches_v1"} // nolint unused for now
 	v1ValidateEndpoint     = transaction.Endpoint{Route: "/api/v1/validate", Name: "validate_v1"}
 
-	seriesEndpoint        = transaction.Endpoint{Route: "/api/v2/series", Name: "series_v2"}
-	eventsEndpoint        = transaction.Endpoint{Route: "/api/v2/events", Name: "events_v2"}
-	serviceChecksEndpoint = transaction.Endpoint{Route: "/api/v2/service_checks", Name: "services_checks_v2"}
-	sketchSeriesEndpoint  = transaction.Endpoint{Route: "/api/beta/sketches", Name: "sketches_v2"}
+	seriesEndpoint        = transaction.Endpoint{Route: "/api/v2/series", Name: SeriesEndpointName}
+	eventsEndpoint        = transaction.Endpoint{Route: "/api/v2/events", Name: EventsEndpointName}
+	serviceChecksEndpoint = transaction.Endpoint{Route: "/api/v2/service_checks", Name: ServiceChecksEndpointName}
+	sketchSeriesEndpoint  = transaction.Endpoint{Route: "/api/beta/sketches", Name: SketchSeriesEndpointName}
 	hostMetadataEndpoint  = transaction.Endpoint{Route: "/api/v2/host_metadata", Name: "host_metadata_v2"}
 	metadataEndpoint      = transaction.Endpoint{Route: "/api/v2/metadata", Name: "metadata_v2"}
 
@@ -72,6 +84,8 @@ func init() {
 	initTransactionsExpvars()
 	initForwarderHealthExpvars()
 	initEndpointExpvars()
+	initEgressSchedulerExpvars()
+	initFailoverExpvars()
 }
 
 func initEndpointExpvars() {
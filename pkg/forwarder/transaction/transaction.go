@@ -8,13 +8,16 @@ package transaction
 import (
 	"bytes"
 	"context"
+	"crypto/rand"
 	"crypto/tls"
+	"encoding/hex"
 	"expvar"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptrace"
 	"strconv"
+	"sync/atomic"
 	"time"
 
 	"github.com/DataDog/datadog-agent/pkg/config"
@@ -54,6 +57,8 @@ var (
 	transactionsHTTPErrors             = expvar.Int{}
 	transactionsHTTPErrorsByCode       = expvar.Map{}
 
+	transactionsRetriedAfterAmbiguousFailure = expvar.Int{}
+
 	tlmConnectEvents = telemetry.NewCounter("transactions", "connection_events",
 		[]string{"connection_event_type"}, "Count of new connection events grouped by type of event")
 
@@ -68,6 +73,8 @@ var (
 		[]string{"domain", "endpoint", "error_type"}, "Count of transactions errored grouped by type of error")
 	tlmTxHTTPErrors = telemetry.NewCounter("transactions", "http_errors",
 		[]string{"domain", "endpoint", "code"}, "Count of transactions http errors per http code")
+	tlmTxAmbiguousRetries = telemetry.NewCounter("transactions", "ambiguous_retries",
+		[]string{"domain", "endpoint"}, "Count of transactions retried after a failure that may have happened once the intake received them")
 )
 
 // Trace is an httptrace.ClientTrace instance that traces the events within HTTP client requests.
@@ -148,6 +155,22 @@ func init() {
 	transactionsErrorsByType.Set("SentRequestErrors", &transactionsSentRequestErrors)
 	TransactionsExpvars.Set("HTTPErrors", &transactionsHTTPErrors)
 	TransactionsExpvars.Set("HTTPErrorsByCode", &transactionsHTTPErrorsByCode)
+	TransactionsExpvars.Set("RetriedAfterAmbiguousFailure", &transactionsRetriedAfterAmbiguousFailure)
+}
+
+// IdempotencyKeyHTTPHeaderKey is the header carrying the idempotency key of a
+// transaction. The key is kept when the transaction is retried, so that the
+// intake can discard the submissions of a payload it already received.
+const IdempotencyKeyHTTPHeaderKey = "DD-Idempotency-Key"
+
+// NewIdempotencyKey returns a new random idempotency key.
+func NewIdempotencyKey() string {
+	key := make([]byte, 16)
+	if _, err := rand.Read(key); err != nil {
+		// the key is only used to discard duplicates, fall back on a key unique to this agent
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+	return hex.EncodeToString(key)
 }
 
 // Priority defines the priority of a transaction
@@ -191,6 +214,11 @@ type HTTPTransaction struct {
 	CompletionHandler HTTPCompletionHandler
 
 	Priority Priority
+
+	// AmbiguousFailure indicates whether the last attempt failed after the request was sent: the intake
+	// may have received the payload, and retrying the transaction may submit it twice.
+	// This field is not restored when a transaction is deserialized from the disk (the default value is used).
+	AmbiguousFailure bool
 }
 
 // TransactionsSerializer serializes Transaction instances.
@@ -267,6 +295,11 @@ func (t *HTTPTransaction) GetPayloadSize() int {
 func (t *HTTPTransaction) Process(ctx context.Context, client *http.Client) error {
 	t.AttemptHandler(t)
 
+	if t.AmbiguousFailure {
+		transactionsRetriedAfterAmbiguousFailure.Add(1)
+		tlmTxAmbiguousRetries.Inc(t.Domain, t.GetEndpointName())
+	}
+
 	statusCode, body, err := t.internalProcess(ctx, client)
 
 	if err == nil || !t.Retryable {
@@ -298,8 +331,16 @@ func (t *HTTPTransaction) internalProcess(ctx context.Context, client *http.Clie
 		transactionsSentRequestErrors.Add(1)
 		return 0, nil, nil
 	}
-	req = req.WithContext(ctx)
+	var wroteRequest int32
+	req = req.WithContext(httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		WroteRequest: func(wroteInfo httptrace.WroteRequestInfo) {
+			if wroteInfo.Err == nil {
+				atomic.StoreInt32(&wroteRequest, 1)
+			}
+		},
+	}))
 	req.Header = t.Headers
+	t.AmbiguousFailure = false
 	resp, err := client.Do(req)
 
 	if err != nil {
@@ -307,6 +348,8 @@ func (t *HTTPTransaction) internalProcess(ctx context.Context, client *http.Clie
 		if ctx.Err() == context.Canceled {
 			return 0, nil, nil
 		}
+		// the intake may have received the request when the error happened while waiting for the response
+		t.AmbiguousFailure = atomic.LoadInt32(&wroteRequest) == 1
 		t.ErrorCount++
 		transactionsErrors.Add(1)
 		tlmTxErrors.Inc(t.Domain, transactionEndpointName, "cant_send")
@@ -347,6 +390,7 @@ func (t *HTTPTransaction) internalProcess(ctx context.Context, client *http.Clie
 		TlmTxDropped.Inc(t.Domain, transactionEndpointName)
 		return resp.StatusCode, body, nil
 	} else if resp.StatusCode > 400 {
+		t.AmbiguousFailure = isAmbiguousStatusCode(resp.StatusCode)
 		t.ErrorCount++
 		transactionsErrors.Add(1)
 		tlmTxErrors.Inc(t.Domain, transactionEndpointName, "gt_400")
@@ -375,6 +419,14 @@ func (t *HTTPTransaction) internalProcess(ctx context.Context, client *http.Clie
 	return resp.StatusCode, body, nil
 }
 
+// isAmbiguousStatusCode returns whether the intake may have processed a request
+// answered with statusCode, as when a load balancer timed out waiting for it.
+func isAmbiguousStatusCode(statusCode int) bool {
+	return statusCode == http.StatusInternalServerError ||
+		statusCode == http.StatusBadGateway ||
+		statusCode == http.StatusGatewayTimeout
+}
+
 // SerializeTo serializes the transaction using TransactionsSerializer
 func (t *HTTPTransaction) SerializeTo(serializer TransactionsSerializer) error {
 	if t.StorableOnDisk {
@@ -7,6 +7,7 @@ package transaction
 
 import (
 	"context"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -112,6 +113,54 @@ func TestProcessHTTPError(t *testing.T) {
 	assert.Equal(t, transaction.ErrorCount, 1)
 }
 
+func TestProcessAmbiguousFailure(t *testing.T) {
+	var errorCode int
+	closeConnection := false
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = ioutil.ReadAll(r.Body)
+		if closeConnection {
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err == nil {
+				conn.Close()
+			}
+			return
+		}
+		w.WriteHeader(errorCode)
+	}))
+	defer ts.Close()
+
+	transaction := NewHTTPTransaction()
+	transaction.Domain = ts.URL
+	transaction.Endpoint.Route = "/endpoint/test"
+	payload := []byte("test payload")
+	transaction.Payload = &payload
+
+	client := &http.Client{}
+	retries := transactionsRetriedAfterAmbiguousFailure.Value()
+
+	// the intake may have received the payloads answered by a gateway timeout
+	errorCode = http.StatusGatewayTimeout
+	assert.NotNil(t, transaction.Process(context.Background(), client))
+	assert.True(t, transaction.AmbiguousFailure)
+	assert.Equal(t, retries, transactionsRetriedAfterAmbiguousFailure.Value())
+
+	errorCode = http.StatusServiceUnavailable
+	assert.NotNil(t, transaction.Process(context.Background(), client))
+	assert.False(t, transaction.AmbiguousFailure)
+	assert.Equal(t, retries+1, transactionsRetriedAfterAmbiguousFailure.Value())
+
+	// the connection is closed once the request was sent
+	closeConnection = true
+	assert.NotNil(t, transaction.Process(context.Background(), client))
+	assert.True(t, transaction.AmbiguousFailure)
+
+	// the request can't be sent
+	transaction.Domain = "http://localhost:1234"
+	assert.NotNil(t, transaction.Process(context.Background(), client))
+	assert.False(t, transaction.AmbiguousFailure)
+	assert.Equal(t, retries+2, transactionsRetriedAfterAmbiguousFailure.Value())
+}
+
 func TestProcessCancel(t *testing.T) {
 	transaction := NewHTTPTransaction()
 	transaction.Domain = "example.com"
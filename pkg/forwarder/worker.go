@@ -36,6 +36,9 @@ type Worker struct {
 	stopChan            chan struct{}
 	stopped             chan struct{}
 	blockedList         *blockedEndpoints
+	// transport is cloned to create the connections of the worker, the agent-wide
+	// transport is used when nil
+	transport *http.Transport
 }
 
 // NewWorker returns a new worker to consume Transaction from inputChan
@@ -45,6 +48,15 @@ func NewWorker(
 	lowPrioChan <-chan transaction.Transaction,
 	requeueChan chan<- transaction.Transaction,
 	blocked *blockedEndpoints) *Worker {
+	return newWorker(highPrioChan, lowPrioChan, requeueChan, blocked, nil)
+}
+
+func newWorker(
+	highPrioChan <-chan transaction.Transaction,
+	lowPrioChan <-chan transaction.Transaction,
+	requeueChan chan<- transaction.Transaction,
+	blocked *blockedEndpoints,
+	transport *http.Transport) *Worker {
 	return &Worker{
 		HighPrio:            highPrioChan,
 		LowPrio:             lowPrioChan,
@@ -52,13 +64,20 @@ func NewWorker(
 		resetConnectionChan: make(chan struct{}, 1),
 		stopChan:            make(chan struct{}),
 		stopped:             make(chan struct{}),
-		Client:              newHTTPClient(),
+		Client:              newHTTPClient(transport),
 		blockedList:         blocked,
+		transport:           transport,
 	}
 }
 
-func newHTTPClient() *http.Client {
-	transport := httputils.CreateHTTPTransport()
+func newHTTPClient(template *http.Transport) *http.Client {
+	var transport *http.Transport
+	if template != nil {
+		// cloning the transport gives new connections with the same settings
+		transport = template.Clone()
+	} else {
+		transport = httputils.CreateHTTPTransport()
+	}
 
 	return &http.Client{
 		Timeout:   config.Datadog.GetDuration("forwarder_timeout") * time.Second,
@@ -192,5 +211,5 @@ func (w *Worker) process(ctx context.Context, t transaction.Transaction) {
 func (w *Worker) resetConnections() {
 	log.Debug("Resetting worker's connections")
 	w.Client.CloseIdleConnections()
-	w.Client = newHTTPClient()
+	w.Client = newHTTPClient(w.transport)
 }
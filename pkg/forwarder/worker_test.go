@@ -41,6 +41,24 @@ func TestNewNoSSLWorker(t *testing.T) {
 	assert.True(t, w.Client.Transport.(*http.Transport).TLSClientConfig.InsecureSkipVerify)
 }
 
+func TestNewWorkerWithTransport(t *testing.T) {
+	highPrio := make(chan transaction.Transaction)
+	lowPrio := make(chan transaction.Transaction)
+	requeue := make(chan transaction.Transaction)
+
+	transport := &http.Transport{MaxConnsPerHost: 3, ForceAttemptHTTP2: true}
+	w := newWorker(highPrio, lowPrio, requeue, newBlockedEndpoints(), transport)
+	clientTransport := w.Client.Transport.(*http.Transport)
+	assert.NotSame(t, transport, clientTransport)
+	assert.Equal(t, 3, clientTransport.MaxConnsPerHost)
+	assert.True(t, clientTransport.ForceAttemptHTTP2)
+
+	// the connections are reset with a new clone of the transport
+	w.resetConnections()
+	assert.NotSame(t, clientTransport, w.Client.Transport)
+	assert.Equal(t, 3, w.Client.Transport.(*http.Transport).MaxConnsPerHost)
+}
+
 func TestWorkerStart(t *testing.T) {
 	highPrio := make(chan transaction.Transaction)
 	lowPrio := make(chan transaction.Transaction)
@@ -11,6 +11,7 @@ import (
 	"errors"
 	"expvar"
 	"fmt"
+	"math"
 	"sort"
 	"strconv"
 	"strings"
@@ -18,9 +19,11 @@ import (
 	agentpayload "github.com/DataDog/agent-payload/gogen"
 	"github.com/gogo/protobuf/proto"
 	jsoniter "github.com/json-iterator/go"
+	"google.golang.org/protobuf/encoding/protowire"
 
 	"github.com/DataDog/datadog-agent/pkg/aggregator/ckey"
 	"github.com/DataDog/datadog-agent/pkg/serializer/marshaler"
+	"github.com/DataDog/datadog-agent/pkg/serializer/stream"
 	"github.com/DataDog/datadog-agent/pkg/telemetry"
 )
 
@@ -236,9 +239,161 @@ func (series Series) SplitPayload(times int) ([]marshaler.Marshaler, error) {
 	return payloads, nil
 }
 
-// MarshalSplitCompress not implemented
+// Field numbers of the agent-payload MetricsPayload message and its nested messages,
+// used to stream series without building the whole payload - see agent_payload.proto.
+const (
+	metricsPayloadSamples  = 1
+	metricsPayloadMetadata = 2
+
+	sampleMetric         = 1
+	sampleType           = 2
+	sampleHost           = 3
+	samplePoints         = 4
+	sampleTags           = 5
+	sampleSourceTypeName = 6
+
+	pointTs    = 1
+	pointValue = 2
+)
+
+// MarshalSplitCompress streams the series into compressed protobuf MetricsPayloads, starting
+// a new payload each time the current one is full. The result is equivalent to splitting the
+// output of Marshal, without marshaling the whole payload first.
 func (series Series) MarshalSplitCompress(bufferContext *marshaler.BufferContext) ([]*[]byte, error) {
-	return nil, fmt.Errorf("Series MarshalSplitCompress is not implemented")
+	// The Metadata field of MetricsPayload is never written to - so pack an empty metadata as the footer
+	footer := protowire.AppendVarint(protowire.AppendTag(nil, metricsPayloadMetadata, protowire.BytesType), 0)
+
+	bufferContext.CompressorInput.Reset()
+	bufferContext.CompressorOutput.Reset()
+
+	compressor, e := stream.NewCompressorWithCompression(bufferContext.Compression, bufferContext.CompressorInput, bufferContext.CompressorOutput, []byte{}, footer, []byte{})
+	if e != nil {
+		return nil, e
+	}
+	payloads := []*[]byte{}
+
+	for _, serie := range series {
+		// Each serie is written as a Samples field of MetricsPayload. The pre-compression
+		// buffer is reused between series and only grows when a serie needs more room.
+		buf := bufferContext.PrecompressionBuf[:0]
+		buf = protowire.AppendTag(buf, metricsPayloadSamples, protowire.BytesType)
+		buf = protowire.AppendVarint(buf, uint64(sampleSize(serie)))
+		buf = appendSample(buf, serie)
+		bufferContext.PrecompressionBuf = buf[:cap(buf)]
+
+		switch e = compressor.AddItem(buf); e {
+		case stream.ErrPayloadFull:
+			seriesExpvar.Add("StreamPayloadFull", 1)
+			tlmSeries.Inc("stream_payload_full")
+
+			// Since the compression buffer is full - flush it and rotate
+			payload, e := compressor.Close()
+			if e != nil {
+				return nil, e
+			}
+			payloads = append(payloads, &payload)
+			bufferContext.CompressorInput.Reset()
+			bufferContext.CompressorOutput.Reset()
+			compressor, e = stream.NewCompressorWithCompression(bufferContext.Compression, bufferContext.CompressorInput, bufferContext.CompressorOutput, []byte{}, footer, []byte{})
+			if e != nil {
+				return nil, e
+			}
+
+			// Add it to the new compression buffer
+			e = compressor.AddItem(buf)
+			if e == stream.ErrItemTooBig {
+				seriesExpvar.Add("StreamItemTooBig", 1)
+				tlmSeries.Inc("stream_item_too_big")
+				continue
+			}
+			if e != nil {
+				return nil, e
+			}
+		case stream.ErrItemTooBig:
+			// Item was too big, drop it
+			seriesExpvar.Add("StreamItemTooBig", 1)
+			tlmSeries.Inc("stream_item_too_big")
+		case nil:
+			continue
+		default:
+			// Unexpected error bail out
+			return nil, e
+		}
+	}
+
+	payload, e := compressor.Close()
+	if e != nil {
+		return nil, e
+	}
+	payloads = append(payloads, &payload)
+
+	return payloads, nil
+}
+
+// sampleSize returns the size of serie encoded as a MetricsPayload_Sample.
+func sampleSize(serie *Serie) int {
+	n := stringFieldSize(sampleMetric, serie.Name) +
+		stringFieldSize(sampleType, serie.MType.String()) +
+		stringFieldSize(sampleHost, serie.Host) +
+		stringFieldSize(sampleSourceTypeName, serie.SourceTypeName)
+	for _, p := range serie.Points {
+		n += protowire.SizeTag(samplePoints) + protowire.SizeBytes(pointSize(p))
+	}
+	for _, t := range serie.Tags {
+		n += protowire.SizeTag(sampleTags) + protowire.SizeBytes(len(t))
+	}
+	return n
+}
+
+// appendSample appends serie to b, encoded as a MetricsPayload_Sample. Like the generated
+// code, it omits the fields holding a zero value.
+func appendSample(b []byte, serie *Serie) []byte {
+	b = appendStringField(b, sampleMetric, serie.Name)
+	b = appendStringField(b, sampleType, serie.MType.String())
+	b = appendStringField(b, sampleHost, serie.Host)
+	for _, p := range serie.Points {
+		b = protowire.AppendTag(b, samplePoints, protowire.BytesType)
+		b = protowire.AppendVarint(b, uint64(pointSize(p)))
+		if ts := int64(p.Ts); ts != 0 {
+			b = protowire.AppendTag(b, pointTs, protowire.VarintType)
+			b = protowire.AppendVarint(b, uint64(ts))
+		}
+		if p.Value != 0 {
+			b = protowire.AppendTag(b, pointValue, protowire.Fixed64Type)
+			b = protowire.AppendFixed64(b, math.Float64bits(p.Value))
+		}
+	}
+	for _, t := range serie.Tags {
+		b = protowire.AppendTag(b, sampleTags, protowire.BytesType)
+		b = protowire.AppendString(b, t)
+	}
+	return appendStringField(b, sampleSourceTypeName, serie.SourceTypeName)
+}
+
+func pointSize(p Point) int {
+	n := 0
+	if ts := int64(p.Ts); ts != 0 {
+		n += protowire.SizeTag(pointTs) + protowire.SizeVarint(uint64(ts))
+	}
+	if p.Value != 0 {
+		n += protowire.SizeTag(pointValue) + protowire.SizeFixed64()
+	}
+	return n
+}
+
+func stringFieldSize(num protowire.Number, s string) int {
+	if s == "" {
+		return 0
+	}
+	return protowire.SizeTag(num) + protowire.SizeBytes(len(s))
+}
+
+func appendStringField(b []byte, num protowire.Number, s string) []byte {
+	if s == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, s)
 }
 
 // UnmarshalJSON is a custom unmarshaller for Point (used for testing)
@@ -18,7 +18,9 @@ import (
 	jsoniter "github.com/json-iterator/go"
 
 	agentpayload "github.com/DataDog/agent-payload/gogen"
+	"github.com/DataDog/datadog-agent/pkg/config"
 	"github.com/DataDog/datadog-agent/pkg/forwarder"
+	"github.com/DataDog/datadog-agent/pkg/serializer/marshaler"
 	"github.com/DataDog/datadog-agent/pkg/serializer/stream"
 	"github.com/gogo/protobuf/proto"
 	"github.com/stretchr/testify/assert"
@@ -495,3 +497,92 @@ func decompressPayload(payload []byte) ([]byte, error) {
 	}
 	return dst, nil
 }
+
+func TestMarshalSplitCompressSeries(t *testing.T) {
+	for name, series := range map[string]Series{
+		"empty": {},
+		"series": {
+			{
+				Points:         []Point{{Ts: 12345.0, Value: 21.21}, {Ts: 67890.0, Value: 0}, {Ts: 0, Value: -1}},
+				MType:          APIGaugeType,
+				Name:           "test.metrics",
+				Host:           "localHost",
+				Tags:           []string{"tag1", "tag2:yes", ""},
+				SourceTypeName: "System",
+			},
+			{
+				Points: []Point{{Ts: 12345.0, Value: 3}},
+				MType:  APICountType,
+				Name:   "test.count",
+			},
+			{
+				Name:  "test.nopoints",
+				MType: APIRateType,
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			expected, err := series.Marshal()
+			require.NoError(t, err)
+
+			payloads, err := series.MarshalSplitCompress(marshaler.DefaultBufferContext())
+			require.NoError(t, err)
+			require.Len(t, payloads, 1)
+			decompressed, err := decompressPayload(*payloads[0])
+			require.NoError(t, err)
+
+			// Check that we encoded the protobuf like the generated code
+			assert.Equal(t, expected, decompressed)
+		})
+	}
+}
+
+func TestMarshalSplitCompressSeriesSplit(t *testing.T) {
+	oldSetting := config.Datadog.Get("serializer_max_uncompressed_payload_size")
+	defer config.Datadog.Set("serializer_max_uncompressed_payload_size", oldSetting)
+	config.Datadog.Set("serializer_max_uncompressed_payload_size", 2000)
+
+	series := Series{}
+	for i := 0; i < 100; i++ {
+		series = append(series, &Serie{
+			Points: []Point{{Ts: 12345.0, Value: float64(i)}},
+			MType:  APIGaugeType,
+			Name:   fmt.Sprintf("test.metrics%d", i),
+			Host:   "localHost",
+			Tags:   []string{"tag1", "tag2:yes"},
+		})
+	}
+	// An item bigger than a payload, which gets dropped
+	series = append(series, &Serie{
+		Points: []Point{{Ts: 12345.0, Value: 1}},
+		MType:  APIGaugeType,
+		Name:   "test.big",
+		Tags:   []string{string(make([]byte, 3000))},
+	})
+
+	// A buffer context which is too small for any item
+	bufferContext := marshaler.DefaultBufferContext()
+	bufferContext.PrecompressionBuf = make([]byte, 1)
+	payloads, err := series.MarshalSplitCompress(bufferContext)
+	require.NoError(t, err)
+	assert.Greater(t, len(payloads), 1)
+
+	var names []string
+	for _, compressed := range payloads {
+		decompressed, err := decompressPayload(*compressed)
+		require.NoError(t, err)
+		assert.LessOrEqual(t, len(decompressed), 2000)
+
+		pl := &agentpayload.MetricsPayload{}
+		require.NoError(t, proto.Unmarshal(decompressed, pl))
+		for _, sample := range pl.Samples {
+			names = append(names, sample.Metric)
+			assert.Equal(t, "localHost", sample.Host)
+			assert.Equal(t, []string{"tag1", "tag2:yes"}, sample.Tags)
+		}
+	}
+	require.Len(t, names, 100)
+	for i, name := range names {
+		assert.Equal(t, fmt.Sprintf("test.metrics%d", i), name)
+	}
+}
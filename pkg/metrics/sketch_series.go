@@ -112,7 +112,7 @@ func (sl SketchSeriesList) MarshalSplitCompress(bufferContext *marshaler.BufferC
 	bufferContext.CompressorInput.Reset()
 	bufferContext.CompressorOutput.Reset()
 
-	compressor, e := stream.NewCompressor(bufferContext.CompressorInput, bufferContext.CompressorOutput, []byte{}, footer, []byte{})
+	compressor, e := stream.NewCompressorWithCompression(bufferContext.Compression, bufferContext.CompressorInput, bufferContext.CompressorOutput, []byte{}, footer, []byte{})
 	if e != nil {
 		return nil, e
 	}
@@ -180,7 +180,7 @@ func (sl SketchSeriesList) MarshalSplitCompress(bufferContext *marshaler.BufferC
 			payloads = append(payloads, &payload)
 			bufferContext.CompressorInput.Reset()
 			bufferContext.CompressorOutput.Reset()
-			compressor, e = stream.NewCompressor(bufferContext.CompressorInput, bufferContext.CompressorOutput, []byte{}, footer, []byte{})
+			compressor, e = stream.NewCompressorWithCompression(bufferContext.Compression, bufferContext.CompressorInput, bufferContext.CompressorOutput, []byte{}, footer, []byte{})
 			if e != nil {
 				return nil, e
 			}
@@ -0,0 +1,224 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+// Package export implements a serializer.MetricSerializer which exports the series and
+// sketches flushed by the aggregator to other monitoring systems, by exposing them on a
+// Prometheus scrape endpoint or pushing them to an OTLP/HTTP receiver. Depending on the
+// configuration, all payloads are still sent to Datadog as well.
+package export
+
+import (
+	"expvar"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/config"
+	"github.com/DataDog/datadog-agent/pkg/metrics"
+	"github.com/DataDog/datadog-agent/pkg/serializer"
+	"github.com/DataDog/datadog-agent/pkg/serializer/marshaler"
+	"github.com/DataDog/datadog-agent/pkg/telemetry"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+var (
+	expvars             = expvar.NewMap("metrics_export")
+	expvarsExportErrors = expvar.Int{}
+	tlmExportErrors     = telemetry.NewCounter("metrics_export", "errors",
+		[]string{"sink"}, "Number of errors while exporting metrics")
+)
+
+func init() {
+	expvars.Set("ExportErrors", &expvarsExportErrors)
+}
+
+// Sink receives the series and sketches flushed by the aggregator.
+type Sink interface {
+	// Name returns the name of the sink, used in logs and telemetry.
+	Name() string
+	// ExportSeries exports the given series.
+	ExportSeries(series metrics.Series) error
+	// ExportSketches exports the given sketches.
+	ExportSketches(sketches metrics.SketchSeriesList) error
+}
+
+// Serializer is a serializer.MetricSerializer handing the series and sketches to its
+// sinks, then passing all payloads to the next serializer, if any. Exporting errors are
+// logged and never prevent payloads from being sent to the next serializer.
+type Serializer struct {
+	next  serializer.MetricSerializer // nil when payloads aren't sent to Datadog
+	sinks []Sink
+}
+
+var _ serializer.MetricSerializer = (*Serializer)(nil)
+
+// NewSerializer returns a new Serializer exporting metrics to sinks. Payloads are then
+// passed to next, unless it is nil in which case they are discarded.
+func NewSerializer(next serializer.MetricSerializer, sinks ...Sink) *Serializer {
+	return &Serializer{next: next, sinks: sinks}
+}
+
+// FromConfig returns a Serializer exporting metrics to the sinks enabled in the
+// metrics_export configuration section, in front of next. It returns nil when
+// no sink is enabled. The returned Serializer has to be started.
+func FromConfig(next serializer.MetricSerializer) (*Serializer, error) {
+	var sinks []Sink
+	if config.Datadog.GetBool("metrics_export.prometheus.enabled") {
+		sinks = append(sinks, NewPrometheusSink(
+			config.Datadog.GetString("metrics_export.prometheus.listen_address"),
+			time.Duration(config.Datadog.GetInt("metrics_export.prometheus.expiry_seconds"))*time.Second,
+		))
+	}
+	if config.Datadog.GetBool("metrics_export.otlp.enabled") {
+		sink, err := NewOTLPSink(
+			config.Datadog.GetString("metrics_export.otlp.endpoint"),
+			config.Datadog.GetStringMapString("metrics_export.otlp.headers"),
+			time.Duration(config.Datadog.GetInt("metrics_export.otlp.timeout"))*time.Second,
+		)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, sink)
+	}
+	if len(sinks) == 0 {
+		return nil, nil
+	}
+	if !config.Datadog.GetBool("metrics_export.forward_to_datadog") {
+		log.Info("Metrics export is enabled and metrics_export.forward_to_datadog is false: no payload will be sent to Datadog")
+		next = nil
+	}
+	return NewSerializer(next, sinks...), nil
+}
+
+// Start starts the sinks which need to, such as the Prometheus endpoint.
+func (s *Serializer) Start() error {
+	for _, sink := range s.sinks {
+		if starter, ok := sink.(interface{ Start() error }); ok {
+			if err := starter.Start(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Stop stops the sinks which were started.
+func (s *Serializer) Stop() {
+	for _, sink := range s.sinks {
+		if stopper, ok := sink.(interface{ Stop() }); ok {
+			stopper.Stop()
+		}
+	}
+}
+
+func (s *Serializer) export(f func(Sink) error) {
+	for _, sink := range s.sinks {
+		if err := f(sink); err != nil {
+			log.Warnf("Error exporting metrics to %s: %v", sink.Name(), err)
+			expvarsExportErrors.Add(1)
+			tlmExportErrors.Inc(sink.Name())
+		}
+	}
+}
+
+// SendSeries exports series, then sends them to the next serializer.
+func (s *Serializer) SendSeries(series marshaler.StreamJSONMarshaler) error {
+	if ss, ok := series.(metrics.Series); ok {
+		s.export(func(sink Sink) error { return sink.ExportSeries(ss) })
+	}
+	if s.next == nil {
+		return nil
+	}
+	return s.next.SendSeries(series)
+}
+
+// SendSketch exports sketches, then sends them to the next serializer.
+func (s *Serializer) SendSketch(sketches marshaler.Marshaler) error {
+	if sl, ok := sketches.(metrics.SketchSeriesList); ok {
+		s.export(func(sink Sink) error { return sink.ExportSketches(sl) })
+	}
+	if s.next == nil {
+		return nil
+	}
+	return s.next.SendSketch(sketches)
+}
+
+// SendEvents sends events to the next serializer.
+func (s *Serializer) SendEvents(e serializer.EventsStreamJSONMarshaler) error {
+	if s.next == nil {
+		return nil
+	}
+	return s.next.SendEvents(e)
+}
+
+// SendServiceChecks sends service checks to the next serializer.
+func (s *Serializer) SendServiceChecks(sc marshaler.StreamJSONMarshaler) error {
+	if s.next == nil {
+		return nil
+	}
+	return s.next.SendServiceChecks(sc)
+}
+
+// SendMetadata sends a metadata payload to the next serializer.
+func (s *Serializer) SendMetadata(m marshaler.Marshaler) error {
+	if s.next == nil {
+		return nil
+	}
+	return s.next.SendMetadata(m)
+}
+
+// SendHostMetadata sends a host metadata payload to the next serializer.
+func (s *Serializer) SendHostMetadata(m marshaler.Marshaler) error {
+	if s.next == nil {
+		return nil
+	}
+	return s.next.SendHostMetadata(m)
+}
+
+// SendProcessesMetadata sends a legacy process metadata payload to the next serializer.
+func (s *Serializer) SendProcessesMetadata(data interface{}) error {
+	if s.next == nil {
+		return nil
+	}
+	return s.next.SendProcessesMetadata(data)
+}
+
+// SendOrchestratorMetadata sends orchestrator metadata payloads to the next serializer.
+func (s *Serializer) SendOrchestratorMetadata(msgs []serializer.ProcessMessageBody, hostName, clusterID, payloadType string) error {
+	if s.next == nil {
+		return nil
+	}
+	return s.next.SendOrchestratorMetadata(msgs, hostName, clusterID, payloadType)
+}
+
+// label is a key/value pair derived from the tags of a series.
+type label struct{ key, value string }
+
+// tagsToLabels converts the tags, host and device of a series to labels sorted by key.
+// Values of tags sharing the same key are joined by commas and tags without a value
+// are given the value "true". The given tags are not modified.
+func tagsToLabels(tags []string, host, device string) []label {
+	values := make(map[string][]string, len(tags)+2)
+	for _, t := range tags {
+		k, v := t, "true"
+		if i := strings.IndexByte(t, ':'); i > 0 {
+			k, v = t[:i], t[i+1:]
+		}
+		values[k] = append(values[k], v)
+	}
+	if _, ok := values["host"]; !ok && host != "" {
+		values["host"] = []string{host}
+	}
+	if _, ok := values["device"]; !ok && device != "" {
+		values["device"] = []string{device}
+	}
+	labels := make([]label, 0, len(values))
+	for k, vs := range values {
+		sort.Strings(vs)
+		labels = append(labels, label{key: k, value: strings.Join(vs, ",")})
+	}
+	sort.Slice(labels, func(i, j int) bool { return labels[i].key < labels[j].key })
+	return labels
+}
@@ -0,0 +1,165 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+// +build test
+
+package export
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/config"
+	"github.com/DataDog/datadog-agent/pkg/metrics"
+	"github.com/DataDog/datadog-agent/pkg/serializer"
+)
+
+type recordingSink struct {
+	series   []metrics.Series
+	sketches []metrics.SketchSeriesList
+	err      error
+}
+
+func (r *recordingSink) Name() string { return "recording" }
+
+func (r *recordingSink) ExportSeries(series metrics.Series) error {
+	r.series = append(r.series, series)
+	return r.err
+}
+
+func (r *recordingSink) ExportSketches(sketches metrics.SketchSeriesList) error {
+	r.sketches = append(r.sketches, sketches)
+	return r.err
+}
+
+func TestSerializer(t *testing.T) {
+	series := metrics.Series{{Name: "foo", Points: []metrics.Point{{Ts: 10, Value: 1}}}}
+	sketches := metrics.SketchSeriesList{{Name: "bar"}}
+
+	t.Run("forward", func(t *testing.T) {
+		next := &serializer.MockSerializer{}
+		next.On("SendSeries", series).Return(nil)
+		next.On("SendSketch", sketches).Return(nil)
+		next.On("SendServiceChecks", metrics.ServiceChecks(nil)).Return(nil)
+		sink := &recordingSink{}
+		s := NewSerializer(next, sink)
+
+		require.NoError(t, s.SendSeries(series))
+		require.NoError(t, s.SendSketch(sketches))
+		require.NoError(t, s.SendServiceChecks(metrics.ServiceChecks(nil)))
+		next.AssertExpectations(t)
+		assert.Equal(t, []metrics.Series{series}, sink.series)
+		assert.Equal(t, []metrics.SketchSeriesList{sketches}, sink.sketches)
+	})
+
+	t.Run("sink-error", func(t *testing.T) {
+		next := &serializer.MockSerializer{}
+		next.On("SendSeries", series).Return(nil)
+		sink := &recordingSink{err: errors.New("unreachable")}
+		before := expvarsExportErrors.Value()
+
+		require.NoError(t, NewSerializer(next, sink).SendSeries(series))
+		next.AssertExpectations(t)
+		assert.Equal(t, before+1, expvarsExportErrors.Value())
+	})
+
+	t.Run("next-error", func(t *testing.T) {
+		next := &serializer.MockSerializer{}
+		next.On("SendSeries", series).Return(errors.New("queue full"))
+		sink := &recordingSink{}
+
+		assert.EqualError(t, NewSerializer(next, sink).SendSeries(series), "queue full")
+		assert.Len(t, sink.series, 1)
+	})
+
+	t.Run("no-forward", func(t *testing.T) {
+		sink := &recordingSink{}
+		s := NewSerializer(nil, sink)
+
+		assert.NoError(t, s.SendSeries(series))
+		assert.NoError(t, s.SendSketch(sketches))
+		assert.NoError(t, s.SendEvents(metrics.Events(nil)))
+		assert.NoError(t, s.SendHostMetadata(nil))
+		assert.Len(t, sink.series, 1)
+		assert.Len(t, sink.sketches, 1)
+	})
+}
+
+func TestFromConfig(t *testing.T) {
+	mockConfig := config.Mock()
+	next := &serializer.MockSerializer{}
+
+	s, err := FromConfig(next)
+	require.NoError(t, err)
+	assert.Nil(t, s)
+
+	mockConfig.Set("metrics_export.otlp.enabled", true)
+	defer mockConfig.Set("metrics_export.otlp.enabled", false)
+	mockConfig.Set("metrics_export.otlp.endpoint", "localhost:4318")
+	_, err = FromConfig(next)
+	assert.Error(t, err)
+
+	mockConfig.Set("metrics_export.otlp.endpoint", "https://collector:4318")
+	s, err = FromConfig(next)
+	require.NoError(t, err)
+	require.Len(t, s.sinks, 1)
+	assert.Equal(t, "https://collector:4318/v1/metrics", s.sinks[0].(*OTLPSink).url)
+	assert.Equal(t, next, s.next)
+
+	mockConfig.Set("metrics_export.forward_to_datadog", false)
+	defer mockConfig.Set("metrics_export.forward_to_datadog", true)
+	s, err = FromConfig(next)
+	require.NoError(t, err)
+	assert.Nil(t, s.next)
+}
+
+func TestTagsToLabels(t *testing.T) {
+	for name, tt := range map[string]struct {
+		tags         []string
+		host, device string
+		want         []label
+	}{
+		"empty": {
+			want: []label{},
+		},
+		"sorted": {
+			tags: []string{"env:prod", "app:web"},
+			want: []label{{"app", "web"}, {"env", "prod"}},
+		},
+		"bare-tag": {
+			tags: []string{"canary"},
+			want: []label{{"canary", "true"}},
+		},
+		"value-with-colon": {
+			tags: []string{"url:http://example.com"},
+			want: []label{{"url", "http://example.com"}},
+		},
+		"duplicate-keys": {
+			tags: []string{"role:web", "role:api"},
+			want: []label{{"role", "api,web"}},
+		},
+		"host-and-device": {
+			tags:   []string{"env:prod"},
+			host:   "myhost",
+			device: "sda1",
+			want:   []label{{"device", "sda1"}, {"env", "prod"}, {"host", "myhost"}},
+		},
+		"host-tag-wins": {
+			tags: []string{"host:other"},
+			host: "myhost",
+			want: []label{{"host", "other"}},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var tags []string
+			tags = append(tags, tt.tags...)
+			assert.Equal(t, tt.want, tagsToLabels(tt.tags, tt.host, tt.device))
+			assert.Equal(t, tags, tt.tags, "tags must not be modified")
+		})
+	}
+}
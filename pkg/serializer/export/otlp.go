@@ -0,0 +1,298 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package export
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/DataDog/datadog-agent/pkg/metrics"
+	"github.com/DataDog/datadog-agent/pkg/quantile"
+	"github.com/DataDog/datadog-agent/pkg/version"
+)
+
+// OTLPPath is the path of the metrics endpoint of OTLP/HTTP receivers.
+const OTLPPath = "/v1/metrics"
+
+// OTLPSink is a Sink pushing the exported metrics to an OTLP/HTTP receiver, such as the
+// OpenTelemetry Collector, each time they are flushed. Datadog gauges and rates become
+// OTLP gauges, counts become non-monotonic delta sums and sketches become summaries
+// describing the distribution of the values received during the flush interval. Series
+// are grouped by host, which is set as the host.name resource attribute.
+type OTLPSink struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+}
+
+// NewOTLPSink returns a new OTLPSink pushing metrics to the OTLP/HTTP receiver at
+// endpoint, with the given extra HTTP headers.
+func NewOTLPSink(endpoint string, headers map[string]string, timeout time.Duration) (*OTLPSink, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("invalid OTLP endpoint %q: expected an http(s) URL such as http://localhost:4318", endpoint)
+	}
+	if !strings.HasSuffix(u.Path, OTLPPath) {
+		u.Path = strings.TrimSuffix(u.Path, "/") + OTLPPath
+	}
+	return &OTLPSink{
+		url:     u.String(),
+		headers: headers,
+		client:  &http.Client{Timeout: timeout},
+	}, nil
+}
+
+// Name implements Sink.
+func (o *OTLPSink) Name() string { return "otlp" }
+
+// ExportSeries implements Sink.
+func (o *OTLPSink) ExportSeries(series metrics.Series) error {
+	if len(series) == 0 {
+		return nil
+	}
+	byHost := make(map[string][]byte)
+	for _, serie := range series {
+		if len(serie.Points) == 0 {
+			continue
+		}
+		byHost[serie.Host] = appendSerie(byHost[serie.Host], serie)
+	}
+	return o.send(byHost)
+}
+
+// ExportSketches implements Sink.
+func (o *OTLPSink) ExportSketches(sketches metrics.SketchSeriesList) error {
+	if len(sketches) == 0 {
+		return nil
+	}
+	byHost := make(map[string][]byte)
+	for _, ss := range sketches {
+		if len(ss.Points) == 0 {
+			continue
+		}
+		byHost[ss.Host] = appendSketch(byHost[ss.Host], ss)
+	}
+	return o.send(byHost)
+}
+
+// send pushes the given encoded metrics, grouped by host, to the receiver.
+func (o *OTLPSink) send(byHost map[string][]byte) error {
+	if len(byHost) == 0 {
+		return nil
+	}
+	req, err := http.NewRequest("POST", o.url, bytes.NewReader(encodeRequest(byHost)))
+	if err != nil {
+		return err
+	}
+	for k, v := range o.headers {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s responded with %s: %s", o.url, resp.Status, bytes.TrimSpace(msg))
+	}
+	io.Copy(ioutil.Discard, resp.Body) //nolint:errcheck
+	return nil
+}
+
+// The functions below encode an ExportMetricsServiceRequest as defined in version 0.9 of
+// the OTLP protocol (opentelemetry/proto/collector/metrics/v1/metrics_service.proto). Only
+// the fields used by the exporter are encoded, and their numbers are listed here.
+const (
+	// ExportMetricsServiceRequest
+	fieldRequestResourceMetrics = 1
+	// ResourceMetrics
+	fieldResourceMetricsResource = 1
+	fieldResourceMetricsLibrary  = 2
+	// Resource
+	fieldResourceAttributes = 1
+	// InstrumentationLibraryMetrics
+	fieldLibraryMetricsLibrary = 1
+	fieldLibraryMetricsMetrics = 2
+	// InstrumentationLibrary
+	fieldLibraryName    = 1
+	fieldLibraryVersion = 2
+	// Metric
+	fieldMetricName    = 1
+	fieldMetricGauge   = 5
+	fieldMetricSum     = 7
+	fieldMetricSummary = 11
+	// Gauge, Sum and Summary
+	fieldDataPoints             = 1
+	fieldSumTemporality         = 2
+	fieldSumMonotonic           = 3
+	aggregationTemporalityDelta = 1
+	// NumberDataPoint and SummaryDataPoint
+	fieldPointStartTime  = 2
+	fieldPointTime       = 3
+	fieldPointAsDouble   = 4
+	fieldPointAttributes = 7
+	// SummaryDataPoint
+	fieldSummaryCount     = 4
+	fieldSummarySum       = 5
+	fieldSummaryQuantiles = 6
+	// ValueAtQuantile
+	fieldQuantileQuantile = 1
+	fieldQuantileValue    = 2
+	// KeyValue
+	fieldKeyValueKey   = 1
+	fieldKeyValueValue = 2
+	// AnyValue
+	fieldAnyValueString = 1
+)
+
+// encodeRequest returns an encoded ExportMetricsServiceRequest holding one ResourceMetrics
+// per host, given the encoded metrics of each host.
+func encodeRequest(byHost map[string][]byte) []byte {
+	hosts := make([]string, 0, len(byHost))
+	for h := range byHost {
+		hosts = append(hosts, h)
+	}
+	sort.Strings(hosts)
+
+	var library []byte
+	library = protowire.AppendTag(library, fieldLibraryName, protowire.BytesType)
+	library = protowire.AppendString(library, "datadog-agent")
+	library = protowire.AppendTag(library, fieldLibraryVersion, protowire.BytesType)
+	library = protowire.AppendString(library, version.AgentVersion)
+
+	var req []byte
+	for _, h := range hosts {
+		var resource []byte
+		if h != "" {
+			resource = appendKeyValue(resource, fieldResourceAttributes, "host.name", h)
+		}
+		var lm []byte
+		lm = appendMessage(lm, fieldLibraryMetricsLibrary, library)
+		lm = append(lm, byHost[h]...)
+
+		var rm []byte
+		rm = appendMessage(rm, fieldResourceMetricsResource, resource)
+		rm = appendMessage(rm, fieldResourceMetricsLibrary, lm)
+		req = appendMessage(req, fieldRequestResourceMetrics, rm)
+	}
+	return req
+}
+
+// appendSerie appends serie to b as a Metric field of InstrumentationLibraryMetrics.
+func appendSerie(b []byte, serie *metrics.Serie) []byte {
+	attrs := appendAttributes(nil, serie.Tags, serie.Device)
+	var points []byte
+	for _, pt := range serie.Points {
+		ts := uint64(pt.Ts * 1e9)
+		var p []byte
+		p = append(p, attrs...)
+		if serie.MType == metrics.APICountType && serie.Interval > 0 {
+			p = protowire.AppendTag(p, fieldPointStartTime, protowire.Fixed64Type)
+			p = protowire.AppendFixed64(p, ts-uint64(serie.Interval)*1e9)
+		}
+		p = protowire.AppendTag(p, fieldPointTime, protowire.Fixed64Type)
+		p = protowire.AppendFixed64(p, ts)
+		p = protowire.AppendTag(p, fieldPointAsDouble, protowire.Fixed64Type)
+		p = protowire.AppendFixed64(p, math.Float64bits(pt.Value))
+		points = appendMessage(points, fieldDataPoints, p)
+	}
+
+	var m []byte
+	m = protowire.AppendTag(m, fieldMetricName, protowire.BytesType)
+	m = protowire.AppendString(m, serie.Name)
+	if serie.MType == metrics.APICountType {
+		sum := points
+		sum = protowire.AppendTag(sum, fieldSumTemporality, protowire.VarintType)
+		sum = protowire.AppendVarint(sum, aggregationTemporalityDelta)
+		// Datadog counts can be negative
+		sum = protowire.AppendTag(sum, fieldSumMonotonic, protowire.VarintType)
+		sum = protowire.AppendVarint(sum, 0)
+		m = appendMessage(m, fieldMetricSum, sum)
+	} else {
+		m = appendMessage(m, fieldMetricGauge, points)
+	}
+	return appendMessage(b, fieldLibraryMetricsMetrics, m)
+}
+
+// appendSketch appends ss to b as a Metric field of InstrumentationLibraryMetrics.
+func appendSketch(b []byte, ss metrics.SketchSeries) []byte {
+	cfg := quantile.Default()
+	attrs := appendAttributes(nil, ss.Tags, "")
+	var points []byte
+	for _, pt := range ss.Points {
+		if pt.Sketch == nil {
+			continue
+		}
+		ts := uint64(pt.Ts) * 1e9
+		var p []byte
+		p = append(p, attrs...)
+		if ss.Interval > 0 {
+			p = protowire.AppendTag(p, fieldPointStartTime, protowire.Fixed64Type)
+			p = protowire.AppendFixed64(p, ts-uint64(ss.Interval)*1e9)
+		}
+		p = protowire.AppendTag(p, fieldPointTime, protowire.Fixed64Type)
+		p = protowire.AppendFixed64(p, ts)
+		p = protowire.AppendTag(p, fieldSummaryCount, protowire.Fixed64Type)
+		p = protowire.AppendFixed64(p, uint64(pt.Sketch.Basic.Cnt))
+		p = protowire.AppendTag(p, fieldSummarySum, protowire.Fixed64Type)
+		p = protowire.AppendFixed64(p, math.Float64bits(pt.Sketch.Basic.Sum))
+		for _, q := range summaryQuantiles {
+			var vq []byte
+			vq = protowire.AppendTag(vq, fieldQuantileQuantile, protowire.Fixed64Type)
+			vq = protowire.AppendFixed64(vq, math.Float64bits(q))
+			vq = protowire.AppendTag(vq, fieldQuantileValue, protowire.Fixed64Type)
+			vq = protowire.AppendFixed64(vq, math.Float64bits(pt.Sketch.Quantile(cfg, q)))
+			p = appendMessage(p, fieldSummaryQuantiles, vq)
+		}
+		points = appendMessage(points, fieldDataPoints, p)
+	}
+
+	var m []byte
+	m = protowire.AppendTag(m, fieldMetricName, protowire.BytesType)
+	m = protowire.AppendString(m, ss.Name)
+	m = appendMessage(m, fieldMetricSummary, points)
+	return appendMessage(b, fieldLibraryMetricsMetrics, m)
+}
+
+// appendAttributes appends the attributes derived from tags and device to b, as
+// fields of a data point.
+func appendAttributes(b []byte, tags []string, device string) []byte {
+	for _, l := range tagsToLabels(tags, "", device) {
+		b = appendKeyValue(b, fieldPointAttributes, l.key, l.value)
+	}
+	return b
+}
+
+// appendKeyValue appends a KeyValue holding a string value to b as the given field.
+func appendKeyValue(b []byte, field protowire.Number, key, value string) []byte {
+	var v []byte
+	v = protowire.AppendTag(v, fieldAnyValueString, protowire.BytesType)
+	v = protowire.AppendString(v, value)
+
+	var kv []byte
+	kv = protowire.AppendTag(kv, fieldKeyValueKey, protowire.BytesType)
+	kv = protowire.AppendString(kv, key)
+	kv = appendMessage(kv, fieldKeyValueValue, v)
+	return appendMessage(b, field, kv)
+}
+
+// appendMessage appends the encoded message msg to b as the given field.
+func appendMessage(b []byte, field protowire.Number, msg []byte) []byte {
+	b = protowire.AppendTag(b, field, protowire.BytesType)
+	return protowire.AppendBytes(b, msg)
+}
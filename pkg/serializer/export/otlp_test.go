@@ -0,0 +1,167 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package export
+
+import (
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/DataDog/datadog-agent/pkg/metrics"
+	"github.com/DataDog/datadog-agent/pkg/quantile"
+)
+
+// fields decodes a protobuf message into its fields, keeping the raw value of
+// length-delimited fields and the numeric value of the others.
+func fields(t *testing.T, b []byte) map[protowire.Number][]interface{} {
+	out := make(map[protowire.Number][]interface{})
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		require.True(t, n > 0, "invalid tag")
+		b = b[n:]
+		var v interface{}
+		switch typ {
+		case protowire.BytesType:
+			v, n = protowire.ConsumeBytes(b)
+		case protowire.Fixed64Type:
+			v, n = protowire.ConsumeFixed64(b)
+		case protowire.VarintType:
+			v, n = protowire.ConsumeVarint(b)
+		default:
+			t.Fatalf("unexpected wire type %d", typ)
+		}
+		require.True(t, n > 0, "invalid field %d", num)
+		b = b[n:]
+		out[num] = append(out[num], v)
+	}
+	return out
+}
+
+func message(t *testing.T, b []byte, path ...protowire.Number) map[protowire.Number][]interface{} {
+	f := fields(t, b)
+	for _, num := range path {
+		require.Len(t, f[num], 1, "field %d", num)
+		f = fields(t, f[num][0].([]byte))
+	}
+	return f
+}
+
+func TestOTLPEndpoint(t *testing.T) {
+	for endpoint, want := range map[string]string{
+		"http://localhost:4318":            "http://localhost:4318/v1/metrics",
+		"https://collector:4318/":          "https://collector:4318/v1/metrics",
+		"http://proxy/otlp":                "http://proxy/otlp/v1/metrics",
+		"http://localhost:4318/v1/metrics": "http://localhost:4318/v1/metrics",
+	} {
+		o, err := NewOTLPSink(endpoint, nil, time.Second)
+		require.NoError(t, err, endpoint)
+		assert.Equal(t, want, o.url)
+	}
+	for _, endpoint := range []string{"localhost:4318", "grpc://localhost:4317", "http://"} {
+		_, err := NewOTLPSink(endpoint, nil, time.Second)
+		assert.Error(t, err, endpoint)
+	}
+}
+
+func TestOTLPExport(t *testing.T) {
+	var (
+		body    []byte
+		headers http.Header
+		status  = http.StatusOK
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, OTLPPath, r.URL.Path)
+		headers = r.Header
+		body, _ = ioutil.ReadAll(r.Body)
+		w.WriteHeader(status)
+		w.Write([]byte("bad payload")) //nolint:errcheck
+	}))
+	defer srv.Close()
+	o, err := NewOTLPSink(srv.URL, map[string]string{"Api-Key": "secret"}, time.Second)
+	require.NoError(t, err)
+
+	t.Run("series", func(t *testing.T) {
+		assert := assert.New(t)
+		require.NoError(t, o.ExportSeries(metrics.Series{
+			{Name: "requests", Host: "myhost", Tags: []string{"env:prod"}, MType: metrics.APICountType, Interval: 10, Points: []metrics.Point{{Ts: 100, Value: 3}}},
+			{Name: "load", Host: "myhost", MType: metrics.APIGaugeType, Points: []metrics.Point{{Ts: 100, Value: 1.5}}},
+			{Name: "empty", Host: "other", MType: metrics.APIGaugeType},
+		}))
+		assert.Equal("application/x-protobuf", headers.Get("Content-Type"))
+		assert.Equal("secret", headers.Get("Api-Key"))
+
+		// a single resource, as "other" has no points
+		req := fields(t, body)
+		require.Len(t, req[fieldRequestResourceMetrics], 1)
+		rm := req[fieldRequestResourceMetrics][0].([]byte)
+
+		attr := message(t, rm, fieldResourceMetricsResource, fieldResourceAttributes)
+		assert.Equal([]byte("host.name"), attr[fieldKeyValueKey][0])
+		lib := message(t, rm, fieldResourceMetricsLibrary, fieldLibraryMetricsLibrary)
+		assert.Equal([]byte("datadog-agent"), lib[fieldLibraryName][0])
+
+		ms := message(t, rm, fieldResourceMetricsLibrary)[fieldLibraryMetricsMetrics]
+		require.Len(t, ms, 2)
+
+		count := fields(t, ms[0].([]byte))
+		assert.Equal([]byte("requests"), count[fieldMetricName][0])
+		sum := message(t, ms[0].([]byte), fieldMetricSum)
+		assert.Equal(uint64(aggregationTemporalityDelta), sum[fieldSumTemporality][0])
+		assert.Equal(uint64(0), sum[fieldSumMonotonic][0])
+		pt := message(t, ms[0].([]byte), fieldMetricSum, fieldDataPoints)
+		assert.Equal(uint64(90e9), pt[fieldPointStartTime][0])
+		assert.Equal(uint64(100e9), pt[fieldPointTime][0])
+		assert.Equal(math.Float64bits(3), pt[fieldPointAsDouble][0])
+		assert.Len(pt[fieldPointAttributes], 1)
+
+		gauge := fields(t, ms[1].([]byte))
+		assert.Equal([]byte("load"), gauge[fieldMetricName][0])
+		pt = message(t, ms[1].([]byte), fieldMetricGauge, fieldDataPoints)
+		assert.NotContains(pt, fieldPointStartTime)
+		assert.Equal(math.Float64bits(1.5), pt[fieldPointAsDouble][0])
+	})
+
+	t.Run("sketches", func(t *testing.T) {
+		assert := assert.New(t)
+		sketch := &quantile.Sketch{}
+		sketch.Insert(quantile.Default(), 1, 2, 3)
+		require.NoError(t, o.ExportSketches(metrics.SketchSeriesList{{
+			Name:     "latency",
+			Interval: 10,
+			Points:   []metrics.SketchPoint{{Ts: 100, Sketch: sketch}},
+		}}))
+
+		// no host: the resource has no attribute
+		req := fields(t, body)
+		require.Len(t, req[fieldRequestResourceMetrics], 1)
+		rm := req[fieldRequestResourceMetrics][0].([]byte)
+		assert.Empty(message(t, rm, fieldResourceMetricsResource))
+
+		m := message(t, rm, fieldResourceMetricsLibrary, fieldLibraryMetricsMetrics)
+		assert.Equal([]byte("latency"), m[fieldMetricName][0])
+		pt := message(t, rm, fieldResourceMetricsLibrary, fieldLibraryMetricsMetrics, fieldMetricSummary, fieldDataPoints)
+		assert.Equal(uint64(3), pt[fieldSummaryCount][0])
+		assert.Equal(math.Float64bits(6), pt[fieldSummarySum][0])
+		assert.Len(pt[fieldSummaryQuantiles], len(summaryQuantiles))
+	})
+
+	t.Run("error", func(t *testing.T) {
+		status = http.StatusBadRequest
+		defer func() { status = http.StatusOK }()
+		err := o.ExportSeries(metrics.Series{
+			{Name: "load", MType: metrics.APIGaugeType, Points: []metrics.Point{{Ts: 100, Value: 1}}},
+		})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "400 Bad Request: bad payload")
+	})
+}
@@ -0,0 +1,361 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package export
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/metrics"
+	"github.com/DataDog/datadog-agent/pkg/quantile"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// PrometheusPath is the path of the Prometheus scrape endpoint.
+const PrometheusPath = "/metrics"
+
+// summaryQuantiles are the quantiles exposed for each sketch.
+var summaryQuantiles = []float64{0.5, 0.75, 0.9, 0.95, 0.99}
+
+// Prometheus metric types, as found in the exposition format.
+const (
+	promGauge   = "gauge"
+	promCounter = "counter"
+	promSummary = "summary"
+)
+
+// promSample holds the current state of a single Prometheus time series.
+type promSample struct {
+	labels  string // rendered labels, including the braces
+	value   float64
+	updated time.Time
+
+	// sum, count and quantiles are only set on summaries
+	sum       float64
+	count     int64
+	quantiles []float64
+}
+
+// promFamily groups the samples sharing the same metric name.
+type promFamily struct {
+	typ     string
+	samples map[string]*promSample // by labels
+}
+
+// PrometheusSink is a Sink exposing the exported metrics on a Prometheus scrape endpoint.
+// Datadog gauges and rates become Prometheus gauges holding the latest value, counts become
+// counters accumulating the flushed values and sketches become summaries, whose quantiles
+// are computed on the last flushed sketch. Series which aren't flushed for the expiry
+// duration are removed.
+type PrometheusSink struct {
+	addr   string
+	expiry time.Duration
+
+	mu       sync.Mutex
+	families map[string]*promFamily // by name
+	server   *http.Server
+	now      func() time.Time // replaced in tests
+}
+
+// NewPrometheusSink returns a new PrometheusSink serving metrics on the given address once
+// started, and removing the series which weren't flushed for the expiry duration.
+func NewPrometheusSink(addr string, expiry time.Duration) *PrometheusSink {
+	return &PrometheusSink{
+		addr:     addr,
+		expiry:   expiry,
+		families: make(map[string]*promFamily),
+		now:      time.Now,
+	}
+}
+
+// Name implements Sink.
+func (p *PrometheusSink) Name() string { return "prometheus" }
+
+// Start starts serving the scrape endpoint.
+func (p *PrometheusSink) Start() error {
+	ln, err := net.Listen("tcp", p.addr)
+	if err != nil {
+		return fmt.Errorf("could not listen on %s for the Prometheus endpoint: %v", p.addr, err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle(PrometheusPath, p)
+	p.server = &http.Server{Handler: mux}
+	go func() {
+		if err := p.server.Serve(ln); err != nil && err != http.ErrServerClosed {
+			log.Errorf("Error serving the Prometheus endpoint: %v", err)
+		}
+	}()
+	log.Infof("Exposing metrics for Prometheus on http://%s%s", ln.Addr(), PrometheusPath)
+	return nil
+}
+
+// Stop stops serving the scrape endpoint.
+func (p *PrometheusSink) Stop() {
+	if p.server == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	p.server.Shutdown(ctx) //nolint:errcheck
+}
+
+// sample returns the sample with the given name, type and labels, creating it if needed.
+// It returns nil if a family with the same name but a different type exists.
+func (p *PrometheusSink) sample(name, typ, labels string) *promSample {
+	f, ok := p.families[name]
+	if !ok {
+		f = &promFamily{typ: typ, samples: make(map[string]*promSample)}
+		p.families[name] = f
+	}
+	if f.typ != typ {
+		return nil
+	}
+	s, ok := f.samples[labels]
+	if !ok {
+		s = &promSample{labels: labels}
+		f.samples[labels] = s
+	}
+	return s
+}
+
+// ExportSeries implements Sink.
+func (p *PrometheusSink) ExportSeries(series metrics.Series) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := p.now()
+	p.expire(now)
+	var conflicts int
+	for _, serie := range series {
+		if len(serie.Points) == 0 {
+			continue
+		}
+		name := sanitizeName(serie.Name)
+		labels := renderLabels(tagsToLabels(serie.Tags, serie.Host, serie.Device))
+		var s *promSample
+		switch serie.MType {
+		case metrics.APICountType:
+			if s = p.sample(name+"_total", promCounter, labels); s != nil {
+				for _, pt := range serie.Points {
+					s.value += pt.Value
+				}
+			}
+		default:
+			if s = p.sample(name, promGauge, labels); s != nil {
+				s.value = serie.Points[len(serie.Points)-1].Value
+			}
+		}
+		if s == nil {
+			conflicts++
+			continue
+		}
+		s.updated = now
+	}
+	if conflicts > 0 {
+		return fmt.Errorf("%d series were not exported because their name is used by a metric of another type", conflicts)
+	}
+	return nil
+}
+
+// ExportSketches implements Sink.
+func (p *PrometheusSink) ExportSketches(sketches metrics.SketchSeriesList) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := p.now()
+	p.expire(now)
+	cfg := quantile.Default()
+	var conflicts int
+	for _, ss := range sketches {
+		if len(ss.Points) == 0 {
+			continue
+		}
+		s := p.sample(sanitizeName(ss.Name), promSummary, renderLabels(tagsToLabels(ss.Tags, ss.Host, "")))
+		if s == nil {
+			conflicts++
+			continue
+		}
+		merged := &quantile.Sketch{}
+		for _, pt := range ss.Points {
+			if pt.Sketch == nil {
+				continue
+			}
+			merged.Merge(cfg, pt.Sketch)
+			s.sum += pt.Sketch.Basic.Sum
+			s.count += pt.Sketch.Basic.Cnt
+		}
+		s.quantiles = make([]float64, len(summaryQuantiles))
+		for i, q := range summaryQuantiles {
+			s.quantiles[i] = merged.Quantile(cfg, q)
+		}
+		s.updated = now
+	}
+	if conflicts > 0 {
+		return fmt.Errorf("%d sketches were not exported because their name is used by a metric of another type", conflicts)
+	}
+	return nil
+}
+
+// ServeHTTP implements http.Handler, writing all the current samples using the Prometheus
+// text exposition format.
+func (p *PrometheusSink) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	bw := bufio.NewWriter(w)
+	p.write(bw)
+	bw.Flush() //nolint:errcheck
+}
+
+// expire removes the samples which weren't updated for the expiry duration, and the
+// families left empty. It's called on each flush and scrape, so that the series stop
+// using memory even when the endpoint isn't scraped.
+func (p *PrometheusSink) expire(now time.Time) {
+	if p.expiry <= 0 {
+		return
+	}
+	expired := now.Add(-p.expiry)
+	for name, f := range p.families {
+		for labels, s := range f.samples {
+			if s.updated.Before(expired) {
+				delete(f.samples, labels)
+			}
+		}
+		if len(f.samples) == 0 {
+			delete(p.families, name)
+		}
+	}
+}
+
+// write removes the expired samples and writes the remaining ones to w, sorted by name
+// and labels.
+func (p *PrometheusSink) write(w io.Writer) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.expire(p.now())
+	names := make([]string, 0, len(p.families))
+	for name := range p.families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		f := p.families[name]
+		samples := make([]*promSample, 0, len(f.samples))
+		for _, s := range f.samples {
+			samples = append(samples, s)
+		}
+		sort.Slice(samples, func(i, j int) bool { return samples[i].labels < samples[j].labels })
+
+		fmt.Fprintf(w, "# TYPE %s %s\n", name, f.typ)
+		for _, s := range samples {
+			if f.typ != promSummary {
+				writeSample(w, name, s.labels, s.value)
+				continue
+			}
+			for i, q := range summaryQuantiles {
+				writeSample(w, name, withQuantile(s.labels, q), s.quantiles[i])
+			}
+			writeSample(w, name+"_sum", s.labels, s.sum)
+			writeSample(w, name+"_count", s.labels, float64(s.count))
+		}
+	}
+}
+
+func writeSample(w io.Writer, name, labels string, value float64) {
+	fmt.Fprintf(w, "%s%s %s\n", name, labels, formatFloat(value))
+}
+
+// formatFloat formats v as expected by Prometheus.
+func formatFloat(v float64) string {
+	switch {
+	case math.IsNaN(v):
+		return "NaN"
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// withQuantile adds the quantile label to the rendered labels.
+func withQuantile(labels string, q float64) string {
+	ql := `quantile="` + strconv.FormatFloat(q, 'g', -1, 64) + `"`
+	if labels == "" {
+		return "{" + ql + "}"
+	}
+	return labels[:len(labels)-1] + "," + ql + "}"
+}
+
+// renderLabels renders labels using the exposition format, sanitizing their names and
+// escaping their values. It returns an empty string when there are no labels.
+func renderLabels(labels []label) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	seen := make(map[string]struct{}, len(labels))
+	for _, l := range labels {
+		k := sanitizeLabelName(l.key)
+		if _, ok := seen[k]; ok {
+			// two keys which only differ by invalid characters
+			continue
+		}
+		seen[k] = struct{}{}
+		if b.Len() > 1 {
+			b.WriteByte(',')
+		}
+		b.WriteString(k)
+		b.WriteString(`="`)
+		b.WriteString(labelValueEscaper.Replace(l.value))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+// sanitizeName returns a valid Prometheus metric name for the given Datadog metric name,
+// replacing invalid characters such as dots by underscores.
+func sanitizeName(name string) string {
+	return sanitize(name, true)
+}
+
+// sanitizeLabelName returns a valid Prometheus label name for the given tag key. Names
+// starting with "__" are reserved by Prometheus and get prefixed with "tag".
+func sanitizeLabelName(key string) string {
+	key = sanitize(key, false)
+	if strings.HasPrefix(key, "__") {
+		key = "tag" + key
+	}
+	return key
+}
+
+func sanitize(s string, allowColon bool) string {
+	if s == "" {
+		return "_"
+	}
+	b := []byte(s)
+	for i, c := range b {
+		valid := c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') ||
+			(i > 0 && c >= '0' && c <= '9') || (allowColon && c == ':')
+		if !valid {
+			b[i] = '_'
+		}
+	}
+	if s[0] >= '0' && s[0] <= '9' {
+		return "_" + s[:1] + string(b[1:])
+	}
+	return string(b)
+}
@@ -0,0 +1,205 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package export
+
+import (
+	"io/ioutil"
+	"math"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/metrics"
+	"github.com/DataDog/datadog-agent/pkg/quantile"
+)
+
+func newTestPrometheusSink(now *time.Time) *PrometheusSink {
+	p := NewPrometheusSink("localhost:0", time.Minute)
+	p.now = func() time.Time { return *now }
+	return p
+}
+
+func scrape(t *testing.T, p *PrometheusSink) string {
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest("GET", PrometheusPath, nil))
+	assert.Equal(t, "text/plain; version=0.0.4; charset=utf-8", rec.Header().Get("Content-Type"))
+	body, err := ioutil.ReadAll(rec.Body)
+	require.NoError(t, err)
+	return string(body)
+}
+
+func TestPrometheusSeries(t *testing.T) {
+	now := time.Now()
+	p := newTestPrometheusSink(&now)
+
+	require.NoError(t, p.ExportSeries(metrics.Series{
+		{Name: "system.load.1", Host: "myhost", MType: metrics.APIGaugeType, Points: []metrics.Point{{Ts: 10, Value: 1}, {Ts: 20, Value: 2.5}}},
+		{Name: "requests", Tags: []string{"env:prod"}, MType: metrics.APICountType, Points: []metrics.Point{{Ts: 10, Value: 3}}},
+		{Name: "bytes.rate", MType: metrics.APIRateType, Points: []metrics.Point{{Ts: 10, Value: 0.5}}},
+		{Name: "empty", MType: metrics.APIGaugeType},
+	}))
+	require.NoError(t, p.ExportSeries(metrics.Series{
+		{Name: "requests", Tags: []string{"env:prod"}, MType: metrics.APICountType, Points: []metrics.Point{{Ts: 20, Value: 4}}},
+	}))
+
+	assert.Equal(t, `# TYPE bytes_rate gauge
+bytes_rate 0.5
+# TYPE requests_total counter
+requests_total{env="prod"} 7
+# TYPE system_load_1 gauge
+system_load_1{host="myhost"} 2.5
+`, scrape(t, p))
+}
+
+func TestPrometheusSketches(t *testing.T) {
+	now := time.Now()
+	p := newTestPrometheusSink(&now)
+	cfg := quantile.Default()
+	sketch := func(vals ...float64) *quantile.Sketch {
+		s := &quantile.Sketch{}
+		s.Insert(cfg, vals...)
+		return s
+	}
+
+	require.NoError(t, p.ExportSketches(metrics.SketchSeriesList{{
+		Name: "latency",
+		Tags: []string{"service:web"},
+		Points: []metrics.SketchPoint{
+			{Ts: 10, Sketch: sketch(1, 1, 1, 1, 1)},
+			{Ts: 20, Sketch: sketch(1, 1, 1, 1, 1)},
+		},
+	}}))
+	out := scrape(t, p)
+	assert.Contains(t, out, "# TYPE latency summary\n")
+	assert.Contains(t, out, `latency{service="web",quantile="0.99"} `)
+	assert.Contains(t, out, "latency_sum{service=\"web\"} 10\n")
+	assert.Contains(t, out, "latency_count{service=\"web\"} 10\n")
+	s := p.families["latency"].samples[`{service="web"}`]
+	for _, v := range s.quantiles {
+		assert.InEpsilon(t, 1, v, 0.02)
+	}
+
+	// sum and count are cumulative, quantiles only describe the last flush
+	require.NoError(t, p.ExportSketches(metrics.SketchSeriesList{{
+		Name:   "latency",
+		Tags:   []string{"service:web"},
+		Points: []metrics.SketchPoint{{Ts: 30, Sketch: sketch(100, 100)}},
+	}}))
+	assert.Equal(t, 210.0, s.sum)
+	assert.EqualValues(t, 12, s.count)
+	assert.InEpsilon(t, 100, s.quantiles[0], 0.02)
+}
+
+func TestPrometheusExpiry(t *testing.T) {
+	now := time.Now()
+	p := newTestPrometheusSink(&now)
+
+	require.NoError(t, p.ExportSeries(metrics.Series{
+		{Name: "old", MType: metrics.APIGaugeType, Points: []metrics.Point{{Ts: 10, Value: 1}}},
+	}))
+	now = now.Add(50 * time.Second)
+	require.NoError(t, p.ExportSeries(metrics.Series{
+		{Name: "new", MType: metrics.APIGaugeType, Points: []metrics.Point{{Ts: 60, Value: 1}}},
+	}))
+	now = now.Add(20 * time.Second)
+
+	assert.Equal(t, "# TYPE new gauge\nnew 1\n", scrape(t, p))
+	assert.NotContains(t, p.families, "old")
+}
+
+func TestPrometheusExpiryOnFlush(t *testing.T) {
+	now := time.Now()
+	p := newTestPrometheusSink(&now)
+
+	require.NoError(t, p.ExportSeries(metrics.Series{
+		{Name: "old", MType: metrics.APICountType, Points: []metrics.Point{{Ts: 10, Value: 1}}},
+	}))
+	require.NoError(t, p.ExportSketches(metrics.SketchSeriesList{{
+		Name:   "latency",
+		Points: []metrics.SketchPoint{{Ts: 10, Sketch: &quantile.Sketch{}}},
+	}}))
+	now = now.Add(70 * time.Second)
+
+	// the expired samples are removed without any scrape
+	require.NoError(t, p.ExportSeries(metrics.Series{
+		{Name: "new", MType: metrics.APIGaugeType, Points: []metrics.Point{{Ts: 80, Value: 1}}},
+	}))
+	assert.NotContains(t, p.families, "old_total")
+	assert.NotContains(t, p.families, "latency")
+	assert.Contains(t, p.families, "new")
+
+	// an expired name can be reused by a metric of another type
+	require.NoError(t, p.ExportSketches(metrics.SketchSeriesList{{
+		Name:   "old_total",
+		Points: []metrics.SketchPoint{{Ts: 80, Sketch: &quantile.Sketch{}}},
+	}}))
+	now = now.Add(70 * time.Second)
+	require.NoError(t, p.ExportSketches(nil))
+	assert.Empty(t, p.families)
+}
+
+func TestPrometheusTypeConflict(t *testing.T) {
+	now := time.Now()
+	p := newTestPrometheusSink(&now)
+
+	require.NoError(t, p.ExportSeries(metrics.Series{
+		{Name: "foo", MType: metrics.APIGaugeType, Points: []metrics.Point{{Ts: 10, Value: 1}}},
+	}))
+	err := p.ExportSketches(metrics.SketchSeriesList{{
+		Name:   "foo",
+		Points: []metrics.SketchPoint{{Ts: 10, Sketch: &quantile.Sketch{}}},
+	}})
+	assert.EqualError(t, err, "1 sketches were not exported because their name is used by a metric of another type")
+	assert.Equal(t, "# TYPE foo gauge\nfoo 1\n", scrape(t, p))
+}
+
+func TestPrometheusStartStop(t *testing.T) {
+	p := NewPrometheusSink("localhost:0", time.Minute)
+	require.NoError(t, p.Start())
+	p.Stop()
+
+	assert.Error(t, NewPrometheusSink("localhost:-1", time.Minute).Start())
+}
+
+func TestRenderLabels(t *testing.T) {
+	for _, tt := range []struct {
+		labels []label
+		want   string
+	}{
+		{nil, ""},
+		{[]label{{"env", "prod"}}, `{env="prod"}`},
+		{[]label{{"kube.namespace", "default"}}, `{kube_namespace="default"}`},
+		{[]label{{"__name__", "x"}}, `{tag__name__="x"}`},
+		{[]label{{"1st", "x"}}, `{_1st="x"}`},
+		{[]label{{"msg", "a \"quoted\"\nline\\"}}, `{msg="a \"quoted\"\nline\\"}`},
+		{[]label{{"a.b", "1"}, {"a_b", "2"}}, `{a_b="1"}`},
+	} {
+		assert.Equal(t, tt.want, renderLabels(tt.labels))
+	}
+}
+
+func TestSanitizeName(t *testing.T) {
+	for in, want := range map[string]string{
+		"system.cpu.user": "system_cpu_user",
+		"ns:metric":       "ns:metric",
+		"2xx.count":       "_2xx_count",
+		"with-dash":       "with_dash",
+		"":                "_",
+	} {
+		assert.Equal(t, want, sanitizeName(in), in)
+	}
+}
+
+func TestFormatFloat(t *testing.T) {
+	assert.Equal(t, "NaN", formatFloat(math.NaN()))
+	assert.Equal(t, "+Inf", formatFloat(math.Inf(1)))
+	assert.Equal(t, "-Inf", formatFloat(math.Inf(-1)))
+	assert.Equal(t, "1e+21", formatFloat(1e21))
+	assert.Equal(t, "0.25", formatFloat(0.25))
+}
@@ -9,6 +9,8 @@ import (
 	"bytes"
 
 	jsoniter "github.com/json-iterator/go"
+
+	"github.com/DataDog/datadog-agent/pkg/util/compression"
 )
 
 // Marshaler is an interface for metrics that are able to serialize themselves to JSON and protobuf
@@ -34,6 +36,7 @@ type BufferContext struct {
 	CompressorInput   *bytes.Buffer
 	CompressorOutput  *bytes.Buffer
 	PrecompressionBuf []byte
+	Compression       compression.Compressor
 }
 
 // DefaultBufferContext initialize the default compression buffers
@@ -42,5 +45,6 @@ func DefaultBufferContext() *BufferContext {
 		bytes.NewBuffer(make([]byte, 0, 1024)),
 		bytes.NewBuffer(make([]byte, 0, 1024)),
 		make([]byte, 1024),
+		compression.Default(),
 	}
 }
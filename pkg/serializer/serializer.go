@@ -78,6 +78,31 @@ func initExtraHeaders() {
 	}
 }
 
+// withContentEncoding returns extraHeaders, one of the global extraHeaders variables
+// with compression, with the Content-Encoding header describing the payloads compressed
+// by comp. extraHeaders is returned as is when comp is the compression selected at build time.
+func withContentEncoding(extraHeaders http.Header, comp compression.Compressor) http.Header {
+	contentEncoding := comp.ContentEncoding()
+	if extraHeaders.Get("Content-Encoding") == contentEncoding {
+		return extraHeaders
+	}
+
+	headers := extraHeaders.Clone()
+	if contentEncoding == "" {
+		headers.Del("Content-Encoding")
+	} else {
+		headers.Set("Content-Encoding", contentEncoding)
+	}
+	return headers
+}
+
+// bufferContext returns the buffers used to stream compress payloads with comp
+func bufferContext(comp compression.Compressor) *marshaler.BufferContext {
+	bufferContext := marshaler.DefaultBufferContext()
+	bufferContext.Compression = comp
+	return bufferContext
+}
+
 // EventsStreamJSONMarshaler handles two serialization logics.
 type EventsStreamJSONMarshaler interface {
 	marshaler.Marshaler
@@ -123,6 +148,7 @@ type Serializer struct {
 	enableServiceChecksJSONStream bool
 	enableEventsJSONStream        bool
 	enableSketchProtobufStream    bool
+	enableSeriesProtobufStream    bool
 }
 
 // NewSerializer returns a new Serializer initialized
@@ -140,6 +166,7 @@ func NewSerializer(forwarder forwarder.Forwarder, orchestratorForwarder forwarde
 		enableServiceChecksJSONStream: stream.Available && config.Datadog.GetBool("enable_service_checks_stream_payload_serialization"),
 		enableEventsJSONStream:        stream.Available && config.Datadog.GetBool("enable_events_stream_payload_serialization"),
 		enableSketchProtobufStream:    stream.Available && config.Datadog.GetBool("enable_sketch_stream_payload_serialization"),
+		enableSeriesProtobufStream:    stream.Available && config.Datadog.GetBool("enable_series_protobuf_stream_payload_serialization"),
 	}
 
 	if !s.enableEvents {
@@ -161,27 +188,27 @@ func NewSerializer(forwarder forwarder.Forwarder, orchestratorForwarder forwarde
 	return s
 }
 
-func (s Serializer) serializePayload(payload marshaler.Marshaler, compress bool, useV1API bool) (forwarder.Payloads, http.Header, error) {
+func (s Serializer) serializePayload(payload marshaler.Marshaler, compress bool, useV1API bool, comp compression.Compressor) (forwarder.Payloads, http.Header, error) {
 	var marshalType split.MarshalType
 	var extraHeaders http.Header
 
 	if useV1API {
 		marshalType = split.MarshalJSON
 		if compress {
-			extraHeaders = jsonExtraHeadersWithCompression
+			extraHeaders = withContentEncoding(jsonExtraHeadersWithCompression, comp)
 		} else {
 			extraHeaders = jsonExtraHeaders
 		}
 	} else {
 		marshalType = split.Marshal
 		if compress {
-			extraHeaders = protobufExtraHeadersWithCompression
+			extraHeaders = withContentEncoding(protobufExtraHeadersWithCompression, comp)
 		} else {
 			extraHeaders = protobufExtraHeaders
 		}
 	}
 
-	payloads, err := split.Payloads(payload, compress, marshalType)
+	payloads, err := split.PayloadsWithCompression(payload, compress, marshalType, comp)
 
 	if err != nil {
 		return nil, nil, fmt.Errorf("could not split payload into small enough chunks: %s", err)
@@ -190,9 +217,9 @@ func (s Serializer) serializePayload(payload marshaler.Marshaler, compress bool,
 	return payloads, extraHeaders, nil
 }
 
-func (s Serializer) serializeStreamablePayload(payload marshaler.StreamJSONMarshaler, policy stream.OnErrItemTooBigPolicy) (forwarder.Payloads, http.Header, error) {
-	payloads, err := s.seriesJSONPayloadBuilder.BuildWithOnErrItemTooBigPolicy(payload, policy)
-	return payloads, jsonExtraHeadersWithCompression, err
+func (s Serializer) serializeStreamablePayload(payload marshaler.StreamJSONMarshaler, policy stream.OnErrItemTooBigPolicy, comp compression.Compressor) (forwarder.Payloads, http.Header, error) {
+	payloads, err := s.seriesJSONPayloadBuilder.BuildWithCompression(payload, policy, comp)
+	return payloads, withContentEncoding(jsonExtraHeadersWithCompression, comp), err
 }
 
 // As events are gathered by SourceType, the serialization logic is more complex than for the other serializations.
@@ -205,9 +232,9 @@ func (s Serializer) serializeStreamablePayload(payload marshaler.StreamJSONMarsh
 //
 // If none of the previous methods work, we fallback to the old serialization method (Serializer.serializePayload).
 func (s Serializer) serializeEventsStreamJSONMarshalerPayload(
-	eventsStreamJSONMarshaler EventsStreamJSONMarshaler, useV1API bool) (forwarder.Payloads, http.Header, error) {
+	eventsStreamJSONMarshaler EventsStreamJSONMarshaler, useV1API bool, comp compression.Compressor) (forwarder.Payloads, http.Header, error) {
 	marshaler := eventsStreamJSONMarshaler.CreateSingleMarshaler()
-	eventPayloads, extraHeaders, err := s.serializeStreamablePayload(marshaler, stream.FailOnErrItemTooBig)
+	eventPayloads, extraHeaders, err := s.serializeStreamablePayload(marshaler, stream.FailOnErrItemTooBig, comp)
 
 	if err == stream.ErrItemTooBig {
 		expvarsSendEventsErrItemTooBigs.Add(1)
@@ -215,12 +242,12 @@ func (s Serializer) serializeEventsStreamJSONMarshalerPayload(
 		// Do not use CreateMarshalersBySourceType when there are too many source types (Performance issue).
 		if marshaler.Len() > maxItemCountForCreateMarshalersBySourceType {
 			expvarsSendEventsErrItemTooBigsFallback.Add(1)
-			eventPayloads, extraHeaders, err = s.serializePayload(eventsStreamJSONMarshaler, true, useV1API)
+			eventPayloads, extraHeaders, err = s.serializePayload(eventsStreamJSONMarshaler, true, useV1API, comp)
 		} else {
 			eventPayloads = nil
 			for _, v := range eventsStreamJSONMarshaler.CreateMarshalersBySourceType() {
 				var eventPayloadsForSourceType forwarder.Payloads
-				eventPayloadsForSourceType, extraHeaders, err = s.serializeStreamablePayload(v, stream.DropItemOnErrItemTooBig)
+				eventPayloadsForSourceType, extraHeaders, err = s.serializeStreamablePayload(v, stream.DropItemOnErrItemTooBig, comp)
 				if err != nil {
 					return nil, nil, err
 				}
@@ -243,10 +270,15 @@ func (s *Serializer) SendEvents(e EventsStreamJSONMarshaler) error {
 	var extraHeaders http.Header
 	var err error
 
+	comp := forwarder.CompressorFor(s.Forwarder, forwarder.EventsEndpointName)
+	if useV1API {
+		comp = forwarder.CompressorFor(s.Forwarder, forwarder.V1IntakeEndpointName)
+	}
+
 	if useV1API && s.enableEventsJSONStream {
-		eventPayloads, extraHeaders, err = s.serializeEventsStreamJSONMarshalerPayload(e, useV1API)
+		eventPayloads, extraHeaders, err = s.serializeEventsStreamJSONMarshalerPayload(e, useV1API, comp)
 	} else {
-		eventPayloads, extraHeaders, err = s.serializePayload(e, true, useV1API)
+		eventPayloads, extraHeaders, err = s.serializePayload(e, true, useV1API, comp)
 	}
 	if err != nil {
 		return fmt.Errorf("dropping event payload: %s", err)
@@ -271,10 +303,15 @@ func (s *Serializer) SendServiceChecks(sc marshaler.StreamJSONMarshaler) error {
 	var extraHeaders http.Header
 	var err error
 
+	comp := forwarder.CompressorFor(s.Forwarder, forwarder.ServiceChecksEndpointName)
+	if useV1API {
+		comp = forwarder.CompressorFor(s.Forwarder, forwarder.V1CheckRunsEndpointName)
+	}
+
 	if useV1API && s.enableServiceChecksJSONStream {
-		serviceCheckPayloads, extraHeaders, err = s.serializeStreamablePayload(sc, stream.DropItemOnErrItemTooBig)
+		serviceCheckPayloads, extraHeaders, err = s.serializeStreamablePayload(sc, stream.DropItemOnErrItemTooBig, comp)
 	} else {
-		serviceCheckPayloads, extraHeaders, err = s.serializePayload(sc, true, useV1API)
+		serviceCheckPayloads, extraHeaders, err = s.serializePayload(sc, true, useV1API, comp)
 	}
 	if err != nil {
 		return fmt.Errorf("dropping service check payload: %s", err)
@@ -299,10 +336,22 @@ func (s *Serializer) SendSeries(series marshaler.StreamJSONMarshaler) error {
 	var extraHeaders http.Header
 	var err error
 
+	comp := forwarder.CompressorFor(s.Forwarder, forwarder.SeriesEndpointName)
+	if useV1API {
+		comp = forwarder.CompressorFor(s.Forwarder, forwarder.V1SeriesEndpointName)
+	}
+
 	if useV1API && s.enableJSONStream {
-		seriesPayloads, extraHeaders, err = s.serializeStreamablePayload(series, stream.DropItemOnErrItemTooBig)
+		seriesPayloads, extraHeaders, err = s.serializeStreamablePayload(series, stream.DropItemOnErrItemTooBig, comp)
+	} else if !useV1API && s.enableSeriesProtobufStream {
+		seriesPayloads, err = series.MarshalSplitCompress(bufferContext(comp))
+		extraHeaders = withContentEncoding(protobufExtraHeadersWithCompression, comp)
+		if err != nil {
+			log.Warnf("Error: %v trying to stream compress Series - falling back to split/compress method", err)
+			seriesPayloads, extraHeaders, err = s.serializePayload(series, true, useV1API, comp)
+		}
 	} else {
-		seriesPayloads, extraHeaders, err = s.serializePayload(series, true, useV1API)
+		seriesPayloads, extraHeaders, err = s.serializePayload(series, true, useV1API, comp)
 	}
 
 	if err != nil {
@@ -322,17 +371,18 @@ func (s *Serializer) SendSketch(sketches marshaler.Marshaler) error {
 		return nil
 	}
 
+	comp := forwarder.CompressorFor(s.Forwarder, forwarder.SketchSeriesEndpointName)
 	if s.enableSketchProtobufStream {
-		payloads, err := sketches.MarshalSplitCompress(marshaler.DefaultBufferContext())
+		payloads, err := sketches.MarshalSplitCompress(bufferContext(comp))
 		if err == nil {
-			return s.Forwarder.SubmitSketchSeries(payloads, protobufExtraHeadersWithCompression)
+			return s.Forwarder.SubmitSketchSeries(payloads, withContentEncoding(protobufExtraHeadersWithCompression, comp))
 		}
 		log.Warnf("Error: %v trying to stream compress SketchSeriesList - falling back to split/compress method", err)
 	}
 
 	compress := true
 	useV1API := false // Sketches only have a v2 endpoint
-	splitSketches, extraHeaders, err := s.serializePayload(sketches, compress, useV1API)
+	splitSketches, extraHeaders, err := s.serializePayload(sketches, compress, useV1API, comp)
 	if err != nil {
 		return fmt.Errorf("dropping sketch payload: %s", err)
 	}
@@ -356,7 +406,9 @@ func (s *Serializer) SendAgentchecksMetadata(m marshaler.Marshaler) error {
 }
 
 func (s *Serializer) sendMetadata(m marshaler.Marshaler, submit func(payload forwarder.Payloads, extra http.Header) error) error {
-	mustSplit, compressedPayload, payload, err := split.CheckSizeAndSerialize(m, true, split.MarshalJSON)
+	// Metadata payloads are all sent to the v1 intake
+	comp := forwarder.CompressorFor(s.Forwarder, forwarder.V1IntakeEndpointName)
+	mustSplit, compressedPayload, payload, err := split.CheckSizeAndSerializeWithCompression(m, true, split.MarshalJSON, comp)
 	if err != nil {
 		return fmt.Errorf("could not determine size of metadata payload: %s", err)
 	}
@@ -367,7 +419,7 @@ func (s *Serializer) sendMetadata(m marshaler.Marshaler, submit func(payload for
 		return fmt.Errorf("metadata payload was too big to send (%d bytes compressed, %d bytes uncompressed), metadata payloads cannot be split", len(compressedPayload), len(payload))
 	}
 
-	if err := submit(forwarder.Payloads{&compressedPayload}, jsonExtraHeadersWithCompression); err != nil {
+	if err := submit(forwarder.Payloads{&compressedPayload}, withContentEncoding(jsonExtraHeadersWithCompression, comp)); err != nil {
 		return err
 	}
 
@@ -387,11 +439,12 @@ func (s *Serializer) SendProcessesMetadata(data interface{}) error {
 	if err != nil {
 		return fmt.Errorf("could not serialize processes metadata payload: %s", err)
 	}
-	compressedPayload, err := compression.Compress(nil, payload)
+	comp := forwarder.CompressorFor(s.Forwarder, forwarder.V1IntakeEndpointName)
+	compressedPayload, err := comp.Compress(payload)
 	if err != nil {
 		return fmt.Errorf("could not compress processes metadata payload: %s", err)
 	}
-	if err := s.Forwarder.SubmitV1Intake(forwarder.Payloads{&compressedPayload}, jsonExtraHeadersWithCompression); err != nil {
+	if err := s.Forwarder.SubmitV1Intake(forwarder.Payloads{&compressedPayload}, withContentEncoding(jsonExtraHeadersWithCompression, comp)); err != nil {
 		return err
 	}
 
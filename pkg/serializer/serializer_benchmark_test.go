@@ -14,6 +14,7 @@ import (
 
 	"github.com/DataDog/datadog-agent/pkg/forwarder"
 	"github.com/DataDog/datadog-agent/pkg/metrics"
+	"github.com/DataDog/datadog-agent/pkg/serializer/marshaler"
 	"github.com/DataDog/datadog-agent/pkg/serializer/split"
 	"github.com/DataDog/datadog-agent/pkg/serializer/stream"
 )
@@ -59,6 +60,28 @@ func benchmarkSplit(b *testing.B, numberOfSeries int) {
 	}
 }
 
+// benchmarkProtobufSplit measures the split method used for the v2 series endpoint
+// when enable_series_protobuf_stream_payload_serialization is false
+func benchmarkProtobufSplit(b *testing.B, numberOfSeries int) {
+	series := buildSeries(numberOfSeries)
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for n := 0; n < b.N; n++ {
+		results, _ = split.Payloads(series, true, split.Marshal)
+	}
+}
+
+func benchmarkProtobufStream(b *testing.B, numberOfSeries int) {
+	series := buildSeries(numberOfSeries)
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for n := 0; n < b.N; n++ {
+		results, _ = series.MarshalSplitCompress(marshaler.DefaultBufferContext())
+	}
+}
+
 func BenchmarkJSONStream1(b *testing.B)        { benchmarkJSONStream(b, 1, false, 1) }
 func BenchmarkJSONStream10(b *testing.B)       { benchmarkJSONStream(b, 1, false, 10) }
 func BenchmarkJSONStream100(b *testing.B)      { benchmarkJSONStream(b, 1, false, 100) }
@@ -121,3 +144,19 @@ func BenchmarkSplit10000(b *testing.B)    { benchmarkSplit(b, 10000) }
 func BenchmarkSplit100000(b *testing.B)   { benchmarkSplit(b, 100000) }
 func BenchmarkSplit1000000(b *testing.B)  { benchmarkSplit(b, 1000000) }
 func BenchmarkSplit10000000(b *testing.B) { benchmarkSplit(b, 10000000) }
+
+func BenchmarkProtobufSplit1(b *testing.B)       { benchmarkProtobufSplit(b, 1) }
+func BenchmarkProtobufSplit10(b *testing.B)      { benchmarkProtobufSplit(b, 10) }
+func BenchmarkProtobufSplit100(b *testing.B)     { benchmarkProtobufSplit(b, 100) }
+func BenchmarkProtobufSplit1000(b *testing.B)    { benchmarkProtobufSplit(b, 1000) }
+func BenchmarkProtobufSplit10000(b *testing.B)   { benchmarkProtobufSplit(b, 10000) }
+func BenchmarkProtobufSplit100000(b *testing.B)  { benchmarkProtobufSplit(b, 100000) }
+func BenchmarkProtobufSplit1000000(b *testing.B) { benchmarkProtobufSplit(b, 1000000) }
+
+func BenchmarkProtobufStream1(b *testing.B)       { benchmarkProtobufStream(b, 1) }
+func BenchmarkProtobufStream10(b *testing.B)      { benchmarkProtobufStream(b, 10) }
+func BenchmarkProtobufStream100(b *testing.B)     { benchmarkProtobufStream(b, 100) }
+func BenchmarkProtobufStream1000(b *testing.B)    { benchmarkProtobufStream(b, 1000) }
+func BenchmarkProtobufStream10000(b *testing.B)   { benchmarkProtobufStream(b, 10000) }
+func BenchmarkProtobufStream100000(b *testing.B)  { benchmarkProtobufStream(b, 100000) }
+func BenchmarkProtobufStream1000000(b *testing.B) { benchmarkProtobufStream(b, 1000000) }
@@ -84,6 +84,30 @@ func TestInitExtraHeadersWithCompression(t *testing.T) {
 	assert.Equal(t, expected, protobufExtraHeadersWithCompression)
 }
 
+func TestWithContentEncoding(t *testing.T) {
+	compression.ContentEncoding = "deflate"
+	defer resetContentEncoding()
+	initExtraHeaders()
+
+	gzip, err := compression.NewCompressor(compression.GzipKind, 0)
+	require.NoError(t, err)
+	none, err := compression.NewCompressor(compression.NoneKind, 0)
+	require.NoError(t, err)
+	zlib, err := compression.NewCompressor(compression.ZlibKind, 9)
+	require.NoError(t, err)
+
+	headers := withContentEncoding(jsonExtraHeadersWithCompression, gzip)
+	assert.Equal(t, "gzip", headers.Get("Content-Encoding"))
+	assert.Equal(t, jsonContentType, headers.Get("Content-Type"))
+	assert.Equal(t, "deflate", jsonExtraHeadersWithCompression.Get("Content-Encoding"), "global headers must not be modified")
+
+	headers = withContentEncoding(protobufExtraHeadersWithCompression, none)
+	assert.Equal(t, protobufExtraHeaders, headers)
+
+	headers = withContentEncoding(jsonExtraHeadersWithCompression, zlib)
+	assert.Equal(t, jsonExtraHeadersWithCompression, headers)
+}
+
 func TestAgentPayloadVersion(t *testing.T) {
 	assert.NotEmpty(t, AgentPayloadVersion, "AgentPayloadVersion is empty, indicates that the package was not built correctly")
 }
@@ -109,7 +133,7 @@ func (p *testPayload) MarshalJSON() ([]byte, error) { return jsonString, nil }
 func (p *testPayload) Marshal() ([]byte, error)     { return protobufString, nil }
 func (p *testPayload) MarshalSplitCompress(bufferContext *marshaler.BufferContext) ([]*[]byte, error) {
 	payloads := forwarder.Payloads{}
-	payload, err := compression.Compress(nil, protobufString)
+	payload, err := bufferContext.Compression.Compress(protobufString)
 	if err != nil {
 		return nil, err
 	}
@@ -454,3 +478,45 @@ func TestSendWithDisabledKind(t *testing.T) {
 	s.SendMetadata(payload)
 	f.AssertNumberOfCalls(t, "SubmitMetadata", 1) // called once for the metadata
 }
+
+// compressingForwarder is a MockedForwarder using the same compression for all the endpoints
+type compressingForwarder struct {
+	*forwarder.MockedForwarder
+	compressor compression.Compressor
+	endpoints  []string
+}
+
+func (f *compressingForwarder) Compressor(endpointName string) compression.Compressor {
+	f.endpoints = append(f.endpoints, endpointName)
+	return f.compressor
+}
+
+func TestSendWithEndpointCompression(t *testing.T) {
+	mockConfig := config.Mock()
+	mockConfig.Set("use_v2_api.series", true)
+	defer mockConfig.Set("use_v2_api.series", nil)
+
+	gzip, err := compression.NewCompressor(compression.GzipKind, 9)
+	require.NoError(t, err)
+	f := &compressingForwarder{MockedForwarder: &forwarder.MockedForwarder{}, compressor: gzip}
+	s := NewSerializer(f, nil)
+
+	gzipProtobuf, err := gzip.Compress(protobufString)
+	require.NoError(t, err)
+	gzipJSON, err := gzip.Compress(jsonString)
+	require.NoError(t, err)
+	gzipProtobufHeaders := withContentEncoding(protobufExtraHeadersWithCompression, gzip)
+	gzipJSONHeaders := withContentEncoding(jsonExtraHeadersWithCompression, gzip)
+	require.Equal(t, "gzip", gzipProtobufHeaders.Get("Content-Encoding"))
+
+	f.On("SubmitSeries", forwarder.Payloads{&gzipProtobuf}, gzipProtobufHeaders).Return(nil).Times(1)
+	f.On("SubmitSketchSeries", forwarder.Payloads{&gzipProtobuf}, gzipProtobufHeaders).Return(nil).Times(1)
+	f.On("SubmitMetadata", forwarder.Payloads{&gzipJSON}, gzipJSONHeaders).Return(nil).Times(1)
+
+	payload := &testPayload{}
+	require.NoError(t, s.SendSeries(payload))
+	require.NoError(t, s.SendSketch(payload))
+	require.NoError(t, s.SendMetadata(payload))
+	f.AssertExpectations(t)
+	assert.Equal(t, []string{forwarder.SeriesEndpointName, forwarder.SketchSeriesEndpointName, forwarder.V1IntakeEndpointName}, f.endpoints)
+}
@@ -58,7 +58,12 @@ func init() {
 // CheckSizeAndSerialize Check the size of a payload and marshall it (optionally compress it)
 // The dual role makes sense as you will never serialize without checking the size of the payload
 func CheckSizeAndSerialize(m marshaler.Marshaler, compress bool, mType MarshalType) (bool, []byte, []byte, error) {
-	compressedPayload, payload, err := serializeMarshaller(m, compress, mType)
+	return CheckSizeAndSerializeWithCompression(m, compress, mType, compression.Default())
+}
+
+// CheckSizeAndSerializeWithCompression is CheckSizeAndSerialize using the given compression
+func CheckSizeAndSerializeWithCompression(m marshaler.Marshaler, compress bool, mType MarshalType, comp compression.Compressor) (bool, []byte, []byte, error) {
+	compressedPayload, payload, err := serializeMarshaller(m, compress, mType, comp)
 	if err != nil {
 		return false, nil, nil, err
 	}
@@ -70,9 +75,14 @@ func CheckSizeAndSerialize(m marshaler.Marshaler, compress bool, mType MarshalTy
 
 // Payloads serializes a metadata payload and sends it to the forwarder
 func Payloads(m marshaler.Marshaler, compress bool, mType MarshalType) (forwarder.Payloads, error) {
+	return PayloadsWithCompression(m, compress, mType, compression.Default())
+}
+
+// PayloadsWithCompression is Payloads using the given compression
+func PayloadsWithCompression(m marshaler.Marshaler, compress bool, mType MarshalType, comp compression.Compressor) (forwarder.Payloads, error) {
 	marshallers := []marshaler.Marshaler{m}
 	smallEnoughPayloads := forwarder.Payloads{}
-	tooBig, compressedPayload, _, err := CheckSizeAndSerialize(m, compress, mType)
+	tooBig, compressedPayload, _, err := CheckSizeAndSerializeWithCompression(m, compress, mType, comp)
 	if err != nil {
 		return smallEnoughPayloads, err
 	}
@@ -99,7 +109,7 @@ func Payloads(m marshaler.Marshaler, compress bool, mType MarshalType) (forwarde
 		for _, toSplit := range tempSlice {
 			var e error
 			// we have to do this every time to get the proper payload
-			compressedPayload, payload, e := serializeMarshaller(toSplit, compress, mType)
+			compressedPayload, payload, e := serializeMarshaller(toSplit, compress, mType, comp)
 			if e != nil {
 				return smallEnoughPayloads, e
 			}
@@ -121,7 +131,7 @@ func Payloads(m marshaler.Marshaler, compress bool, mType MarshalType) (forwarde
 			// after the payload has been split, loop through the chunks
 			for _, chunk := range chunks {
 				// serialize the payload
-				tooBigChunk, compressedPayload, _, err := CheckSizeAndSerialize(chunk, compress, mType)
+				tooBigChunk, compressedPayload, _, err := CheckSizeAndSerializeWithCompression(chunk, compress, mType, comp)
 				if err != nil {
 					log.Debugf("Error serializing a chunk: %s", err)
 					continue
@@ -155,7 +165,7 @@ func Payloads(m marshaler.Marshaler, compress bool, mType MarshalType) (forwarde
 }
 
 // serializeMarshaller serializes the marshaller and returns both the compressed and uncompressed payloads
-func serializeMarshaller(m marshaler.Marshaler, compress bool, mType MarshalType) ([]byte, []byte, error) {
+func serializeMarshaller(m marshaler.Marshaler, compress bool, mType MarshalType, comp compression.Compressor) ([]byte, []byte, error) {
 	var payload []byte
 	var compressedPayload []byte
 	var err error
@@ -165,7 +175,7 @@ func serializeMarshaller(m marshaler.Marshaler, compress bool, mType MarshalType
 		return nil, nil, err
 	}
 	if compress {
-		compressedPayload, err = compression.Compress(nil, payload)
+		compressedPayload, err = comp.Compress(payload)
 		if err != nil {
 			return nil, nil, err
 		}
@@ -20,6 +20,7 @@ import (
 
 	"github.com/DataDog/datadog-agent/pkg/config"
 	"github.com/DataDog/datadog-agent/pkg/serializer/marshaler"
+	"github.com/DataDog/datadog-agent/pkg/util/compression"
 )
 
 var (
@@ -183,3 +184,27 @@ func TestLockedCompressorProducesSamePayloads(t *testing.T) {
 
 	require.Equal(t, payloadToString(*payloads1[0]), payloadToString(*payloads2[0]))
 }
+
+func TestBuildWithCompression(t *testing.T) {
+	m := &dummyMarshaller{
+		items:  []string{"A", "B", "C", "D", "E", "F"},
+		header: "{[",
+		footer: "]}",
+	}
+
+	for _, spec := range []string{"none", "gzip:9", "zlib:1"} {
+		t.Run(spec, func(t *testing.T) {
+			comp, err := compression.Parse(spec)
+			require.NoError(t, err)
+
+			builder := NewJSONPayloadBuilder(false)
+			payloads, err := builder.BuildWithCompression(m, DropItemOnErrItemTooBig, comp)
+			require.NoError(t, err)
+			require.Len(t, payloads, 1)
+
+			p, err := comp.Decompress(*payloads[0])
+			require.NoError(t, err)
+			require.Equal(t, "{[A,B,C,D,E,F]}", string(p))
+		})
+	}
+}
@@ -200,6 +200,9 @@ func formatTitle(title string) string {
 }
 
 func status(check map[string]interface{}) string {
+	if stuck, _ := check["Stuck"].(bool); stuck {
+		return fmt.Sprintf("[%s]", color.RedString("STUCK"))
+	}
 	if check["LastError"].(string) != "" {
 		return fmt.Sprintf("[%s]", color.RedString("ERROR"))
 	}
@@ -14,15 +14,18 @@ import (
 	"github.com/DataDog/datadog-agent/pkg/trace/api"
 	"github.com/DataDog/datadog-agent/pkg/trace/config"
 	"github.com/DataDog/datadog-agent/pkg/trace/config/features"
+	"github.com/DataDog/datadog-agent/pkg/trace/debugger"
 	"github.com/DataDog/datadog-agent/pkg/trace/event"
 	"github.com/DataDog/datadog-agent/pkg/trace/filters"
 	"github.com/DataDog/datadog-agent/pkg/trace/info"
+	"github.com/DataDog/datadog-agent/pkg/trace/metrics"
 	"github.com/DataDog/datadog-agent/pkg/trace/metrics/timing"
 	"github.com/DataDog/datadog-agent/pkg/trace/obfuscate"
 	"github.com/DataDog/datadog-agent/pkg/trace/pb"
 	"github.com/DataDog/datadog-agent/pkg/trace/sampler"
 	"github.com/DataDog/datadog-agent/pkg/trace/stats"
 	"github.com/DataDog/datadog-agent/pkg/trace/traceutil"
+	"github.com/DataDog/datadog-agent/pkg/trace/watchdog"
 	"github.com/DataDog/datadog-agent/pkg/trace/writer"
 	"github.com/DataDog/datadog-agent/pkg/util/log"
 )
@@ -49,6 +52,14 @@ type Agent struct {
 	TraceWriter           *writer.TraceWriter
 	StatsWriter           *writer.StatsWriter
 
+	// Debugger records the decisions taken for the most recent traces. It is nil
+	// unless trace debugging is enabled.
+	Debugger *debugger.Buffer
+
+	// Ladder is the receiver's degradation ladder, which turns features off as
+	// resource usage gets close to the configured limits.
+	Ladder *watchdog.Ladder
+
 	// obfuscator is used to obfuscate sensitive data from various span
 	// tags based on their type.
 	obfuscator *obfuscate.Obfuscator
@@ -87,8 +98,22 @@ func NewAgent(ctx context.Context, conf *config.AgentConfig) *Agent {
 		conf:                  conf,
 		ctx:                   ctx,
 	}
+	if conf.SpanMetrics.Enabled {
+		if conn, err := metrics.Dial(conf); err != nil {
+			log.Errorf("Span metrics are disabled, could not connect to Dogstatsd: %v", err)
+		} else {
+			agnt.Concentrator.SpanMetrics = stats.NewSpanMetrics(conf, conn)
+		}
+	}
+	if conf.DebugTraces > 0 {
+		agnt.Debugger = debugger.NewBuffer(conf.DebugTraces)
+	}
 	agnt.Receiver = api.NewHTTPReceiver(conf, dynConf, in, agnt)
+	agnt.Receiver.Debugger = agnt.Debugger
+	agnt.Ladder = agnt.Receiver.Ladder
+	agnt.Concentrator.Ladder = agnt.Ladder
 	agnt.OTLPReceiver = api.NewOTLPReceiver(in, conf.OTLPReceiver)
+	agnt.Receiver.ShareServiceLimiter(agnt.OTLPReceiver)
 	return agnt
 }
 
@@ -187,6 +212,7 @@ func (a *Agent) Process(p *api.Payload) {
 	ts := p.Source
 	ss := new(writer.SampledSpans)
 	var envtraces []stats.EnvTrace
+	a.obfuscator.SetQueryCache(!a.Ladder.Reached(watchdog.StepNoObfuscationCache))
 	a.PrioritySampler.CountClientDroppedP0s(p.ClientDroppedP0s)
 	for _, t := range p.Traces {
 		if len(t) == 0 {
@@ -196,10 +222,13 @@ func (a *Agent) Process(p *api.Payload) {
 
 		tracen := int64(len(t))
 		atomic.AddInt64(&ts.SpansReceived, tracen)
+		decision := a.Debugger.NewDecision(ts, t)
 		err := normalizeTrace(p.Source, t)
 		if err != nil {
 			log.Debug("Dropping invalid trace: %s", err)
 			atomic.AddInt64(&ts.SpansDropped, tracen)
+			decision.SetNormalizationError(err)
+			a.Debugger.Record(decision)
 			continue
 		}
 
@@ -210,6 +239,8 @@ func (a *Agent) Process(p *api.Payload) {
 			log.Debugf("Trace rejected by blacklister. root: %v", root)
 			atomic.AddInt64(&ts.TracesFiltered, 1)
 			atomic.AddInt64(&ts.SpansFiltered, tracen)
+			decision.SetFiltered(debugger.FilterIgnoreResources)
+			a.Debugger.Record(decision)
 			continue
 		}
 
@@ -217,6 +248,8 @@ func (a *Agent) Process(p *api.Payload) {
 			log.Debugf("Trace rejected as it fails to meet tag requirements. root: %v", root)
 			atomic.AddInt64(&ts.TracesFiltered, 1)
 			atomic.AddInt64(&ts.SpansFiltered, tracen)
+			decision.SetFiltered(debugger.FilterTags)
+			a.Debugger.Record(decision)
 			continue
 		}
 
@@ -257,15 +290,19 @@ func (a *Agent) Process(p *api.Payload) {
 			// this trace has a user defined env.
 			env = v
 		}
+		decision.SetRoot(root, env)
 		pt := ProcessedTrace{
 			Trace:            t,
 			WeightedTrace:    stats.NewWeightedTrace(t, root),
 			Root:             root,
 			Env:              env,
 			ClientDroppedP0s: p.ClientDroppedP0s > 0,
+			Decision:         decision,
 		}
 
 		events, keep := a.sample(ts, pt)
+		decision.SetWriter(len(events))
+		a.Debugger.Record(decision)
 		if !p.ClientComputedStats {
 			if envtraces == nil {
 				envtraces = make([]stats.EnvTrace, 0, len(p.Traces))
@@ -378,12 +415,19 @@ func (a *Agent) sample(ts *info.TagStats, pt ProcessedTrace) (events []*pb.Span,
 		}
 	}
 	atomic.AddInt64(stat, 1)
+	if hasPriority {
+		pt.Decision.SetPriority(int(priority))
+	}
 
 	if priority < 0 {
+		pt.Decision.SetSampling("priority", a.PrioritySampler.Rate(pt.Root), false)
 		return nil, false
 	}
 
 	sampled := a.runSamplers(pt, hasPriority)
+	if a.Ladder.Reached(watchdog.StepNoEventExtraction) {
+		return nil, sampled
+	}
 
 	events, numExtracted := a.EventProcessor.Process(pt.Root, pt.Trace)
 
@@ -407,21 +451,30 @@ func (a *Agent) runSamplers(pt ProcessedTrace, hasPriority bool) bool {
 // or measured spans that are not caught by PrioritySampler and ErrorSampler.
 func (a *Agent) samplePriorityTrace(pt ProcessedTrace) bool {
 	if a.PrioritySampler.Sample(pt.Trace, pt.Root, pt.Env, pt.ClientDroppedP0s) {
+		pt.Decision.SetSampling("priority", a.PrioritySampler.Rate(pt.Root), true)
 		return true
 	}
 	if traceContainsError(pt.Trace) {
-		return a.ErrorsSampler.Sample(pt.Trace, pt.Root, pt.Env)
+		keep := a.ErrorsSampler.Sample(pt.Trace, pt.Root, pt.Env)
+		pt.Decision.SetSampling("errors", a.ErrorsSampler.Rate(pt.Root), keep)
+		return keep
 	}
-	return a.ExceptionSampler.Sample(pt.Trace, pt.Root, pt.Env)
+	keep := a.ExceptionSampler.Sample(pt.Trace, pt.Root, pt.Env)
+	pt.Decision.SetSampling("exception", 1, keep)
+	return keep
 }
 
 // sampleNoPriorityTrace samples traces with no priority set on them. The traces
 // get sampled by either the score sampler or the error sampler if they have an error.
 func (a *Agent) sampleNoPriorityTrace(pt ProcessedTrace) bool {
 	if traceContainsError(pt.Trace) {
-		return a.ErrorsSampler.Sample(pt.Trace, pt.Root, pt.Env)
+		keep := a.ErrorsSampler.Sample(pt.Trace, pt.Root, pt.Env)
+		pt.Decision.SetSampling("errors", a.ErrorsSampler.Rate(pt.Root), keep)
+		return keep
 	}
-	return a.NoPrioritySampler.Sample(pt.Trace, pt.Root, pt.Env)
+	keep := a.NoPrioritySampler.Sample(pt.Trace, pt.Root, pt.Env)
+	pt.Decision.SetSampling("no_priority", a.NoPrioritySampler.Rate(pt.Root), keep)
+	return keep
 }
 
 func traceContainsError(trace pb.Trace) bool {
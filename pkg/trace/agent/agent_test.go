@@ -21,6 +21,7 @@ import (
 
 	"github.com/DataDog/datadog-agent/pkg/trace/api"
 	"github.com/DataDog/datadog-agent/pkg/trace/config"
+	"github.com/DataDog/datadog-agent/pkg/trace/debugger"
 	"github.com/DataDog/datadog-agent/pkg/trace/event"
 	"github.com/DataDog/datadog-agent/pkg/trace/filters"
 	"github.com/DataDog/datadog-agent/pkg/trace/info"
@@ -365,6 +366,100 @@ func TestProcess(t *testing.T) {
 		// without missing a trace
 		assert.Equal(t, gotCount, len(traces))
 	})
+
+	t.Run("Debugger", func(t *testing.T) {
+		cfg := config.New()
+		cfg.Endpoints[0].APIKey = "test"
+		cfg.Ignore["resource"] = []string{"^INSERT.*"}
+		cfg.DebugTraces = 10
+		ctx, cancel := context.WithCancel(context.Background())
+		agnt := NewAgent(ctx, cfg)
+		defer cancel()
+
+		now := time.Now()
+		newSpan := func(traceID uint64, resource string, priority float64) *pb.Span {
+			return &pb.Span{
+				TraceID:  traceID,
+				SpanID:   1,
+				Service:  "db",
+				Resource: resource,
+				Type:     "sql",
+				Start:    now.Add(-time.Second).UnixNano(),
+				Duration: (500 * time.Millisecond).Nanoseconds(),
+				Metrics:  map[string]float64{sampler.KeySamplingPriority: priority},
+			}
+		}
+		foreign := newSpan(5, "SELECT 1", 1)
+		agnt.Process(&api.Payload{
+			Traces: pb.Traces{
+				{newSpan(1, "SELECT 1", 2)},
+				{newSpan(2, "INSERT INTO db VALUES (1, 2, 3)", 2)},
+				{newSpan(3, "SELECT 1", -1)},
+				{newSpan(4, "SELECT 1", 1), foreign},
+			},
+			Source: agnt.Receiver.Stats.GetTagStats(info.Tags{Lang: "go", EndpointVersion: "v0.4"}),
+		})
+
+		assert := assert.New(t)
+		decisions := agnt.Debugger.Find(debugger.Query{Service: "db"})
+		assert.Len(decisions, 4)
+
+		d := agnt.Debugger.Find(debugger.Query{TraceID: 1})
+		assert.Len(d, 1)
+		assert.Equal("go", d[0].Lang)
+		assert.Equal("priority", d[0].Sampler)
+		assert.EqualValues(2, *d[0].Priority)
+		assert.True(d[0].Kept)
+		assert.Equal(debugger.WriterQueued, d[0].Writer)
+
+		d = agnt.Debugger.Find(debugger.Query{TraceID: 2})
+		assert.Equal(debugger.FilterIgnoreResources, d[0].Filter)
+		assert.Equal(debugger.WriterNone, d[0].Writer)
+
+		d = agnt.Debugger.Find(debugger.Query{TraceID: 3})
+		assert.Equal("priority", d[0].Sampler)
+		assert.False(d[0].Kept)
+		assert.Equal(debugger.WriterNone, d[0].Writer)
+
+		// most recent first
+		d = agnt.Debugger.Find(debugger.Query{Limit: 1})
+		assert.Len(d, 1)
+		assert.Contains(d[0].NormalizationError, "foreign_span")
+		assert.Equal(2, d[0].Spans)
+	})
+
+	t.Run("Degradation", func(t *testing.T) {
+		cfg := config.New()
+		cfg.Endpoints[0].APIKey = "test"
+		ctx, cancel := context.WithCancel(context.Background())
+		agnt := NewAgent(ctx, cfg)
+		defer cancel()
+
+		assert := assert.New(t)
+		assert.Same(agnt.Ladder, agnt.Receiver.Ladder)
+		assert.Same(agnt.Ladder, agnt.Concentrator.Ladder)
+
+		root := &pb.Span{
+			TraceID: 1,
+			SpanID:  1,
+			Service: "web",
+			Name:    "http.request",
+			Metrics: map[string]float64{
+				sampler.KeySamplingPriority:            2,
+				sampler.KeySamplingRateEventExtraction: 1,
+			},
+		}
+		pt := ProcessedTrace{Trace: pb.Trace{root}, Root: root}
+		ts := agnt.Receiver.Stats.GetTagStats(info.Tags{})
+		events, keep := agnt.sample(ts, pt)
+		assert.True(keep)
+		assert.Len(events, 1)
+
+		agnt.Ladder.Update(0.95)
+		events, keep = agnt.sample(ts, pt)
+		assert.True(keep)
+		assert.Empty(events)
+	})
 }
 
 func TestClientComputedTopLevel(t *testing.T) {
@@ -6,6 +6,7 @@
 package agent
 
 import (
+	"github.com/DataDog/datadog-agent/pkg/trace/debugger"
 	"github.com/DataDog/datadog-agent/pkg/trace/pb"
 	"github.com/DataDog/datadog-agent/pkg/trace/stats"
 )
@@ -17,4 +18,7 @@ type ProcessedTrace struct {
 	Root             *pb.Span
 	Env              string
 	ClientDroppedP0s bool
+
+	// Decision records the decisions taken for this trace when trace debugging is enabled.
+	Decision *debugger.Decision
 }
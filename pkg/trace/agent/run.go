@@ -7,6 +7,7 @@ package agent
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"math/rand"
 	"os"
@@ -21,6 +22,7 @@ import (
 	"github.com/DataDog/datadog-agent/pkg/tagger/local"
 	"github.com/DataDog/datadog-agent/pkg/tagger/remote"
 	"github.com/DataDog/datadog-agent/pkg/trace/config"
+	"github.com/DataDog/datadog-agent/pkg/trace/debugger"
 	"github.com/DataDog/datadog-agent/pkg/trace/flags"
 	"github.com/DataDog/datadog-agent/pkg/trace/info"
 	"github.com/DataDog/datadog-agent/pkg/trace/metrics"
@@ -73,6 +75,14 @@ func Run(ctx context.Context) {
 		return
 	}
 
+	if flag.Arg(0) == "debug" {
+		// trace-agent debug [-trace_id <id>] [-service <name>] [-limit <n>] [-json]
+		if err := debugger.Debug(os.Stdout, cfg, flag.Args()[1:]); err != nil {
+			osutil.Exitf("Failed to query trace decisions: %s", err)
+		}
+		return
+	}
+
 	if err := coreconfig.SetupLogger(
 		coreconfig.LoggerName("TRACE"),
 		cfg.LogLevel,
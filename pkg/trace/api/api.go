@@ -7,6 +7,7 @@ package api
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/json"
 	"expvar"
@@ -37,6 +38,7 @@ import (
 	"github.com/DataDog/datadog-agent/pkg/trace/api/apiutil"
 	"github.com/DataDog/datadog-agent/pkg/trace/config"
 	"github.com/DataDog/datadog-agent/pkg/trace/config/features"
+	"github.com/DataDog/datadog-agent/pkg/trace/debugger"
 	"github.com/DataDog/datadog-agent/pkg/trace/info"
 	"github.com/DataDog/datadog-agent/pkg/trace/logutil"
 	"github.com/DataDog/datadog-agent/pkg/trace/metrics"
@@ -70,6 +72,16 @@ type HTTPReceiver struct {
 	Stats       *info.ReceiverStats
 	RateLimiter *rateLimiter
 
+	// Debugger serves the decisions taken for the most recent traces. It is nil unless
+	// trace debugging is enabled.
+	Debugger *debugger.Buffer
+
+	// Ladder holds the step of the degradation ladder reached by the agent, based on
+	// its resource usage. It is updated by the watchdog.
+	Ladder *watchdog.Ladder
+
+	serviceLimiter *serviceLimiter
+
 	out            chan *Payload
 	conf           *config.AgentConfig
 	dynConf        *sampler.DynamicConfig
@@ -97,7 +109,9 @@ func NewHTTPReceiver(conf *config.AgentConfig, dynConf *sampler.DynamicConfig, o
 	return &HTTPReceiver{
 		Stats:       info.NewReceiverStats(),
 		RateLimiter: newRateLimiter(),
+		Ladder:      watchdog.NewLadder(),
 
+		serviceLimiter: newServiceLimiter(),
 		out:            out,
 		statsProcessor: statsProcessor,
 		conf:           conf,
@@ -223,6 +237,8 @@ func (r *HTTPReceiver) attachDebugHandlers(mux *http.ServeMux) {
 		w.Write([]byte(fmt.Sprintf("Block profile rate set to %d. It will automatically be disabled again after calling /debug/pprof/block\n", rate)))
 	})
 
+	mux.Handle(debugger.Path, r.Debugger)
+
 	mux.HandleFunc("/debug/pprof/block", func(w http.ResponseWriter, r *http.Request) {
 		// serve the block profile and reset the rate to 0.
 		pprof.Handler("block").ServeHTTP(w, r)
@@ -501,7 +517,13 @@ func (r *HTTPReceiver) handleTraces(v Version, w http.ResponseWriter, req *http.
 		ClientComputedStats:    req.Header.Get(headerComputedStats) != "",
 		ClientDroppedP0s:       droppedTracesFromHeader(req.Header, ts),
 	}
+	r.sendPayload(payload)
+}
 
+// sendPayload sends the given payload down the receiver's output channel. If the channel
+// is blocked, the payload is sent asynchronously to ensure that it is never dropped.
+func (r *HTTPReceiver) sendPayload(payload *Payload) {
+	limitServices(r.serviceLimiter, payload)
 	select {
 	case r.out <- payload:
 		// ok
@@ -519,6 +541,21 @@ func (r *HTTPReceiver) handleTraces(v Version, w http.ResponseWriter, req *http.
 	}
 }
 
+// limitServices drops the traces of payload which the given service limiter doesn't keep.
+func limitServices(l *serviceLimiter, payload *Payload) {
+	var dropped int64
+	payload.Traces, dropped = l.filter(payload.Traces)
+	if dropped > 0 {
+		atomic.AddInt64(&payload.Source.TracesDropped.ServiceLimited, dropped)
+	}
+}
+
+// ShareServiceLimiter makes the given OTLP receiver drop traces per service along with
+// this receiver when the agent is short on resources.
+func (r *HTTPReceiver) ShareServiceLimiter(o *OTLPReceiver) {
+	o.serviceLimiter = r.serviceLimiter
+}
+
 func droppedTracesFromHeader(h http.Header, ts *info.TagStats) int64 {
 	var dropped int64
 	if v := h.Get(headerDroppedP0Traces); v != "" {
@@ -626,16 +663,17 @@ func (r *HTTPReceiver) loop() {
 // killProcess exits the process with the given msg; replaced in tests.
 var killProcess = func(format string, a ...interface{}) { osutil.Exitf(format, a...) }
 
-// watchdog checks the trace-agent's heap and CPU usage and updates the rate limiter using a correct
-// sampling rate to maintain resource usage within set thresholds. These thresholds are defined by
-// the configuration MaxMemory and MaxCPU. If these values are 0, all limits are disabled and the rate
-// limiter will accept everything.
+// watchdog checks the trace-agent's heap and CPU usage against the configured MaxMemory and MaxCPU
+// limits and moves the degradation ladder accordingly. As usage gets closer to the limits, features
+// are progressively turned off by the components reading the ladder. Once a limit is reached, traces
+// are dropped per service, proportionally to their volume, and past that, the rate limiter rejects
+// whole payloads. If both limits are 0, the ladder never moves and everything is accepted.
 func (r *HTTPReceiver) watchdog(now time.Time) {
 	wi := watchdog.Info{
 		Mem: watchdog.Mem(),
 		CPU: watchdog.CPU(now),
 	}
-	rateMem := 1.0
+	var usage float64
 	if r.conf.MaxMemory > 0 {
 		if current, allowed := float64(wi.Mem.Alloc), r.conf.MaxMemory*1.5; current > allowed {
 			// This is a safety mechanism: if the agent is using more than 1.5x max. memory, there
@@ -645,29 +683,69 @@ func (r *HTTPReceiver) watchdog(now time.Time) {
 			log.Criticalf("Killing process. Memory threshold exceeded: %.2fM / %.2fM", current/1024/1024, allowed/1024/1024)
 			killProcess("OOM")
 		}
-		rateMem = computeRateLimitingRate(r.conf.MaxMemory, float64(wi.Mem.Alloc), r.RateLimiter.RealRate())
-		if rateMem < 1 {
+		usage = float64(wi.Mem.Alloc) / r.conf.MaxMemory
+		if usage > 1 {
 			log.Warnf("Memory threshold exceeded (apm_config.max_memory: %.0f bytes): %d", r.conf.MaxMemory, wi.Mem.Alloc)
 		}
 	}
-	rateCPU := 1.0
 	if r.conf.MaxCPU > 0 {
-		rateCPU = computeRateLimitingRate(r.conf.MaxCPU, wi.CPU.UserAvg, r.RateLimiter.RealRate())
-		if rateCPU < 1 {
+		cpu := wi.CPU.UserAvg / r.conf.MaxCPU
+		if cpu > 1 {
 			log.Warnf("CPU threshold exceeded (apm_config.max_cpu_percent: %.0f): %.0f", r.conf.MaxCPU*100, wi.CPU.UserAvg)
 		}
+		usage = math.Max(usage, cpu)
+	}
+
+	step, changed := r.Ladder.Update(usage)
+	if changed {
+		log.Warnf("Resource usage at %.0f%% of the configured limits, degradation step is now %q (in effect: %s)",
+			usage*100, step, strings.Join(watchdog.StepsUpTo(step), ", "))
+		metrics.Count("datadog.trace_agent.degradation.step_change", 1, []string{"step:" + step.String()}, 1)
+	}
+	switch {
+	case step >= watchdog.StepPayloadRateLimiting:
+		// the per-service rates weren't enough on their own: keep lowering them, and
+		// reject payloads until they catch up
+		r.serviceLimiter.SetTargetRate(r.limitingRate(wi, r.serviceLimiter.TargetRate()))
+		r.RateLimiter.SetTargetRate(r.limitingRate(wi, r.RateLimiter.RealRate()))
+	case step == watchdog.StepServiceSampling:
+		r.serviceLimiter.SetTargetRate(r.limitingRate(wi, r.serviceLimiter.TargetRate()))
+		r.RateLimiter.SetTargetRate(1)
+	default:
+		r.serviceLimiter.SetTargetRate(1)
+		r.RateLimiter.SetTargetRate(1)
 	}
 
-	r.RateLimiter.SetTargetRate(math.Min(rateCPU, rateMem))
-
 	stats := r.RateLimiter.Stats()
 
 	info.UpdateRateLimiter(*stats)
 	info.UpdateWatchdogInfo(wi)
+	info.UpdateDegradation(info.DegradationInfo{
+		Step:          step.String(),
+		Level:         int(step),
+		Usage:         usage,
+		InEffect:      watchdog.StepsUpTo(step),
+		RateByService: r.serviceLimiter.Rates(),
+	})
 
 	metrics.Gauge("datadog.trace_agent.heap_alloc", float64(wi.Mem.Alloc), nil, 1)
 	metrics.Gauge("datadog.trace_agent.cpu_percent", wi.CPU.UserAvg*100, nil, 1)
 	metrics.Gauge("datadog.trace_agent.receiver.ratelimit", stats.TargetRate, nil, 1)
+	metrics.Gauge("datadog.trace_agent.receiver.service_ratelimit", r.serviceLimiter.TargetRate(), nil, 1)
+	metrics.Gauge("datadog.trace_agent.degradation.step", float64(step), nil, 1)
+}
+
+// limitingRate returns the rate at which traffic should be limited to stay within the configured
+// resource limits, given the resource usage in wi and the rate currently in effect.
+func (r *HTTPReceiver) limitingRate(wi watchdog.Info, rate float64) float64 {
+	rateMem, rateCPU := 1.0, 1.0
+	if r.conf.MaxMemory > 0 {
+		rateMem = computeRateLimitingRate(r.conf.MaxMemory, float64(wi.Mem.Alloc), rate)
+	}
+	if r.conf.MaxCPU > 0 {
+		rateCPU = computeRateLimitingRate(r.conf.MaxCPU, wi.CPU.UserAvg, rate)
+	}
+	return math.Min(rateMem, rateCPU)
 }
 
 // Languages returns the list of the languages used in the traces the agent receives.
@@ -752,6 +830,22 @@ func getContainerTags(containerID string) string {
 	return strings.Join(list, ",")
 }
 
+// readRequestBody reads the entire body of req, decompressing it if it was gzip encoded.
+// The decompressed body is limited to limit bytes too, as the limit set on the body of
+// req only applies to the compressed stream.
+func readRequestBody(req *http.Request, limit int64) ([]byte, error) {
+	var rd io.Reader = req.Body
+	if req.Header.Get("Content-Encoding") == "gzip" {
+		gzipr, err := gzip.NewReader(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		defer gzipr.Close()
+		rd = apiutil.NewLimitedReader(gzipr, limit)
+	}
+	return ioutil.ReadAll(rd)
+}
+
 // getMediaType attempts to return the media type from the Content-Type MIME header. If it fails
 // it returns the default media type "application/json".
 func getMediaType(req *http.Request) string {
@@ -25,6 +25,7 @@ import (
 	"github.com/DataDog/datadog-agent/pkg/trace/pb"
 	"github.com/DataDog/datadog-agent/pkg/trace/sampler"
 	"github.com/DataDog/datadog-agent/pkg/trace/test/testutil"
+	"github.com/DataDog/datadog-agent/pkg/trace/watchdog"
 
 	"github.com/cihub/seelog"
 	"github.com/stretchr/testify/assert"
@@ -1113,6 +1114,26 @@ func TestWatchdog(t *testing.T) {
 		r.watchdog(time.Now())
 		assert.NotEqual(t, 1.0, r.RateLimiter.TargetRate())
 	})
+
+	t.Run("payload-rate-limiting", func(t *testing.T) {
+		cfg := config.New()
+		cfg.MaxMemory = 1
+		r := &HTTPReceiver{
+			conf:           cfg,
+			RateLimiter:    newRateLimiter(),
+			Ladder:         watchdog.NewLadder(),
+			serviceLimiter: newServiceLimiter(),
+		}
+
+		r.watchdog(time.Now())
+		assert.Equal(t, watchdog.StepPayloadRateLimiting, r.Ladder.Step())
+		rate := r.serviceLimiter.TargetRate()
+		assert.Less(t, rate, 1.0)
+		// the service limiter keeps lowering its rate along with the payload rate limiter
+		r.watchdog(time.Now())
+		assert.Less(t, r.serviceLimiter.TargetRate(), rate)
+		assert.Less(t, r.RateLimiter.TargetRate(), 1.0)
+	})
 }
 
 func msgpTraces(t *testing.T, traces pb.Traces) []byte {
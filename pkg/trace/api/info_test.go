@@ -118,6 +118,8 @@ func TestInfoHandler(t *testing.T) {
 		"/v0.5/traces",
 		"/profiling/v1/input",
 		"/v0.6/stats",
+		"/api/v2/spans",
+		"/api/traces",
 		"/appsec/proxy/"
 	],
 	"feature_flags": [
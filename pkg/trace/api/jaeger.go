@@ -0,0 +1,599 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package api
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/info"
+	"github.com/DataDog/datadog-agent/pkg/trace/pb"
+	"github.com/DataDog/datadog-agent/pkg/trace/pb/otlppb"
+	"github.com/DataDog/datadog-agent/pkg/trace/sampler"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+
+	"go.opentelemetry.io/otel/semconv"
+)
+
+// handleJaeger handles Jaeger batches encoded using the Thrift binary protocol.
+func (r *HTTPReceiver) handleJaeger(v Version, w http.ResponseWriter, req *http.Request) {
+	body, err := readRequestBody(req, r.conf.MaxRequestBytes)
+	if err != nil {
+		httpDecodingError(err, []string{"handler:jaeger", "v:" + string(v)}, w)
+		log.Errorf("Cannot decode %s traces payload: %v", v, err)
+		return
+	}
+	batch, err := decodeJaegerBatch(body)
+	if err != nil {
+		httpDecodingError(err, []string{"handler:jaeger", "v:" + string(v)}, w)
+		log.Errorf("Cannot decode %s traces payload: %v", v, err)
+		return
+	}
+	tags := info.Tags{
+		Lang:            req.Header.Get(headerLang),
+		TracerVersion:   req.Header.Get(headerTracerVersion),
+		EndpointVersion: string(v),
+	}
+	if lang, version := jaegerClientVersion(batch.process); lang != "" {
+		tags.Lang, tags.TracerVersion = lang, version
+	}
+	w.WriteHeader(http.StatusAccepted)
+	r.receiveTraces(req, r.Stats.GetTagStats(tags), jaegerBatchToTraces(batch))
+}
+
+// jaegerClientVersion returns the client language and version reported by the Jaeger client
+// in the "jaeger.version" process tag (e.g. "Go-2.29.1").
+func jaegerClientVersion(p jaegerProcess) (lang, version string) {
+	for _, tag := range p.tags {
+		if tag.key != "jaeger.version" {
+			continue
+		}
+		parts := strings.SplitN(tag.vStr, "-", 2)
+		if len(parts) != 2 {
+			return "", ""
+		}
+		return strings.ToLower(parts[0]), "jaeger-" + parts[1]
+	}
+	return "", ""
+}
+
+// jaegerBatchToTraces converts all spans in the given batch to Datadog spans, grouped by trace.
+func jaegerBatchToTraces(batch *jaegerBatch) pb.Traces {
+	pattr := make(map[string]string, len(batch.process.tags))
+	for _, tag := range batch.process.tags {
+		switch tag.key {
+		case "hostname":
+			pattr[tagHostname] = tag.String()
+		case "jaeger.version", "ip", "client-uuid":
+			// not useful as span tags
+		default:
+			pattr[tag.key] = tag.String()
+		}
+	}
+	tracesByID := make(map[uint64]pb.Trace)
+	for i := range batch.spans {
+		span := convertJaegerSpan(batch.process.serviceName, pattr, &batch.spans[i])
+		tracesByID[span.TraceID] = append(tracesByID[span.TraceID], span)
+	}
+	traces := make(pb.Traces, 0, len(tracesByID))
+	for _, trace := range tracesByID {
+		traces = append(traces, trace)
+	}
+	return traces
+}
+
+// jaegerKinds maps the values of the Jaeger "span.kind" tag to their OpenTelemetry equivalent.
+var jaegerKinds = map[string]otlppb.Span_SpanKind{
+	"server":   otlppb.Span_SPAN_KIND_SERVER,
+	"client":   otlppb.Span_SPAN_KIND_CLIENT,
+	"producer": otlppb.Span_SPAN_KIND_PRODUCER,
+	"consumer": otlppb.Span_SPAN_KIND_CONSUMER,
+}
+
+// convertJaegerSpan converts the Jaeger span in to a Datadog span, using the given service and
+// the pattr process tags to further augment it.
+func convertJaegerSpan(service string, pattr map[string]string, in *jaegerSpan) *pb.Span {
+	priority := sampler.PriorityAutoKeep
+	if in.flags&jaegerFlagDebug != 0 {
+		priority = sampler.PriorityUserKeep
+	}
+	span := &pb.Span{
+		TraceID:  uint64(in.traceIDLow),
+		SpanID:   uint64(in.spanID),
+		ParentID: uint64(in.parentSpanID),
+		Start:    in.startTime * 1000,
+		Duration: in.duration * 1000,
+		Service:  service,
+		Resource: in.operationName,
+		Meta:     make(map[string]string, len(pattr)+len(in.tags)),
+		Metrics: map[string]float64{
+			// sampling decisions are taken by Jaeger clients and unsampled spans
+			// are never reported.
+			sampler.KeySamplingPriority: float64(priority),
+		},
+	}
+	for k, v := range pattr {
+		span.Meta[k] = v
+	}
+	if in.traceIDHigh != 0 {
+		span.Meta[tagTraceIDHigh] = strconv.FormatUint(uint64(in.traceIDHigh), 16)
+	}
+	var links []*otlppb.Span_Link
+	for _, ref := range in.references {
+		if ref.refType == jaegerRefChildOf && span.ParentID == 0 {
+			span.ParentID = uint64(ref.spanID)
+			continue
+		}
+		if uint64(ref.spanID) == span.ParentID {
+			continue
+		}
+		traceID := make([]byte, 16)
+		binary.BigEndian.PutUint64(traceID[:8], uint64(ref.traceIDHigh))
+		binary.BigEndian.PutUint64(traceID[8:], uint64(ref.traceIDLow))
+		spanID := make([]byte, 8)
+		binary.BigEndian.PutUint64(spanID, uint64(ref.spanID))
+		links = append(links, &otlppb.Span_Link{TraceId: traceID, SpanId: spanID})
+	}
+	if len(links) > 0 {
+		span.Meta["links"] = marshalLinks(links)
+	}
+	kind := otlppb.Span_SPAN_KIND_INTERNAL
+	for _, tag := range in.tags {
+		switch tag.key {
+		case "span.kind":
+			if k, ok := jaegerKinds[tag.vStr]; ok {
+				kind = k
+			}
+		case "error":
+			if tag.vBool || tag.vStr == "true" {
+				span.Error = 1
+			}
+		case "sampler.type", "sampler.param", "internal.span.format":
+			// Jaeger internals
+		default:
+			switch tag.vType {
+			case jaegerTagDouble:
+				span.Metrics[tag.key] = tag.vDouble
+			case jaegerTagLong:
+				span.Metrics[tag.key] = float64(tag.vLong)
+			default:
+				span.Meta[tag.key] = tag.String()
+			}
+		}
+	}
+	span.Name = "jaeger." + spanKindName(kind)
+	if len(in.logs) > 0 {
+		events := make([]*otlppb.Span_Event, len(in.logs))
+		for i, l := range in.logs {
+			e := &otlppb.Span_Event{TimeUnixNano: uint64(l.timestamp) * 1000}
+			for _, f := range l.fields {
+				if f.key == "event" {
+					e.Name = f.String()
+					continue
+				}
+				if span.Error == 1 {
+					switch f.key {
+					case "message", "error.object":
+						span.Meta["error.msg"] = f.String()
+					case "error.kind":
+						span.Meta["error.type"] = f.String()
+					case "stack":
+						span.Meta["error.stack"] = f.String()
+					}
+				}
+				e.Attributes = append(e.Attributes, &otlppb.KeyValue{
+					Key:   f.key,
+					Value: &otlppb.AnyValue{Value: &otlppb.AnyValue_StringValue{StringValue: f.String()}},
+				})
+			}
+			events[i] = e
+		}
+		span.Meta["events"] = marshalEvents(events)
+	}
+	if _, ok := span.Meta["version"]; !ok {
+		if ver := span.Meta[string(semconv.ServiceVersionKey)]; ver != "" {
+			span.Meta["version"] = ver
+		}
+	}
+	applySemanticConventions(kind, span)
+	return span
+}
+
+const (
+	// jaegerFlagDebug is set in the flags of spans which were force-sampled.
+	jaegerFlagDebug = 2
+
+	// jaegerRefChildOf is the Jaeger SpanRefType for parent references.
+	jaegerRefChildOf = 0
+)
+
+// Jaeger TagType values.
+const (
+	jaegerTagString int32 = iota
+	jaegerTagDouble
+	jaegerTagBool
+	jaegerTagLong
+	jaegerTagBinary
+)
+
+// jaegerTag is a Jaeger Tag, as defined in jaeger.thrift.
+type jaegerTag struct {
+	key     string
+	vType   int32
+	vStr    string
+	vDouble float64
+	vBool   bool
+	vLong   int64
+	vBinary []byte
+}
+
+// String returns the string representation of the tag's value.
+func (t *jaegerTag) String() string {
+	switch t.vType {
+	case jaegerTagDouble:
+		return strconv.FormatFloat(t.vDouble, 'f', -1, 64)
+	case jaegerTagBool:
+		return strconv.FormatBool(t.vBool)
+	case jaegerTagLong:
+		return strconv.FormatInt(t.vLong, 10)
+	case jaegerTagBinary:
+		return base64.StdEncoding.EncodeToString(t.vBinary)
+	default:
+		return t.vStr
+	}
+}
+
+// jaegerLog is a Jaeger Log, as defined in jaeger.thrift.
+type jaegerLog struct {
+	timestamp int64
+	fields    []jaegerTag
+}
+
+// jaegerSpanRef is a Jaeger SpanRef, as defined in jaeger.thrift.
+type jaegerSpanRef struct {
+	refType     int32
+	traceIDLow  int64
+	traceIDHigh int64
+	spanID      int64
+}
+
+// jaegerSpan is a Jaeger Span, as defined in jaeger.thrift. Times are in microseconds.
+type jaegerSpan struct {
+	traceIDLow    int64
+	traceIDHigh   int64
+	spanID        int64
+	parentSpanID  int64
+	operationName string
+	references    []jaegerSpanRef
+	flags         int32
+	startTime     int64
+	duration      int64
+	tags          []jaegerTag
+	logs          []jaegerLog
+}
+
+// jaegerProcess is a Jaeger Process, as defined in jaeger.thrift.
+type jaegerProcess struct {
+	serviceName string
+	tags        []jaegerTag
+}
+
+// jaegerBatch is a Jaeger Batch, as defined in jaeger.thrift.
+type jaegerBatch struct {
+	process jaegerProcess
+	spans   []jaegerSpan
+}
+
+// decodeJaegerBatch decodes a Jaeger Batch encoded using the Thrift binary protocol.
+func decodeJaegerBatch(data []byte) (*jaegerBatch, error) {
+	var batch jaegerBatch
+	d := &thriftDecoder{buf: data}
+	err := d.readStruct(func(id int16, typ byte) error {
+		switch {
+		case id == 1 && typ == thriftStruct:
+			return d.readStruct(func(id int16, typ byte) error {
+				switch {
+				case id == 1 && typ == thriftString:
+					batch.process.serviceName = d.readString()
+				case id == 2 && typ == thriftList:
+					batch.process.tags = d.readTags()
+				default:
+					d.skip(typ, 0)
+				}
+				return d.err
+			})
+		case id == 2 && typ == thriftList:
+			n := d.readListHeader(thriftStruct)
+			batch.spans = make([]jaegerSpan, n)
+			for i := 0; i < n && d.err == nil; i++ {
+				d.readSpan(&batch.spans[i])
+			}
+		default:
+			d.skip(typ, 0)
+		}
+		return d.err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &batch, nil
+}
+
+// Thrift binary protocol type identifiers.
+const (
+	thriftStop   byte = 0
+	thriftBool   byte = 2
+	thriftByte   byte = 3
+	thriftDouble byte = 4
+	thriftI16    byte = 6
+	thriftI32    byte = 8
+	thriftI64    byte = 10
+	thriftString byte = 11
+	thriftStruct byte = 12
+	thriftMap    byte = 13
+	thriftSet    byte = 14
+	thriftList   byte = 15
+)
+
+// thriftMaxDepth limits the nesting of skipped values to protect against malicious payloads.
+const thriftMaxDepth = 64
+
+var (
+	errThriftShort = errors.New("thrift: unexpected end of payload")
+	errThriftDepth = errors.New("thrift: maximum nesting depth exceeded")
+)
+
+// thriftDecoder decodes values encoded using the Thrift binary protocol. The first error
+// encountered is kept in err, after which all reads return zero values.
+type thriftDecoder struct {
+	buf []byte
+	off int
+	err error
+}
+
+func (d *thriftDecoder) next(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n < 0 || len(d.buf)-d.off < n {
+		d.err = errThriftShort
+		return nil
+	}
+	b := d.buf[d.off : d.off+n]
+	d.off += n
+	return b
+}
+
+func (d *thriftDecoder) readByte() byte {
+	if b := d.next(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (d *thriftDecoder) readI16() int16 {
+	if b := d.next(2); b != nil {
+		return int16(binary.BigEndian.Uint16(b))
+	}
+	return 0
+}
+
+func (d *thriftDecoder) readI32() int32 {
+	if b := d.next(4); b != nil {
+		return int32(binary.BigEndian.Uint32(b))
+	}
+	return 0
+}
+
+func (d *thriftDecoder) readI64() int64 {
+	if b := d.next(8); b != nil {
+		return int64(binary.BigEndian.Uint64(b))
+	}
+	return 0
+}
+
+func (d *thriftDecoder) readDouble() float64 {
+	return math.Float64frombits(uint64(d.readI64()))
+}
+
+func (d *thriftDecoder) readBinary() []byte {
+	n := d.readI32()
+	b := d.next(int(n))
+	if b == nil {
+		return nil
+	}
+	out := make([]byte, len(b))
+	copy(out, b)
+	return out
+}
+
+func (d *thriftDecoder) readString() string {
+	n := d.readI32()
+	return string(d.next(int(n)))
+}
+
+// readListHeader reads the header of a list and returns its size. It fails if the list's
+// elements are not of the expected type.
+func (d *thriftDecoder) readListHeader(expected byte) int {
+	typ := d.readByte()
+	n := int(d.readI32())
+	if d.err != nil {
+		return 0
+	}
+	if typ != expected {
+		d.err = fmt.Errorf("thrift: expected list of type %d, got %d", expected, typ)
+		return 0
+	}
+	if n < 0 || n > len(d.buf)-d.off {
+		// every element takes at least one byte
+		d.err = errThriftShort
+		return 0
+	}
+	return n
+}
+
+// readStruct reads a struct, calling fn for each field found until the end of the struct.
+// fn must consume the field's value.
+func (d *thriftDecoder) readStruct(fn func(id int16, typ byte) error) error {
+	for d.err == nil {
+		typ := d.readByte()
+		if typ == thriftStop || d.err != nil {
+			break
+		}
+		id := d.readI16()
+		if d.err != nil {
+			break
+		}
+		if err := fn(id, typ); err != nil {
+			return err
+		}
+	}
+	return d.err
+}
+
+// skip skips over a value of the given type.
+func (d *thriftDecoder) skip(typ byte, depth int) {
+	if depth > thriftMaxDepth {
+		d.err = errThriftDepth
+		return
+	}
+	switch typ {
+	case thriftBool, thriftByte:
+		d.next(1)
+	case thriftI16:
+		d.next(2)
+	case thriftI32:
+		d.next(4)
+	case thriftDouble, thriftI64:
+		d.next(8)
+	case thriftString:
+		d.next(int(d.readI32()))
+	case thriftStruct:
+		d.readStruct(func(_ int16, typ byte) error {
+			d.skip(typ, depth+1)
+			return d.err
+		})
+	case thriftMap:
+		ktyp, vtyp := d.readByte(), d.readByte()
+		n := int(d.readI32())
+		for i := 0; i < n && d.err == nil; i++ {
+			d.skip(ktyp, depth+1)
+			d.skip(vtyp, depth+1)
+		}
+	case thriftSet, thriftList:
+		etyp := d.readByte()
+		n := int(d.readI32())
+		for i := 0; i < n && d.err == nil; i++ {
+			d.skip(etyp, depth+1)
+		}
+	default:
+		if d.err == nil {
+			d.err = fmt.Errorf("thrift: unknown type %d", typ)
+		}
+	}
+}
+
+func (d *thriftDecoder) readTags() []jaegerTag {
+	n := d.readListHeader(thriftStruct)
+	tags := make([]jaegerTag, n)
+	for i := 0; i < n && d.err == nil; i++ {
+		t := &tags[i]
+		d.readStruct(func(id int16, typ byte) error {
+			switch {
+			case id == 1 && typ == thriftString:
+				t.key = d.readString()
+			case id == 2 && typ == thriftI32:
+				t.vType = d.readI32()
+			case id == 3 && typ == thriftString:
+				t.vStr = d.readString()
+			case id == 4 && typ == thriftDouble:
+				t.vDouble = d.readDouble()
+			case id == 5 && typ == thriftBool:
+				t.vBool = d.readByte() != 0
+			case id == 6 && typ == thriftI64:
+				t.vLong = d.readI64()
+			case id == 7 && typ == thriftString:
+				t.vBinary = d.readBinary()
+			default:
+				d.skip(typ, 0)
+			}
+			return d.err
+		})
+	}
+	return tags
+}
+
+func (d *thriftDecoder) readSpan(s *jaegerSpan) {
+	d.readStruct(func(id int16, typ byte) error {
+		switch {
+		case id == 1 && typ == thriftI64:
+			s.traceIDLow = d.readI64()
+		case id == 2 && typ == thriftI64:
+			s.traceIDHigh = d.readI64()
+		case id == 3 && typ == thriftI64:
+			s.spanID = d.readI64()
+		case id == 4 && typ == thriftI64:
+			s.parentSpanID = d.readI64()
+		case id == 5 && typ == thriftString:
+			s.operationName = d.readString()
+		case id == 6 && typ == thriftList:
+			n := d.readListHeader(thriftStruct)
+			s.references = make([]jaegerSpanRef, n)
+			for i := 0; i < n && d.err == nil; i++ {
+				ref := &s.references[i]
+				d.readStruct(func(id int16, typ byte) error {
+					switch {
+					case id == 1 && typ == thriftI32:
+						ref.refType = d.readI32()
+					case id == 2 && typ == thriftI64:
+						ref.traceIDLow = d.readI64()
+					case id == 3 && typ == thriftI64:
+						ref.traceIDHigh = d.readI64()
+					case id == 4 && typ == thriftI64:
+						ref.spanID = d.readI64()
+					default:
+						d.skip(typ, 0)
+					}
+					return d.err
+				})
+			}
+		case id == 7 && typ == thriftI32:
+			s.flags = d.readI32()
+		case id == 8 && typ == thriftI64:
+			s.startTime = d.readI64()
+		case id == 9 && typ == thriftI64:
+			s.duration = d.readI64()
+		case id == 10 && typ == thriftList:
+			s.tags = d.readTags()
+		case id == 11 && typ == thriftList:
+			n := d.readListHeader(thriftStruct)
+			s.logs = make([]jaegerLog, n)
+			for i := 0; i < n && d.err == nil; i++ {
+				l := &s.logs[i]
+				d.readStruct(func(id int16, typ byte) error {
+					switch {
+					case id == 1 && typ == thriftI64:
+						l.timestamp = d.readI64()
+					case id == 2 && typ == thriftList:
+						l.fields = d.readTags()
+					default:
+						d.skip(typ, 0)
+					}
+					return d.err
+				})
+			}
+		default:
+			d.skip(typ, 0)
+		}
+		return d.err
+	})
+}
@@ -0,0 +1,290 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package api
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/info"
+	"github.com/stretchr/testify/assert"
+)
+
+// thriftEncoder encodes values using the Thrift binary protocol.
+type thriftEncoder struct{ bytes.Buffer }
+
+func (e *thriftEncoder) field(typ byte, id int16) {
+	e.WriteByte(typ)
+	binary.Write(e, binary.BigEndian, id)
+}
+
+func (e *thriftEncoder) stop() { e.WriteByte(thriftStop) }
+
+func (e *thriftEncoder) i32(id int16, v int32) {
+	e.field(thriftI32, id)
+	binary.Write(e, binary.BigEndian, v)
+}
+
+func (e *thriftEncoder) i64(id int16, v int64) {
+	e.field(thriftI64, id)
+	binary.Write(e, binary.BigEndian, v)
+}
+
+func (e *thriftEncoder) double(id int16, v float64) {
+	e.field(thriftDouble, id)
+	binary.Write(e, binary.BigEndian, math.Float64bits(v))
+}
+
+func (e *thriftEncoder) boolean(id int16, v bool) {
+	e.field(thriftBool, id)
+	if v {
+		e.WriteByte(1)
+	} else {
+		e.WriteByte(0)
+	}
+}
+
+func (e *thriftEncoder) str(id int16, v string) {
+	e.field(thriftString, id)
+	binary.Write(e, binary.BigEndian, int32(len(v)))
+	e.WriteString(v)
+}
+
+func (e *thriftEncoder) list(id int16, typ byte, n int) {
+	e.field(thriftList, id)
+	e.WriteByte(typ)
+	binary.Write(e, binary.BigEndian, int32(n))
+}
+
+func (e *thriftEncoder) tags(id int16, tags []jaegerTag) {
+	e.list(id, thriftStruct, len(tags))
+	for _, t := range tags {
+		e.str(1, t.key)
+		e.i32(2, t.vType)
+		switch t.vType {
+		case jaegerTagString:
+			e.str(3, t.vStr)
+		case jaegerTagDouble:
+			e.double(4, t.vDouble)
+		case jaegerTagBool:
+			e.boolean(5, t.vBool)
+		case jaegerTagLong:
+			e.i64(6, t.vLong)
+		}
+		e.stop()
+	}
+}
+
+func encodeJaegerBatch(b *jaegerBatch) []byte {
+	var e thriftEncoder
+	e.field(thriftStruct, 1)
+	e.str(1, b.process.serviceName)
+	e.tags(2, b.process.tags)
+	e.stop()
+	e.list(2, thriftStruct, len(b.spans))
+	for _, s := range b.spans {
+		e.i64(1, s.traceIDLow)
+		e.i64(2, s.traceIDHigh)
+		e.i64(3, s.spanID)
+		e.i64(4, s.parentSpanID)
+		e.str(5, s.operationName)
+		if len(s.references) > 0 {
+			e.list(6, thriftStruct, len(s.references))
+			for _, ref := range s.references {
+				e.i32(1, ref.refType)
+				e.i64(2, ref.traceIDLow)
+				e.i64(3, ref.traceIDHigh)
+				e.i64(4, ref.spanID)
+				e.stop()
+			}
+		}
+		e.i32(7, s.flags)
+		e.i64(8, s.startTime)
+		e.i64(9, s.duration)
+		e.tags(10, s.tags)
+		if len(s.logs) > 0 {
+			e.list(11, thriftStruct, len(s.logs))
+			for _, l := range s.logs {
+				e.i64(1, l.timestamp)
+				e.tags(2, l.fields)
+				e.stop()
+			}
+		}
+		// unknown fields must be skipped
+		e.field(thriftMap, 99)
+		e.WriteByte(thriftString)
+		e.WriteByte(thriftI32)
+		binary.Write(&e, binary.BigEndian, int32(1))
+		binary.Write(&e, binary.BigEndian, int32(1))
+		e.WriteString("k")
+		binary.Write(&e, binary.BigEndian, int32(2))
+		e.stop()
+	}
+	e.i64(3, 42) // seqNo
+	e.stop()
+	return e.Bytes()
+}
+
+var jaegerTestBatch = &jaegerBatch{
+	process: jaegerProcess{
+		serviceName: "frontend",
+		tags: []jaegerTag{
+			{key: "jaeger.version", vType: jaegerTagString, vStr: "Go-2.29.1"},
+			{key: "hostname", vType: jaegerTagString, vStr: "host-a"},
+			{key: "deployment.environment", vType: jaegerTagString, vStr: "prod"},
+		},
+	},
+	spans: []jaegerSpan{
+		{
+			traceIDLow:    1,
+			traceIDHigh:   2,
+			spanID:        3,
+			operationName: "HTTP GET",
+			flags:         1,
+			startTime:     1581452772000000,
+			duration:      2000,
+			tags: []jaegerTag{
+				{key: "span.kind", vType: jaegerTagString, vStr: "server"},
+				{key: "http.method", vType: jaegerTagString, vStr: "GET"},
+				{key: "http.route", vType: jaegerTagString, vStr: "/users"},
+				{key: "http.status_code", vType: jaegerTagLong, vLong: 500},
+				{key: "error", vType: jaegerTagBool, vBool: true},
+				{key: "ratio", vType: jaegerTagDouble, vDouble: 0.5},
+				{key: "sampler.type", vType: jaegerTagString, vStr: "const"},
+			},
+			logs: []jaegerLog{
+				{
+					timestamp: 1581452772000500,
+					fields: []jaegerTag{
+						{key: "event", vType: jaegerTagString, vStr: "error"},
+						{key: "message", vType: jaegerTagString, vStr: "boom"},
+					},
+				},
+			},
+		},
+		{
+			traceIDLow:    1,
+			traceIDHigh:   2,
+			spanID:        4,
+			operationName: "send",
+			flags:         3,
+			startTime:     1581452772000100,
+			duration:      1000,
+			references: []jaegerSpanRef{
+				{refType: jaegerRefChildOf, traceIDLow: 1, traceIDHigh: 2, spanID: 3},
+				{refType: 1, traceIDLow: 5, spanID: 6},
+			},
+			tags: []jaegerTag{
+				{key: "span.kind", vType: jaegerTagString, vStr: "producer"},
+				{key: "messaging.system", vType: jaegerTagString, vStr: "kafka"},
+				{key: "messaging.operation", vType: jaegerTagString, vStr: "send"},
+				{key: "messaging.destination", vType: jaegerTagString, vStr: "orders"},
+			},
+		},
+	},
+}
+
+func TestDecodeJaegerBatch(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		batch, err := decodeJaegerBatch(encodeJaegerBatch(jaegerTestBatch))
+		assert.NoError(t, err)
+		assert.Equal(t, jaegerTestBatch, batch)
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		data := encodeJaegerBatch(jaegerTestBatch)
+		for _, n := range []int{1, 10, len(data) / 2, len(data) - 1} {
+			_, err := decodeJaegerBatch(data[:n])
+			assert.Error(t, err, n)
+		}
+	})
+
+	t.Run("huge-list", func(t *testing.T) {
+		var e thriftEncoder
+		e.list(2, thriftStruct, math.MaxInt32)
+		_, err := decodeJaegerBatch(e.Bytes())
+		assert.Equal(t, errThriftShort, err)
+	})
+
+	t.Run("depth", func(t *testing.T) {
+		var e thriftEncoder
+		for i := 0; i < thriftMaxDepth+2; i++ {
+			e.field(thriftStruct, 99)
+		}
+		_, err := decodeJaegerBatch(e.Bytes())
+		assert.Equal(t, errThriftDepth, err)
+	})
+}
+
+func TestJaegerBatchToTraces(t *testing.T) {
+	assert := assert.New(t)
+	traces := jaegerBatchToTraces(jaegerTestBatch)
+	assert.Len(traces, 1)
+	assert.Len(traces[0], 2)
+
+	server, producer := traces[0][0], traces[0][1]
+	assert.Equal("frontend", server.Service)
+	assert.Equal("jaeger.server", server.Name)
+	assert.Equal("GET /users", server.Resource)
+	assert.Equal("web", server.Type)
+	assert.Equal(uint64(1), server.TraceID)
+	assert.Equal(uint64(3), server.SpanID)
+	assert.Equal(int64(1581452772000000000), server.Start)
+	assert.Equal(int64(2000000), server.Duration)
+	assert.EqualValues(1, server.Error)
+	assert.Equal("boom", server.Meta["error.msg"])
+	assert.Equal("500", server.Meta["http.status_code"])
+	assert.Equal("host-a", server.Meta["_dd.hostname"])
+	assert.Equal("prod", server.Meta["env"])
+	assert.Equal("2", server.Meta["_dd.p.tid"])
+	assert.Equal(0.5, server.Metrics["ratio"])
+	assert.EqualValues(1, server.Metrics["_sampling_priority_v1"])
+	assert.JSONEq(`[{"time_unix_nano":1581452772000500000,"name":"error","attributes":{"message":"boom"}}]`, server.Meta["events"])
+	_, ok := server.Meta["sampler.type"]
+	assert.False(ok)
+	_, ok = server.Meta["jaeger.version"]
+	assert.False(ok)
+
+	assert.Equal("jaeger.producer", producer.Name)
+	assert.Equal("queue", producer.Type)
+	assert.Equal("send orders", producer.Resource)
+	assert.Equal(uint64(3), producer.ParentID)
+	assert.EqualValues(2, producer.Metrics["_sampling_priority_v1"])
+	assert.JSONEq(`[{"trace_id":"00000000000000000000000000000005","span_id":"0000000000000006"}]`, producer.Meta["links"])
+}
+
+func TestJaegerReceiver(t *testing.T) {
+	assert := assert.New(t)
+	receiver := newTestReceiverFromConfig(newTestReceiverConfig())
+	handler := receiver.handleWithVersion(vJaegerThrift, receiver.handleJaeger)
+
+	rr := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/traces", bytes.NewReader(encodeJaegerBatch(jaegerTestBatch)))
+	req.Header.Set("Content-Type", "application/x-thrift")
+	handler.ServeHTTP(rr, req)
+	assert.Equal(http.StatusAccepted, rr.Code)
+
+	select {
+	case p := <-receiver.out:
+		assert.Len(p.Traces, 1)
+		assert.Equal("go", p.Source.Lang)
+	case <-time.After(time.Second):
+		t.Fatal("timed out")
+	}
+	ts, ok := receiver.Stats.Stats[info.Tags{Lang: "go", TracerVersion: "jaeger-2.29.1", EndpointVersion: "jaeger_thrift"}]
+	assert.True(ok)
+	assert.EqualValues(1, ts.TracesReceived)
+
+	rr = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/api/traces", bytes.NewReader([]byte{thriftStruct, 0}))
+	handler.ServeHTTP(rr, req)
+	assert.Equal(http.StatusBadRequest, rr.Code)
+}
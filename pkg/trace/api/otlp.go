@@ -6,8 +6,10 @@
 package api
 
 import (
+	"bytes"
 	"compress/gzip"
 	"context"
+	"encoding/base64"
 	"encoding/binary"
 	"encoding/hex"
 	"encoding/json"
@@ -31,6 +33,7 @@ import (
 	"github.com/DataDog/datadog-agent/pkg/trace/sampler"
 	"github.com/DataDog/datadog-agent/pkg/util/log"
 
+	"github.com/gogo/protobuf/jsonpb"
 	"github.com/gogo/protobuf/proto"
 	"go.opentelemetry.io/otel/semconv"
 	"google.golang.org/grpc"
@@ -42,6 +45,8 @@ const (
 	otlpProtocolHTTP = "http"
 	// otlpProtocolGRPC specifies that the incoming connection was made over gRPC.
 	otlpProtocolGRPC = "grpc"
+	// tagHostname specifies the span tag which holds the hostname of the span's origin.
+	tagHostname = "_dd.hostname"
 )
 
 // OTLPReceiver implements an OpenTelemetry Collector receiver which accepts incoming
@@ -52,6 +57,8 @@ type OTLPReceiver struct {
 	grpcsrv *grpc.Server    // the running GRPC server on a started receiver, if enabled
 	out     chan<- *Payload // the outgoing payload channel
 	cfg     *config.OTLP    // receiver config
+
+	serviceLimiter *serviceLimiter // drops traces per service, shared with the HTTP receiver
 }
 
 // NewOTLPReceiver returns a new OTLPReceiver which sends any incoming traces down the out channel.
@@ -158,7 +165,7 @@ func (o *OTLPReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	case "application/json":
 		fallthrough
 	default:
-		if err := json.Unmarshal(slurp, &in); err != nil {
+		if err := unmarshalJSONRequest(slurp, &in); err != nil {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			metrics.Count("datadog.trace_agent.otlp.error", 1, append(mtags, "reason:decode_json"), 1)
 			return
@@ -167,6 +174,59 @@ func (o *OTLPReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	o.processRequest(otlpProtocolHTTP, req.Header, &in)
 }
 
+// otlpJSONIDKeys holds the set of JSON keys which hold trace and span IDs in OTLP/HTTP JSON payloads,
+// both in their lowerCamelCase and original proto field names.
+var otlpJSONIDKeys = map[string]struct{}{
+	"traceId":        {},
+	"spanId":         {},
+	"parentSpanId":   {},
+	"trace_id":       {},
+	"span_id":        {},
+	"parent_span_id": {},
+}
+
+// unmarshalJSONRequest decodes the OTLP/HTTP JSON encoded data into in. As per the OTLP specification,
+// trace and span IDs are expected to be hex encoded, but base64 encoded IDs (as produced by the standard
+// protobuf JSON mapping) are also accepted.
+func unmarshalJSONRequest(data []byte, in *otlppb.ExportTraceServiceRequest) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return err
+	}
+	normalized, err := json.Marshal(hexIDsToBase64(v))
+	if err != nil {
+		return err
+	}
+	u := jsonpb.Unmarshaler{AllowUnknownFields: true}
+	return u.Unmarshal(bytes.NewReader(normalized), in)
+}
+
+// hexIDsToBase64 walks the decoded JSON value v and replaces any hex encoded trace or span IDs
+// with their base64 representation, as expected by the protobuf JSON mapping.
+func hexIDsToBase64(v interface{}) interface{} {
+	switch vv := v.(type) {
+	case map[string]interface{}:
+		for k, val := range vv {
+			if _, ok := otlpJSONIDKeys[k]; ok {
+				if str, ok := val.(string); ok && (len(str) == 16 || len(str) == 32) {
+					if b, err := hex.DecodeString(str); err == nil {
+						vv[k] = base64.StdEncoding.EncodeToString(b)
+						continue
+					}
+				}
+			}
+			vv[k] = hexIDsToBase64(val)
+		}
+	case []interface{}:
+		for i, val := range vv {
+			vv[i] = hexIDsToBase64(val)
+		}
+	}
+	return v
+}
+
 func tagsFromHeaders(h http.Header, protocol string) []string {
 	tags := []string{"endpoint_version:opentelemetry_" + protocol + "_v1"}
 	if v := fastHeaderGet(h, headerLang); v != "" {
@@ -203,8 +263,9 @@ func (o *OTLPReceiver) processRequest(protocol string, header http.Header, in *o
 	for _, rspans := range in.ResourceSpans {
 		// each rspans is coming from a different resource and should be considered
 		// a separate payload; typically there is only one item in this slice
-		rattr := make(map[string]string, len(rspans.Resource.Attributes))
-		for _, attr := range rspans.Resource.Attributes {
+		rattrs := rspans.GetResource().GetAttributes()
+		rattr := make(map[string]string, len(rattrs))
+		for _, attr := range rattrs {
 			rattr[attr.Key] = anyValueString(attr.Value)
 		}
 		lang := rattr[string(semconv.TelemetrySDKLanguageKey)]
@@ -220,10 +281,14 @@ func (o *OTLPReceiver) processRequest(protocol string, header http.Header, in *o
 				TracerVersion:   fmt.Sprintf("otlp-%s", rattr[string(semconv.TelemetrySDKVersionKey)]),
 				EndpointVersion: fmt.Sprintf("opentelemetry_%s_v1", protocol),
 			},
+			Stats: info.Stats{TracesDropped: &info.TracesDropped{}, SpansMalformed: &info.SpansMalformed{}},
 		}
 		tracesByID := make(map[uint64]pb.Trace)
 		for _, libspans := range rspans.InstrumentationLibrarySpans {
 			lib := libspans.InstrumentationLibrary
+			if lib == nil {
+				lib = &otlppb.InstrumentationLibrary{}
+			}
 			for _, span := range libspans.Spans {
 				traceID := byteArrayToUint64(span.TraceId)
 				if tracesByID[traceID] == nil {
@@ -235,14 +300,20 @@ func (o *OTLPReceiver) processRequest(protocol string, header http.Header, in *o
 		tags := tagstats.AsTags()
 		metrics.Count("datadog.trace_agent.otlp.spans", int64(len(rspans.InstrumentationLibrarySpans)), tags, 1)
 		metrics.Count("datadog.trace_agent.otlp.traces", int64(len(tracesByID)), tags, 1)
+		containerID := fastHeaderGet(header, headerContainerID)
+		if containerID == "" {
+			containerID = rattr[string(semconv.ContainerIDKey)]
+		}
 		p := Payload{
 			Source:        tagstats,
-			ContainerTags: getContainerTags(fastHeaderGet(header, headerContainerID)),
+			ContainerID:   containerID,
+			ContainerTags: getContainerTags(containerID),
 			Traces:        make(pb.Traces, 0, len(tracesByID)),
 		}
 		for _, trace := range tracesByID {
 			p.Traces = append(p.Traces, trace)
 		}
+		limitServices(o.serviceLimiter, &p)
 		o.out <- &p
 	}
 }
@@ -266,27 +337,16 @@ func marshalEvents(events []*otlppb.Span_Event) string {
 			if wrote {
 				str.WriteString(",")
 			}
-			str.WriteString(`"name":"`)
-			str.WriteString(v)
-			str.WriteString(`"`)
+			str.WriteString(`"name":`)
+			str.WriteString(jsonString(v))
 			wrote = true
 		}
 		if len(e.Attributes) > 0 {
 			if wrote {
 				str.WriteString(",")
 			}
-			str.WriteString(`"attributes":{`)
-			for j, kv := range e.Attributes {
-				if j > 0 {
-					str.WriteString(",")
-				}
-				str.WriteString(`"`)
-				str.WriteString(kv.Key)
-				str.WriteString(`":"`)
-				str.WriteString(anyValueString(kv.Value))
-				str.WriteString(`"`)
-			}
-			str.WriteString("}")
+			str.WriteString(`"attributes":`)
+			marshalAttributes(&str, e.Attributes)
 			wrote = true
 		}
 		if v := e.DroppedAttributesCount; v != 0 {
@@ -302,6 +362,61 @@ func marshalEvents(events []*otlppb.Span_Event) string {
 	return str.String()
 }
 
+// marshalLinks marshals span links into JSON.
+func marshalLinks(links []*otlppb.Span_Link) string {
+	var str strings.Builder
+	str.WriteString("[")
+	for i, l := range links {
+		if i > 0 {
+			str.WriteString(",")
+		}
+		str.WriteString(`{"trace_id":"`)
+		str.WriteString(hex.EncodeToString(l.TraceId))
+		str.WriteString(`","span_id":"`)
+		str.WriteString(hex.EncodeToString(l.SpanId))
+		str.WriteString(`"`)
+		if v := l.TraceState; v != "" {
+			str.WriteString(`,"trace_state":`)
+			str.WriteString(jsonString(v))
+		}
+		if len(l.Attributes) > 0 {
+			str.WriteString(`,"attributes":`)
+			marshalAttributes(&str, l.Attributes)
+		}
+		if v := l.DroppedAttributesCount; v != 0 {
+			str.WriteString(`,"dropped_attributes_count":`)
+			str.WriteString(strconv.FormatUint(uint64(v), 10))
+		}
+		str.WriteString("}")
+	}
+	str.WriteString("]")
+	return str.String()
+}
+
+// marshalAttributes writes the given attributes as a JSON object into str.
+func marshalAttributes(str *strings.Builder, attrs []*otlppb.KeyValue) {
+	str.WriteString("{")
+	for i, kv := range attrs {
+		if i > 0 {
+			str.WriteString(",")
+		}
+		str.WriteString(jsonString(kv.Key))
+		str.WriteString(":")
+		str.WriteString(jsonString(anyValueString(kv.Value)))
+	}
+	str.WriteString("}")
+}
+
+// jsonString returns s as a quoted and escaped JSON string.
+func jsonString(s string) string {
+	b, err := json.Marshal(s)
+	if err != nil {
+		// should never happen for a string
+		return `""`
+	}
+	return string(b)
+}
+
 // convertSpan converts the span in to a Datadog span, and uses the rattr resource tags and the lib instrumentation
 // library attributes to further augment it.
 func convertSpan(rattr map[string]string, lib *otlppb.InstrumentationLibrary, in *otlppb.Span) *pb.Span {
@@ -320,13 +435,17 @@ func convertSpan(rattr map[string]string, lib *otlppb.InstrumentationLibrary, in
 		Duration: int64(in.EndTimeUnixNano) - int64(in.StartTimeUnixNano),
 		Service:  rattr[string(semconv.ServiceNameKey)],
 		Resource: in.Name,
-		Meta:     rattr,
+		Meta:     make(map[string]string, len(rattr)+len(in.Attributes)),
 		Metrics: map[string]float64{
 			// auto-keep all incoming traces; it was already chosen as a keeper on
 			// the client side.
 			sampler.KeySamplingPriority: float64(sampler.PriorityAutoKeep),
 		},
 	}
+	for k, v := range rattr {
+		// each span gets its own copy of the resource attributes
+		span.Meta[k] = v
+	}
 	if features.Has("otlp_original_ids") {
 		// keep original IDs
 		span.Meta["otlp_ids.trace"] = hex.EncodeToString(in.TraceId)
@@ -341,8 +460,11 @@ func convertSpan(rattr map[string]string, lib *otlppb.InstrumentationLibrary, in
 	if len(in.Events) > 0 {
 		span.Meta["events"] = marshalEvents(in.Events)
 	}
+	if len(in.Links) > 0 {
+		span.Meta["links"] = marshalLinks(in.Links)
+	}
 	for _, kv := range in.Attributes {
-		switch v := kv.Value.Value.(type) {
+		switch v := kv.GetValue().GetValue().(type) {
 		case *otlppb.AnyValue_DoubleValue:
 			span.Metrics[kv.Key] = v.DoubleValue
 		case *otlppb.AnyValue_IntValue:
@@ -351,10 +473,9 @@ func convertSpan(rattr map[string]string, lib *otlppb.InstrumentationLibrary, in
 			span.Meta[kv.Key] = anyValueString(kv.Value)
 		}
 	}
-	if _, ok := span.Meta["env"]; !ok {
-		if env := span.Meta[string(semconv.DeploymentEnvironmentKey)]; env != "" {
-			span.Meta["env"] = env
-		}
+	if span.Service == "" {
+		// the span may carry its own service name
+		span.Service = span.Meta[string(semconv.ServiceNameKey)]
 	}
 	if in.TraceState != "" {
 		span.Meta["trace_state"] = in.TraceState
@@ -365,15 +486,43 @@ func convertSpan(rattr map[string]string, lib *otlppb.InstrumentationLibrary, in
 	if lib.Version != "" {
 		span.Meta["instrumentation_library.version"] = lib.Version
 	}
+	applySemanticConventions(in.Kind, span)
+	status2Error(in.Status, in.Events, span)
+	return span
+}
+
+// applySemanticConventions uses the OpenTelemetry semantic conventions found in the span's tags
+// to fill in the span's service, type, resource and the tags used for stats aggregation. The
+// given kind is the OpenTelemetry equivalent of the span's kind.
+func applySemanticConventions(kind otlppb.Span_SpanKind, span *pb.Span) {
+	if _, ok := span.Meta["env"]; !ok {
+		if env := span.Meta[string(semconv.DeploymentEnvironmentKey)]; env != "" {
+			span.Meta["env"] = env
+		}
+	}
+	if _, ok := span.Meta[tagHostname]; !ok {
+		if host := span.Meta[string(semconv.HostNameKey)]; host != "" {
+			span.Meta[tagHostname] = host
+		}
+	}
+	if code, ok := span.Metrics[string(semconv.HTTPStatusCodeKey)]; ok {
+		// the status code is expected as a tag by stats aggregation
+		span.Meta[string(semconv.HTTPStatusCodeKey)] = strconv.FormatFloat(code, 'f', -1, 64)
+		delete(span.Metrics, string(semconv.HTTPStatusCodeKey))
+	}
 	if svc := span.Meta[string(semconv.PeerServiceKey)]; svc != "" {
 		span.Service = svc
 	}
+	span.Type = spanKind2Type(kind, span)
 	if r := resourceFromTags(span.Meta); r != "" {
 		span.Resource = r
 	}
-	span.Type = spanKind2Type(in.Kind, span)
-	status2Error(in.Status, in.Events, span)
-	return span
+	if span.Type == "sql" || span.Type == "cassandra" {
+		if stmt := span.Meta[string(semconv.DBStatementKey)]; stmt != "" {
+			// the statement is obfuscated by the agent for these types
+			span.Resource = stmt
+		}
+	}
 }
 
 // resourceFromTags attempts to deduce a more accurate span resource from the given list of tags meta.
@@ -392,6 +541,16 @@ func resourceFromTags(meta map[string]string) string {
 		if dest := meta[string(semconv.MessagingDestinationKey)]; dest != "" {
 			r += " " + dest
 		}
+	} else if m := meta[string(semconv.RPCMethodKey)]; m != "" {
+		r = m
+		if svc := meta[string(semconv.RPCServiceKey)]; svc != "" {
+			r = svc + "/" + m
+		}
+	} else if m := meta[string(semconv.DBOperationKey)]; m != "" {
+		r = m
+		if name := meta[string(semconv.DBNameKey)]; name != "" {
+			r += " " + name
+		}
 	}
 	return r
 }
@@ -428,15 +587,17 @@ func spanKind2Type(kind otlppb.Span_SpanKind, span *pb.Span) string {
 		typ = "web"
 	case otlppb.Span_SPAN_KIND_CLIENT:
 		typ = "http"
-		db, ok := span.Meta[string(semconv.DBSystemKey)]
-		if !ok {
-			break
+		if db, ok := span.Meta[string(semconv.DBSystemKey)]; ok {
+			typ = dbSystem2Type(db)
+		} else if _, ok := span.Meta[string(semconv.MessagingSystemKey)]; ok {
+			typ = "queue"
+		} else if _, ok := span.Meta[string(semconv.RPCSystemKey)]; ok {
+			typ = "rpc"
 		}
-		switch db {
-		case "redis", "memcached":
-			typ = "cache"
-		default:
-			typ = "db"
+	case otlppb.Span_SPAN_KIND_PRODUCER, otlppb.Span_SPAN_KIND_CONSUMER:
+		typ = "custom"
+		if _, ok := span.Meta[string(semconv.MessagingSystemKey)]; ok {
+			typ = "queue"
 		}
 	default:
 		typ = "custom"
@@ -444,6 +605,39 @@ func spanKind2Type(kind otlppb.Span_SpanKind, span *pb.Span) string {
 	return typ
 }
 
+// sqlDBSystems holds the set of db.system values which identify SQL databases.
+var sqlDBSystems = map[string]struct{}{
+	"mssql":       {},
+	"mysql":       {},
+	"oracle":      {},
+	"db2":         {},
+	"postgresql":  {},
+	"redshift":    {},
+	"hive":        {},
+	"cloudscape":  {},
+	"hsqldb":      {},
+	"h2":          {},
+	"sqlite":      {},
+	"mariadb":     {},
+	"derby":       {},
+	"cockroachdb": {},
+	"other_sql":   {},
+}
+
+// dbSystem2Type returns the span type corresponding to the given db.system attribute value.
+func dbSystem2Type(db string) string {
+	switch db {
+	case "redis", "memcached":
+		return "cache"
+	case "cassandra":
+		return "cassandra"
+	}
+	if _, ok := sqlDBSystems[db]; ok {
+		return "sql"
+	}
+	return "db"
+}
+
 func byteArrayToUint64(b []byte) uint64 {
 	if len(b) < 8 {
 		return 0
@@ -453,6 +647,9 @@ func byteArrayToUint64(b []byte) uint64 {
 
 // anyValueString converts otlppb.AnyValue a to its string representation.
 func anyValueString(a *otlppb.AnyValue) string {
+	if a == nil {
+		return ""
+	}
 	switch v := a.Value.(type) {
 	case *otlppb.AnyValue_StringValue:
 		return v.StringValue
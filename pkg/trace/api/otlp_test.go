@@ -2,6 +2,7 @@ package api
 
 import (
 	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
 	"time"
@@ -160,6 +161,67 @@ func TestOTLPReceiver(t *testing.T) {
 		assert.NotNil(t, o.httpsrv)
 	})
 
+	t.Run("ServeHTTP/json", func(t *testing.T) {
+		out := make(chan *Payload, 1)
+		o := NewOTLPReceiver(out, &config.OTLP{MaxRequestBytes: 1024 * 1024})
+		req := httptest.NewRequest("POST", "/v1/traces", strings.NewReader(`{
+			"resourceSpans": [{
+				"resource": {"attributes": [{"key": "service.name", "value": {"stringValue": "pylons"}}]},
+				"instrumentationLibrarySpans": [{"spans": [{
+					"traceId": "72df520af2bde7a5240031ead750e5f3",
+					"spanId": "240031ead750e5f3",
+					"name": "op",
+					"kind": 2
+				}]}]
+			}]
+		}`))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		o.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		select {
+		case p := <-out:
+			assert.Len(t, p.Traces, 1)
+			span := p.Traces[0][0]
+			assert.Equal(t, "pylons", span.Service)
+			assert.Equal(t, uint64(0x240031ead750e5f3), span.TraceID)
+			assert.Equal(t, uint64(0x240031ead750e5f3), span.SpanID)
+		case <-time.After(time.Second):
+			t.Fatal("timed out")
+		}
+	})
+
+	t.Run("ServeHTTP/service-limited", func(t *testing.T) {
+		out := make(chan *Payload, 1)
+		o := NewOTLPReceiver(out, &config.OTLP{MaxRequestBytes: 1024 * 1024})
+		r := &HTTPReceiver{serviceLimiter: newServiceLimiter()}
+		r.serviceLimiter.rates = map[string]float64{"pylons": 0}
+		r.ShareServiceLimiter(o)
+		req := httptest.NewRequest("POST", "/v1/traces", strings.NewReader(`{
+			"resourceSpans": [{
+				"resource": {"attributes": [{"key": "service.name", "value": {"stringValue": "pylons"}}]},
+				"instrumentationLibrarySpans": [{"spans": [{
+					"traceId": "72df520af2bde7a5240031ead750e5f3",
+					"spanId": "240031ead750e5f3",
+					"name": "op",
+					"kind": 2
+				}]}]
+			}]
+		}`))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		o.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		select {
+		case p := <-out:
+			assert.Empty(t, p.Traces)
+			assert.EqualValues(t, 1, p.Source.TracesDropped.ServiceLimited)
+			assert.EqualValues(t, 1, r.serviceLimiter.counts["pylons"])
+		case <-time.After(time.Second):
+			t.Fatal("timed out")
+		}
+	})
+
 	t.Run("processRequest", func(t *testing.T) {
 		out := make(chan *Payload, 5)
 		o := NewOTLPReceiver(out, nil)
@@ -372,6 +434,22 @@ func TestOTLPHelpers(t *testing.T) {
 				meta: map[string]string{"messaging.operation": "DO", "messaging.destination": "OP"},
 				out:  "DO OP",
 			},
+			{
+				meta: map[string]string{"rpc.method": "Get"},
+				out:  "Get",
+			},
+			{
+				meta: map[string]string{"rpc.method": "Get", "rpc.service": "users.UserService"},
+				out:  "users.UserService/Get",
+			},
+			{
+				meta: map[string]string{"db.operation": "SELECT"},
+				out:  "SELECT",
+			},
+			{
+				meta: map[string]string{"db.operation": "SELECT", "db.name": "customers"},
+				out:  "SELECT customers",
+			},
 		} {
 			assert.Equal(t, tt.out, resourceFromTags(tt.meta))
 		}
@@ -406,6 +484,36 @@ func TestOTLPHelpers(t *testing.T) {
 				meta: map[string]string{"db.system": "other"},
 				out:  "db",
 			},
+			{
+				kind: otlppb.Span_SPAN_KIND_CLIENT,
+				meta: map[string]string{"db.system": "postgresql"},
+				out:  "sql",
+			},
+			{
+				kind: otlppb.Span_SPAN_KIND_CLIENT,
+				meta: map[string]string{"db.system": "cassandra"},
+				out:  "cassandra",
+			},
+			{
+				kind: otlppb.Span_SPAN_KIND_CLIENT,
+				meta: map[string]string{"messaging.system": "kafka"},
+				out:  "queue",
+			},
+			{
+				kind: otlppb.Span_SPAN_KIND_CLIENT,
+				meta: map[string]string{"rpc.system": "grpc"},
+				out:  "rpc",
+			},
+			{
+				kind: otlppb.Span_SPAN_KIND_PRODUCER,
+				meta: map[string]string{"messaging.system": "rabbitmq"},
+				out:  "queue",
+			},
+			{
+				kind: otlppb.Span_SPAN_KIND_CONSUMER,
+				meta: map[string]string{"messaging.system": "rabbitmq"},
+				out:  "queue",
+			},
 			{
 				kind: otlppb.Span_SPAN_KIND_PRODUCER,
 				out:  "custom",
@@ -438,6 +546,170 @@ func TestOTLPHelpers(t *testing.T) {
 	})
 }
 
+func TestOTLPUnmarshalJSON(t *testing.T) {
+	t.Run("hex", func(t *testing.T) {
+		assert := assert.New(t)
+		var in otlppb.ExportTraceServiceRequest
+		err := unmarshalJSONRequest([]byte(`{
+			"resourceSpans": [{
+				"resource": {
+					"attributes": [{"key": "service.name", "value": {"stringValue": "pylons"}}]
+				},
+				"instrumentationLibrarySpans": [{
+					"instrumentationLibrary": {"name": "lib"},
+					"spans": [{
+						"traceId": "72df520af2bde7a5240031ead750e5f3",
+						"spanId": "240031ead750e5f3",
+						"name": "GET /users",
+						"kind": 2,
+						"startTimeUnixNano": "1581452772000000321",
+						"endTimeUnixNano": 1581452773000000789,
+						"attributes": [
+							{"key": "http.status_code", "value": {"intValue": "200"}},
+							{"key": "ratio", "value": {"doubleValue": 0.5}}
+						],
+						"links": [{"traceId": "72df520af2bde7a5240031ead750e5f3", "spanId": "240031ead750e5f3"}],
+						"status": {"code": 2}
+					}]
+				}]
+			}]
+		}`), &in)
+		assert.NoError(err)
+		assert.Len(in.ResourceSpans, 1)
+		rspans := in.ResourceSpans[0]
+		assert.Equal("service.name", rspans.Resource.Attributes[0].Key)
+		assert.Equal("pylons", anyValueString(rspans.Resource.Attributes[0].Value))
+		span := rspans.InstrumentationLibrarySpans[0].Spans[0]
+		assert.Equal(otlpTestID128, span.TraceId)
+		assert.Equal(otlpTestID128[8:], span.SpanId)
+		assert.Equal(otlpTestID128, span.Links[0].TraceId)
+		assert.Equal(otlppb.Span_SPAN_KIND_SERVER, span.Kind)
+		assert.Equal(uint64(1581452772000000321), span.StartTimeUnixNano)
+		assert.Equal(uint64(1581452773000000789), span.EndTimeUnixNano)
+		assert.Equal(int64(200), span.Attributes[0].Value.GetIntValue())
+		assert.Equal(0.5, span.Attributes[1].Value.GetDoubleValue())
+		assert.Equal(otlppb.Status_STATUS_CODE_ERROR, span.Status.Code)
+	})
+
+	t.Run("base64", func(t *testing.T) {
+		var in otlppb.ExportTraceServiceRequest
+		err := unmarshalJSONRequest([]byte(`{"resource_spans": [{"instrumentation_library_spans": [{"spans": [{"trace_id": "ct9SCvK956UkADHq11Dl8w=="}]}]}]}`), &in)
+		assert.NoError(t, err)
+		assert.Equal(t, otlpTestID128, in.ResourceSpans[0].InstrumentationLibrarySpans[0].Spans[0].TraceId)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		var in otlppb.ExportTraceServiceRequest
+		assert.Error(t, unmarshalJSONRequest([]byte(`{"resourceSpans": [`), &in))
+		assert.Error(t, unmarshalJSONRequest([]byte(`{"resourceSpans": "abc"}`), &in))
+	})
+}
+
+func TestOTLPSemanticConventions(t *testing.T) {
+	lib := &otlppb.InstrumentationLibrary{Name: "otel"}
+	strAttr := func(k, v string) *otlppb.KeyValue {
+		return &otlppb.KeyValue{Key: k, Value: &otlppb.AnyValue{Value: &otlppb.AnyValue_StringValue{StringValue: v}}}
+	}
+
+	t.Run("stats", func(t *testing.T) {
+		assert := assert.New(t)
+		rattr := map[string]string{
+			"service.name":           "api",
+			"deployment.environment": "prod",
+			"host.name":              "host-a",
+		}
+		span := convertSpan(rattr, lib, &otlppb.Span{
+			Kind: otlppb.Span_SPAN_KIND_SERVER,
+			Attributes: []*otlppb.KeyValue{
+				strAttr("http.method", "GET"),
+				strAttr("http.route", "/users/:id"),
+				{Key: "http.status_code", Value: &otlppb.AnyValue{Value: &otlppb.AnyValue_IntValue{IntValue: 404}}},
+			},
+		})
+		assert.Equal("api", span.Service)
+		assert.Equal("GET /users/:id", span.Resource)
+		assert.Equal("web", span.Type)
+		assert.Equal("prod", span.Meta["env"])
+		assert.Equal("host-a", span.Meta["_dd.hostname"])
+		assert.Equal("404", span.Meta["http.status_code"])
+		_, ok := span.Metrics["http.status_code"]
+		assert.False(ok)
+		// the resource attributes must not be shared between spans
+		assert.NotContains(rattr, "env")
+	})
+
+	t.Run("sql", func(t *testing.T) {
+		span := convertSpan(map[string]string{"service.name": "api"}, lib, &otlppb.Span{
+			Name: "query",
+			Kind: otlppb.Span_SPAN_KIND_CLIENT,
+			Attributes: []*otlppb.KeyValue{
+				strAttr("db.system", "mysql"),
+				strAttr("db.operation", "SELECT"),
+				strAttr("db.statement", "SELECT * FROM users WHERE id = 42"),
+			},
+		})
+		assert.Equal(t, "sql", span.Type)
+		assert.Equal(t, "SELECT * FROM users WHERE id = 42", span.Resource)
+	})
+
+	t.Run("mongodb", func(t *testing.T) {
+		span := convertSpan(map[string]string{"service.name": "api"}, lib, &otlppb.Span{
+			Name: "find",
+			Kind: otlppb.Span_SPAN_KIND_CLIENT,
+			Attributes: []*otlppb.KeyValue{
+				strAttr("db.system", "mongodb"),
+				strAttr("db.operation", "find"),
+				strAttr("db.name", "users"),
+				strAttr("db.statement", `{"id": 42}`),
+			},
+		})
+		assert.Equal(t, "db", span.Type)
+		assert.Equal(t, "find users", span.Resource)
+	})
+
+	t.Run("rpc", func(t *testing.T) {
+		span := convertSpan(map[string]string{"service.name": "api"}, lib, &otlppb.Span{
+			Name: "call",
+			Kind: otlppb.Span_SPAN_KIND_CLIENT,
+			Attributes: []*otlppb.KeyValue{
+				strAttr("rpc.system", "grpc"),
+				strAttr("rpc.service", "users.UserService"),
+				strAttr("rpc.method", "Get"),
+			},
+		})
+		assert.Equal(t, "rpc", span.Type)
+		assert.Equal(t, "users.UserService/Get", span.Resource)
+	})
+
+	t.Run("messaging", func(t *testing.T) {
+		span := convertSpan(map[string]string{"service.name": "api"}, lib, &otlppb.Span{
+			Name: "send",
+			Kind: otlppb.Span_SPAN_KIND_PRODUCER,
+			Attributes: []*otlppb.KeyValue{
+				strAttr("messaging.system", "kafka"),
+				strAttr("messaging.operation", "send"),
+				strAttr("messaging.destination", "orders"),
+			},
+		})
+		assert.Equal(t, "queue", span.Type)
+		assert.Equal(t, "send orders", span.Resource)
+	})
+
+	t.Run("service", func(t *testing.T) {
+		span := convertSpan(map[string]string{}, lib, &otlppb.Span{
+			Attributes: []*otlppb.KeyValue{strAttr("service.name", "from-span")},
+		})
+		assert.Equal(t, "from-span", span.Service)
+	})
+
+	t.Run("links", func(t *testing.T) {
+		span := convertSpan(map[string]string{}, lib, &otlppb.Span{
+			Links: []*otlppb.Span_Link{{TraceId: otlpTestID128, SpanId: otlpTestID128[8:]}},
+		})
+		assert.JSONEq(t, `[{"trace_id":"72df520af2bde7a5240031ead750e5f3","span_id":"240031ead750e5f3"}]`, span.Meta["links"])
+	})
+}
+
 func TestOTLPConvertSpan(t *testing.T) {
 	now := uint64(time.Now().UnixNano())
 	for i, tt := range []struct {
@@ -673,6 +945,45 @@ func TestOTLPConvertSpan(t *testing.T) {
 	}
 }
 
+func TestMarshalLinks(t *testing.T) {
+	for _, tt := range []struct {
+		in  []*otlppb.Span_Link
+		out string
+	}{
+		{
+			in: []*otlppb.Span_Link{
+				{TraceId: otlpTestID128, SpanId: otlpTestID128[8:]},
+			},
+			out: `[{"trace_id":"72df520af2bde7a5240031ead750e5f3","span_id":"240031ead750e5f3"}]`,
+		}, {
+			in: []*otlppb.Span_Link{
+				{
+					TraceId:    otlpTestID128,
+					SpanId:     otlpTestID128[8:],
+					TraceState: "a=b",
+					Attributes: []*otlppb.KeyValue{
+						{Key: "message", Value: &otlppb.AnyValue{Value: &otlppb.AnyValue_StringValue{StringValue: `say "hi"`}}},
+					},
+					DroppedAttributesCount: 1,
+				},
+				{TraceId: otlpTestID128[8:], SpanId: otlpTestID128[8:]},
+			},
+			out: `[{
+					"trace_id":"72df520af2bde7a5240031ead750e5f3",
+					"span_id":"240031ead750e5f3",
+					"trace_state":"a=b",
+					"attributes":{"message":"say \"hi\""},
+					"dropped_attributes_count":1
+				}, {
+					"trace_id":"240031ead750e5f3",
+					"span_id":"240031ead750e5f3"
+				}]`,
+		},
+	} {
+		assert.JSONEq(t, tt.out, marshalLinks(tt.in))
+	}
+}
+
 func TestMarshalEvents(t *testing.T) {
 	for _, tt := range []struct {
 		in  []*otlppb.Span_Event
@@ -0,0 +1,178 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package api
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/pb"
+	"github.com/DataDog/datadog-agent/pkg/trace/sampler"
+	"github.com/DataDog/datadog-agent/pkg/trace/traceutil"
+)
+
+// serviceLimiter drops traces per root service when the agent is short on resources. Given a
+// global keep rate, it spreads the traces it is allowed to keep evenly across services:
+// services sending fewer traces than their fair share are kept entirely, and the remaining
+// budget is split between the higher volume ones. This way, a burst of traffic from a single
+// service doesn't result in dropping the traces of all the others.
+//
+// Similarly to the rateLimiter, volumes are tracked using counters which are decayed each
+// time the target rate is updated, so that older traffic has less impact on the rates.
+type serviceLimiter struct {
+	mu sync.RWMutex
+	// counts holds the recent number of traces seen for each root service.
+	counts map[string]float64
+	// rates holds the keep rate of each service being limited. It is nil when the
+	// limiter is not active.
+	rates map[string]float64
+	// targetRate is the global rate which rates was computed for.
+	targetRate float64
+	// decayFactor specifies the factor by which the counters are divided on each update.
+	decayFactor float64
+}
+
+// newServiceLimiter returns an initialized service limiter, keeping everything.
+func newServiceLimiter() *serviceLimiter {
+	return &serviceLimiter{
+		counts:      make(map[string]float64),
+		targetRate:  1,
+		decayFactor: 9.0 / 8.0,
+	}
+}
+
+// filter counts the traces by root service and removes those which should be dropped from
+// traces, which it returns along with the number of dropped traces. The rate of the kept
+// traces of limited services is added to their root's global sample rate, so that the stats
+// computed from them are weighted to account for the dropped ones. It is safe to call on a
+// nil limiter, which keeps everything.
+func (l *serviceLimiter) filter(traces pb.Traces) (pb.Traces, int64) {
+	if l == nil || len(traces) == 0 {
+		return traces, 0
+	}
+	roots := make([]*pb.Span, len(traces))
+	for i, t := range traces {
+		if len(t) > 0 {
+			roots[i] = traceutil.GetRoot(t)
+		}
+	}
+
+	l.mu.Lock()
+	for _, root := range roots {
+		if root != nil {
+			l.counts[root.Service]++
+		}
+	}
+	rates := l.rates
+	l.mu.Unlock()
+
+	if rates == nil {
+		return traces, 0
+	}
+	kept := traces[:0]
+	var dropped int64
+	for i, t := range traces {
+		root := roots[i]
+		if root == nil {
+			kept = append(kept, t)
+			continue
+		}
+		rate, ok := rates[root.Service]
+		if !ok {
+			kept = append(kept, t)
+			continue
+		}
+		if !sampler.SampleByRate(root.TraceID, rate) {
+			dropped++
+			continue
+		}
+		sampler.AddGlobalRate(root, rate)
+		kept = append(kept, t)
+	}
+	return kept, dropped
+}
+
+// TargetRate returns the global rate the limiter is currently aiming for.
+func (l *serviceLimiter) TargetRate() float64 {
+	if l == nil {
+		return 1
+	}
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.targetRate
+}
+
+// SetTargetRate computes the keep rate of each service so that rate of all recently seen
+// traces are kept overall, and decays the counters. A rate of 1 or more disables the limiter.
+func (l *serviceLimiter) SetTargetRate(rate float64) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if rate >= 1 {
+		l.targetRate = 1
+		l.rates = nil
+	} else {
+		l.targetRate = rate
+		l.rates = fairRates(l.counts, rate)
+	}
+	for s, n := range l.counts {
+		n /= l.decayFactor
+		if n < 1 {
+			// the service hasn't sent anything in a while
+			delete(l.counts, s)
+			continue
+		}
+		l.counts[s] = n
+	}
+}
+
+// Rates returns a copy of the keep rates of the services currently being limited.
+func (l *serviceLimiter) Rates() map[string]float64 {
+	if l == nil {
+		return nil
+	}
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if l.rates == nil {
+		return nil
+	}
+	rates := make(map[string]float64, len(l.rates))
+	for s, r := range l.rates {
+		rates[s] = r
+	}
+	return rates
+}
+
+// fairRates returns the keep rate of each of the services having the given counts, such that
+// the given rate of the total count is kept and the kept traces are split as evenly as possible
+// between services. Only services which have to be sampled, having a rate below 1, are returned.
+func fairRates(counts map[string]float64, rate float64) map[string]float64 {
+	services := make([]string, 0, len(counts))
+	var total float64
+	for s, n := range counts {
+		services = append(services, s)
+		total += n
+	}
+	sort.Slice(services, func(i, j int) bool { return counts[services[i]] < counts[services[j]] })
+
+	rates := make(map[string]float64)
+	budget := total * rate
+	for i, s := range services {
+		share := budget / float64(len(services)-i)
+		if n := counts[s]; n > share {
+			// all the remaining services are above their fair share, since they
+			// are sorted by count: cap them all to it.
+			for _, s := range services[i:] {
+				rates[s] = share / counts[s]
+			}
+			break
+		}
+		budget -= counts[s]
+	}
+	return rates
+}
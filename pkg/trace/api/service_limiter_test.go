@@ -0,0 +1,135 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package api
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/pb"
+	"github.com/DataDog/datadog-agent/pkg/trace/sampler"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFairRates(t *testing.T) {
+	for name, tt := range map[string]struct {
+		counts map[string]float64
+		rate   float64
+		want   map[string]float64
+	}{
+		"even": {
+			counts: map[string]float64{"a": 100, "b": 100},
+			rate:   0.5,
+			want:   map[string]float64{"a": 0.5, "b": 0.5},
+		},
+		"low-volume-kept": {
+			// 1000 traces, 500 to keep: a and b are below their fair share
+			counts: map[string]float64{"a": 10, "b": 90, "c": 900},
+			rate:   0.5,
+			want:   map[string]float64{"c": 400.0 / 900},
+		},
+		"cascade": {
+			// 200 to keep: a is kept, 190 are split between b and c
+			counts: map[string]float64{"a": 10, "b": 190, "c": 800},
+			rate:   0.2,
+			want:   map[string]float64{"b": 95.0 / 190, "c": 95.0 / 800},
+		},
+		"empty": {
+			counts: map[string]float64{},
+			rate:   0.5,
+			want:   map[string]float64{},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			got := fairRates(tt.counts, tt.rate)
+			assert.Len(t, got, len(tt.want))
+			for s, r := range tt.want {
+				assert.InDelta(t, r, got[s], 1e-9, s)
+			}
+		})
+	}
+}
+
+func TestServiceLimiter(t *testing.T) {
+	traces := func(counts map[string]int) pb.Traces {
+		var traces pb.Traces
+		for service, n := range counts {
+			for i := 0; i < n; i++ {
+				traces = append(traces, pb.Trace{{TraceID: rand.Uint64(), SpanID: 1, Service: service}})
+			}
+		}
+		return traces
+	}
+	countServices := func(traces pb.Traces) map[string]int {
+		counts := make(map[string]int)
+		for _, t := range traces {
+			counts[t[0].Service]++
+		}
+		return counts
+	}
+
+	t.Run("inactive", func(t *testing.T) {
+		l := newServiceLimiter()
+		in := traces(map[string]int{"web": 100})
+		out, dropped := l.filter(in)
+		assert.Len(t, out, 100)
+		assert.Zero(t, dropped)
+		assert.Equal(t, 100.0, l.counts["web"])
+		assert.Nil(t, l.Rates())
+	})
+
+	t.Run("limiting", func(t *testing.T) {
+		assert := assert.New(t)
+		l := newServiceLimiter()
+		l.filter(traces(map[string]int{"web": 9000, "db": 1000}))
+		l.SetTargetRate(0.5)
+		assert.Equal(0.5, l.TargetRate())
+		assert.InDelta(4000.0/9000, l.Rates()["web"], 1e-9)
+		assert.NotContains(l.Rates(), "db")
+		// counters were decayed
+		assert.InDelta(9000/l.decayFactor, l.counts["web"], 1e-9)
+
+		out, dropped := l.filter(traces(map[string]int{"web": 9000, "db": 1000}))
+		got := countServices(out)
+		assert.Equal(1000, got["db"])
+		assert.InDelta(4000, got["web"], 300)
+		assert.EqualValues(9000-got["web"], dropped)
+		for _, t := range out {
+			// the stats of the kept traces account for the dropped ones
+			if t[0].Service == "web" {
+				assert.InDelta(4000.0/9000, sampler.GetGlobalRate(t[0]), 1e-9)
+			} else {
+				assert.Equal(1.0, sampler.GetGlobalRate(t[0]))
+			}
+		}
+
+		l.SetTargetRate(1)
+		assert.Nil(l.Rates())
+		out, dropped = l.filter(traces(map[string]int{"web": 10}))
+		assert.Len(out, 10)
+		assert.Zero(dropped)
+	})
+
+	t.Run("expiry", func(t *testing.T) {
+		l := newServiceLimiter()
+		l.filter(traces(map[string]int{"web": 2}))
+		for i := 0; i < 10; i++ {
+			l.SetTargetRate(1)
+		}
+		assert.NotContains(t, l.counts, "web")
+	})
+
+	t.Run("nil", func(t *testing.T) {
+		var l *serviceLimiter
+		in := traces(map[string]int{"web": 3})
+		out, dropped := l.filter(in)
+		assert.Len(t, out, 3)
+		assert.Zero(t, dropped)
+		l.SetTargetRate(0.5)
+		assert.Equal(t, 1.0, l.TargetRate())
+		assert.Nil(t, l.Rates())
+	})
+}
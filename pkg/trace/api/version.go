@@ -69,4 +69,19 @@ const (
 	//
 	v05 Version = "v0.5"
 	v06 Version = "v0.6"
+
+	// vZipkinV2
+	//
+	// Content-Type: application/json or application/x-protobuf
+	// Payload: Zipkin v2 spans (https://zipkin.io/zipkin-api/#/default/post_spans).
+	// Response: 202 Accepted.
+	vZipkinV2 Version = "zipkin_v2"
+
+	// vJaegerThrift
+	//
+	// Content-Type: application/x-thrift
+	// Payload: A Jaeger Batch encoded using the Thrift binary protocol, as accepted by the
+	// Jaeger collector's /api/traces endpoint.
+	// Response: 202 Accepted.
+	vJaegerThrift Version = "jaeger_thrift"
 )
@@ -0,0 +1,180 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/api/apiutil"
+	"github.com/DataDog/datadog-agent/pkg/trace/info"
+	"github.com/DataDog/datadog-agent/pkg/trace/metrics"
+	"github.com/DataDog/datadog-agent/pkg/trace/pb"
+	"github.com/DataDog/datadog-agent/pkg/trace/pb/otlppb"
+	"github.com/DataDog/datadog-agent/pkg/trace/sampler"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+
+	zipkinmodel "github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/proto/zipkin_proto3"
+	"go.opentelemetry.io/otel/semconv"
+)
+
+// tagTraceIDHigh specifies the span tag which holds the hex encoded upper 64 bits of a 128-bit
+// trace ID, for spans coming from tracers which support them.
+const tagTraceIDHigh = "_dd.p.tid"
+
+// handleZipkin handles Zipkin v2 spans encoded as JSON or Protobuf.
+func (r *HTTPReceiver) handleZipkin(v Version, w http.ResponseWriter, req *http.Request) {
+	ts := r.tagStats(v, req.Header)
+	traces, err := decodeZipkinTraces(req, r.conf.MaxRequestBytes)
+	if err != nil {
+		httpDecodingError(err, []string{"handler:zipkin", "v:" + string(v)}, w)
+		log.Errorf("Cannot decode %s traces payload: %v", v, err)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+	r.receiveTraces(req, ts, traces)
+}
+
+// receiveTraces records the given traces as received from an external tracing format and
+// sends them down the receiver's output channel.
+func (r *HTTPReceiver) receiveTraces(req *http.Request, ts *info.TagStats, traces pb.Traces) {
+	var spans int64
+	for _, t := range traces {
+		spans += int64(len(t))
+	}
+	metrics.Count("datadog.trace_agent.receiver.external_spans", spans, ts.AsTags(), 1)
+
+	atomic.AddInt64(&ts.TracesReceived, int64(len(traces)))
+	if rd, ok := req.Body.(*apiutil.LimitedReader); ok {
+		atomic.AddInt64(&ts.TracesBytes, rd.Count)
+	}
+	atomic.AddInt64(&ts.PayloadAccepted, 1)
+
+	cid := req.Header.Get(headerContainerID)
+	r.sendPayload(&Payload{
+		Source:        ts,
+		Traces:        traces,
+		ContainerID:   cid,
+		ContainerTags: getContainerTags(cid),
+	})
+}
+
+// decodeZipkinTraces decodes the Zipkin v2 spans found in the body of req and groups
+// them into traces. The decompressed body is limited to limit bytes.
+func decodeZipkinTraces(req *http.Request, limit int64) (pb.Traces, error) {
+	body, err := readRequestBody(req, limit)
+	if err != nil {
+		return nil, err
+	}
+	var spans []*zipkinmodel.SpanModel
+	switch getMediaType(req) {
+	case "application/x-protobuf":
+		spans, err = zipkin_proto3.ParseSpans(body, false)
+	default:
+		err = json.Unmarshal(body, &spans)
+	}
+	if err != nil {
+		return nil, err
+	}
+	tracesByID := make(map[uint64]pb.Trace)
+	for _, s := range spans {
+		if s == nil {
+			continue
+		}
+		span := convertZipkinSpan(s)
+		tracesByID[span.TraceID] = append(tracesByID[span.TraceID], span)
+	}
+	traces := make(pb.Traces, 0, len(tracesByID))
+	for _, trace := range tracesByID {
+		traces = append(traces, trace)
+	}
+	return traces, nil
+}
+
+// zipkinKinds maps Zipkin span kinds to their OpenTelemetry equivalent.
+var zipkinKinds = map[zipkinmodel.Kind]otlppb.Span_SpanKind{
+	zipkinmodel.Server:   otlppb.Span_SPAN_KIND_SERVER,
+	zipkinmodel.Client:   otlppb.Span_SPAN_KIND_CLIENT,
+	zipkinmodel.Producer: otlppb.Span_SPAN_KIND_PRODUCER,
+	zipkinmodel.Consumer: otlppb.Span_SPAN_KIND_CONSUMER,
+}
+
+// convertZipkinSpan converts the Zipkin span in to a Datadog span.
+func convertZipkinSpan(in *zipkinmodel.SpanModel) *pb.Span {
+	kind, ok := zipkinKinds[in.Kind]
+	if !ok {
+		// spans without a kind are local spans
+		kind = otlppb.Span_SPAN_KIND_INTERNAL
+	}
+	priority := sampler.PriorityAutoKeep
+	if in.Debug {
+		priority = sampler.PriorityUserKeep
+	}
+	span := &pb.Span{
+		Name:     "zipkin." + spanKindName(kind),
+		TraceID:  in.TraceID.Low,
+		SpanID:   uint64(in.ID),
+		Start:    in.Timestamp.UnixNano(),
+		Duration: int64(in.Duration),
+		Resource: in.Name,
+		Meta:     make(map[string]string, len(in.Tags)+2),
+		Metrics: map[string]float64{
+			// sampling decisions are taken by Zipkin clients and unsampled spans
+			// are never reported.
+			sampler.KeySamplingPriority: float64(priority),
+		},
+	}
+	if in.Timestamp.IsZero() {
+		span.Start = 0
+	}
+	if in.TraceID.High != 0 {
+		span.Meta[tagTraceIDHigh] = strconv.FormatUint(in.TraceID.High, 16)
+	}
+	if in.ParentID != nil {
+		span.ParentID = uint64(*in.ParentID)
+	}
+	if ep := in.LocalEndpoint; ep != nil {
+		span.Service = ep.ServiceName
+	}
+	if ep := in.RemoteEndpoint; ep != nil {
+		if ep.ServiceName != "" {
+			span.Meta["peer.hostname"] = ep.ServiceName
+		}
+		if ep.IPv4 != nil {
+			span.Meta[string(semconv.NetPeerIPKey)] = ep.IPv4.String()
+		} else if ep.IPv6 != nil {
+			span.Meta[string(semconv.NetPeerIPKey)] = ep.IPv6.String()
+		}
+		if ep.Port != 0 {
+			span.Meta[string(semconv.NetPeerPortKey)] = strconv.Itoa(int(ep.Port))
+		}
+	}
+	for k, v := range in.Tags {
+		span.Meta[k] = v
+	}
+	if len(in.Annotations) > 0 {
+		events := make([]*otlppb.Span_Event, len(in.Annotations))
+		for i, a := range in.Annotations {
+			events[i] = &otlppb.Span_Event{
+				TimeUnixNano: uint64(a.Timestamp.UnixNano()),
+				Name:         a.Value,
+			}
+		}
+		span.Meta["events"] = marshalEvents(events)
+	}
+	if msg, ok := in.Tags["error"]; ok {
+		// Zipkin marks errors by setting the "error" tag, optionally to the error message
+		span.Error = 1
+		if msg != "" && msg != "true" {
+			span.Meta["error.msg"] = msg
+		}
+	}
+	applySemanticConventions(kind, span)
+	return span
+}
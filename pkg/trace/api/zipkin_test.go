@@ -0,0 +1,221 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package api
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/info"
+	"github.com/DataDog/datadog-agent/pkg/trace/pb"
+	zipkinmodel "github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/proto/zipkin_proto3"
+	"github.com/stretchr/testify/assert"
+)
+
+const zipkinTestJSON = `[
+  {
+    "traceId": "72df520af2bde7a5240031ead750e5f3",
+    "id": "240031ead750e5f3",
+    "kind": "SERVER",
+    "name": "get /users/{id}",
+    "timestamp": 1581452772000000,
+    "duration": 2000,
+    "localEndpoint": {"serviceName": "frontend", "ipv4": "192.168.99.1", "port": 3306},
+    "annotations": [{"timestamp": 1581452772000500, "value": "cache miss"}],
+    "tags": {"http.method": "GET", "http.route": "/users/{id}", "http.status_code": "500", "error": "timeout"}
+  },
+  {
+    "traceId": "72df520af2bde7a5240031ead750e5f3",
+    "parentId": "240031ead750e5f3",
+    "id": "0000000000000002",
+    "kind": "CLIENT",
+    "name": "query",
+    "timestamp": 1581452772000100,
+    "duration": 1000,
+    "localEndpoint": {"serviceName": "frontend"},
+    "remoteEndpoint": {"serviceName": "mysql", "ipv4": "10.0.0.1", "port": 3306},
+    "tags": {"db.system": "mysql", "db.statement": "SELECT * FROM users WHERE id = 1"}
+  }
+]`
+
+func TestZipkinReceiver(t *testing.T) {
+	t.Run("json", func(t *testing.T) {
+		assert := assert.New(t)
+		receiver := newTestReceiverFromConfig(newTestReceiverConfig())
+		handler := receiver.handleWithVersion(vZipkinV2, receiver.handleZipkin)
+
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/api/v2/spans", strings.NewReader(zipkinTestJSON))
+		req.Header.Set("Content-Type", "application/json")
+		handler.ServeHTTP(rr, req)
+		assert.Equal(http.StatusAccepted, rr.Code)
+
+		select {
+		case p := <-receiver.out:
+			assert.Len(p.Traces, 1)
+			assert.Len(p.Traces[0], 2)
+			assert.Equal("zipkin_v2", p.Source.EndpointVersion)
+		case <-time.After(time.Second):
+			t.Fatal("timed out")
+		}
+		ts, ok := receiver.Stats.Stats[info.Tags{EndpointVersion: "zipkin_v2"}]
+		assert.True(ok)
+		assert.EqualValues(1, ts.TracesReceived)
+		assert.EqualValues(len(zipkinTestJSON), ts.TracesBytes)
+	})
+
+	t.Run("proto", func(t *testing.T) {
+		assert := assert.New(t)
+		receiver := newTestReceiverFromConfig(newTestReceiverConfig())
+		handler := receiver.handleWithVersion(vZipkinV2, receiver.handleZipkin)
+
+		var spans []*zipkinmodel.SpanModel
+		assert.NoError(json.Unmarshal([]byte(zipkinTestJSON), &spans))
+		body, err := zipkin_proto3.SpanSerializer{}.Serialize(spans)
+		assert.NoError(err)
+
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/api/v2/spans", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/x-protobuf")
+		handler.ServeHTTP(rr, req)
+		assert.Equal(http.StatusAccepted, rr.Code)
+
+		select {
+		case p := <-receiver.out:
+			assert.Len(p.Traces, 1)
+			assert.Len(p.Traces[0], 2)
+		case <-time.After(time.Second):
+			t.Fatal("timed out")
+		}
+	})
+
+	t.Run("gzip", func(t *testing.T) {
+		assert := assert.New(t)
+		receiver := newTestReceiverFromConfig(newTestReceiverConfig())
+		handler := receiver.handleWithVersion(vZipkinV2, receiver.handleZipkin)
+
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		gz.Write([]byte(zipkinTestJSON))
+		gz.Close()
+
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/api/v2/spans", &buf)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Content-Encoding", "gzip")
+		handler.ServeHTTP(rr, req)
+		assert.Equal(http.StatusAccepted, rr.Code)
+		assert.Len(receiver.out, 1)
+	})
+
+	t.Run("gzip-limit", func(t *testing.T) {
+		assert := assert.New(t)
+		conf := newTestReceiverConfig()
+		conf.MaxRequestBytes = 100 << 10
+		receiver := newTestReceiverFromConfig(conf)
+		handler := receiver.handleWithVersion(vZipkinV2, receiver.handleZipkin)
+
+		// the compressed body is under the limit, the decompressed one is way over it
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		gz.Write([]byte(strings.Repeat(" ", 10<<20) + zipkinTestJSON))
+		gz.Close()
+		assert.True(int64(buf.Len()) < conf.MaxRequestBytes, buf.Len())
+
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/api/v2/spans", &buf)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Content-Encoding", "gzip")
+		handler.ServeHTTP(rr, req)
+		assert.Equal(http.StatusRequestEntityTooLarge, rr.Code)
+		assert.Len(receiver.out, 0)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		receiver := newTestReceiverFromConfig(newTestReceiverConfig())
+		handler := receiver.handleWithVersion(vZipkinV2, receiver.handleZipkin)
+
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/api/v2/spans", strings.NewReader(`[{"traceId": "xyz"}]`))
+		handler.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		assert.Len(t, receiver.out, 0)
+	})
+}
+
+func TestConvertZipkinSpan(t *testing.T) {
+	var spans []*zipkinmodel.SpanModel
+	assert.NoError(t, json.Unmarshal([]byte(zipkinTestJSON), &spans))
+
+	t.Run("server", func(t *testing.T) {
+		assert := assert.New(t)
+		span := convertZipkinSpan(spans[0])
+		assert.Equal(&pb.Span{
+			Service:  "frontend",
+			Name:     "zipkin.server",
+			Resource: "GET /users/{id}",
+			TraceID:  0x240031ead750e5f3,
+			SpanID:   0x240031ead750e5f3,
+			Start:    1581452772000000000,
+			Duration: 2000000,
+			Error:    1,
+			Type:     "web",
+			Meta: map[string]string{
+				"_dd.p.tid":        "72df520af2bde7a5",
+				"http.method":      "GET",
+				"http.route":       "/users/{id}",
+				"http.status_code": "500",
+				"error":            "timeout",
+				"error.msg":        "timeout",
+				"events":           `[{"time_unix_nano":1581452772000500000,"name":"cache miss"}]`,
+			},
+			Metrics: map[string]float64{"_sampling_priority_v1": 1},
+		}, span)
+	})
+
+	t.Run("client", func(t *testing.T) {
+		assert := assert.New(t)
+		span := convertZipkinSpan(spans[1])
+		assert.Equal("frontend", span.Service)
+		assert.Equal("zipkin.client", span.Name)
+		assert.Equal(uint64(0x240031ead750e5f3), span.ParentID)
+		assert.Equal(uint64(2), span.SpanID)
+		assert.Equal("sql", span.Type)
+		assert.Equal("SELECT * FROM users WHERE id = 1", span.Resource)
+		assert.Equal("mysql", span.Meta["peer.hostname"])
+		assert.Equal("10.0.0.1", span.Meta["net.peer.ip"])
+		assert.Equal("3306", span.Meta["net.peer.port"])
+		assert.EqualValues(0, span.Error)
+	})
+
+	t.Run("local", func(t *testing.T) {
+		assert := assert.New(t)
+		span := convertZipkinSpan(&zipkinmodel.SpanModel{
+			SpanContext: zipkinmodel.SpanContext{
+				TraceID: zipkinmodel.TraceID{Low: 1},
+				ID:      2,
+				Debug:   true,
+			},
+			Name:          "compute",
+			LocalEndpoint: &zipkinmodel.Endpoint{ServiceName: "worker", IPv4: net.ParseIP("127.0.0.1")},
+		})
+		assert.Equal("zipkin.internal", span.Name)
+		assert.Equal("custom", span.Type)
+		assert.Equal("compute", span.Resource)
+		assert.EqualValues(0, span.Start)
+		assert.EqualValues(2, span.Metrics["_sampling_priority_v1"])
+		_, ok := span.Meta["_dd.p.tid"]
+		assert.False(ok)
+	})
+}
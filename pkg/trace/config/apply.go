@@ -42,6 +42,24 @@ type OTLP struct {
 	MaxRequestBytes int64 `mapstructure:"-"`
 }
 
+// SpanMetricsConfig holds the configuration for generating metrics out of the
+// stats computed from traces.
+type SpanMetricsConfig struct {
+	// Enabled reports whether metrics should be sent to DogStatsD for each flushed stats bucket.
+	Enabled bool `mapstructure:"enabled"`
+
+	// Namespace specifies the prefix of the generated metric names.
+	Namespace string `mapstructure:"namespace"`
+
+	// Services restricts the generated metrics to the given services. All services are
+	// included when it is empty.
+	Services []string `mapstructure:"services"`
+
+	// IncludeResource specifies whether the metrics should be tagged with the resource name.
+	// Disabling it reduces the number of contexts when resources have a high cardinality.
+	IncludeResource bool `mapstructure:"include_resource"`
+}
+
 // ObfuscationConfig holds the configuration for obfuscating sensitive data
 // for various span types.
 type ObfuscationConfig struct {
@@ -309,6 +327,22 @@ func (c *AgentConfig) applyDatadogConfig() error {
 		}
 	}
 
+	if config.Datadog.IsSet("apm_config.span_metrics") {
+		if err := config.Datadog.UnmarshalKey("apm_config.span_metrics", c.SpanMetrics); err != nil {
+			log.Errorf("Error reading span metrics config: %v", err)
+		}
+	}
+	if config.Datadog.IsSet("apm_config.span_metrics.enabled") {
+		c.SpanMetrics.Enabled = config.Datadog.GetBool("apm_config.span_metrics.enabled")
+	}
+
+	if config.Datadog.GetBool("apm_config.debug_traces.enabled") {
+		c.DebugTraces = 1000
+		if config.Datadog.IsSet("apm_config.debug_traces.buffer_size") {
+			c.DebugTraces = config.Datadog.GetInt("apm_config.debug_traces.buffer_size")
+		}
+	}
+
 	// undocumented
 	if config.Datadog.IsSet("apm_config.max_cpu_percent") {
 		c.MaxCPU = config.Datadog.GetFloat64("apm_config.max_cpu_percent") / 100
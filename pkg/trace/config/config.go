@@ -131,6 +131,14 @@ type AgentConfig struct {
 
 	// OTLPReceiver holds the configuration for OpenTelemetry receiver.
 	OTLPReceiver *OTLP
+
+	// DebugTraces specifies the number of recently received traces for which the agent's
+	// decisions are kept in memory for debugging purposes. It is disabled when 0.
+	DebugTraces int
+
+	// SpanMetrics holds the configuration for generating DogStatsD metrics out of the
+	// computed trace stats.
+	SpanMetrics *SpanMetricsConfig
 }
 
 // Tag represents a key/value pair.
@@ -185,6 +193,7 @@ func New() *AgentConfig {
 
 		DDAgentBin:   defaultDDAgentBin,
 		OTLPReceiver: &OTLP{},
+		SpanMetrics:  &SpanMetricsConfig{Namespace: "trace.span_metrics", IncludeResource: true},
 	}
 }
 
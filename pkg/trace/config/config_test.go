@@ -267,6 +267,13 @@ func TestFullYamlConfig(t *testing.T) {
 
 	assert.ElementsMatch([]*Tag{{K: "env", V: "prod"}, {K: "db", V: "mongodb"}}, c.RequireTags)
 	assert.ElementsMatch([]*Tag{{K: "outcome", V: "success"}}, c.RejectTags)
+	assert.Equal(50, c.DebugTraces)
+	assert.Equal(&SpanMetricsConfig{
+		Enabled:         true,
+		Namespace:       "trace.span_metrics",
+		Services:        []string{"web", "db"},
+		IncludeResource: false,
+	}, c.SpanMetrics)
 
 	assert.ElementsMatch([]*ReplaceRule{
 		{
@@ -277,6 +277,18 @@ func TestLoadEnv(t *testing.T) {
 		assert.Equal(cfg.RejectTags, []*Tag{{K: "bad1", V: "value1"}})
 	})
 
+	env = "DD_APM_DEBUG_TRACES_BUFFER_SIZE"
+	t.Run(env, func(t *testing.T) {
+		defer cleanConfig()()
+		assert := assert.New(t)
+		err := os.Setenv(env, "25")
+		assert.NoError(err)
+		defer os.Unsetenv(env)
+		cfg, err := Load("./testdata/full.yaml")
+		assert.NoError(err)
+		assert.Equal(25, cfg.DebugTraces)
+	})
+
 	for _, envKey := range []string{
 		"DD_CONNECTION_LIMIT", // deprecated
 		"DD_APM_CONNECTION_LIMIT",
@@ -0,0 +1,241 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+// Package debugger keeps track of the decisions taken by the trace-agent for the most
+// recently received traces, so that users can find out why a given trace was or
+// wasn't sent to Datadog.
+package debugger
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/info"
+	"github.com/DataDog/datadog-agent/pkg/trace/pb"
+	"github.com/DataDog/datadog-agent/pkg/trace/traceutil"
+)
+
+const (
+	// FilterIgnoreResources is the filter reason used when a trace was rejected by
+	// the apm_config.ignore_resources setting.
+	FilterIgnoreResources = "ignore_resources"
+
+	// FilterTags is the filter reason used when a trace did not match the
+	// apm_config.filter_tags setting.
+	FilterTags = "filter_tags"
+)
+
+const (
+	// WriterQueued is the writer result of a trace which was sent to the trace writer.
+	WriterQueued = "queued"
+
+	// WriterEventsOnly is the writer result of a dropped trace from which APM events were
+	// extracted and sent to the trace writer.
+	WriterEventsOnly = "events_only"
+
+	// WriterNone is the writer result of a trace from which nothing was sent to the trace writer.
+	WriterNone = "none"
+)
+
+// Decision holds the decisions taken by the agent while processing a trace.
+type Decision struct {
+	TraceID  uint64    `json:"trace_id"`
+	Service  string    `json:"service"`
+	Name     string    `json:"name"`
+	Resource string    `json:"resource"`
+	Env      string    `json:"env,omitempty"`
+	Spans    int       `json:"spans"`
+	Received time.Time `json:"received"`
+
+	// Lang, TracerVersion and EndpointVersion describe the client which sent the trace.
+	Lang            string `json:"lang,omitempty"`
+	TracerVersion   string `json:"tracer_version,omitempty"`
+	EndpointVersion string `json:"endpoint_version,omitempty"`
+
+	// NormalizationError is set when the trace was dropped because it could not be normalized.
+	NormalizationError string `json:"normalization_error,omitempty"`
+
+	// Filter is set to the reason for which the trace was filtered out, if any.
+	Filter string `json:"filter,omitempty"`
+
+	// Priority holds the sampling priority of the trace, if it was set by the tracer.
+	Priority *int `json:"priority,omitempty"`
+
+	// Sampler and Rate specify the name of the sampler which took the final sampling
+	// decision and the rate it applied.
+	Sampler string  `json:"sampler,omitempty"`
+	Rate    float64 `json:"rate,omitempty"`
+	Kept    bool    `json:"kept"`
+
+	// Events holds the number of APM events extracted from the trace.
+	Events int `json:"events,omitempty"`
+
+	// Writer holds the outcome of sending the trace to the trace writer.
+	Writer string `json:"writer,omitempty"`
+}
+
+// SetNormalizationError records that the trace failed normalization with err.
+func (d *Decision) SetNormalizationError(err error) {
+	if d == nil {
+		return
+	}
+	d.NormalizationError = err.Error()
+	d.Writer = WriterNone
+}
+
+// SetRoot updates the decision with the values found on the normalized root span and
+// the environment of the trace.
+func (d *Decision) SetRoot(root *pb.Span, env string) {
+	if d == nil {
+		return
+	}
+	d.Service = root.Service
+	d.Name = root.Name
+	d.Resource = root.Resource
+	d.Env = env
+}
+
+// SetFiltered records that the trace was filtered out for the given reason.
+func (d *Decision) SetFiltered(reason string) {
+	if d == nil {
+		return
+	}
+	d.Filter = reason
+	d.Writer = WriterNone
+}
+
+// SetPriority records the sampling priority of the trace.
+func (d *Decision) SetPriority(priority int) {
+	if d == nil {
+		return
+	}
+	d.Priority = &priority
+}
+
+// SetSampling records the sampler which decided whether to keep the trace, along with
+// the rate it applied.
+func (d *Decision) SetSampling(sampler string, rate float64, kept bool) {
+	if d == nil {
+		return
+	}
+	d.Sampler = sampler
+	d.Rate = rate
+	d.Kept = kept
+}
+
+// SetWriter records what was sent to the trace writer for this trace, given the number
+// of extracted events.
+func (d *Decision) SetWriter(events int) {
+	if d == nil {
+		return
+	}
+	d.Events = events
+	switch {
+	case d.Kept:
+		d.Writer = WriterQueued
+	case events > 0:
+		d.Writer = WriterEventsOnly
+	default:
+		d.Writer = WriterNone
+	}
+}
+
+// Buffer is a bounded ring buffer holding the decisions taken for the most recently
+// received traces. A nil *Buffer is valid and discards everything.
+type Buffer struct {
+	mu        sync.RWMutex
+	decisions []*Decision
+	next      int  // index of the next insertion
+	full      bool // true once the buffer wrapped around
+}
+
+// NewBuffer returns a new Buffer able to hold the given number of decisions.
+func NewBuffer(size int) *Buffer {
+	if size <= 0 {
+		size = 1
+	}
+	return &Buffer{decisions: make([]*Decision, size)}
+}
+
+// NewDecision returns a new decision for the trace t coming from the client described by
+// ts. It returns nil when b is nil, which makes all of the decision's setters no-ops.
+func (b *Buffer) NewDecision(ts *info.TagStats, t pb.Trace) *Decision {
+	if b == nil || len(t) == 0 {
+		return nil
+	}
+	root := traceutil.GetRoot(t)
+	d := &Decision{
+		TraceID:  root.TraceID,
+		Service:  root.Service,
+		Name:     root.Name,
+		Resource: root.Resource,
+		Spans:    len(t),
+		Received: time.Now(),
+	}
+	if ts != nil {
+		d.Lang = ts.Lang
+		d.TracerVersion = ts.TracerVersion
+		d.EndpointVersion = ts.EndpointVersion
+	}
+	return d
+}
+
+// Record adds d to the buffer, evicting the oldest decision if the buffer is full.
+func (b *Buffer) Record(d *Decision) {
+	if b == nil || d == nil {
+		return
+	}
+	b.mu.Lock()
+	b.decisions[b.next] = d
+	b.next++
+	if b.next == len(b.decisions) {
+		b.next = 0
+		b.full = true
+	}
+	b.mu.Unlock()
+}
+
+// Query specifies a filter on the decisions held by a Buffer. Zero values match everything.
+type Query struct {
+	TraceID uint64
+	Service string
+	Limit   int
+}
+
+func (q Query) matches(d *Decision) bool {
+	if q.TraceID != 0 && d.TraceID != q.TraceID {
+		return false
+	}
+	if q.Service != "" && !strings.EqualFold(d.Service, q.Service) {
+		return false
+	}
+	return true
+}
+
+// Find returns copies of the decisions matching q, most recent first.
+func (b *Buffer) Find(q Query) []Decision {
+	if b == nil {
+		return nil
+	}
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	n := b.next
+	if b.full {
+		n = len(b.decisions)
+	}
+	var found []Decision
+	for i := 1; i <= n; i++ {
+		d := b.decisions[(b.next-i+len(b.decisions))%len(b.decisions)]
+		if !q.matches(d) {
+			continue
+		}
+		found = append(found, *d)
+		if q.Limit > 0 && len(found) == q.Limit {
+			break
+		}
+	}
+	return found
+}
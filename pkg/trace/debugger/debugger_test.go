@@ -0,0 +1,220 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package debugger
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/config"
+	"github.com/DataDog/datadog-agent/pkg/trace/info"
+	"github.com/DataDog/datadog-agent/pkg/trace/pb"
+	"github.com/stretchr/testify/assert"
+)
+
+func testDecision(b *Buffer, traceID uint64, service string) *Decision {
+	ts := info.NewReceiverStats().GetTagStats(info.Tags{Lang: "python", TracerVersion: "0.50", EndpointVersion: "v0.4"})
+	return b.NewDecision(ts, pb.Trace{{TraceID: traceID, SpanID: 1, Service: service, Name: "web.request", Resource: "GET /"}})
+}
+
+func TestBuffer(t *testing.T) {
+	t.Run("nil", func(t *testing.T) {
+		var b *Buffer
+		d := testDecision(b, 1, "web")
+		assert.Nil(t, d)
+		// none of these should panic
+		d.SetNormalizationError(errors.New("error"))
+		d.SetFiltered(FilterTags)
+		d.SetPriority(1)
+		d.SetSampling("priority", 1, true)
+		d.SetWriter(0)
+		b.Record(d)
+		assert.Nil(t, b.Find(Query{}))
+	})
+
+	t.Run("ring", func(t *testing.T) {
+		assert := assert.New(t)
+		b := NewBuffer(3)
+		assert.Empty(b.Find(Query{}))
+		for i := uint64(1); i <= 5; i++ {
+			b.Record(testDecision(b, i, "web"))
+		}
+		found := b.Find(Query{})
+		assert.Len(found, 3)
+		for i, id := range []uint64{5, 4, 3} {
+			assert.Equal(id, found[i].TraceID)
+		}
+	})
+
+	t.Run("query", func(t *testing.T) {
+		assert := assert.New(t)
+		b := NewBuffer(10)
+		for i := uint64(1); i <= 6; i++ {
+			service := "web"
+			if i%2 == 0 {
+				service = "db"
+			}
+			b.Record(testDecision(b, i, service))
+		}
+		assert.Len(b.Find(Query{Service: "DB"}), 3)
+		assert.Len(b.Find(Query{Service: "db", Limit: 2}), 2)
+		found := b.Find(Query{TraceID: 3})
+		assert.Len(found, 1)
+		assert.Equal("web", found[0].Service)
+		assert.Equal("python", found[0].Lang)
+		assert.Empty(b.Find(Query{TraceID: 3, Service: "db"}))
+	})
+}
+
+func TestDecision(t *testing.T) {
+	b := NewBuffer(1)
+	for name, tt := range map[string]struct {
+		apply  func(d *Decision)
+		writer string
+	}{
+		"normalization": {
+			apply:  func(d *Decision) { d.SetNormalizationError(errors.New("invalid")) },
+			writer: WriterNone,
+		},
+		"filtered": {
+			apply:  func(d *Decision) { d.SetFiltered(FilterIgnoreResources) },
+			writer: WriterNone,
+		},
+		"kept": {
+			apply: func(d *Decision) {
+				d.SetSampling("priority", 0.5, true)
+				d.SetWriter(1)
+			},
+			writer: WriterQueued,
+		},
+		"events-only": {
+			apply: func(d *Decision) {
+				d.SetSampling("no_priority", 0.1, false)
+				d.SetWriter(2)
+			},
+			writer: WriterEventsOnly,
+		},
+		"dropped": {
+			apply: func(d *Decision) {
+				d.SetSampling("errors", 0.1, false)
+				d.SetWriter(0)
+			},
+			writer: WriterNone,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			d := testDecision(b, 1, "web")
+			tt.apply(d)
+			assert.Equal(t, tt.writer, d.Writer)
+		})
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	t.Run("disabled", func(t *testing.T) {
+		var b *Buffer
+		rec := httptest.NewRecorder()
+		b.ServeHTTP(rec, httptest.NewRequest("GET", Path, nil))
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	b := NewBuffer(10)
+	for i := uint64(1); i <= 3; i++ {
+		b.Record(testDecision(b, i, "web"))
+	}
+	for name, tt := range map[string]struct {
+		query string
+		code  int
+		ids   []uint64
+	}{
+		"all":        {query: "", code: http.StatusOK, ids: []uint64{3, 2, 1}},
+		"trace_id":   {query: "?trace_id=2", code: http.StatusOK, ids: []uint64{2}},
+		"limit":      {query: "?service=web&limit=1", code: http.StatusOK, ids: []uint64{3}},
+		"none":       {query: "?service=db", code: http.StatusOK, ids: []uint64{}},
+		"bad-id":     {query: "?trace_id=abc", code: http.StatusBadRequest},
+		"bad-limit":  {query: "?limit=-1", code: http.StatusBadRequest},
+		"hex-id":     {query: "?trace_id=0x1", code: http.StatusBadRequest},
+		"zero-limit": {query: "?limit=0", code: http.StatusOK, ids: []uint64{3, 2, 1}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			b.ServeHTTP(rec, httptest.NewRequest("GET", Path+tt.query, nil))
+			assert.Equal(t, tt.code, rec.Code)
+			if tt.code != http.StatusOK {
+				return
+			}
+			var decisions []Decision
+			assert.NoError(t, json.NewDecoder(rec.Body).Decode(&decisions))
+			ids := make([]uint64, len(decisions))
+			for i, d := range decisions {
+				ids[i] = d.TraceID
+			}
+			assert.Equal(t, tt.ids, ids)
+		})
+	}
+}
+
+func TestDebug(t *testing.T) {
+	b := NewBuffer(10)
+	kept := testDecision(b, 1, "web")
+	kept.SetRoot(&pb.Span{Service: "web", Name: "web.request", Resource: "GET /"}, "prod")
+	kept.SetPriority(1)
+	kept.SetSampling("priority", 0.25, true)
+	kept.SetWriter(0)
+	b.Record(kept)
+	filtered := testDecision(b, 2, "db")
+	filtered.SetFiltered(FilterTags)
+	b.Record(filtered)
+
+	srv := httptest.NewServer(b)
+	defer srv.Close()
+	host, port, err := net.SplitHostPort(srv.Listener.Addr().String())
+	assert.NoError(t, err)
+	conf := config.New()
+	conf.ReceiverHost = host
+	conf.ReceiverPort, _ = strconv.Atoi(port)
+
+	t.Run("text", func(t *testing.T) {
+		var out bytes.Buffer
+		assert.NoError(t, Debug(&out, conf, []string{"-service", "web"}))
+		assert.Contains(t, out.String(), "Trace 1\n")
+		assert.Contains(t, out.String(), `service="web" name="web.request" resource="GET /" env="prod"`)
+		assert.Contains(t, out.String(), "priority (rate 0.25): kept")
+		assert.Contains(t, out.String(), "python 0.50 v0.4")
+		assert.NotContains(t, out.String(), "Trace 2\n")
+	})
+
+	t.Run("filtered", func(t *testing.T) {
+		var out bytes.Buffer
+		assert.NoError(t, Debug(&out, conf, []string{"-trace_id", "2"}))
+		assert.Contains(t, out.String(), "rejected by filter_tags")
+	})
+
+	t.Run("json", func(t *testing.T) {
+		var out bytes.Buffer
+		assert.NoError(t, Debug(&out, conf, []string{"-json", "-limit", "0"}))
+		var decisions []Decision
+		assert.NoError(t, json.Unmarshal(out.Bytes(), &decisions))
+		assert.Len(t, decisions, 2)
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		var out bytes.Buffer
+		assert.NoError(t, Debug(&out, conf, []string{"-service", "cache"}))
+		assert.Equal(t, "No matching traces found.\n", out.String())
+	})
+
+	t.Run("error", func(t *testing.T) {
+		var out bytes.Buffer
+		assert.Error(t, Debug(&out, conf, []string{"-trace_id", "abc"}))
+	})
+}
@@ -0,0 +1,162 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package debugger
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/config"
+)
+
+// Path is the path of the receiver endpoint serving the decisions.
+const Path = "/debug/traces"
+
+// defaultLimit specifies the maximum number of decisions returned when no limit is given.
+const defaultLimit = 100
+
+// ServeHTTP implements http.Handler. It replies with the decisions matching the trace_id,
+// service and limit query string parameters, encoded as JSON.
+func (b *Buffer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if b == nil {
+		http.Error(w, "trace debugging is disabled, set apm_config.debug_traces.enabled to true to enable it", http.StatusNotFound)
+		return
+	}
+	q, err := parseQuery(req.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	decisions := b.Find(q)
+	if decisions == nil {
+		decisions = []Decision{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(decisions); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func parseQuery(v url.Values) (Query, error) {
+	q := Query{Service: v.Get("service"), Limit: defaultLimit}
+	if id := v.Get("trace_id"); id != "" {
+		n, err := strconv.ParseUint(id, 10, 64)
+		if err != nil {
+			return q, fmt.Errorf("invalid trace_id %q: must be an unsigned decimal integer", id)
+		}
+		q.TraceID = n
+	}
+	if limit := v.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 0 {
+			return q, fmt.Errorf("invalid limit %q: must be a positive integer", limit)
+		}
+		q.Limit = n
+	}
+	return q, nil
+}
+
+// Debug implements the "trace-agent debug" command. It parses args, queries the
+// decisions of the trace-agent running with the given configuration and prints them to w.
+func Debug(w io.Writer, conf *config.AgentConfig, args []string) error {
+	fs := flag.NewFlagSet("debug", flag.ContinueOnError)
+	fs.SetOutput(w)
+	traceID := fs.String("trace_id", "", "Only show the trace with the given ID")
+	service := fs.String("service", "", "Only show traces having the given root service")
+	limit := fs.Int("limit", 20, "Maximum number of traces to show, 0 for all")
+	asJSON := fs.Bool("json", false, "Print the raw JSON response")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	v := url.Values{}
+	if *traceID != "" {
+		v.Set("trace_id", *traceID)
+	}
+	if *service != "" {
+		v.Set("service", *service)
+	}
+	v.Set("limit", strconv.Itoa(*limit))
+	u := fmt.Sprintf("http://%s:%d%s?%s", conf.ReceiverHost, conf.ReceiverPort, Path, v.Encode())
+
+	client := http.Client{Timeout: 3 * time.Second}
+	resp, err := client.Get(u)
+	if err != nil {
+		return fmt.Errorf("could not reach the trace-agent on port %d, is it running? %v", conf.ReceiverPort, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	if *asJSON {
+		_, err := io.Copy(w, resp.Body)
+		return err
+	}
+	var decisions []Decision
+	if err := json.NewDecoder(resp.Body).Decode(&decisions); err != nil {
+		return fmt.Errorf("error decoding response from %s: %v", u, err)
+	}
+	return printDecisions(w, decisions)
+}
+
+// printDecisions writes a human readable representation of decisions to w.
+func printDecisions(w io.Writer, decisions []Decision) error {
+	if len(decisions) == 0 {
+		_, err := fmt.Fprintln(w, "No matching traces found.")
+		return err
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, d := range decisions {
+		fmt.Fprintf(tw, "Trace %d\n", d.TraceID)
+		fmt.Fprintf(tw, "  Received:\t%s (%d spans, %s)\n", d.Received.Format(time.RFC3339), d.Spans, describeClient(d))
+		fmt.Fprintf(tw, "  Root:\tservice=%q name=%q resource=%q env=%q\n", d.Service, d.Name, d.Resource, d.Env)
+		switch {
+		case d.NormalizationError != "":
+			fmt.Fprintf(tw, "  Normalization:\tdropped: %s\n", d.NormalizationError)
+		case d.Filter != "":
+			fmt.Fprintf(tw, "  Filter:\trejected by %s\n", d.Filter)
+		default:
+			if d.Priority != nil {
+				fmt.Fprintf(tw, "  Priority:\t%d\n", *d.Priority)
+			}
+			verdict := "dropped"
+			if d.Kept {
+				verdict = "kept"
+			}
+			if d.Sampler != "" {
+				fmt.Fprintf(tw, "  Sampler:\t%s (rate %g): %s\n", d.Sampler, d.Rate, verdict)
+			} else {
+				fmt.Fprintf(tw, "  Sampler:\t%s\n", verdict)
+			}
+			fmt.Fprintf(tw, "  Events:\t%d\n", d.Events)
+		}
+		fmt.Fprintf(tw, "  Writer:\t%s\n\n", d.Writer)
+	}
+	return tw.Flush()
+}
+
+// describeClient returns a description of the client which sent the trace of d.
+func describeClient(d Decision) string {
+	parts := make([]string, 0, 3)
+	for _, p := range []string{d.Lang, d.TracerVersion, d.EndpointVersion} {
+		if p != "" {
+			parts = append(parts, p)
+		}
+	}
+	if len(parts) == 0 {
+		return "unknown client"
+	}
+	return strings.Join(parts, " ")
+}
@@ -37,6 +37,7 @@ var (
 	watchdogInfo     watchdog.Info
 	rateByService    map[string]float64
 	rateLimiterStats RateLimiterStats
+	degradationInfo  DegradationInfo
 	start            = time.Now()
 	once             sync.Once
 	infoTmpl         *template.Template
@@ -77,6 +78,12 @@ const (
   {{if lt .Status.RateLimiter.TargetRate 1.0}}
   WARNING: Rate-limiter keep percentage: {{percent .Status.RateLimiter.TargetRate}} %
   {{end}}
+  {{if gt .Status.Degradation.Level 0}}
+  WARNING: Resource usage at {{percent .Status.Degradation.Usage}} % of limits, degradation steps in effect: {{join .Status.Degradation.InEffect ", "}}
+  {{ range $key, $value := .Status.Degradation.RateByService }}
+  Resource limiting keep rate for '{{ $key }}': {{percent $value}} %
+  {{ end }}
+  {{end}}
 
   --- Writer stats (1 min) ---
 
@@ -188,6 +195,34 @@ func publishRateLimiterStats() interface{} {
 	return rateLimiterStats
 }
 
+// DegradationInfo describes the step of the degradation ladder reached by the agent
+// because of its resource usage.
+type DegradationInfo struct {
+	// Step is the name of the current step, and Level its position on the ladder,
+	// 0 meaning that all features are enabled.
+	Step  string
+	Level int
+	// Usage is the highest ratio of used to allowed resources (CPU or memory).
+	Usage float64
+	// InEffect holds the names of all the steps in effect, up to Step.
+	InEffect []string
+	// RateByService holds the keep rate of the services being limited by the receiver.
+	RateByService map[string]float64
+}
+
+// UpdateDegradation updates the internal state of the degradation ladder.
+func UpdateDegradation(di DegradationInfo) {
+	infoMu.Lock()
+	defer infoMu.Unlock()
+	degradationInfo = di
+}
+
+func publishDegradation() interface{} {
+	infoMu.RLock()
+	defer infoMu.RUnlock()
+	return degradationInfo
+}
+
 func publishUptime() interface{} {
 	return int(time.Since(start) / time.Second)
 }
@@ -204,6 +239,7 @@ func InitInfo(conf *config.AgentConfig) error {
 		"add": func(a, b int64) int64 {
 			return a + b
 		},
+		"join": strings.Join,
 		"percent": func(v float64) string {
 			return fmt.Sprintf("%02.1f", v*100)
 		},
@@ -219,6 +255,7 @@ func InitInfo(conf *config.AgentConfig) error {
 		expvar.Publish("ratebyservice", expvar.Func(publishRateByService))
 		expvar.Publish("watchdog", expvar.Func(publishWatchdogInfo))
 		expvar.Publish("ratelimiter", expvar.Func(publishRateLimiterStats))
+		expvar.Publish("degradation", expvar.Func(publishDegradation))
 
 		// copy the config to ensure we don't expose sensitive data such as API keys
 		c := *conf
@@ -276,6 +313,7 @@ type StatusInfo struct {
 	StatsWriter   StatsWriterInfo    `json:"stats_writer"`
 	Watchdog      watchdog.Info      `json:"watchdog"`
 	RateLimiter   RateLimiterStats   `json:"ratelimiter"`
+	Degradation   DegradationInfo    `json:"degradation"`
 	Config        config.AgentConfig `json:"config"`
 }
 
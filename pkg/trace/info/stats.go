@@ -218,6 +218,9 @@ type TracesDropped struct {
 	// EOF is when an unexpected EOF is encountered, this can happen because the client has aborted
 	// or because a bad payload (i.e. shorter than claimed in Content-Length) was sent.
 	EOF int64
+	// ServiceLimited is when a trace is dropped by the receiver to keep resource usage within
+	// the configured limits, based on the volume of its root service.
+	ServiceLimited int64
 }
 
 // tagValues converts TracesDropped into a map representation with keys matching standardized names for all reasons
@@ -231,6 +234,7 @@ func (s *TracesDropped) tagValues() map[string]int64 {
 		"foreign_span":      atomic.LoadInt64(&s.ForeignSpan),
 		"timeout":           atomic.LoadInt64(&s.Timeout),
 		"unexpected_eof":    atomic.LoadInt64(&s.EOF),
+		"service_limited":   atomic.LoadInt64(&s.ServiceLimited),
 	}
 }
 
@@ -339,6 +343,7 @@ func (s *Stats) update(recent *Stats) {
 	atomic.AddInt64(&s.TracesDropped.TraceIDZero, atomic.LoadInt64(&recent.TracesDropped.TraceIDZero))
 	atomic.AddInt64(&s.TracesDropped.SpanIDZero, atomic.LoadInt64(&recent.TracesDropped.SpanIDZero))
 	atomic.AddInt64(&s.TracesDropped.ForeignSpan, atomic.LoadInt64(&recent.TracesDropped.ForeignSpan))
+	atomic.AddInt64(&s.TracesDropped.ServiceLimited, atomic.LoadInt64(&recent.TracesDropped.ServiceLimited))
 	atomic.AddInt64(&s.SpansMalformed.DuplicateSpanID, atomic.LoadInt64(&recent.SpansMalformed.DuplicateSpanID))
 	atomic.AddInt64(&s.SpansMalformed.ServiceEmpty, atomic.LoadInt64(&recent.SpansMalformed.ServiceEmpty))
 	atomic.AddInt64(&s.SpansMalformed.ServiceTruncate, atomic.LoadInt64(&recent.SpansMalformed.ServiceTruncate))
@@ -379,6 +384,7 @@ func (s *Stats) reset() {
 	atomic.StoreInt64(&s.TracesDropped.ForeignSpan, 0)
 	atomic.StoreInt64(&s.TracesDropped.Timeout, 0)
 	atomic.StoreInt64(&s.TracesDropped.EOF, 0)
+	atomic.StoreInt64(&s.TracesDropped.ServiceLimited, 0)
 	atomic.StoreInt64(&s.SpansMalformed.DuplicateSpanID, 0)
 	atomic.StoreInt64(&s.SpansMalformed.ServiceEmpty, 0)
 	atomic.StoreInt64(&s.SpansMalformed.ServiceTruncate, 0)
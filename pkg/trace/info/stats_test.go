@@ -29,6 +29,7 @@ func TestTracesDropped(t *testing.T) {
 			"span_id_zero":      1,
 			"timeout":           0,
 			"unexpected_eof":    0,
+			"service_limited":   0,
 		}, s.tagValues())
 	})
 
@@ -12,6 +12,8 @@ package metrics
 import (
 	"errors"
 	"fmt"
+	"net"
+	"strings"
 
 	mainconfig "github.com/DataDog/datadog-agent/pkg/config"
 	"github.com/DataDog/datadog-agent/pkg/trace/config"
@@ -50,3 +52,20 @@ func Configure(conf *config.AgentConfig, tags []string) error {
 	Client = client
 	return nil
 }
+
+// Dial opens a connection to the Dogstatsd server found in the given agent's configuration,
+// allowing to write raw Dogstatsd datagrams to it.
+func Dial(conf *config.AgentConfig) (net.Conn, error) {
+	addr, err := findAddr(conf)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case strings.HasPrefix(addr, "unix://"):
+		return net.Dial("unixgram", strings.TrimPrefix(addr, "unix://"))
+	case strings.HasPrefix(addr, `\\.\pipe\`):
+		return nil, fmt.Errorf("unsupported Dogstatsd address %q: named pipes can not be dialed directly", addr)
+	default:
+		return net.Dial("udp", addr)
+	}
+}
@@ -8,6 +8,8 @@
 package metrics
 
 import (
+	"errors"
+	"net"
 	"os"
 	"strconv"
 	"sync"
@@ -34,6 +36,11 @@ func Configure(cfg *config.AgentConfig, tags []string) error {
 	return nil
 }
 
+// Dial is not supported in benchmarking mode.
+func Dial(_ *config.AgentConfig) (net.Conn, error) {
+	return nil, errors.New("raw Dogstatsd connections are not available in benchmarking mode")
+}
+
 type captureClient struct {
 	mu sync.Mutex // guards f
 	f  *os.File
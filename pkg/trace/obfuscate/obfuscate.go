@@ -33,6 +33,8 @@ type Obfuscator struct {
 	sqlLiteralEscapes int32
 	// queryCache keeps a cache of already obfuscated queries.
 	queryCache *measuredCache
+	// queryCacheOff reports whether queryCache should be bypassed. A non-zero value means 'yes'.
+	queryCacheOff int32
 }
 
 // SQLOptions holds options that change the behavior of the obfuscator for SQL.
@@ -56,6 +58,21 @@ func (o *Obfuscator) SQLLiteralEscapes() bool {
 	return atomic.LoadInt32(&o.sqlLiteralEscapes) == 1
 }
 
+// SetQueryCache enables or disables the cache of obfuscated SQL queries. Disabling it stops
+// the cache from growing, at the cost of obfuscating every query again.
+func (o *Obfuscator) SetQueryCache(enabled bool) {
+	if enabled {
+		atomic.StoreInt32(&o.queryCacheOff, 0)
+	} else {
+		atomic.StoreInt32(&o.queryCacheOff, 1)
+	}
+}
+
+// queryCacheEnabled reports whether the cache of obfuscated SQL queries should be used.
+func (o *Obfuscator) queryCacheEnabled() bool {
+	return atomic.LoadInt32(&o.queryCacheOff) == 0
+}
+
 // NewObfuscator creates a new obfuscator
 func NewObfuscator(cfg *config.ObfuscationConfig) *Obfuscator {
 	if cfg == nil {
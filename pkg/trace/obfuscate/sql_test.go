@@ -83,6 +83,26 @@ func TestKeepSQLAlias(t *testing.T) {
 	})
 }
 
+func TestSQLQueryCache(t *testing.T) {
+	defer testutil.WithFeatures("sql_cache")()
+	q := `SELECT * FROM users WHERE id=4`
+	o := NewObfuscator(nil)
+	defer o.Stop()
+
+	oq, err := o.ObfuscateSQLString(q)
+	assert.NoError(t, err)
+	o.queryCache.Wait()
+	cached, err := o.ObfuscateSQLString(q)
+	assert.NoError(t, err)
+	assert.True(t, oq == cached, "expected the cached query")
+
+	o.SetQueryCache(false)
+	uncached, err := o.ObfuscateSQLString(q)
+	assert.NoError(t, err)
+	assert.False(t, oq == uncached, "expected the cache to be bypassed")
+	assert.Equal(t, oq.Query, uncached.Query)
+}
+
 func TestDollarQuotedFunc(t *testing.T) {
 	q := `SELECT $func$INSERT INTO table VALUES ('a', 1, 2)$func$ FROM users`
 
@@ -129,6 +129,17 @@ func (s *PrioritySampler) Sample(trace pb.Trace, root *pb.Span, env string, clie
 	return sampled
 }
 
+// Rate returns the priority sampling rate stored on the given root span by the tracer or
+// by the sampler, or 1 if there is none.
+func (s *PrioritySampler) Rate(root *pb.Span) float64 {
+	for _, k := range []string{agentRateKey, ruleRateKey, deprecatedRateKey} {
+		if rate, ok := getMetric(root, k); ok {
+			return rate
+		}
+	}
+	return 1
+}
+
 // CountClientDroppedP0s counts client dropped traces. They are added
 // to the totalScore, allowing them to weight on sampling rates during
 // adjust calls
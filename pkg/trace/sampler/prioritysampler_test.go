@@ -147,6 +147,22 @@ func TestPrioritySampleThresholdTo1(t *testing.T) {
 	}
 }
 
+func TestPrioritySamplerRate(t *testing.T) {
+	assert := assert.New(t)
+	s := getTestPrioritySampler()
+	for _, tt := range []struct {
+		metrics map[string]float64
+		rate    float64
+	}{
+		{metrics: nil, rate: 1},
+		{metrics: map[string]float64{deprecatedRateKey: 0.3}, rate: 0.3},
+		{metrics: map[string]float64{ruleRateKey: 0.2, deprecatedRateKey: 0.3}, rate: 0.2},
+		{metrics: map[string]float64{agentRateKey: 0.1, ruleRateKey: 0.2}, rate: 0.1},
+	} {
+		assert.Equal(tt.rate, s.Rate(&pb.Span{Metrics: tt.metrics}))
+	}
+}
+
 func TestTargetTPSByService(t *testing.T) {
 	rand.Seed(1)
 	// Test the "effectiveness" of the targetTPS option.
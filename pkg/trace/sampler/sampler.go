@@ -87,6 +87,11 @@ func GetGlobalRate(s *pb.Span) float64 {
 	return getMetricDefault(s, KeySamplingRateGlobal, 1.0)
 }
 
+// AddGlobalRate updates the cumulative sample rate of the trace to which this span belongs to.
+func AddGlobalRate(s *pb.Span, rate float64) {
+	setMetric(s, KeySamplingRateGlobal, rate*GetGlobalRate(s))
+}
+
 // GetClientRate gets the rate at which the trace this span belongs to was sampled by the tracer.
 // NOTE: This defaults to 1 if no rate is stored.
 func GetClientRate(s *pb.Span) float64 {
@@ -78,6 +78,12 @@ func (s ScoreSampler) Sample(trace pb.Trace, root *pb.Span, env string) bool {
 	return sampled
 }
 
+// Rate returns the rate applied by the sampler to the trace having the given root span,
+// or 1 if the sampler did not apply any.
+func (s ScoreSampler) Rate(root *pb.Span) float64 {
+	return getMetricDefault(root, s.samplingRateKey, 1)
+}
+
 func (s ScoreSampler) applySampleRate(root *pb.Span, rate float64) bool {
 	initialRate := GetGlobalRate(root)
 	newRate := initialRate * rate
@@ -81,6 +81,17 @@ func TestErrorSampleThresholdTo1(t *testing.T) {
 	}
 }
 
+func TestScoreSamplerRate(t *testing.T) {
+	assert := assert.New(t)
+	s := getTestErrorsSampler()
+	_, root := getTestTrace()
+	assert.Equal(1.0, s.Rate(root))
+	root.Metrics = map[string]float64{errorsRateKey: 0.5}
+	assert.Equal(0.5, s.Rate(root))
+	root.Metrics[noPriorityRateKey] = 0.1
+	assert.Equal(0.5, s.Rate(root))
+}
+
 func TestTargetTPS(t *testing.T) {
 	// Test the "effectiveness" of the targetTPS option.
 	assert := assert.New(t)
@@ -28,6 +28,13 @@ type Concentrator struct {
 	In  chan Input
 	Out chan pb.StatsPayload
 
+	// SpanMetrics, when set, generates Dogstatsd metrics out of each flushed bucket.
+	SpanMetrics *SpanMetrics
+
+	// Ladder, when set, turns off stats for measured spans which aren't top-level once
+	// watchdog.StepTopLevelStatsOnly is reached.
+	Ladder *watchdog.Ladder
+
 	// bucket duration in nanoseconds
 	bsize int64
 	// Timestamp of the oldest time bucket for which we allow data.
@@ -140,8 +147,9 @@ func (c *Concentrator) addNow(i *EnvTrace, containerID string) {
 	if env == "" {
 		env = c.agentEnv
 	}
+	topLevelOnly := c.Ladder.Reached(watchdog.StepTopLevelStatsOnly)
 	for _, s := range i.Trace {
-		if !(s.TopLevel || s.Measured) {
+		if !(s.TopLevel || s.Measured) || (topLevelOnly && !s.TopLevel) {
 			continue
 		}
 		end := s.Start + s.Duration
@@ -168,6 +176,7 @@ func (c *Concentrator) Flush() pb.StatsPayload {
 
 func (c *Concentrator) flushNow(now int64) pb.StatsPayload {
 	m := make(map[PayloadAggregationKey][]pb.ClientStatsBucket)
+	var flushed []*RawBucket
 
 	c.mu.Lock()
 	for ts, srb := range c.buckets {
@@ -181,6 +190,9 @@ func (c *Concentrator) flushNow(now int64) pb.StatsPayload {
 		for k, b := range srb.Export() {
 			m[k] = append(m[k], b)
 		}
+		if c.SpanMetrics != nil {
+			flushed = append(flushed, srb)
+		}
 		delete(c.buckets, ts)
 	}
 	// After flushing, update the oldest timestamp allowed to prevent having stats for
@@ -191,6 +203,9 @@ func (c *Concentrator) flushNow(now int64) pb.StatsPayload {
 		c.oldestTs = newOldestTs
 	}
 	c.mu.Unlock()
+	for _, srb := range flushed {
+		c.SpanMetrics.emit(srb)
+	}
 	sb := make([]pb.ClientStatsPayload, 0, len(m))
 	for k, s := range m {
 		p := pb.ClientStatsPayload{
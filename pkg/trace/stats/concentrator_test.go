@@ -15,6 +15,7 @@ import (
 	"github.com/DataDog/datadog-agent/pkg/trace/info"
 	"github.com/DataDog/datadog-agent/pkg/trace/pb"
 	"github.com/DataDog/datadog-agent/pkg/trace/traceutil"
+	"github.com/DataDog/datadog-agent/pkg/trace/watchdog"
 
 	"github.com/DataDog/sketches-go/ddsketch"
 	"github.com/DataDog/sketches-go/ddsketch/pb/sketchpb"
@@ -488,3 +489,36 @@ func TestDistributions(t *testing.T) {
 		}
 	})
 }
+
+func TestConcentratorLadder(t *testing.T) {
+	now := time.Now()
+	hits := func(l *watchdog.Ladder) map[string]uint64 {
+		c := NewTestConcentrator(now)
+		c.Ladder = l
+		c.oldestTs = alignTs(now.UnixNano(), c.bsize) - int64(c.bufferLen)*c.bsize
+		child := testSpan(2, 1, 40, 0, "A1", "child", 0)
+		traceutil.SetMetric(child, "_dd.measured", 1)
+		trace := pb.Trace{testSpan(1, 0, 50, 0, "A1", "root", 0), child}
+		traceutil.ComputeTopLevel(trace)
+		c.addNow(&EnvTrace{Env: "none", Trace: NewWeightedTrace(trace, traceutil.GetRoot(trace))}, "")
+
+		got := make(map[string]uint64)
+		flushTime := now.UnixNano()
+		for i := 0; i <= c.bufferLen; i++ {
+			for _, p := range c.flushNow(flushTime).Stats {
+				for _, b := range p.Stats {
+					for _, s := range b.Stats {
+						got[s.Resource] += s.Hits
+					}
+				}
+			}
+			flushTime += c.bsize
+		}
+		return got
+	}
+
+	assert.Equal(t, map[string]uint64{"root": 1, "child": 1}, hits(nil))
+	l := watchdog.NewLadder()
+	l.Update(0.85)
+	assert.Equal(t, map[string]uint64{"root": 1}, hits(l))
+}
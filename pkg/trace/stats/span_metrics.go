@@ -0,0 +1,195 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package stats
+
+import (
+	"io"
+	"math"
+	"strconv"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/config"
+	"github.com/DataDog/datadog-agent/pkg/trace/metrics"
+	"github.com/DataDog/datadog-agent/pkg/trace/traceutil"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+	"github.com/DataDog/sketches-go/ddsketch"
+)
+
+// maxDatagramSize is the maximum size of a datagram written by SpanMetrics. It matches
+// the default dogstatsd_buffer_size of the Dogstatsd server.
+const maxDatagramSize = 8192
+
+// SpanMetrics generates Dogstatsd metrics out of the stats buckets flushed by the
+// Concentrator: the hits and errors of each aggregation as counts, along with its
+// latency distribution in seconds as a distribution, which is stored as a sketch by
+// the core agent's aggregator.
+//
+// Metrics are written as raw datagrams instead of going through the statsd client
+// because the latency sketches are sent as one sample per sketch bin, using the
+// sample rate to encode the bin's count, and the client would sample those.
+type SpanMetrics struct {
+	out           io.Writer
+	namespace     string
+	services      map[string]struct{}
+	resource      bool
+	agentHostname string
+
+	buf  []byte // current datagram
+	line []byte // line being built
+}
+
+// NewSpanMetrics returns a new SpanMetrics writing datagrams to out, usually a connection
+// to the Dogstatsd server obtained using metrics.Dial.
+func NewSpanMetrics(conf *config.AgentConfig, out io.Writer) *SpanMetrics {
+	m := &SpanMetrics{
+		out:           out,
+		namespace:     conf.SpanMetrics.Namespace,
+		resource:      conf.SpanMetrics.IncludeResource,
+		agentHostname: conf.Hostname,
+		buf:           make([]byte, 0, maxDatagramSize),
+	}
+	if len(conf.SpanMetrics.Services) > 0 {
+		m.services = make(map[string]struct{}, len(conf.SpanMetrics.Services))
+		for _, s := range conf.SpanMetrics.Services {
+			m.services[s] = struct{}{}
+		}
+	}
+	return m
+}
+
+// emit writes the metrics for all the aggregations found in b. It is a no-op
+// when m is nil.
+func (m *SpanMetrics) emit(b *RawBucket) {
+	if m == nil {
+		return
+	}
+	var contexts int64
+	for aggr, gs := range b.data {
+		if m.services != nil {
+			if _, ok := m.services[aggr.Service]; !ok {
+				continue
+			}
+		}
+		tags := m.tags(aggr)
+		if hits := round(gs.hits); hits > 0 {
+			m.add("hits", strconv.FormatUint(hits, 10), "c", 1, tags, "")
+		}
+		if errors := round(gs.errors); errors > 0 {
+			m.add("errors", strconv.FormatUint(errors, 10), "c", 1, tags, "")
+		}
+		m.addSketch(gs.okDistribution, tags, "error:false")
+		m.addSketch(gs.errDistribution, tags, "error:true")
+		contexts++
+	}
+	m.flush()
+	metrics.Count("datadog.trace_agent.span_metrics.aggregations", contexts, nil, 1)
+}
+
+// tags returns the Dogstatsd tags for the given aggregation, joined by commas.
+func (m *SpanMetrics) tags(aggr Aggregation) []byte {
+	var tags []byte
+	appendTag := func(k, v string) {
+		if v == "" {
+			return
+		}
+		if len(tags) > 0 {
+			tags = append(tags, ',')
+		}
+		// the normalized tag can not contain any character having a meaning
+		// in the Dogstatsd protocol.
+		tags = append(tags, traceutil.NormalizeTag(k+":"+v)...)
+	}
+	appendTag("env", aggr.Env)
+	appendTag("service", aggr.Service)
+	appendTag("operation_name", aggr.Name)
+	if m.resource {
+		appendTag("resource_name", aggr.Resource)
+	}
+	appendTag("span_type", aggr.Type)
+	if aggr.StatusCode != 0 {
+		appendTag("http.status_code", strconv.FormatUint(uint64(aggr.StatusCode), 10))
+	}
+	appendTag("version", aggr.Version)
+	if aggr.Synthetics {
+		appendTag("synthetics", "true")
+	}
+	if aggr.Hostname != m.agentHostname {
+		// spans reported on behalf of another host
+		appendTag("host", aggr.Hostname)
+	}
+	return tags
+}
+
+// addSketch adds one distribution sample for each bin of the given sketch. The sample rate
+// is set so that the Dogstatsd server counts each sample as many times as the bin's count.
+func (m *SpanMetrics) addSketch(s *ddsketch.DDSketch, tags []byte, extraTag string) {
+	if s == nil {
+		return
+	}
+	s.ForEach(func(value, count float64) bool {
+		n := math.Round(count)
+		if n < 1 {
+			return false
+		}
+		m.add("latency", strconv.FormatFloat(value/1e9, 'g', -1, 64), "d", sampleRate(n), tags, extraTag)
+		return false
+	})
+}
+
+// sampleRate returns the sample rate making the Dogstatsd server count a sample n times.
+func sampleRate(n float64) float64 {
+	if n <= 1 {
+		return 1
+	}
+	// Dogstatsd truncates 1/rate, make sure it lands on n.
+	return math.Nextafter(1/n, 0)
+}
+
+// add appends a metric to the current datagram, writing it out first when the
+// metric doesn't fit.
+func (m *SpanMetrics) add(name, value, typ string, rate float64, tags []byte, extraTag string) {
+	line := m.line[:0]
+	line = append(line, m.namespace...)
+	line = append(line, '.')
+	line = append(line, name...)
+	line = append(line, ':')
+	line = append(line, value...)
+	line = append(line, '|')
+	line = append(line, typ...)
+	if rate < 1 {
+		line = append(line, "|@"...)
+		line = strconv.AppendFloat(line, rate, 'g', -1, 64)
+	}
+	if len(tags) > 0 || extraTag != "" {
+		line = append(line, "|#"...)
+		line = append(line, tags...)
+		if extraTag != "" {
+			if len(tags) > 0 {
+				line = append(line, ',')
+			}
+			line = append(line, extraTag...)
+		}
+	}
+	m.line = line
+	if len(m.buf) > 0 && len(m.buf)+1+len(line) > maxDatagramSize {
+		m.flush()
+	}
+	if len(m.buf) > 0 {
+		m.buf = append(m.buf, '\n')
+	}
+	m.buf = append(m.buf, line...)
+}
+
+// flush writes out the current datagram.
+func (m *SpanMetrics) flush() {
+	if len(m.buf) == 0 {
+		return
+	}
+	if _, err := m.out.Write(m.buf); err != nil {
+		log.Debugf("Error writing span metrics: %v", err)
+		metrics.Count("datadog.trace_agent.span_metrics.errors", 1, nil, 1)
+	}
+	m.buf = m.buf[:0]
+}
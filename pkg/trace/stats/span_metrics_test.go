@@ -0,0 +1,213 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package stats
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/config"
+	"github.com/DataDog/datadog-agent/pkg/trace/pb"
+	"github.com/DataDog/datadog-agent/pkg/trace/traceutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// datagramRecorder records the datagrams written to it.
+type datagramRecorder struct{ datagrams []string }
+
+func (r *datagramRecorder) Write(p []byte) (int, error) {
+	r.datagrams = append(r.datagrams, string(p))
+	return len(p), nil
+}
+
+// dogstatsdSample is a parsed Dogstatsd metric line.
+type dogstatsdSample struct {
+	name, value, typ string
+	rate             float64
+	tags             []string
+}
+
+func (r *datagramRecorder) samples(t *testing.T) []dogstatsdSample {
+	var samples []dogstatsdSample
+	for _, d := range r.datagrams {
+		for _, line := range strings.Split(d, "\n") {
+			fields := strings.Split(line, "|")
+			nameValue := strings.SplitN(fields[0], ":", 2)
+			s := dogstatsdSample{name: nameValue[0], value: nameValue[1], typ: fields[1], rate: 1}
+			for _, f := range fields[2:] {
+				switch f[0] {
+				case '@':
+					rate, err := strconv.ParseFloat(f[1:], 64)
+					assert.NoError(t, err)
+					s.rate = rate
+				case '#':
+					s.tags = strings.Split(f[1:], ",")
+				}
+			}
+			samples = append(samples, s)
+		}
+	}
+	return samples
+}
+
+func testSpanMetricsConfig() *config.AgentConfig {
+	return &config.AgentConfig{
+		Hostname:    "agent-host",
+		SpanMetrics: &config.SpanMetricsConfig{Enabled: true, Namespace: "test.spans", IncludeResource: true},
+	}
+}
+
+func testSpanMetricsBucket() *RawBucket {
+	b := NewRawBucket(0, uint64(time.Second))
+	add := func(service, resource string, duration int64, err int32, meta map[string]string) {
+		b.HandleSpan(&WeightedSpan{
+			Weight:   1,
+			TopLevel: true,
+			Span: &pb.Span{
+				Service:  service,
+				Name:     "http.request",
+				Resource: resource,
+				Type:     "web",
+				Duration: duration,
+				Error:    err,
+				Meta:     meta,
+			},
+		}, "prod", "agent-host", "")
+	}
+	for i := 0; i < 10; i++ {
+		add("web", "GET /users, /orders", int64(time.Millisecond), 0, map[string]string{"http.status_code": "200"})
+	}
+	add("web", "GET /users, /orders", int64(time.Second), 1, map[string]string{"http.status_code": "500"})
+	add("web", "GET /users, /orders", int64(time.Second), 1, map[string]string{"http.status_code": "500"})
+	add("db", "SELECT", int64(5*time.Millisecond), 0, map[string]string{"_dd.hostname": "db-host"})
+	return b
+}
+
+func TestSpanMetrics(t *testing.T) {
+	t.Run("emit", func(t *testing.T) {
+		assert := assert.New(t)
+		var rec datagramRecorder
+		NewSpanMetrics(testSpanMetricsConfig(), &rec).emit(testSpanMetricsBucket())
+		assert.Len(rec.datagrams, 1)
+
+		hits := make(map[string]int)
+		errors := make(map[string]int)
+		latency := make(map[string]int)
+		for _, s := range rec.samples(t) {
+			key := strings.Join(s.tags, ",")
+			switch s.name {
+			case "test.spans.hits":
+				assert.Equal("c", s.typ)
+				n, _ := strconv.Atoi(s.value)
+				hits[key] += n
+			case "test.spans.errors":
+				assert.Equal("c", s.typ)
+				n, _ := strconv.Atoi(s.value)
+				errors[key] += n
+			case "test.spans.latency":
+				assert.Equal("d", s.typ)
+				// emulate the Dogstatsd server, which truncates 1/rate
+				latency[key] += int(1 / s.rate)
+				v, err := strconv.ParseFloat(s.value, 64)
+				assert.NoError(err)
+				assert.True(v > 0 && v < 1.1, v)
+			default:
+				t.Fatalf("unexpected metric %s", s.name)
+			}
+		}
+
+		ok := "env:prod,service:web,operation_name:http.request,resource_name:get_/users_/orders,span_type:web,http.status_code:200"
+		ko := "env:prod,service:web,operation_name:http.request,resource_name:get_/users_/orders,span_type:web,http.status_code:500"
+		db := "env:prod,service:db,operation_name:http.request,resource_name:select,span_type:web,host:db-host"
+		assert.Equal(map[string]int{ok: 10, ko: 2, db: 1}, hits)
+		assert.Equal(map[string]int{ko: 2}, errors)
+		assert.Equal(map[string]int{ok + ",error:false": 10, ko + ",error:true": 2, db + ",error:false": 1}, latency)
+	})
+
+	t.Run("services", func(t *testing.T) {
+		assert := assert.New(t)
+		conf := testSpanMetricsConfig()
+		conf.SpanMetrics.Services = []string{"db"}
+		conf.SpanMetrics.IncludeResource = false
+		var rec datagramRecorder
+		NewSpanMetrics(conf, &rec).emit(testSpanMetricsBucket())
+		samples := rec.samples(t)
+		assert.Len(samples, 2)
+		for _, s := range samples {
+			assert.Contains(s.tags, "service:db")
+			for _, tag := range s.tags {
+				assert.False(strings.HasPrefix(tag, "resource_name:"), tag)
+			}
+		}
+	})
+
+	t.Run("split", func(t *testing.T) {
+		assert := assert.New(t)
+		b := NewRawBucket(0, uint64(time.Second))
+		for i := 0; i < 500; i++ {
+			b.HandleSpan(&WeightedSpan{
+				Weight: 1,
+				Span:   &pb.Span{Service: "web", Name: "op", Resource: "resource-" + strconv.Itoa(i), Duration: int64(i + 1)},
+			}, "prod", "agent-host", "")
+		}
+		var rec datagramRecorder
+		NewSpanMetrics(testSpanMetricsConfig(), &rec).emit(b)
+		assert.True(len(rec.datagrams) > 1)
+		for _, d := range rec.datagrams {
+			assert.True(len(d) <= maxDatagramSize, len(d))
+			assert.False(strings.HasSuffix(d, "\n"))
+		}
+		assert.Len(rec.samples(t), 1000)
+	})
+
+	t.Run("nil", func(t *testing.T) {
+		var m *SpanMetrics
+		m.emit(testSpanMetricsBucket())
+	})
+}
+
+func TestSampleRate(t *testing.T) {
+	assert.Equal(t, 1.0, sampleRate(1))
+	for n := 2; n < 100000; n++ {
+		// the rate goes through the Dogstatsd protocol, then the server truncates 1/rate
+		rate, err := strconv.ParseFloat(strconv.FormatFloat(sampleRate(float64(n)), 'g', -1, 64), 64)
+		assert.NoError(t, err)
+		if !assert.Equal(t, uint(n), uint(1/rate), "rate %v", rate) {
+			break
+		}
+	}
+}
+
+func TestConcentratorSpanMetrics(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Now()
+	c := NewTestConcentrator(now)
+	var rec datagramRecorder
+	conf := testSpanMetricsConfig()
+	conf.Hostname = "hostname"
+	c.SpanMetrics = NewSpanMetrics(conf, &rec)
+	c.oldestTs = alignTs(now.UnixNano(), c.bsize) - int64(c.bufferLen)*c.bsize
+
+	trace := pb.Trace{
+		testSpan(1, 0, 50, 5, "A1", "resource1", 0),
+		testSpan(2, 1, 40, 5, "A1", "resource1", 1),
+	}
+	traceutil.ComputeTopLevel(trace)
+	c.addNow(&EnvTrace{Env: "none", Trace: NewWeightedTrace(trace, traceutil.GetRoot(trace))}, "")
+
+	c.flushNow(now.UnixNano() + int64(c.bufferLen)*c.bsize)
+	var hits int
+	for _, s := range rec.samples(t) {
+		if s.name == "test.spans.hits" {
+			n, _ := strconv.Atoi(s.value)
+			hits += n
+			assert.Contains(s.tags, "service:a1")
+		}
+	}
+	assert.Equal(1, hits)
+}
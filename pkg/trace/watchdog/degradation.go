@@ -0,0 +1,129 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package watchdog
+
+import (
+	"sync/atomic"
+)
+
+// Step is a step of the degradation ladder. Each step implies all of the steps
+// below it: the higher the step, the more features are turned off to save resources.
+type Step int32
+
+const (
+	// StepNone is the step at which the agent runs with all of its features enabled.
+	StepNone Step = iota
+	// StepNoObfuscationCache disables the cache of obfuscated SQL queries, which
+	// trades a bit of CPU for a potentially large amount of memory.
+	StepNoObfuscationCache
+	// StepTopLevelStatsOnly stops computing stats for spans which are neither top-level
+	// nor measured.
+	StepTopLevelStatsOnly
+	// StepNoEventExtraction stops extracting APM events from traces.
+	StepNoEventExtraction
+	// StepServiceSampling drops traces per service, proportionally to each service's volume.
+	StepServiceSampling
+	// StepPayloadRateLimiting rejects whole payloads in the receiver, regardless of
+	// the services they contain.
+	StepPayloadRateLimiting
+)
+
+// stepThresholds holds the resource usage (as a ratio of the configured limits) at which
+// each step is reached, indexed by step.
+var stepThresholds = [...]float64{
+	StepNone:                0,
+	StepNoObfuscationCache:  0.7,
+	StepTopLevelStatsOnly:   0.8,
+	StepNoEventExtraction:   0.9,
+	StepServiceSampling:     1,
+	StepPayloadRateLimiting: 1.3,
+}
+
+// stepHysteresis is how far below a step's threshold the resource usage has to go
+// before the ladder steps down, to avoid flapping between two steps.
+const stepHysteresis = 0.05
+
+var stepNames = [...]string{
+	StepNone:                "none",
+	StepNoObfuscationCache:  "no_obfuscation_cache",
+	StepTopLevelStatsOnly:   "top_level_stats_only",
+	StepNoEventExtraction:   "no_event_extraction",
+	StepServiceSampling:     "service_sampling",
+	StepPayloadRateLimiting: "payload_rate_limiting",
+}
+
+// String implements fmt.Stringer.
+func (s Step) String() string {
+	if s < 0 || int(s) >= len(stepNames) {
+		return "unknown"
+	}
+	return stepNames[s]
+}
+
+// stepFor returns the step matching the given resource usage.
+func stepFor(usage float64) Step {
+	step := StepNone
+	for s := StepNone + 1; int(s) < len(stepThresholds); s++ {
+		if usage >= stepThresholds[s] {
+			step = s
+		}
+	}
+	return step
+}
+
+// Ladder keeps track of the current step of the degradation ladder. It is updated by the
+// receiver's watchdog and read concurrently by the components it turns features off in.
+// A nil *Ladder is valid and never reaches any step.
+type Ladder struct {
+	step int32 // atomic
+}
+
+// NewLadder returns a new Ladder at StepNone.
+func NewLadder() *Ladder { return &Ladder{} }
+
+// Update moves the ladder to the step matching usage, which is the highest ratio of used
+// to allowed resources, 1 meaning that a limit is reached. Stepping up is immediate, while
+// stepping down only happens once usage is clearly below the current step's threshold.
+// It returns the new step and whether it changed. On a nil *Ladder, Update returns the
+// step matching usage.
+func (l *Ladder) Update(usage float64) (step Step, changed bool) {
+	step = stepFor(usage)
+	if l == nil {
+		return step, false
+	}
+	current := l.Step()
+	if step < current && usage >= stepThresholds[current]-stepHysteresis {
+		step = current
+	}
+	if step == current {
+		return current, false
+	}
+	atomic.StoreInt32(&l.step, int32(step))
+	return step, true
+}
+
+// Step returns the current step.
+func (l *Ladder) Step() Step {
+	if l == nil {
+		return StepNone
+	}
+	return Step(atomic.LoadInt32(&l.step))
+}
+
+// Reached reports whether the ladder is at step s or above.
+func (l *Ladder) Reached(s Step) bool {
+	return l.Step() >= s
+}
+
+// StepsUpTo returns the names of the steps which are in effect at step s, from the first
+// to be reached to the last.
+func StepsUpTo(s Step) []string {
+	var names []string
+	for i := StepNone + 1; i <= s && int(i) < len(stepNames); i++ {
+		names = append(names, i.String())
+	}
+	return names
+}
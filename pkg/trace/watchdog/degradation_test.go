@@ -0,0 +1,69 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package watchdog
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLadder(t *testing.T) {
+	t.Run("steps", func(t *testing.T) {
+		for usage, want := range map[float64]Step{
+			0:    StepNone,
+			0.5:  StepNone,
+			0.7:  StepNoObfuscationCache,
+			0.85: StepTopLevelStatsOnly,
+			0.95: StepNoEventExtraction,
+			1.1:  StepServiceSampling,
+			1.3:  StepPayloadRateLimiting,
+			10:   StepPayloadRateLimiting,
+		} {
+			step, _ := NewLadder().Update(usage)
+			assert.Equal(t, want, step, usage)
+		}
+	})
+
+	t.Run("hysteresis", func(t *testing.T) {
+		assert := assert.New(t)
+		l := NewLadder()
+		for _, tt := range []struct {
+			usage   float64
+			step    Step
+			changed bool
+		}{
+			{0.5, StepNone, false},
+			{1.1, StepServiceSampling, true},
+			{0.98, StepServiceSampling, false}, // not far enough below 1
+			{0.94, StepNoEventExtraction, true},
+			{0.6, StepNone, true},
+			{1.5, StepPayloadRateLimiting, true},
+		} {
+			step, changed := l.Update(tt.usage)
+			assert.Equal(tt.step, step, tt.usage)
+			assert.Equal(tt.changed, changed, tt.usage)
+			assert.Equal(tt.step, l.Step())
+		}
+		assert.True(l.Reached(StepNoEventExtraction))
+	})
+
+	t.Run("nil", func(t *testing.T) {
+		var l *Ladder
+		step, changed := l.Update(1.1)
+		assert.Equal(t, StepServiceSampling, step)
+		assert.False(t, changed)
+		assert.Equal(t, StepNone, l.Step())
+		assert.False(t, l.Reached(StepNoObfuscationCache))
+	})
+}
+
+func TestStepsUpTo(t *testing.T) {
+	assert.Empty(t, StepsUpTo(StepNone))
+	assert.Equal(t, []string{"no_obfuscation_cache", "top_level_stats_only"}, StepsUpTo(StepTopLevelStatsOnly))
+	assert.Len(t, StepsUpTo(StepPayloadRateLimiting), 5)
+	assert.Equal(t, "unknown", Step(42).String())
+}
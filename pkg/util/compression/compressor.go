@@ -0,0 +1,212 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package compression
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+)
+
+// Kind is a compression algorithm which can be selected at runtime, unlike the one
+// selected by the build tags of this package.
+type Kind string
+
+// Supported compression kinds
+const (
+	NoneKind Kind = "none"
+	ZlibKind Kind = "zlib"
+	GzipKind Kind = "gzip"
+	ZstdKind Kind = "zstd"
+)
+
+// ErrZstdUnavailable is returned when the zstd compression is selected while the zstd
+// build tag didn't link the zstd library.
+var ErrZstdUnavailable = errors.New("zstd compression is not available: the Agent was built without the zstd build tag")
+
+// newZstdCompressor returns a zstd Compressor, it is set when the zstd build tag
+// links the zstd library.
+var newZstdCompressor func(level int) (Compressor, error)
+
+// Compressor compresses payloads using a given algorithm and level.
+type Compressor interface {
+	// Kind returns the compression algorithm
+	Kind() Kind
+	// ContentEncoding returns the value of the Content-Encoding HTTP header describing
+	// the compressed payloads, empty if they aren't compressed
+	ContentEncoding() string
+	// Compress returns the compressed src
+	Compress(src []byte) ([]byte, error)
+	// Decompress returns the decompressed src
+	Decompress(src []byte) ([]byte, error)
+	// CompressBound returns the worst case size of sourceLen bytes once compressed
+	CompressBound(sourceLen int) int
+	// NewStreamWriter returns a StreamWriter compressing the data written to it into w
+	NewStreamWriter(w io.Writer) (StreamWriter, error)
+}
+
+// StreamWriter compresses the data written to it. Flush writes the pending compressed
+// data to the underlying writer, and Close writes the end of the compressed stream.
+type StreamWriter interface {
+	io.WriteCloser
+	Flush() error
+}
+
+// NewCompressor returns a Compressor using the given algorithm and level. A zero level
+// selects the default level of the algorithm.
+func NewCompressor(kind Kind, level int) (Compressor, error) {
+	switch kind {
+	case NoneKind:
+		if level != 0 {
+			return nil, fmt.Errorf("no compression level can be set when compression is disabled")
+		}
+		return noneCompressor{}, nil
+	case ZlibKind, GzipKind:
+		if level == 0 {
+			level = zlib.DefaultCompression
+		} else if level < zlib.BestSpeed || level > zlib.BestCompression {
+			return nil, fmt.Errorf("invalid %s compression level %d: expected a value between %d and %d", kind, level, zlib.BestSpeed, zlib.BestCompression)
+		}
+		if kind == GzipKind {
+			return gzipCompressor{level: level}, nil
+		}
+		return zlibCompressor{level: level}, nil
+	case ZstdKind:
+		if newZstdCompressor == nil {
+			return nil, ErrZstdUnavailable
+		}
+		return newZstdCompressor(level)
+	default:
+		return nil, fmt.Errorf("unknown compression %q: expected one of none, zlib, gzip or zstd", kind)
+	}
+}
+
+// Parse returns the Compressor described by spec, which is a compression kind optionally
+// followed by a colon and a level, for example "zstd" or "gzip:9".
+func Parse(spec string) (Compressor, error) {
+	kind, level := strings.TrimSpace(spec), 0
+	if i := strings.IndexByte(kind, ':'); i >= 0 {
+		var err error
+		if level, err = strconv.Atoi(kind[i+1:]); err != nil {
+			return nil, fmt.Errorf("invalid compression level in %q: %v", spec, err)
+		}
+		kind = kind[:i]
+	}
+	return NewCompressor(Kind(strings.ToLower(kind)), level)
+}
+
+// ForContentEncoding returns the Compressor decoding the payloads described by the given
+// value of the Content-Encoding HTTP header, using the default level of the algorithm.
+func ForContentEncoding(contentEncoding string) (Compressor, error) {
+	switch strings.ToLower(strings.TrimSpace(contentEncoding)) {
+	case "", "identity":
+		return NewCompressor(NoneKind, 0)
+	case "deflate":
+		return NewCompressor(ZlibKind, 0)
+	case "gzip":
+		return NewCompressor(GzipKind, 0)
+	case "zstd":
+		return NewCompressor(ZstdKind, 0)
+	default:
+		return nil, fmt.Errorf("unknown content encoding %q", contentEncoding)
+	}
+}
+
+type noneCompressor struct{}
+
+func (noneCompressor) Kind() Kind                            { return NoneKind }
+func (noneCompressor) ContentEncoding() string               { return "" }
+func (noneCompressor) Compress(src []byte) ([]byte, error)   { return src, nil }
+func (noneCompressor) Decompress(src []byte) ([]byte, error) { return src, nil }
+func (noneCompressor) CompressBound(sourceLen int) int       { return sourceLen }
+func (noneCompressor) NewStreamWriter(w io.Writer) (StreamWriter, error) {
+	return nopStreamWriter{w}, nil
+}
+
+type nopStreamWriter struct{ io.Writer }
+
+func (nopStreamWriter) Flush() error { return nil }
+func (nopStreamWriter) Close() error { return nil }
+
+type zlibCompressor struct{ level int }
+
+func (zlibCompressor) Kind() Kind              { return ZlibKind }
+func (zlibCompressor) ContentEncoding() string { return "deflate" }
+
+func (c zlibCompressor) Compress(src []byte) ([]byte, error) {
+	var b bytes.Buffer
+	w, err := zlib.NewWriterLevel(&b, c.level)
+	if err != nil {
+		return nil, err
+	}
+	return compressWith(&b, w, src)
+}
+
+func (zlibCompressor) Decompress(src []byte) ([]byte, error) {
+	r, err := zlib.NewReader(bytes.NewReader(src))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
+func (zlibCompressor) CompressBound(sourceLen int) int {
+	// From https://code.woboq.org/gcc/zlib/compress.c.html#compressBound
+	return sourceLen + (sourceLen >> 12) + (sourceLen >> 14) + (sourceLen >> 25) + 13
+}
+
+func (c zlibCompressor) NewStreamWriter(w io.Writer) (StreamWriter, error) {
+	return zlib.NewWriterLevel(w, c.level)
+}
+
+type gzipCompressor struct{ level int }
+
+func (gzipCompressor) Kind() Kind              { return GzipKind }
+func (gzipCompressor) ContentEncoding() string { return "gzip" }
+
+func (c gzipCompressor) Compress(src []byte) ([]byte, error) {
+	var b bytes.Buffer
+	w, err := gzip.NewWriterLevel(&b, c.level)
+	if err != nil {
+		return nil, err
+	}
+	return compressWith(&b, w, src)
+}
+
+func (gzipCompressor) Decompress(src []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(src))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
+func (gzipCompressor) CompressBound(sourceLen int) int {
+	// Same deflate stream as zlib, with a larger header and trailer
+	return zlibCompressor{}.CompressBound(sourceLen) + 12
+}
+
+func (c gzipCompressor) NewStreamWriter(w io.Writer) (StreamWriter, error) {
+	return gzip.NewWriterLevel(w, c.level)
+}
+
+func compressWith(b *bytes.Buffer, w io.WriteCloser, src []byte) ([]byte, error) {
+	if _, err := w.Write(src); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
@@ -0,0 +1,99 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package compression
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompressor(t *testing.T) {
+	for _, tt := range []struct {
+		spec            string
+		kind            Kind
+		contentEncoding string
+	}{
+		{"none", NoneKind, ""},
+		{"zlib", ZlibKind, "deflate"},
+		{"zlib:1", ZlibKind, "deflate"},
+		{"gzip:9", GzipKind, "gzip"},
+	} {
+		t.Run(tt.spec, func(t *testing.T) {
+			testCompressor(t, tt.spec, tt.kind, tt.contentEncoding)
+		})
+	}
+}
+
+func testCompressor(t *testing.T, spec string, kind Kind, contentEncoding string) {
+	src := []byte(strings.Repeat(`{"metric":"system.load.1","points":[[1600000000,0.5]]}`, 100))
+
+	c, err := Parse(spec)
+	require.NoError(t, err)
+	assert.Equal(t, kind, c.Kind())
+	assert.Equal(t, contentEncoding, c.ContentEncoding())
+
+	compressed, err := c.Compress(src)
+	require.NoError(t, err)
+	assert.True(t, len(compressed) <= c.CompressBound(len(src)))
+	decompressed, err := c.Decompress(compressed)
+	require.NoError(t, err)
+	assert.Equal(t, src, decompressed)
+
+	// the stream writer is flushed between items and closed at the end
+	var b bytes.Buffer
+	w, err := c.NewStreamWriter(&b)
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		_, err = w.Write(src)
+		require.NoError(t, err)
+		require.NoError(t, w.Flush())
+	}
+	require.NoError(t, w.Close())
+	decompressed, err = c.Decompress(b.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, bytes.Repeat(src, 3), decompressed)
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"brotli",
+		"none:1",
+		"zlib:10",
+		"gzip:-2",
+		"zstd:21",
+		"zstd:fast",
+	} {
+		_, err := Parse(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestForContentEncoding(t *testing.T) {
+	src := []byte(strings.Repeat(`{"metric":"system.load.1","points":[[1600000000,0.5]]}`, 100))
+
+	// the payloads compressed at build time are decoded by their Content-Encoding
+	c := Default()
+	compressed, err := c.Compress(src)
+	require.NoError(t, err)
+	d, err := ForContentEncoding(c.ContentEncoding())
+	require.NoError(t, err)
+	assert.Equal(t, c.Kind(), d.Kind())
+	decompressed, err := d.Decompress(compressed)
+	require.NoError(t, err)
+	assert.Equal(t, src, decompressed)
+
+	for _, contentEncoding := range []string{"identity", "deflate", "GZIP"} {
+		_, err = ForContentEncoding(contentEncoding)
+		assert.NoError(t, err, contentEncoding)
+	}
+	_, err = ForContentEncoding("br")
+	assert.EqualError(t, err, `unknown content encoding "br"`)
+}
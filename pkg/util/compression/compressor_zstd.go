@@ -0,0 +1,47 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+// +build zstd
+
+package compression
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/DataDog/zstd"
+)
+
+func init() {
+	newZstdCompressor = func(level int) (Compressor, error) {
+		if level == 0 {
+			level = zstd.DefaultCompression
+		} else if level < zstd.BestSpeed || level > zstd.BestCompression {
+			return nil, fmt.Errorf("invalid zstd compression level %d: expected a value between %d and %d", level, zstd.BestSpeed, zstd.BestCompression)
+		}
+		return zstdCompressor{level: level}, nil
+	}
+}
+
+type zstdCompressor struct{ level int }
+
+func (zstdCompressor) Kind() Kind              { return ZstdKind }
+func (zstdCompressor) ContentEncoding() string { return "zstd" }
+
+func (c zstdCompressor) Compress(src []byte) ([]byte, error) {
+	return zstd.CompressLevel(nil, src, c.level)
+}
+
+func (zstdCompressor) Decompress(src []byte) ([]byte, error) {
+	return zstd.Decompress(nil, src)
+}
+
+func (zstdCompressor) CompressBound(sourceLen int) int {
+	return zstd.CompressBound(sourceLen)
+}
+
+func (c zstdCompressor) NewStreamWriter(w io.Writer) (StreamWriter, error) {
+	return zstd.NewWriterLevel(w, c.level), nil
+}
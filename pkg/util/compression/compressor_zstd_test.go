@@ -0,0 +1,20 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+// +build zstd
+
+package compression
+
+import (
+	"testing"
+)
+
+func TestZstdCompressor(t *testing.T) {
+	for _, spec := range []string{"ZSTD", "zstd:19"} {
+		t.Run(spec, func(t *testing.T) {
+			testCompressor(t, spec, ZstdKind, "zstd")
+		})
+	}
+}
@@ -28,3 +28,8 @@ func Decompress(dst []byte, src []byte) ([]byte, error) {
 func CompressBound(sourceLen int) int {
 	return sourceLen
 }
+
+// Default returns the Compressor matching the compression selected at build time
+func Default() Compressor {
+	return noneCompressor{}
+}
@@ -53,3 +53,8 @@ func CompressBound(sourceLen int) int {
 	// From https://code.woboq.org/gcc/zlib/compress.c.html#compressBound
 	return sourceLen + (sourceLen >> 12) + (sourceLen >> 14) + (sourceLen >> 25) + 13
 }
+
+// Default returns the Compressor matching the compression selected at build time
+func Default() Compressor {
+	return zlibCompressor{level: zlib.DefaultCompression}
+}
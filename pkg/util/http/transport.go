@@ -7,7 +7,10 @@ package http
 
 import (
 	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"fmt"
+	"io/ioutil"
 	"net"
 	"net/http"
 	"net/url"
@@ -50,8 +53,41 @@ func warnOnce(warnMap map[string]bool, key string, format string, params ...inte
 	}
 }
 
+// TransportOptions overrides the agent-wide settings of the transports created by
+// CreateHTTPTransportWithOptions. The zero value keeps the agent-wide settings.
+type TransportOptions struct {
+	// Proxies replaces the agent-wide proxy settings when not nil
+	Proxies *config.Proxy
+	// NoProxy sends the requests directly, ignoring any proxy setting
+	NoProxy bool
+	// TLSCAFile is a PEM file containing the certificate authorities used to verify
+	// the server certificates instead of the system ones
+	TLSCAFile string
+	// TLSCertFile and TLSKeyFile are the PEM files of the client certificate sent
+	// to the servers requesting one (mutual TLS)
+	TLSCertFile string
+	TLSKeyFile  string
+	// HTTP2 allows to use HTTP/2 with the servers supporting it
+	HTTP2 bool
+	// MaxIdleConnsPerHost is the number of idle connections kept open per host, 5 when 0
+	MaxIdleConnsPerHost int
+	// MaxConnsPerHost limits the number of connections per host, 0 means no limit
+	MaxConnsPerHost int
+	// IdleConnTimeout is the time after which idle connections are closed, 90s when 0
+	IdleConnTimeout time.Duration
+}
+
 // CreateHTTPTransport creates an *http.Transport for use in the agent
 func CreateHTTPTransport() *http.Transport {
+	// Without TLS files, the options cannot be invalid
+	transport, _ := CreateHTTPTransportWithOptions(TransportOptions{})
+	return transport
+}
+
+// CreateHTTPTransportWithOptions creates an *http.Transport for use in the agent,
+// overriding the agent-wide settings with options. It returns an error if the TLS
+// files of options cannot be loaded.
+func CreateHTTPTransportWithOptions(options TransportOptions) (*http.Transport, error) {
 	tlsConfig := &tls.Config{
 		InsecureSkipVerify: config.Datadog.GetBool("skip_ssl_validation"),
 	}
@@ -60,6 +96,19 @@ func CreateHTTPTransport() *http.Transport {
 		tlsConfig.MinVersion = tls.VersionTLS12
 	}
 
+	if err := loadTLSFiles(tlsConfig, options); err != nil {
+		return nil, err
+	}
+
+	maxIdleConnsPerHost := 5
+	if options.MaxIdleConnsPerHost > 0 {
+		maxIdleConnsPerHost = options.MaxIdleConnsPerHost
+	}
+	idleConnTimeout := 90 * time.Second
+	if options.IdleConnTimeout > 0 {
+		idleConnTimeout = options.IdleConnTimeout
+	}
+
 	// Most of the following timeouts are a copy of Golang http.DefaultTransport
 	// They are mostly used to act as safeguards in case we forget to add a general
 	// timeout to our http clients.
@@ -73,18 +122,51 @@ func CreateHTTPTransport() *http.Transport {
 			FallbackDelay: -1 * time.Nanosecond,
 		}).DialContext,
 		MaxIdleConns:        100,
-		MaxIdleConnsPerHost: 5,
+		MaxIdleConnsPerHost: maxIdleConnsPerHost,
+		MaxConnsPerHost:     options.MaxConnsPerHost,
 		// This parameter is set to avoid connections sitting idle in the pool indefinitely
-		IdleConnTimeout:       90 * time.Second,
+		IdleConnTimeout:       idleConnTimeout,
 		TLSHandshakeTimeout:   10 * time.Second,
 		ExpectContinueTimeout: 1 * time.Second,
+		// HTTP/2 is only used when explicitly enabled, as a custom TLS config and
+		// dialer are set.
+		ForceAttemptHTTP2: options.HTTP2,
 	}
 
-	if proxies := config.GetProxies(); proxies != nil {
+	proxies := config.GetProxies()
+	if options.Proxies != nil {
+		proxies = options.Proxies
+	}
+	if proxies != nil && !options.NoProxy {
 		transport.Proxy = GetProxyTransportFunc(proxies)
 	}
 
-	return transport
+	return transport, nil
+}
+
+func loadTLSFiles(tlsConfig *tls.Config, options TransportOptions) error {
+	if options.TLSCAFile != "" {
+		pem, err := ioutil.ReadFile(options.TLSCAFile)
+		if err != nil {
+			return fmt.Errorf("cannot read the CA file: %v", err)
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificate found in the CA file %s", options.TLSCAFile)
+		}
+	}
+
+	if (options.TLSCertFile == "") != (options.TLSKeyFile == "") {
+		return errors.New("both the client certificate and key files must be set")
+	}
+	if options.TLSCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(options.TLSCertFile, options.TLSKeyFile)
+		if err != nil {
+			return fmt.Errorf("cannot load the client certificate: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return nil
 }
 
 // GetProxyTransportFunc return a proxy function for a http.Transport that
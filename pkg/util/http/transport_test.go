@@ -6,9 +6,21 @@
 package http
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
 	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
 	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -189,3 +201,131 @@ func TestNoProxyWarningMap(t *testing.T) {
 
 	assert.Equal(t, NoProxyIgnoredWarningMap["http://api.test_http.com"], true)
 }
+
+func TestCreateHTTPTransportWithOptions(t *testing.T) {
+	transport, err := CreateHTTPTransportWithOptions(TransportOptions{
+		MaxIdleConnsPerHost: 10,
+		MaxConnsPerHost:     20,
+		IdleConnTimeout:     time.Minute,
+		HTTP2:               true,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 10, transport.MaxIdleConnsPerHost)
+	assert.Equal(t, 20, transport.MaxConnsPerHost)
+	assert.Equal(t, time.Minute, transport.IdleConnTimeout)
+	assert.True(t, transport.ForceAttemptHTTP2)
+
+	transport = CreateHTTPTransport()
+	assert.Equal(t, 5, transport.MaxIdleConnsPerHost)
+	assert.Equal(t, 0, transport.MaxConnsPerHost)
+	assert.Equal(t, 90*time.Second, transport.IdleConnTimeout)
+	assert.False(t, transport.ForceAttemptHTTP2)
+}
+
+func TestCreateHTTPTransportWithProxyOptions(t *testing.T) {
+	r, _ := http.NewRequest("GET", "https://test.com/api/v1", nil)
+
+	transport, err := CreateHTTPTransportWithOptions(TransportOptions{
+		Proxies: &config.Proxy{HTTPS: "https://relay.com:3128"},
+	})
+	require.NoError(t, err)
+	require.NotNil(t, transport.Proxy)
+	proxyURL, err := transport.Proxy(r)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://relay.com:3128", proxyURL.String())
+
+	transport, err = CreateHTTPTransportWithOptions(TransportOptions{
+		Proxies: &config.Proxy{HTTPS: "https://relay.com:3128"},
+		NoProxy: true,
+	})
+	require.NoError(t, err)
+	assert.Nil(t, transport.Proxy)
+}
+
+func TestCreateHTTPTransportWithTLSOptions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tls")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	clientCert, clientCertFile, clientKeyFile := writeTestCertificate(t, dir)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	server.EnableHTTP2 = true
+	server.TLS = &tls.Config{
+		ClientAuth: tls.RequireAndVerifyClientCert,
+		ClientCAs:  x509.NewCertPool(),
+	}
+	server.TLS.ClientCAs.AddCert(clientCert)
+	server.StartTLS()
+	defer server.Close()
+
+	caFile := filepath.Join(dir, "ca.pem")
+	require.NoError(t, ioutil.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0600))
+
+	get := func(options TransportOptions) (*http.Response, error) {
+		transport, err := CreateHTTPTransportWithOptions(options)
+		require.NoError(t, err)
+		defer transport.CloseIdleConnections()
+		resp, err := (&http.Client{Transport: transport}).Get(server.URL)
+		if err == nil {
+			resp.Body.Close()
+		}
+		return resp, err
+	}
+
+	// the server certificate isn't trusted
+	_, err = get(TransportOptions{TLSCertFile: clientCertFile, TLSKeyFile: clientKeyFile})
+	assert.Error(t, err)
+
+	// no client certificate is sent
+	_, err = get(TransportOptions{TLSCAFile: caFile})
+	assert.Error(t, err)
+
+	resp, err := get(TransportOptions{TLSCAFile: caFile, TLSCertFile: clientCertFile, TLSKeyFile: clientKeyFile})
+	require.NoError(t, err)
+	assert.Equal(t, 1, resp.ProtoMajor)
+
+	resp, err = get(TransportOptions{TLSCAFile: caFile, TLSCertFile: clientCertFile, TLSKeyFile: clientKeyFile, HTTP2: true})
+	require.NoError(t, err)
+	assert.Equal(t, 2, resp.ProtoMajor)
+
+	for _, options := range []TransportOptions{
+		{TLSCAFile: filepath.Join(dir, "missing.pem")},
+		{TLSCAFile: clientKeyFile},
+		{TLSCertFile: clientCertFile},
+		{TLSCertFile: clientCertFile, TLSKeyFile: caFile},
+	} {
+		_, err := CreateHTTPTransportWithOptions(options)
+		assert.Error(t, err, "%+v", options)
+	}
+}
+
+// writeTestCertificate writes a self-signed client certificate and its key to dir
+func writeTestCertificate(t *testing.T, dir string) (*x509.Certificate, string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "agent"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certFile := filepath.Join(dir, "client.pem")
+	keyFile := filepath.Join(dir, "client.key")
+	require.NoError(t, ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	require.NoError(t, ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600))
+	return cert, certFile, keyFile
+}
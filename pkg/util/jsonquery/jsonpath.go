@@ -0,0 +1,67 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2019-present Datadog, Inc.
+
+package jsonquery
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// FromJSONPath converts a JSONPath expression to a jq query. Only the child
+// (`.name`, `['name']`), index (`[0]`) and wildcard (`.*`, `[*]`) operators are
+// supported.
+func FromJSONPath(path string) (string, error) {
+	if !strings.HasPrefix(path, "$") {
+		return "", fmt.Errorf("invalid JSONPath expression %q: it must start with $", path)
+	}
+
+	var query strings.Builder
+	query.WriteString(".")
+	rest := path[1:]
+	for rest != "" {
+		switch {
+		case strings.HasPrefix(rest, ".."):
+			return "", fmt.Errorf("invalid JSONPath expression %q: the recursive descent isn't supported", path)
+		case strings.HasPrefix(rest, ".*"):
+			query.WriteString("[]")
+			rest = rest[2:]
+		case rest[0] == '.':
+			end := strings.IndexAny(rest[1:], ".[")
+			if end < 0 {
+				end = len(rest) - 1
+			}
+			name := rest[1 : end+1]
+			if name == "" {
+				return "", fmt.Errorf("invalid JSONPath expression %q: empty member name", path)
+			}
+			query.WriteString("[" + strconv.Quote(name) + "]")
+			rest = rest[end+1:]
+		case rest[0] == '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return "", fmt.Errorf("invalid JSONPath expression %q: unclosed bracket", path)
+			}
+			selector := strings.TrimSpace(rest[1:end])
+			switch {
+			case selector == "*":
+				query.WriteString("[]")
+			case len(selector) >= 2 && (selector[0] == '\'' || selector[0] == '"') && selector[len(selector)-1] == selector[0]:
+				query.WriteString("[" + strconv.Quote(selector[1:len(selector)-1]) + "]")
+			default:
+				index, err := strconv.Atoi(selector)
+				if err != nil {
+					return "", fmt.Errorf("invalid JSONPath expression %q: unsupported selector %q", path, selector)
+				}
+				query.WriteString("[" + strconv.Itoa(index) + "]")
+			}
+			rest = rest[end+1:]
+		default:
+			return "", fmt.Errorf("invalid JSONPath expression %q: unexpected %q", path, rest)
+		}
+	}
+	return query.String(), nil
+}
@@ -0,0 +1,53 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2019-present Datadog, Inc.
+
+package jsonquery
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFromJSONPath(t *testing.T) {
+	for path, expected := range map[string]string{
+		"$":                    ".",
+		"$.store.book":         `.["store"]["book"]`,
+		"$.store.book[*].name": `.["store"]["book"][]["name"]`,
+		"$['store'].book[0]":   `.["store"]["book"][0]`,
+		`$["a b"].*`:           `.["a b"][]`,
+		"$[-1]":                ".[-1]",
+	} {
+		query, err := FromJSONPath(path)
+		require.NoError(t, err, path)
+		assert.Equal(t, expected, query, path)
+	}
+
+	for _, path := range []string{".store", "$..book", "$.", "$.book[?(@.price < 10)]", "$.book[0", "$store"} {
+		_, err := FromJSONPath(path)
+		assert.Error(t, err, path)
+	}
+}
+
+func TestRun(t *testing.T) {
+	object := map[string]interface{}{
+		"items": []interface{}{
+			map[string]interface{}{"name": "a"},
+			map[string]interface{}{"name": "b"},
+		},
+	}
+
+	values, err := Run(".items[].name", object)
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{"a", "b"}, values)
+
+	values, err = Run(".missing[]?", object)
+	require.NoError(t, err)
+	assert.Empty(t, values)
+
+	_, err = Run(".items | keys[] | error", object)
+	assert.Error(t, err)
+}
@@ -63,3 +63,24 @@ func RunSingleOutput(q string, object interface{}) (string, bool, error) {
 
 	return "", false, nil
 }
+
+// Run returns all the outputs of a query on an object.
+func Run(q string, object interface{}) ([]interface{}, error) {
+	code, err := Parse(q)
+	if err != nil {
+		return nil, err
+	}
+
+	var values []interface{}
+	iter := code.Run(object)
+	for {
+		value, ok := iter.Next()
+		if !ok {
+			return values, nil
+		}
+		if err, ok := value.(error); ok {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+}
@@ -16,6 +16,15 @@ var tmplVarRegex = regexp.MustCompile(`%%.+?%%`)
 // TemplateVar is the info for a parsed template variable.
 type TemplateVar struct {
 	Raw, Name, Key []byte
+	// Functions are the functions the value of the variable is piped to, in
+	// order, e.g. `%%label_app | default(web) | lower%%`
+	Functions []Function
+}
+
+// Function is a function applied to the value of a template variable, with an
+// optional argument.
+type Function struct {
+	Name, Arg []byte
 }
 
 // ParseString returns parsed template variables found in the input string.
@@ -28,8 +37,13 @@ func Parse(b []byte) []TemplateVar {
 	var parsed []TemplateVar
 	vars := tmplVarRegex.FindAll(b, -1)
 	for _, v := range vars {
-		name, key := parseTemplateVar(v)
-		parsed = append(parsed, TemplateVar{v, name, key})
+		segments := bytes.Split(bytes.Trim(v, "%"), []byte("|"))
+		name, key := parseTemplateVar(segments[0])
+		var functions []Function
+		for _, segment := range segments[1:] {
+			functions = append(functions, parseFunction(segment))
+		}
+		parsed = append(parsed, TemplateVar{v, name, key, functions})
 	}
 	return parsed
 }
@@ -52,3 +66,18 @@ func parseTemplateVar(v []byte) (name, key []byte) {
 	}
 	return name, key
 }
+
+// parseFunction extracts the name and the argument of a function, written as
+// `name` or `name(argument)`. Unlike the variables, the spaces of the
+// arguments are kept.
+func parseFunction(f []byte) Function {
+	f = bytes.TrimSpace(f)
+	open := bytes.IndexByte(f, '(')
+	if open < 0 || f[len(f)-1] != ')' {
+		return Function{Name: f, Arg: []byte("")}
+	}
+	return Function{
+		Name: bytes.TrimSpace(f[:open]),
+		Arg:  bytes.TrimSpace(f[open+1 : len(f)-1]),
+	}
+}
@@ -51,3 +51,23 @@ func TestParseTemplateVar(t *testing.T) {
 		})
 	}
 }
+
+func TestParseFunctions(t *testing.T) {
+	vars := ParseString("url: http://%%host%%:%%label_app.port | port%%/%%annotation_path|default(/status page) | lower%%")
+	assert.Len(t, vars, 3)
+
+	assert.Equal(t, "host", string(vars[0].Name))
+	assert.Empty(t, vars[0].Functions)
+
+	assert.Equal(t, "%%label_app.port | port%%", string(vars[1].Raw))
+	assert.Equal(t, "label", string(vars[1].Name))
+	assert.Equal(t, "app.port", string(vars[1].Key))
+	assert.Equal(t, []Function{{Name: []byte("port"), Arg: []byte("")}}, vars[1].Functions)
+
+	assert.Equal(t, "annotation", string(vars[2].Name))
+	assert.Equal(t, "path", string(vars[2].Key))
+	assert.Equal(t, []Function{
+		{Name: []byte("default"), Arg: []byte("/status page")},
+		{Name: []byte("lower"), Arg: []byte("")},
+	}, vars[2].Functions)
+}
@@ -1,9 +1,12 @@
 """
 Utilities to manage build tags
 """
+import re
 import sys
+from subprocess import check_output
 
 from invoke import task
+from invoke.exceptions import Exit
 
 # ALL_TAGS lists all available build tags.
 # Used to remove unknown tags from provided tag lists.
@@ -32,6 +35,7 @@ ALL_TAGS = set(
         "python",
         "secrets",
         "systemd",
+        "wasmcheck",
         "zk",
         "zlib",
     ]
@@ -106,6 +110,11 @@ WINDOWS_EXCLUDE_TAGS = set(["linux_bpf"])
 # List of tags to always remove when building on Windows 32-bits
 WINDOWS_32BIT_EXCLUDE_TAGS = set(["docker", "kubeapiserver", "kubelet", "orchestrator",])
 
+# List of tags requiring a more recent Go than the one of go.mod, with the minimum (major, minor) version
+GO_VERSION_TAGS = {
+    "wasmcheck": (1, 18),  # The wazero runtime requires Go 1.18
+}
+
 # Build type: build tags map
 build_tags = {
     # Build setups
@@ -156,9 +165,35 @@ def filter_incompatible_tags(include, arch="x64"):
     if sys.platform == "win32" and arch == "x86":
         exclude = exclude.union(WINDOWS_32BIT_EXCLUDE_TAGS)
 
+    check_go_version_tags(include)
+
     return get_build_tags(include, exclude)
 
 
+def check_go_version_tags(include):
+    """
+    Exit when a tag of the list requires a more recent Go than the installed one.
+    """
+    tags = set(include).intersection(GO_VERSION_TAGS)
+    if not tags:
+        return
+
+    output = check_output(['go', 'version']).decode('utf-8')
+    match = re.search(r'go(\d+)\.(\d+)', output)
+    if match is None:
+        print("Warning: cannot parse the Go version from '{}'.".format(output.strip()))
+        return
+
+    version = (int(match.group(1)), int(match.group(2)))
+    for tag in sorted(tags):
+        required = GO_VERSION_TAGS[tag]
+        if version < required:
+            print(
+                "The build tag '{}' requires Go {}.{} or later, found {}.{}.".format(tag, *(required + version))
+            )
+            raise Exit(code=1)
+
+
 def get_build_tags(include, exclude):
     """
     Build the list of tags based on inclusions and exclusions passed through
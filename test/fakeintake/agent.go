@@ -0,0 +1,54 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package fakeintake
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/DataDog/datadog-agent/pkg/config"
+)
+
+// Start starts a fake intake listening on a random local port, stopped at the end
+// of the test, and returns a Client querying it.
+func Start(t testing.TB) *Client {
+	server := httptest.NewServer(NewServer())
+	t.Cleanup(server.Close)
+	return NewClient(server.URL)
+}
+
+// ConfigureAgent sets the intake URLs of the core, trace, process and logs agents
+// in cfg to the fake intake listening on intakeURL.
+func ConfigureAgent(cfg config.Config, intakeURL string) error {
+	u, err := url.Parse(intakeURL)
+	if err != nil {
+		return err
+	}
+	if !cfg.IsSet("api_key") {
+		cfg.Set("api_key", "00000000000000000000000000000000")
+	}
+	cfg.Set("dd_url", intakeURL)
+	cfg.Set("apm_config.apm_dd_url", intakeURL)
+	cfg.Set("process_config.process_dd_url", intakeURL)
+	cfg.Set("orchestrator_explorer.orchestrator_dd_url", intakeURL)
+
+	// the logs agent expects a host and a port and sends the logs over TCP by default
+	cfg.Set("logs_config.logs_dd_url", u.Host)
+	cfg.Set("logs_config.logs_no_ssl", u.Scheme == "http")
+	cfg.Set("logs_config.use_http", true)
+	return nil
+}
+
+// StartForAgent starts a fake intake like Start, and points the intake URLs of the
+// mocked agent configuration to it.
+func StartForAgent(t testing.TB, cfg *config.MockConfig) *Client {
+	client := Start(t)
+	if err := ConfigureAgent(cfg, client.URL()); err != nil {
+		t.Fatalf("cannot configure the agent to use the fake intake: %v", err)
+	}
+	return client
+}
@@ -0,0 +1,74 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package fakeintake
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/config"
+	"github.com/DataDog/datadog-agent/pkg/forwarder"
+	"github.com/DataDog/datadog-agent/pkg/metrics"
+	"github.com/DataDog/datadog-agent/pkg/serializer"
+)
+
+func TestConfigureAgent(t *testing.T) {
+	mockConfig := config.Mock()
+	require.NoError(t, ConfigureAgent(mockConfig, "http://127.0.0.1:4242"))
+
+	assert.Equal(t, "http://127.0.0.1:4242", mockConfig.GetString("dd_url"))
+	assert.Equal(t, "http://127.0.0.1:4242", mockConfig.GetString("apm_config.apm_dd_url"))
+	assert.Equal(t, "http://127.0.0.1:4242", mockConfig.GetString("process_config.process_dd_url"))
+	assert.Equal(t, "127.0.0.1:4242", mockConfig.GetString("logs_config.logs_dd_url"))
+	assert.True(t, mockConfig.GetBool("logs_config.logs_no_ssl"))
+	assert.True(t, mockConfig.GetBool("logs_config.use_http"))
+	assert.NotEmpty(t, mockConfig.GetString("api_key"))
+}
+
+func TestAgentPayloads(t *testing.T) {
+	mockConfig := config.Mock()
+	mockConfig.Set("api_key", "abcdefabcdef")
+	client := StartForAgent(t, mockConfig)
+
+	keysPerDomain, err := config.GetMultipleEndpoints()
+	require.NoError(t, err)
+	f := forwarder.NewSyncForwarder(keysPerDomain, 5*time.Second)
+	s := serializer.NewSerializer(f, nil)
+
+	require.NoError(t, s.SendSeries(metrics.Series{{
+		Name:   "my.metric",
+		Points: []metrics.Point{{Ts: 1600000000, Value: 42}},
+		Tags:   []string{"env:test"},
+		Host:   "myhost",
+		MType:  metrics.APIGaugeType,
+	}}))
+	require.NoError(t, s.SendServiceChecks(metrics.ServiceChecks{{
+		CheckName: "my.check",
+		Host:      "myhost",
+		Ts:        1600000000,
+		Status:    metrics.ServiceCheckCritical,
+	}}))
+
+	series, err := client.Series()
+	require.NoError(t, err)
+	require.Len(t, series, 1)
+	assert.Equal(t, "my.metric", series[0].Metric)
+	assert.Equal(t, []Point{{Timestamp: 1600000000, Value: 42}}, series[0].Points)
+
+	checkRuns, err := client.CheckRuns()
+	require.NoError(t, err)
+	require.Len(t, checkRuns, 1)
+	assert.Equal(t, "my.check", checkRuns[0].Check)
+	assert.Equal(t, int(metrics.ServiceCheckCritical), checkRuns[0].Status)
+
+	payloads, err := client.Payloads(KindSeries)
+	require.NoError(t, err)
+	require.Len(t, payloads, 1)
+	assert.Equal(t, "abcdefabcdef", payloads[0].APIKey)
+}
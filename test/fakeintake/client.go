@@ -0,0 +1,187 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package fakeintake
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	agentpayload "github.com/DataDog/agent-payload/gogen"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/pb"
+)
+
+// Client queries the payloads stored by a fake intake.
+type Client struct {
+	url    string
+	client *http.Client
+}
+
+// NewClient returns a Client querying the fake intake listening on url.
+func NewClient(url string) *Client {
+	return &Client{
+		url:    strings.TrimSuffix(url, "/"),
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// URL returns the URL of the fake intake.
+func (c *Client) URL() string {
+	return c.url
+}
+
+// Payloads returns the payloads of the given kind, or all of them when kind is
+// empty, in the order the fake intake received them.
+func (c *Client) Payloads(kind Kind) ([]Payload, error) {
+	query := ""
+	if kind != "" {
+		query = "?kind=" + url.QueryEscape(string(kind))
+	}
+	resp, err := c.client.Get(c.url + payloadsPath + query)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d: %s", resp.StatusCode, body)
+	}
+
+	var response struct {
+		Payloads []Payload `json:"payloads"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, err
+	}
+	return response.Payloads, nil
+}
+
+// Flush deletes the payloads stored by the fake intake.
+func (c *Client) Flush() error {
+	req, err := http.NewRequest(http.MethodDelete, c.url+payloadsPath, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Series returns the metric series received by the fake intake.
+func (c *Client) Series() ([]Series, error) {
+	var series []Series
+	err := c.forEachItems(KindSeries, func(items json.RawMessage) error {
+		var s []Series
+		err := json.Unmarshal(items, &s)
+		series = append(series, s...)
+		return err
+	})
+	return series, err
+}
+
+// Sketches returns the distribution sketches received by the fake intake.
+func (c *Client) Sketches() ([]agentpayload.SketchPayload_Sketch, error) {
+	var sketches []agentpayload.SketchPayload_Sketch
+	err := c.forEachItems(KindSketches, func(items json.RawMessage) error {
+		var s []agentpayload.SketchPayload_Sketch
+		err := json.Unmarshal(items, &s)
+		sketches = append(sketches, s...)
+		return err
+	})
+	return sketches, err
+}
+
+// CheckRuns returns the service checks received by the fake intake.
+func (c *Client) CheckRuns() ([]CheckRun, error) {
+	var checkRuns []CheckRun
+	err := c.forEachItems(KindCheckRuns, func(items json.RawMessage) error {
+		var s []CheckRun
+		err := json.Unmarshal(items, &s)
+		checkRuns = append(checkRuns, s...)
+		return err
+	})
+	return checkRuns, err
+}
+
+// Events returns the events received by the fake intake.
+func (c *Client) Events() ([]Event, error) {
+	var events []Event
+	err := c.forEachItems(KindEvents, func(items json.RawMessage) error {
+		var s []Event
+		err := json.Unmarshal(items, &s)
+		events = append(events, s...)
+		return err
+	})
+	return events, err
+}
+
+// Logs returns the logs received by the fake intake.
+func (c *Client) Logs() ([]Log, error) {
+	var logs []Log
+	err := c.forEachItems(KindLogs, func(items json.RawMessage) error {
+		var s []Log
+		err := json.Unmarshal(items, &s)
+		logs = append(logs, s...)
+		return err
+	})
+	return logs, err
+}
+
+// Traces returns the trace payloads received by the fake intake.
+func (c *Client) Traces() ([]pb.TracePayload, error) {
+	var traces []pb.TracePayload
+	err := c.forEachItems(KindTraces, func(items json.RawMessage) error {
+		var s []pb.TracePayload
+		err := json.Unmarshal(items, &s)
+		traces = append(traces, s...)
+		return err
+	})
+	return traces, err
+}
+
+// ProcessMessages returns the messages received by the process and orchestrator
+// endpoints of the fake intake.
+func (c *Client) ProcessMessages() ([]ProcessMessage, error) {
+	var messages []ProcessMessage
+	err := c.forEachItems(KindProcess, func(items json.RawMessage) error {
+		var s []ProcessMessage
+		err := json.Unmarshal(items, &s)
+		messages = append(messages, s...)
+		return err
+	})
+	return messages, err
+}
+
+// forEachItems calls f with the items of every decoded payload of the given kind.
+func (c *Client) forEachItems(kind Kind, f func(items json.RawMessage) error) error {
+	payloads, err := c.Payloads(kind)
+	if err != nil {
+		return err
+	}
+	for _, p := range payloads {
+		if p.Error != "" {
+			continue
+		}
+		if err := f(p.Items); err != nil {
+			return fmt.Errorf("cannot decode the payload received on %s: %v", p.Path, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,24 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+// fakeintake runs a local Datadog intake storing the payloads it receives, see
+// the fakeintake package.
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+
+	"github.com/DataDog/datadog-agent/test/fakeintake"
+)
+
+func main() {
+	addr := flag.String("addr", "localhost:8080", "address to listen on")
+	flag.Parse()
+
+	log.Printf("fake intake listening on %s, query the payloads on http://%s/fakeintake/payloads", *addr, *addr)
+	log.Fatal(http.ListenAndServe(*addr, fakeintake.NewServer()))
+}
@@ -0,0 +1,241 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package fakeintake
+
+import (
+	"encoding/json"
+	"strings"
+
+	agentpayload "github.com/DataDog/agent-payload/gogen"
+	model "github.com/DataDog/agent-payload/process"
+	"github.com/gogo/protobuf/proto"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/pb"
+	"github.com/DataDog/datadog-agent/pkg/util/compression"
+)
+
+// decoder decodes the decompressed body of a request into the kind and the items
+// of the payload.
+type decoder func(body []byte) (Kind, interface{}, error)
+
+// decoders are the decoders of the supported intake endpoints, by path.
+var decoders = map[string]decoder{
+	"/api/v1/series":         decodeSeriesV1,
+	"/api/v2/series":         decodeSeriesV2,
+	"/api/beta/sketches":     decodeSketches,
+	"/api/v1/check_run":      decodeCheckRunsV1,
+	"/api/v2/service_checks": decodeCheckRunsV2,
+	"/api/v2/events":         decodeEventsV2,
+	"/intake/":               decodeIntake,
+	"/v1/input":              decodeLogs,
+	"/api/v2/logs":           decodeLogs,
+	"/api/v0.2/traces":       decodeTraces,
+	"/api/v0.2/stats":        decodeStats,
+	"/api/v1/collector":      decodeProcess,
+	"/api/v1/container":      decodeProcess,
+	"/api/v1/connections":    decodeProcess,
+	"/api/v1/orchestrator":   decodeProcess,
+}
+
+// decoderFor returns the decoder of path, the logs intake accepting the API key
+// as the last element of the path.
+func decoderFor(path string) (decoder, bool) {
+	if d, found := decoders[path]; found {
+		return d, true
+	}
+	if strings.HasPrefix(path, "/v1/input/") {
+		return decodeLogs, true
+	}
+	return nil, false
+}
+
+// decompress returns the decompressed body according to its Content-Encoding.
+func decompress(body []byte, contentEncoding string) ([]byte, error) {
+	c, err := compression.ForContentEncoding(contentEncoding)
+	if err != nil {
+		return nil, err
+	}
+	return c.Decompress(body)
+}
+
+func decodeSeriesV1(body []byte) (Kind, interface{}, error) {
+	var payload struct {
+		Series []struct {
+			Series
+			Points [][2]float64 `json:"points"`
+		} `json:"series"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return KindSeries, nil, err
+	}
+	series := make([]Series, 0, len(payload.Series))
+	for _, s := range payload.Series {
+		serie := s.Series
+		serie.Points = make([]Point, 0, len(s.Points))
+		for _, p := range s.Points {
+			serie.Points = append(serie.Points, Point{Timestamp: int64(p[0]), Value: p[1]})
+		}
+		series = append(series, serie)
+	}
+	return KindSeries, series, nil
+}
+
+func decodeSeriesV2(body []byte) (Kind, interface{}, error) {
+	var payload agentpayload.MetricsPayload
+	if err := proto.Unmarshal(body, &payload); err != nil {
+		return KindSeries, nil, err
+	}
+	series := make([]Series, 0, len(payload.Samples))
+	for _, s := range payload.Samples {
+		serie := Series{
+			Metric:         s.Metric,
+			Type:           s.Type,
+			Host:           s.Host,
+			Tags:           s.Tags,
+			SourceTypeName: s.SourceTypeName,
+			Points:         make([]Point, 0, len(s.Points)),
+		}
+		for _, p := range s.Points {
+			serie.Points = append(serie.Points, Point{Timestamp: p.Ts, Value: p.Value})
+		}
+		series = append(series, serie)
+	}
+	return KindSeries, series, nil
+}
+
+func decodeSketches(body []byte) (Kind, interface{}, error) {
+	var payload agentpayload.SketchPayload
+	if err := proto.Unmarshal(body, &payload); err != nil {
+		return KindSketches, nil, err
+	}
+	return KindSketches, payload.Sketches, nil
+}
+
+func decodeCheckRunsV1(body []byte) (Kind, interface{}, error) {
+	var payload []struct {
+		CheckRun
+		Host string `json:"host_name"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return KindCheckRuns, nil, err
+	}
+	checkRuns := make([]CheckRun, 0, len(payload))
+	for _, c := range payload {
+		checkRun := c.CheckRun
+		checkRun.Host = c.Host
+		checkRuns = append(checkRuns, checkRun)
+	}
+	return KindCheckRuns, checkRuns, nil
+}
+
+func decodeCheckRunsV2(body []byte) (Kind, interface{}, error) {
+	var payload agentpayload.ServiceChecksPayload
+	if err := proto.Unmarshal(body, &payload); err != nil {
+		return KindCheckRuns, nil, err
+	}
+	checkRuns := make([]CheckRun, 0, len(payload.ServiceChecks))
+	for _, c := range payload.ServiceChecks {
+		checkRuns = append(checkRuns, CheckRun{
+			Check:     c.Name,
+			Host:      c.Host,
+			Timestamp: c.Ts,
+			Status:    int(c.Status),
+			Message:   c.Message,
+			Tags:      c.Tags,
+		})
+	}
+	return KindCheckRuns, checkRuns, nil
+}
+
+func decodeEventsV2(body []byte) (Kind, interface{}, error) {
+	var payload agentpayload.EventsPayload
+	if err := proto.Unmarshal(body, &payload); err != nil {
+		return KindEvents, nil, err
+	}
+	events := make([]Event, 0, len(payload.Events))
+	for _, e := range payload.Events {
+		events = append(events, Event{
+			Title:          e.Title,
+			Text:           e.Text,
+			Timestamp:      e.Ts,
+			Priority:       e.Priority,
+			Host:           e.Host,
+			Tags:           e.Tags,
+			AlertType:      e.AlertType,
+			AggregationKey: e.AggregationKey,
+			SourceTypeName: e.SourceTypeName,
+		})
+	}
+	return KindEvents, events, nil
+}
+
+// decodeIntake decodes the payloads of the v1 intake, which receives the events
+// and the metadata payloads.
+func decodeIntake(body []byte) (Kind, interface{}, error) {
+	var payload map[string]json.RawMessage
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return KindMetadata, nil, err
+	}
+	rawEvents, found := payload["events"]
+	if !found {
+		return KindMetadata, []map[string]json.RawMessage{payload}, nil
+	}
+
+	var eventsBySource map[string][]struct {
+		Event
+		Title string `json:"msg_title"`
+		Text  string `json:"msg_text"`
+	}
+	if err := json.Unmarshal(rawEvents, &eventsBySource); err != nil {
+		return KindEvents, nil, err
+	}
+	events := []Event{}
+	for _, sourceEvents := range eventsBySource {
+		for _, e := range sourceEvents {
+			event := e.Event
+			event.Title = e.Title
+			event.Text = e.Text
+			events = append(events, event)
+		}
+	}
+	return KindEvents, events, nil
+}
+
+func decodeLogs(body []byte) (Kind, interface{}, error) {
+	var logs []Log
+	if err := json.Unmarshal(body, &logs); err != nil {
+		return KindLogs, nil, err
+	}
+	return KindLogs, logs, nil
+}
+
+func decodeTraces(body []byte) (Kind, interface{}, error) {
+	var payload pb.TracePayload
+	if err := proto.Unmarshal(body, &payload); err != nil {
+		return KindTraces, nil, err
+	}
+	return KindTraces, []pb.TracePayload{payload}, nil
+}
+
+func decodeStats(body []byte) (Kind, interface{}, error) {
+	var payload pb.StatsPayload
+	if _, err := payload.UnmarshalMsg(body); err != nil {
+		return KindStats, nil, err
+	}
+	return KindStats, []pb.StatsPayload{payload}, nil
+}
+
+func decodeProcess(body []byte) (Kind, interface{}, error) {
+	message, err := model.DecodeMessage(body)
+	if err != nil {
+		return KindProcess, nil, err
+	}
+	messageBody, err := json.Marshal(message.Body)
+	if err != nil {
+		return KindProcess, nil, err
+	}
+	return KindProcess, []ProcessMessage{{Type: message.Header.Type.String(), Body: messageBody}}, nil
+}
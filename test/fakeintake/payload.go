@@ -0,0 +1,102 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package fakeintake
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Kind is the kind of data carried by a payload, several intake endpoints can
+// receive the same kind of data in different formats.
+type Kind string
+
+// Kinds of payloads stored by the fake intake
+const (
+	KindSeries    Kind = "series"
+	KindSketches  Kind = "sketches"
+	KindCheckRuns Kind = "check_runs"
+	KindEvents    Kind = "events"
+	KindMetadata  Kind = "metadata"
+	KindLogs      Kind = "logs"
+	KindTraces    Kind = "traces"
+	KindStats     Kind = "stats"
+	KindProcess   Kind = "process"
+	KindUnknown   Kind = "unknown"
+)
+
+// Payload is a payload received by the fake intake.
+type Payload struct {
+	Kind       Kind      `json:"kind"`
+	Path       string    `json:"path"`
+	ReceivedAt time.Time `json:"received_at"`
+	APIKey     string    `json:"api_key,omitempty"`
+	// Items is the JSON array of the decoded items of the payload, its elements
+	// are of the type returned by the Client method of the payload Kind.
+	Items json.RawMessage `json:"items,omitempty"`
+	// Raw is the received body, only kept when it can't be decoded
+	Raw   []byte `json:"raw,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// Series is a metric serie, received by the v1 or v2 series endpoint.
+type Series struct {
+	Metric         string   `json:"metric"`
+	Type           string   `json:"type"`
+	Host           string   `json:"host"`
+	Tags           []string `json:"tags"`
+	Points         []Point  `json:"points"`
+	Interval       int64    `json:"interval,omitempty"`
+	SourceTypeName string   `json:"source_type_name,omitempty"`
+}
+
+// Point is a point of a metric serie.
+type Point struct {
+	Timestamp int64   `json:"timestamp"`
+	Value     float64 `json:"value"`
+}
+
+// CheckRun is a service check, received by the v1 check run or the v2 service
+// checks endpoint.
+type CheckRun struct {
+	Check     string   `json:"check"`
+	Host      string   `json:"host"`
+	Timestamp int64    `json:"timestamp"`
+	Status    int      `json:"status"`
+	Message   string   `json:"message"`
+	Tags      []string `json:"tags"`
+}
+
+// Event is an event, received by the v1 intake or the v2 events endpoint.
+type Event struct {
+	Title          string   `json:"title"`
+	Text           string   `json:"text"`
+	Timestamp      int64    `json:"timestamp"`
+	Priority       string   `json:"priority,omitempty"`
+	Host           string   `json:"host"`
+	Tags           []string `json:"tags,omitempty"`
+	AlertType      string   `json:"alert_type,omitempty"`
+	AggregationKey string   `json:"aggregation_key,omitempty"`
+	SourceTypeName string   `json:"source_type_name,omitempty"`
+}
+
+// Log is a log message, received by the logs HTTP intake.
+type Log struct {
+	Message   string `json:"message"`
+	Status    string `json:"status"`
+	Timestamp int64  `json:"timestamp"`
+	Hostname  string `json:"hostname"`
+	Service   string `json:"service"`
+	Source    string `json:"ddsource"`
+	Tags      string `json:"ddtags"`
+}
+
+// ProcessMessage is a message received by the process or orchestrator intake.
+type ProcessMessage struct {
+	// Type is the name of the message type, e.g. "process" or "container"
+	Type string          `json:"type"`
+	Body json.RawMessage `json:"body"`
+}
@@ -0,0 +1,208 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+// Package fakeintake implements a local Datadog intake, storing the payloads
+// sent by the agents so that end-to-end tests can assert on them without any
+// network access.
+package fakeintake
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	model "github.com/DataDog/agent-payload/process"
+)
+
+const (
+	// payloadsPath is the path of the query API of the fake intake
+	payloadsPath = "/fakeintake/payloads"
+	// validatePath is the path of the API key validation endpoint
+	validatePath = "/api/v1/validate"
+)
+
+// Server is an http.Handler implementing the intake endpoints, decoding and storing
+// the payloads it receives, and a query API listing them:
+//
+//   GET /fakeintake/payloads?kind=<kind>  returns the stored payloads, of any kind by default
+//   DELETE /fakeintake/payloads           deletes the stored payloads
+//
+// The payloads are kept in memory until they are deleted.
+type Server struct {
+	m        sync.RWMutex
+	payloads []Payload
+}
+
+// NewServer returns a new fake intake Server.
+func NewServer() *Server {
+	return &Server{}
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case payloadsPath:
+		s.serveQuery(w, r)
+	case validatePath:
+		writeJSON(w, http.StatusOK, map[string]bool{"valid": true})
+	default:
+		s.serveIntake(w, r)
+	}
+}
+
+// Payloads returns the stored payloads of the given kind, or all of them when
+// kind is empty, in the order they were received.
+func (s *Server) Payloads(kind Kind) []Payload {
+	s.m.RLock()
+	defer s.m.RUnlock()
+	payloads := []Payload{}
+	for _, p := range s.payloads {
+		if kind == "" || p.Kind == kind {
+			payloads = append(payloads, p)
+		}
+	}
+	return payloads
+}
+
+// Flush deletes the stored payloads.
+func (s *Server) Flush() {
+	s.m.Lock()
+	defer s.m.Unlock()
+	s.payloads = nil
+}
+
+func (s *Server) store(p Payload) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	s.payloads = append(s.payloads, p)
+}
+
+func (s *Server) serveQuery(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		kind := Kind(r.URL.Query().Get("kind"))
+		writeJSON(w, http.StatusOK, map[string][]Payload{"payloads": s.Payloads(kind)})
+	case http.MethodDelete:
+		s.Flush()
+		w.WriteHeader(http.StatusOK)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) serveIntake(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost && r.Method != http.MethodPut {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	payload := Payload{
+		Kind:       KindUnknown,
+		Path:       r.URL.Path,
+		ReceivedAt: time.Now(),
+		APIKey:     apiKey(r),
+	}
+	err := s.decode(r, &payload)
+	s.store(payload)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if payload.Kind == KindProcess {
+		writeProcessResponse(w)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, map[string]string{"status": "ok"})
+}
+
+// decode decodes the body of r into p, keeping the raw body in p when it can't
+// be decoded.
+func (s *Server) decode(r *http.Request, p *Payload) error {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		p.Error = fmt.Sprintf("cannot read the body: %v", err)
+		return err
+	}
+
+	d, found := decoderFor(r.URL.Path)
+	if !found {
+		p.Raw = body
+		p.Error = "unknown endpoint"
+		return fmt.Errorf("unknown endpoint %s", r.URL.Path)
+	}
+
+	fail := func(err error) error {
+		p.Raw = body
+		p.Error = err.Error()
+		return err
+	}
+
+	decompressed, err := decompress(body, r.Header.Get("Content-Encoding"))
+	if err != nil {
+		return fail(fmt.Errorf("cannot decompress the payload: %v", err))
+	}
+	kind, items, err := d(decompressed)
+	p.Kind = kind
+	if err != nil {
+		return fail(fmt.Errorf("cannot decode the payload: %v", err))
+	}
+	if p.Items, err = json.Marshal(items); err != nil {
+		return fail(fmt.Errorf("cannot encode the decoded payload: %v", err))
+	}
+	return nil
+}
+
+// apiKey returns the API key of r, set in a header, in the query or, by the older
+// logs agents, in the URL path.
+func apiKey(r *http.Request) string {
+	if key := r.Header.Get("DD-Api-Key"); key != "" {
+		return key
+	}
+	if key := r.URL.Query().Get("api_key"); key != "" {
+		return key
+	}
+	if strings.HasPrefix(r.URL.Path, "/v1/input/") {
+		return strings.TrimPrefix(r.URL.Path, "/v1/input/")
+	}
+	return ""
+}
+
+// writeProcessResponse writes the response expected by the process agent, which
+// decodes it to know the interval of the real-time checks.
+func writeProcessResponse(w http.ResponseWriter) {
+	body, err := model.EncodeMessage(model.Message{
+		Header: model.MessageHeader{
+			Version:  model.MessageV3,
+			Encoding: model.MessageEncodingProtobuf,
+			Type:     model.TypeResCollector,
+		},
+		Body: &model.ResCollector{
+			Status: &model.CollectorStatus{ActiveClients: 0, Interval: 2},
+		},
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	w.WriteHeader(http.StatusAccepted)
+	w.Write(body) //nolint:errcheck
+}
+
+func writeJSON(w http.ResponseWriter, statusCode int, v interface{}) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	w.Write(body) //nolint:errcheck
+}
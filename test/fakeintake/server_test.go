@@ -0,0 +1,267 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package fakeintake
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+
+	agentpayload "github.com/DataDog/agent-payload/gogen"
+	model "github.com/DataDog/agent-payload/process"
+	"github.com/gogo/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/metrics"
+	"github.com/DataDog/datadog-agent/pkg/trace/pb"
+	"github.com/DataDog/datadog-agent/pkg/util/compression"
+)
+
+func post(t *testing.T, url string, body []byte, contentEncoding string) *http.Response {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("DD-Api-Key", "abcdefabcdef")
+	if contentEncoding != "" {
+		req.Header.Set("Content-Encoding", contentEncoding)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	return resp
+}
+
+func compress(t *testing.T, kind compression.Kind, body []byte) []byte {
+	c, err := compression.NewCompressor(kind, 0)
+	require.NoError(t, err)
+	compressed, err := c.Compress(body)
+	require.NoError(t, err)
+	return compressed
+}
+
+func TestServerSeries(t *testing.T) {
+	client := Start(t)
+
+	v1 := []byte(`{"series":[{"metric":"foo.bar","points":[[1600000000,1.5]],"tags":["a:b"],"host":"myhost","type":"gauge","interval":10}]}`)
+	resp := post(t, client.URL()+"/api/v1/series", compress(t, compression.ZlibKind, v1), "deflate")
+	assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+
+	v2, err := metrics.Series{{
+		Name:   "foo.baz",
+		Points: []metrics.Point{{Ts: 1600000010, Value: 2}},
+		Tags:   []string{"c:d"},
+		Host:   "myhost",
+		MType:  metrics.APICountType,
+	}}.Marshal()
+	require.NoError(t, err)
+	resp = post(t, client.URL()+"/api/v2/series", compress(t, compression.GzipKind, v2), "gzip")
+	assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+
+	series, err := client.Series()
+	require.NoError(t, err)
+	assert.Equal(t, []Series{
+		{Metric: "foo.bar", Type: "gauge", Host: "myhost", Tags: []string{"a:b"}, Points: []Point{{Timestamp: 1600000000, Value: 1.5}}, Interval: 10},
+		{Metric: "foo.baz", Type: "count", Host: "myhost", Tags: []string{"c:d"}, Points: []Point{{Timestamp: 1600000010, Value: 2}}},
+	}, series)
+
+	payloads, err := client.Payloads(KindSeries)
+	require.NoError(t, err)
+	require.Len(t, payloads, 2)
+	assert.Equal(t, "/api/v1/series", payloads[0].Path)
+	assert.Equal(t, "abcdefabcdef", payloads[0].APIKey)
+}
+
+func TestServerSketches(t *testing.T) {
+	client := Start(t)
+
+	body, err := proto.Marshal(&agentpayload.SketchPayload{
+		Sketches: []agentpayload.SketchPayload_Sketch{{
+			Metric: "foo.dist",
+			Host:   "myhost",
+			Tags:   []string{"a:b"},
+			Dogsketches: []agentpayload.SketchPayload_Sketch_Dogsketch{
+				{Ts: 1600000000, Cnt: 2, Min: 1, Max: 3, Avg: 2, Sum: 4, K: []int32{1, 2}, N: []uint32{1, 1}},
+			},
+		}},
+	})
+	require.NoError(t, err)
+	resp := post(t, client.URL()+"/api/beta/sketches", body, "")
+	assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+
+	sketches, err := client.Sketches()
+	require.NoError(t, err)
+	require.Len(t, sketches, 1)
+	assert.Equal(t, "foo.dist", sketches[0].Metric)
+	require.Len(t, sketches[0].Dogsketches, 1)
+	assert.EqualValues(t, 2, sketches[0].Dogsketches[0].Cnt)
+}
+
+func TestServerCheckRunsAndEvents(t *testing.T) {
+	client := Start(t)
+
+	checkRuns := []byte(`[{"check":"my.check","host_name":"myhost","timestamp":1600000000,"status":2,"message":"failed","tags":["a:b"]}]`)
+	post(t, client.URL()+"/api/v1/check_run", checkRuns, "")
+	serviceChecks, err := metrics.ServiceChecks{{CheckName: "other.check", Host: "myhost", Ts: 1600000001, Status: metrics.ServiceCheckOK}}.Marshal()
+	require.NoError(t, err)
+	post(t, client.URL()+"/api/v2/service_checks", serviceChecks, "")
+
+	events := []byte(`{"apiKey":"","events":{"api":[{"msg_title":"hello","msg_text":"world","timestamp":1600000000,"host":"myhost","tags":["a:b"],"alert_type":"info"}]},"internalHostname":"myhost"}`)
+	post(t, client.URL()+"/intake/", events, "")
+	eventsV2, err := metrics.Events{{Title: "hi", Text: "there", Ts: 1600000001, Host: "myhost"}}.Marshal()
+	require.NoError(t, err)
+	post(t, client.URL()+"/api/v2/events", eventsV2, "")
+
+	// host metadata payloads are sent to the intake too
+	post(t, client.URL()+"/intake/", []byte(`{"internalHostname":"myhost","os":"linux"}`), "")
+
+	receivedCheckRuns, err := client.CheckRuns()
+	require.NoError(t, err)
+	assert.Equal(t, []CheckRun{
+		{Check: "my.check", Host: "myhost", Timestamp: 1600000000, Status: 2, Message: "failed", Tags: []string{"a:b"}},
+		{Check: "other.check", Host: "myhost", Timestamp: 1600000001, Status: 0},
+	}, receivedCheckRuns)
+
+	receivedEvents, err := client.Events()
+	require.NoError(t, err)
+	assert.Equal(t, []Event{
+		{Title: "hello", Text: "world", Timestamp: 1600000000, Host: "myhost", Tags: []string{"a:b"}, AlertType: "info"},
+		{Title: "hi", Text: "there", Timestamp: 1600000001, Host: "myhost"},
+	}, receivedEvents)
+
+	metadata, err := client.Payloads(KindMetadata)
+	require.NoError(t, err)
+	require.Len(t, metadata, 1)
+	assert.JSONEq(t, `[{"internalHostname":"myhost","os":"linux"}]`, string(metadata[0].Items))
+}
+
+func TestServerLogs(t *testing.T) {
+	client := Start(t)
+
+	body := []byte(`[{"message":"hello","status":"info","timestamp":1600000000,"hostname":"myhost","service":"web","ddsource":"go","ddtags":"a:b"}]`)
+	post(t, client.URL()+"/v1/input", compress(t, compression.GzipKind, body), "gzip")
+	post(t, client.URL()+"/v1/input/otherkey", body, "identity")
+
+	logs, err := client.Logs()
+	require.NoError(t, err)
+	expected := Log{Message: "hello", Status: "info", Timestamp: 1600000000, Hostname: "myhost", Service: "web", Source: "go", Tags: "a:b"}
+	assert.Equal(t, []Log{expected, expected}, logs)
+
+	payloads, err := client.Payloads(KindLogs)
+	require.NoError(t, err)
+	require.Len(t, payloads, 2)
+	assert.Equal(t, "abcdefabcdef", payloads[1].APIKey)
+}
+
+func TestServerTracesAndStats(t *testing.T) {
+	client := Start(t)
+
+	traces, err := proto.Marshal(&pb.TracePayload{
+		HostName: "myhost",
+		Env:      "test",
+		Traces: []*pb.APITrace{{
+			TraceID: 42,
+			Spans:   []*pb.Span{{Service: "web", Name: "request", TraceID: 42, SpanID: 1}},
+		}},
+	})
+	require.NoError(t, err)
+	resp := post(t, client.URL()+"/api/v0.2/traces", compress(t, compression.GzipKind, traces), "gzip")
+	assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+
+	stats, err := (&pb.StatsPayload{AgentHostname: "myhost", AgentEnv: "test"}).MarshalMsg(nil)
+	require.NoError(t, err)
+	resp = post(t, client.URL()+"/api/v0.2/stats", compress(t, compression.GzipKind, stats), "gzip")
+	assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+
+	received, err := client.Traces()
+	require.NoError(t, err)
+	require.Len(t, received, 1)
+	assert.Equal(t, "myhost", received[0].HostName)
+	require.Len(t, received[0].Traces, 1)
+	assert.Equal(t, "request", received[0].Traces[0].Spans[0].Name)
+
+	payloads, err := client.Payloads(KindStats)
+	require.NoError(t, err)
+	require.Len(t, payloads, 1)
+	assert.Empty(t, payloads[0].Error)
+}
+
+func TestServerProcess(t *testing.T) {
+	client := Start(t)
+
+	body, err := model.EncodeMessage(model.Message{
+		Header: model.MessageHeader{
+			Version:  model.MessageV3,
+			Encoding: model.MessageEncodingZstdPB,
+			Type:     model.TypeCollectorProc,
+		},
+		Body: &model.CollectorProc{HostName: "myhost", Processes: []*model.Process{{Pid: 42}}},
+	})
+	require.NoError(t, err)
+
+	req, err := http.NewRequest(http.MethodPost, client.URL()+"/api/v1/collector", bytes.NewReader(body))
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	var responseBody bytes.Buffer
+	_, err = responseBody.ReadFrom(resp.Body)
+	require.NoError(t, err)
+
+	// the process agent decodes the response
+	response, err := model.DecodeMessage(responseBody.Bytes())
+	require.NoError(t, err)
+	assert.EqualValues(t, model.TypeResCollector, response.Header.Type)
+
+	messages, err := client.ProcessMessages()
+	require.NoError(t, err)
+	require.Len(t, messages, 1)
+	assert.Equal(t, "process", messages[0].Type)
+	assert.Contains(t, string(messages[0].Body), `"hostName":"myhost"`)
+}
+
+func TestServerInvalidPayloads(t *testing.T) {
+	client := Start(t)
+
+	resp := post(t, client.URL()+"/api/v1/series", []byte("not json"), "")
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	resp = post(t, client.URL()+"/api/v1/unknown", []byte("payload"), "")
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	resp = post(t, client.URL()+"/api/v1/series", []byte("payload"), "br")
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	payloads, err := client.Payloads("")
+	require.NoError(t, err)
+	require.Len(t, payloads, 3)
+	assert.Equal(t, KindSeries, payloads[0].Kind)
+	assert.Equal(t, []byte("not json"), payloads[0].Raw)
+	assert.Contains(t, payloads[0].Error, "cannot decode the payload")
+	assert.Equal(t, KindUnknown, payloads[1].Kind)
+	assert.Equal(t, `cannot decompress the payload: unknown content encoding "br"`, payloads[2].Error)
+
+	// the undecodable payloads are skipped by the typed queries
+	series, err := client.Series()
+	require.NoError(t, err)
+	assert.Empty(t, series)
+}
+
+func TestServerFlushAndValidate(t *testing.T) {
+	client := Start(t)
+
+	resp, err := http.Get(client.URL() + "/api/v1/validate")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	post(t, client.URL()+"/api/v1/check_run", []byte(`[]`), "")
+	payloads, err := client.Payloads("")
+	require.NoError(t, err)
+	assert.Len(t, payloads, 1)
+
+	require.NoError(t, client.Flush())
+	payloads, err = client.Payloads("")
+	require.NoError(t, err)
+	assert.Empty(t, payloads)
+}
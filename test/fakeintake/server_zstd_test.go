@@ -0,0 +1,39 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+// +build zstd
+
+package fakeintake
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/metrics"
+	"github.com/DataDog/datadog-agent/pkg/util/compression"
+)
+
+func TestServerZstdSeries(t *testing.T) {
+	client := Start(t)
+
+	body, err := metrics.Series{{
+		Name:   "foo.baz",
+		Points: []metrics.Point{{Ts: 1600000010, Value: 2}},
+		Host:   "myhost",
+		MType:  metrics.APICountType,
+	}}.Marshal()
+	require.NoError(t, err)
+	resp := post(t, client.URL()+"/api/v2/series", compress(t, compression.ZstdKind, body), "zstd")
+	assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+
+	series, err := client.Series()
+	require.NoError(t, err)
+	assert.Equal(t, []Series{
+		{Metric: "foo.baz", Type: "count", Host: "myhost", Points: []Point{{Timestamp: 1600000010, Value: 2}}},
+	}, series)
+}
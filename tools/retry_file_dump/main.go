@@ -69,6 +69,9 @@ func dumpRetryFile(file string) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
+	if IsEncryptedRetryFile(content) {
+		return nil, fmt.Errorf("%s is encrypted, see `forwarder_storage_encryption_key`", file)
+	}
 	collection := HttpTransactionProtoCollection{}
 
 	if err := proto.Unmarshal(content, &collection); err != nil {